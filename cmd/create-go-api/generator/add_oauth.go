@@ -3,6 +3,7 @@ package generator
 import (
 	"bytes"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,138 +12,300 @@ import (
 	"strings"
 )
 
+// AddOAuthOptions controls how AddOAuth applies changes to the project.
+type AddOAuthOptions struct {
+	// DryRun reports the planned changes without touching the project.
+	DryRun bool
+	// Backup copies every file that would be modified or deleted into
+	// BackupDirName inside the project before applying changes.
+	Backup bool
+}
+
+// BackupDirName is the directory (relative to the project) that receives
+// original files when AddOAuthOptions.Backup is set.
+const BackupDirName = ".oauth-backup"
+
+// ChangeKind describes what happens to a single file.
+type ChangeKind string
+
+const (
+	ChangeAdd    ChangeKind = "add"
+	ChangeModify ChangeKind = "modify"
+	ChangeDelete ChangeKind = "delete"
+)
+
+// FileChange is a single file-level difference between the baseline and the
+// OAuth-enabled generated projects. Paths are slash-separated and relative to
+// the project root.
+type FileChange struct {
+	Path     string
+	Kind     ChangeKind
+	Baseline []byte // content without OAuth (nil for adds)
+	// Content is the content with OAuth (nil for deletes). For a file edited
+	// locally, it is the local content with the OAuth hunks applied.
+	Content []byte
+}
+
 // AddOAuth adds OAuth support to an existing generated project using a
 // generate-diff-apply strategy: it generates two temporary projects
-// (with and without OAuth), diffs them, and applies the patch.
-func AddOAuth(projectDir string) error {
+// (with and without OAuth), compares them file by file, and applies the
+// differences to the project. It returns the list of applied (or, in dry-run
+// mode, planned) changes.
+func AddOAuth(projectDir string, opts AddOAuthOptions) ([]FileChange, error) {
 	// 1. Load existing config
 	cfg, err := LoadConfigFromFile(projectDir)
 	if err != nil {
-		return fmt.Errorf("not a create-go-api project (missing %s): %w", ConfigFileName, err)
+		return nil, fmt.Errorf("not a create-go-api project (missing %s): %w", ConfigFileName, err)
 	}
 
 	// 2. Validate OAuth not already enabled
 	if cfg.HasOAuth {
-		return fmt.Errorf("OAuth is already enabled in this project")
-	}
-
-	// 3. Verify git repo
-	if _, err := os.Stat(filepath.Join(projectDir, ".git")); os.IsNotExist(err) {
-		return fmt.Errorf("git repository required — run 'git init' first")
+		return nil, fmt.Errorf("OAuth is already enabled in this project")
 	}
 
-	// 4. Create temp dir with a/ and b/ subdirs
+	// 3. Create temp dir with a/ and b/ subdirs
 	tmpDir, err := os.MkdirTemp("", "go-api-oauth-*")
 	if err != nil {
-		return fmt.Errorf("create temp directory: %w", err)
+		return nil, fmt.Errorf("create temp directory: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
 	dirA := filepath.Join(tmpDir, "a")
 	dirB := filepath.Join(tmpDir, "b")
 
-	// 5. Generate without OAuth (baseline)
+	// 4. Generate without OAuth (baseline)
 	cfgWithout := *cfg
 	cfgWithout.HasOAuth = false
 	if err := GenerateTo(dirA, &cfgWithout); err != nil {
-		return fmt.Errorf("generate baseline project: %w", err)
+		return nil, fmt.Errorf("generate baseline project: %w", err)
 	}
 
-	// 6. Generate with OAuth
+	// 5. Generate with OAuth
 	cfgWith := *cfg
 	cfgWith.HasOAuth = true
 	if err := GenerateTo(dirB, &cfgWith); err != nil {
-		return fmt.Errorf("generate oauth project: %w", err)
+		return nil, fmt.Errorf("generate oauth project: %w", err)
 	}
 
-	// 7. Run diff
-	patch, err := generateDiff(tmpDir)
+	// 6. Compare the two trees
+	changes, err := diffTrees(dirA, dirB)
 	if err != nil {
-		return fmt.Errorf("generate diff: %w", err)
+		return nil, fmt.Errorf("compare generated projects: %w", err)
 	}
 
-	if len(bytes.TrimSpace(patch)) == 0 {
-		return fmt.Errorf("no differences found — OAuth may already be integrated")
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no differences found — OAuth may already be integrated")
 	}
 
-	// 8. Renumber migration files if needed
-	patch, err = fixMigrationNumbers(patch, projectDir)
-	if err != nil {
-		return fmt.Errorf("fix migration numbers: %w", err)
+	// 7. Renumber migration files if needed
+	changes = fixMigrationNumbers(changes, projectDir)
+
+	// 8. Merge the changes into locally edited files, hunk by hunk
+	if err := mergeChanges(projectDir, changes); err != nil {
+		return nil, err
 	}
 
-	// 9. Apply patch
-	if err := applyPatch(projectDir, patch); err != nil {
-		// Save patch for manual review
-		patchPath := filepath.Join(projectDir, "oauth.patch")
-		_ = os.WriteFile(patchPath, patch, 0o644)
-		return fmt.Errorf("patch failed to apply cleanly (saved to oauth.patch for manual review): %w", err)
+	if opts.DryRun {
+		return changes, nil
+	}
+
+	// 9. Apply changes
+	if opts.Backup {
+		if err := backupFiles(projectDir, changes); err != nil {
+			return nil, fmt.Errorf("backup files: %w", err)
+		}
+	}
+	if err := applyChanges(projectDir, changes); err != nil {
+		return nil, fmt.Errorf("apply changes: %w", err)
 	}
 
 	// 10. Update config
 	cfg.HasOAuth = true
 	if err := cfg.SaveToFile(projectDir); err != nil {
-		return fmt.Errorf("update config: %w", err)
+		return nil, fmt.Errorf("update config: %w", err)
 	}
 
 	// 11. Run go mod tidy
 	if err := runGoModTidy(projectDir); err != nil {
-		return fmt.Errorf("go mod tidy: %w", err)
+		return nil, fmt.Errorf("go mod tidy: %w", err)
 	}
 
-	return nil
+	return changes, nil
 }
 
-// generateDiff runs diff -ruN between a/ and b/ in the given directory.
-func generateDiff(tmpDir string) ([]byte, error) {
-	cmd := exec.Command("diff", "-ruN", "--exclude=go.sum", "a", "b")
-	cmd.Dir = tmpDir
-
-	out, err := cmd.CombinedOutput()
-	// diff exits 1 when files differ (which is expected)
+// diffTrees walks both generated projects and returns the per-file adds,
+// modifications, and deletions needed to turn dirA into dirB, sorted by path.
+func diffTrees(dirA, dirB string) ([]FileChange, error) {
+	filesA, err := readTree(dirA)
+	if err != nil {
+		return nil, err
+	}
+	filesB, err := readTree(dirB)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return out, nil
+		return nil, err
+	}
+
+	var changes []FileChange
+	for path, content := range filesB {
+		baseline, ok := filesA[path]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: path, Kind: ChangeAdd, Content: content})
+		case !bytes.Equal(baseline, content):
+			changes = append(changes, FileChange{Path: path, Kind: ChangeModify, Baseline: baseline, Content: content})
+		}
+	}
+	for path, baseline := range filesA {
+		if _, ok := filesB[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Kind: ChangeDelete, Baseline: baseline})
 		}
-		return nil, fmt.Errorf("diff command failed: %w\n%s", err, out)
 	}
-	// exit 0 means no differences
-	return out, nil
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
 }
 
-// applyPatch applies a unified diff patch using git apply.
-func applyPatch(projectDir string, patch []byte) error {
-	cmd := exec.Command("git", "apply", "-p1", "--stat", "--apply")
-	cmd.Dir = projectDir
-	cmd.Stdin = bytes.NewReader(patch)
+// readTree reads every regular file under root (except go.sum) keyed by its
+// slash-separated relative path.
+func readTree(root string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == "go.sum" {
+			return nil
+		}
 
-	out, err := cmd.CombinedOutput()
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", rel, err)
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("%s\n%s", err, out)
+		return nil, err
+	}
+	return files, nil
+}
+
+// mergeChanges checks every file the changes touch against the project. A
+// modified file that was edited locally gets the OAuth changes applied hunk
+// by hunk, like git apply, and only conflicts when a hunk's lines can't be
+// found. Added files must not exist with other content, and deleted files
+// must be unedited. Nothing is applied if any file conflicts.
+func mergeChanges(projectDir string, changes []FileChange) error {
+	var conflicts []string
+	for i := range changes {
+		c := &changes[i]
+		current, err := os.ReadFile(filepath.Join(projectDir, filepath.FromSlash(c.Path)))
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("read %s: %w", c.Path, err)
+		}
+
+		switch {
+		case c.Kind == ChangeAdd:
+			if exists && !bytes.Equal(current, c.Content) {
+				conflicts = append(conflicts, c.Path+" (already exists)")
+			}
+		case !exists:
+			conflicts = append(conflicts, c.Path+" (missing)")
+		case bytes.Equal(current, c.Baseline):
+		case c.Kind == ChangeDelete:
+			conflicts = append(conflicts, c.Path+" (modified locally)")
+		default:
+			merged, err := mergeLocalEdits(c.Baseline, c.Content, current)
+			if err != nil {
+				conflicts = append(conflicts, fmt.Sprintf("%s (%v)", c.Path, err))
+				continue
+			}
+			c.Content = merged
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("cannot apply changes cleanly, local edits conflict in these files:\n  %s",
+			strings.Join(conflicts, "\n  "))
 	}
 	return nil
 }
 
-// fixMigrationNumbers adjusts OAuth migration numbers in the patch if 000003 is
-// already taken by a user migration in the project.
-func fixMigrationNumbers(patch []byte, projectDir string) ([]byte, error) {
+// backupFiles copies every existing file that changes will overwrite or
+// remove into BackupDirName, preserving relative paths.
+func backupFiles(projectDir string, changes []FileChange) error {
+	backupDir := filepath.Join(projectDir, BackupDirName)
+	for _, c := range changes {
+		if c.Kind == ChangeAdd {
+			continue
+		}
+		src := filepath.Join(projectDir, filepath.FromSlash(c.Path))
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", c.Path, err)
+		}
+		dst := filepath.Join(backupDir, filepath.FromSlash(c.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, 0o644); err != nil {
+			return fmt.Errorf("write backup %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// applyChanges writes added and modified files and removes deleted ones.
+func applyChanges(projectDir string, changes []FileChange) error {
+	for _, c := range changes {
+		target := filepath.Join(projectDir, filepath.FromSlash(c.Path))
+
+		if c.Kind == ChangeDelete {
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("delete %s: %w", c.Path, err)
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, c.Content, 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// fixMigrationNumbers renames the OAuth migration files if 000003 is already
+// taken by a user migration in the project.
+func fixMigrationNumbers(changes []FileChange, projectDir string) []FileChange {
 	nextNum, err := nextMigrationNumber(projectDir)
 	if err != nil {
 		// No migrations dir — use default numbering
-		return patch, nil
+		return changes
 	}
 
-	// OAuth migrations in the generated diff use 000003
+	// OAuth migrations in the generated projects use 000003
 	if nextNum <= 3 {
 		// 000003 is available, no renumbering needed
-		return patch, nil
+		return changes
 	}
 
-	oldPrefix := "000003"
-	newPrefix := fmt.Sprintf("%06d", nextNum)
-	patch = bytes.ReplaceAll(patch, []byte(oldPrefix+"_add_oauth_fields"), []byte(newPrefix+"_add_oauth_fields"))
+	oldPrefix := "migrations/000003_add_oauth_fields"
+	newPrefix := fmt.Sprintf("migrations/%06d_add_oauth_fields", nextNum)
+	for i := range changes {
+		if strings.HasPrefix(changes[i].Path, oldPrefix) {
+			changes[i].Path = newPrefix + strings.TrimPrefix(changes[i].Path, oldPrefix)
+		}
+	}
 
-	return patch, nil
+	return changes
 }
 
 // nextMigrationNumber finds the highest existing migration number + 1.
@@ -17,6 +17,7 @@ const (
 	DatabasePostgres Database = "postgres"
 	DatabaseMySQL    Database = "mysql"
 	DatabaseMongoDB  Database = "mongodb"
+	DatabaseSQLite   Database = "sqlite"
 )
 
 // ORM represents a supported ORM or database driver.
@@ -46,6 +47,14 @@ type ProjectConfig struct {
 	ORM         ORM       `json:"orm"`
 	Auth        AuthToken `json:"auth"`
 	HasOAuth    bool      `json:"has_oauth"`
+	// HasPublicConfig adds a GET /config endpoint with non-secret settings for frontends
+	HasPublicConfig bool `json:"has_public_config,omitempty"`
+	// HasErrorCodeExport adds a command that exports the API error codes for frontends
+	HasErrorCodeExport bool `json:"has_error_code_export,omitempty"`
+	// HasPasskeys adds WebAuthn passkey registration and login
+	HasPasskeys bool `json:"has_passkeys,omitempty"`
+	// HasConfigTest adds a test that loads the config from representative env vars
+	HasConfigTest bool `json:"has_config_test,omitempty"`
 }
 
 // SaveToFile writes the config as JSON to ConfigFileName in the given directory.
@@ -84,6 +93,8 @@ func (d Database) Label() string {
 		return "MySQL"
 	case DatabaseMongoDB:
 		return "MongoDB"
+	case DatabaseSQLite:
+		return "SQLite"
 	default:
 		return string(d)
 	}
@@ -0,0 +1,255 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+)
+
+// doctorTimeout bounds each network check so an unreachable host can't hang the report
+const doctorTimeout = 3 * time.Second
+
+// CheckStatus is the outcome of a single doctor check.
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+// Check is one line of the doctor report. Only failed checks marked Critical
+// make the doctor command exit non-zero.
+type Check struct {
+	Name     string
+	Status   CheckStatus
+	Detail   string
+	Critical bool
+}
+
+// HasCriticalFailure reports whether any critical check failed.
+func HasCriticalFailure(checks []Check) bool {
+	for _, c := range checks {
+		if c.Critical && c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderSecrets are the example values shipped in .env.example
+var placeholderSecrets = map[string]string{
+	"PASETO_KEY": "your-32-byte-secret-key-here!!!",
+	"JWT_SECRET": "your-jwt-secret-key-change-me",
+}
+
+// Doctor checks that a generated project's local environment is ready to run:
+// a .env with the keys its database and auth choices need, a reachable
+// database and Redis, and the migration and Swagger tools on PATH. It only
+// reads; nothing in the project or its services is changed. An error means
+// projectDir isn't a generated project.
+func Doctor(projectDir string) ([]Check, error) {
+	cfg, err := LoadConfigFromFile(projectDir)
+	if err != nil {
+		return nil, fmt.Errorf("not a create-go-api project (missing %s): %w", ConfigFileName, err)
+	}
+
+	env, err := godotenv.Read(filepath.Join(projectDir, ".env"))
+	if err != nil {
+		return []Check{{
+			Name:     ".env file",
+			Status:   CheckFail,
+			Detail:   "missing or unreadable; copy .env.example to .env and fill it in",
+			Critical: true,
+		}}, nil
+	}
+
+	checks := []Check{{Name: ".env file", Status: CheckPass, Critical: true}}
+	checks = append(checks, checkEnvKeys(cfg, env)...)
+	checks = append(checks, checkDatabase(projectDir, cfg, env), checkRedis(env))
+	checks = append(checks, checkTools(cfg)...)
+	return checks, nil
+}
+
+// requiredEnvKeys lists the .env keys the project can't start without
+func requiredEnvKeys(cfg *ProjectConfig) []string {
+	var keys []string
+	switch cfg.Database {
+	case DatabasePostgres, DatabaseMySQL:
+		keys = append(keys, "DB_HOST", "DB_PORT", "DB_USER", "DB_NAME")
+	case DatabaseSQLite:
+		keys = append(keys, "DB_PATH")
+	case DatabaseMongoDB:
+		keys = append(keys, "MONGO_URI", "MONGO_DB_NAME")
+	}
+	keys = append(keys, "REDIS_HOST", "REDIS_PORT")
+
+	switch cfg.Auth {
+	case AuthPaseto:
+		keys = append(keys, "PASETO_KEY")
+	case AuthJWT:
+		keys = append(keys, "JWT_SECRET")
+	}
+
+	if cfg.HasOAuth {
+		keys = append(keys, "OAUTH_REDIRECT_BASE_URL")
+	}
+	return keys
+}
+
+func checkEnvKeys(cfg *ProjectConfig, env map[string]string) []Check {
+	var missing []string
+	for _, key := range requiredEnvKeys(cfg) {
+		if env[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	keysCheck := Check{Name: "required .env keys", Status: CheckPass, Critical: true}
+	if len(missing) > 0 {
+		keysCheck.Status = CheckFail
+		keysCheck.Detail = "missing " + strings.Join(missing, ", ")
+	}
+	checks := []Check{keysCheck}
+
+	for key, placeholder := range placeholderSecrets {
+		if value, ok := env[key]; ok && value == placeholder {
+			checks = append(checks, Check{
+				Name:   key,
+				Status: CheckWarn,
+				Detail: "still the example value; generate a real secret before deploying",
+			})
+		}
+	}
+
+	return checks
+}
+
+func checkDatabase(projectDir string, cfg *ProjectConfig, env map[string]string) Check {
+	check := Check{Name: cfg.Database.Label() + " reachable", Critical: true}
+
+	var addr string
+	switch cfg.Database {
+	case DatabasePostgres, DatabaseMySQL:
+		addr = net.JoinHostPort(env["DB_HOST"], env["DB_PORT"])
+	case DatabaseMongoDB:
+		u, err := url.Parse(env["MONGO_URI"])
+		if err != nil || u.Host == "" {
+			check.Status = CheckFail
+			check.Detail = "MONGO_URI is not a valid URI"
+			return check
+		}
+		// Replica set URIs list several hosts; the first is enough to see the server is up
+		addr, _, _ = strings.Cut(u.Host, ",")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "27017")
+		}
+	case DatabaseSQLite:
+		check.Name = "SQLite database path"
+		path := env["DB_PATH"]
+		if path == ":memory:" {
+			check.Status = CheckPass
+			check.Detail = "in-memory database"
+			return check
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(projectDir, path)
+		}
+		if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+			check.Status = CheckFail
+			check.Detail = "directory for DB_PATH does not exist"
+			return check
+		}
+		check.Status = CheckPass
+		return check
+	}
+
+	if err := dial(addr); err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("%v; is Docker running? try make docker-up", err)
+		return check
+	}
+	check.Status = CheckPass
+	check.Detail = addr
+	return check
+}
+
+func checkRedis(env map[string]string) Check {
+	check := Check{Name: "Redis reachable", Critical: true}
+	addr := net.JoinHostPort(env["REDIS_HOST"], env["REDIS_PORT"])
+
+	redis.SetLogger(quietRedisLogger{})
+	client := redis.NewClient(&redis.Options{
+		Addr:        addr,
+		Password:    env["REDIS_PASSWORD"],
+		DialTimeout: doctorTimeout,
+		ReadTimeout: doctorTimeout,
+		MaxRetries:  -1,
+	})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("%v; is Docker running? try make docker-up", err)
+		return check
+	}
+	check.Status = CheckPass
+	check.Detail = addr
+	return check
+}
+
+// checkTools looks for the CLIs the Makefile relies on. Migrations can't be
+// applied without migrate, so it is critical for SQL databases; swag only
+// regenerates the API docs.
+func checkTools(cfg *ProjectConfig) []Check {
+	var checks []Check
+	if cfg.Database != DatabaseMongoDB {
+		checks = append(checks, checkTool("migrate", "golang-migrate CLI", true))
+	}
+	checks = append(checks, checkTool("swag", "Swagger generator", false))
+	return checks
+}
+
+func checkTool(name, label string, critical bool) Check {
+	check := Check{Name: name + " installed", Critical: critical}
+	path, err := exec.LookPath(name)
+	switch {
+	case err == nil:
+		check.Status = CheckPass
+		check.Detail = path
+	case critical:
+		check.Status = CheckFail
+		check.Detail = label + " not found on PATH; run make install-tools"
+	default:
+		check.Status = CheckWarn
+		check.Detail = label + " not found on PATH; run make install-tools"
+	}
+	return check
+}
+
+// quietRedisLogger drops go-redis's internal log lines, which would interleave
+// with the report; failures are reported through the check instead
+type quietRedisLogger struct{}
+
+func (quietRedisLogger) Printf(context.Context, string, ...any) {}
+
+// dial opens and closes a TCP connection to addr
+func dial(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, doctorTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -1,9 +1,10 @@
 package generator
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -25,39 +26,63 @@ func Generate(cfg *ProjectConfig) error {
 // Unlike Generate, it does not save a config file, making it suitable
 // for generating temporary projects (e.g., for diff-based patching).
 func GenerateTo(outDir string, cfg *ProjectConfig) error {
+	return generate(outDir, cfg, diskWriter{})
+}
+
+// DryRun renders the project in memory and returns the files Generate would
+// create (relative to the project directory, sorted by path) without writing
+// anything to disk.
+func DryRun(cfg *ProjectConfig) ([]PlannedFile, error) {
+	w := newDryRunWriter()
+	if err := generate("", cfg, w); err != nil {
+		return nil, err
+	}
+
+	// Generate also saves the config file next to the generated sources
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	w.files[ConfigFileName] = len(data) + 1
+
+	return w.planned(), nil
+}
+
+// generate renders the project into outDir through the given fileWriter.
+func generate(outDir string, cfg *ProjectConfig, w fileWriter) error {
 	if err := ValidateConfig(cfg); err != nil {
 		return fmt.Errorf("invalid config: %w", err)
 	}
 
-	if err := os.MkdirAll(outDir, 0o755); err != nil {
+	if err := w.MkdirAll(outDir); err != nil {
 		return fmt.Errorf("create output directory: %w", err)
 	}
 
 	tplData := buildTemplateData(cfg)
 
 	// 1. Copy static files
-	if err := copyStatic(outDir, cfg); err != nil {
+	if err := copyStatic(w, outDir, cfg); err != nil {
 		return fmt.Errorf("copy static files: %w", err)
 	}
 
 	// 2. Copy database variant files
-	if err := copyDatabaseVariant(outDir, cfg, tplData); err != nil {
+	if err := copyDatabaseVariant(w, outDir, cfg, tplData); err != nil {
 		return fmt.Errorf("copy database variant: %w", err)
 	}
 
 	// 3. Copy auth variant files
-	if err := copyAuthVariant(outDir, cfg, tplData); err != nil {
+	if err := copyAuthVariant(w, outDir, cfg, tplData); err != nil {
 		return fmt.Errorf("copy auth variant: %w", err)
 	}
 
 	// 4. Render shared templates
-	if err := renderTemplates(outDir, cfg); err != nil {
+	if err := renderTemplates(w, outDir, cfg); err != nil {
 		return fmt.Errorf("render templates: %w", err)
 	}
 
 	// 5. Copy OAuth files (if enabled)
 	if cfg.HasOAuth {
-		if err := copyOAuthFiles(outDir, cfg); err != nil {
+		if err := copyOAuthFiles(w, outDir, cfg); err != nil {
 			return fmt.Errorf("copy oauth files: %w", err)
 		}
 	}
@@ -75,7 +100,7 @@ func stripGoTmplExt(name string) string {
 
 // copyStatic copies all files from templates/static/ to the output directory,
 // rewriting Go import paths.
-func copyStatic(outDir string, cfg *ProjectConfig) error {
+func copyStatic(w fileWriter, outDir string, cfg *ProjectConfig) error {
 	root := "static"
 	return fs.WalkDir(templates.StaticFS, root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -93,11 +118,19 @@ func copyStatic(outDir string, cfg *ProjectConfig) error {
 			return nil
 		}
 
+		// Skip the passkey package unless it was requested
+		if !cfg.HasPasskeys && strings.HasPrefix(rel, filepath.Join("internal", "passkey")) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
 		rel = stripGoTmplExt(rel)
 		target := filepath.Join(outDir, rel)
 
 		if d.IsDir() {
-			return os.MkdirAll(target, 0o755)
+			return w.MkdirAll(target)
 		}
 
 		data, err := fs.ReadFile(templates.StaticFS, path)
@@ -110,15 +143,15 @@ func copyStatic(outDir string, cfg *ProjectConfig) error {
 			content = rewriteImports(content, cfg.ModuleName)
 		}
 
-		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		if err := w.MkdirAll(filepath.Dir(target)); err != nil {
 			return err
 		}
-		return os.WriteFile(target, []byte(content), 0o644)
+		return w.WriteFile(target, []byte(content))
 	})
 }
 
 // copyDatabaseVariant copies the correct database variant files into the output project.
-func copyDatabaseVariant(outDir string, cfg *ProjectConfig, tplData *TemplateData) error {
+func copyDatabaseVariant(w fileWriter, outDir string, cfg *ProjectConfig, tplData *TemplateData) error {
 	variantRoot := fmt.Sprintf("variants/database/%s/%s", cfg.ORM, cfg.Database)
 
 	return fs.WalkDir(templates.VariantsFS, variantRoot, func(path string, d fs.DirEntry, err error) error {
@@ -144,21 +177,21 @@ func copyDatabaseVariant(outDir string, cfg *ProjectConfig, tplData *TemplateDat
 
 		// Determine target path based on filename conventions
 		target := resolveVariantTarget(outDir, rel, cfg)
-		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		if err := w.MkdirAll(filepath.Dir(target)); err != nil {
 			return err
 		}
 
 		// Template-render .go.tmpl files; copy others as-is
 		if strings.HasSuffix(path, ".go.tmpl") {
-			return renderVariantTemplate(path, string(data), target, tplData)
+			return renderVariantTemplate(w, path, string(data), target, tplData)
 		}
 
-		return os.WriteFile(target, data, 0o644)
+		return w.WriteFile(target, data)
 	})
 }
 
 // copyAuthVariant copies the correct auth token variant files.
-func copyAuthVariant(outDir string, cfg *ProjectConfig, tplData *TemplateData) error {
+func copyAuthVariant(w fileWriter, outDir string, cfg *ProjectConfig, tplData *TemplateData) error {
 	variantRoot := fmt.Sprintf("variants/auth/%s", cfg.Auth)
 
 	return fs.WalkDir(templates.VariantsFS, variantRoot, func(path string, d fs.DirEntry, err error) error {
@@ -178,16 +211,16 @@ func copyAuthVariant(outDir string, cfg *ProjectConfig, tplData *TemplateData) e
 		}
 
 		target := filepath.Join(outDir, "internal", "auth", rel)
-		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		if err := w.MkdirAll(filepath.Dir(target)); err != nil {
 			return err
 		}
 
 		// Template-render .go.tmpl files; copy others as-is
 		if strings.HasSuffix(path, ".go.tmpl") {
-			return renderVariantTemplate(path, string(data), target, tplData)
+			return renderVariantTemplate(w, path, string(data), target, tplData)
 		}
 
-		return os.WriteFile(target, data, 0o644)
+		return w.WriteFile(target, data)
 	})
 }
 
@@ -223,7 +256,7 @@ func resolveVariantTarget(outDir, rel string, cfg *ProjectConfig) string {
 }
 
 // renderTemplates processes .tmpl files from templates/shared/ and writes them to the output.
-func renderTemplates(outDir string, cfg *ProjectConfig) error {
+func renderTemplates(w fileWriter, outDir string, cfg *ProjectConfig) error {
 	root := "shared"
 
 	tplData := buildTemplateData(cfg)
@@ -237,6 +270,22 @@ func renderTemplates(outDir string, cfg *ProjectConfig) error {
 		}
 
 		rel, _ := filepath.Rel(root, path)
+
+		// Skip the public config endpoint unless it was requested
+		if !cfg.HasPublicConfig && strings.Contains(rel, "public_config") {
+			return nil
+		}
+
+		// Skip the error code exporter unless it was requested
+		if !cfg.HasErrorCodeExport && strings.Contains(rel, "errcodes") {
+			return nil
+		}
+
+		// Skip the config loading test unless it was requested
+		if !cfg.HasConfigTest && rel == filepath.Join("internal", "config", "config_test.go.tmpl") {
+			return nil
+		}
+
 		// Strip .tmpl extension for output path
 		outPath := strings.TrimSuffix(rel, ".tmpl")
 		target := filepath.Join(outDir, outPath)
@@ -251,17 +300,15 @@ func renderTemplates(outDir string, cfg *ProjectConfig) error {
 			return fmt.Errorf("parse template %s: %w", path, err)
 		}
 
-		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		if err := w.MkdirAll(filepath.Dir(target)); err != nil {
 			return err
 		}
 
-		f, err := os.Create(target)
-		if err != nil {
-			return fmt.Errorf("create %s: %w", target, err)
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, tplData); err != nil {
+			return fmt.Errorf("execute template %s: %w", path, err)
 		}
-		defer f.Close()
-
-		return tmpl.Execute(f, tplData)
+		return w.WriteFile(target, buf.Bytes())
 	})
 }
 
@@ -274,60 +321,68 @@ type TemplateData struct {
 	Auth        AuthToken
 
 	// Convenience booleans for templates
-	IsPostgres bool
-	IsMySQL    bool
-	IsMongoDB  bool
-	IsBun      bool
-	IsGORM     bool
-	IsPgx      bool
-	IsSQLRaw   bool
-	IsMongo    bool
-	IsPaseto   bool
-	IsJWT      bool
-	IsSQL      bool // true for Postgres and MySQL (not MongoDB)
-	HasOAuth   bool
+	IsPostgres         bool
+	IsMySQL            bool
+	IsMongoDB          bool
+	IsSQLite           bool
+	IsBun              bool
+	IsGORM             bool
+	IsPgx              bool
+	IsSQLRaw           bool
+	IsMongo            bool
+	IsPaseto           bool
+	IsJWT              bool
+	IsSQL              bool // true for Postgres, MySQL and SQLite (not MongoDB)
+	HasOAuth           bool
+	HasPublicConfig    bool
+	HasErrorCodeExport bool
+	HasPasskeys        bool
+	HasConfigTest      bool
 }
 
 func buildTemplateData(cfg *ProjectConfig) *TemplateData {
 	return &TemplateData{
-		ProjectName: cfg.ProjectName,
-		ModuleName:  cfg.ModuleName,
-		Database:    cfg.Database,
-		ORM:         cfg.ORM,
-		Auth:        cfg.Auth,
-		IsPostgres:  cfg.Database == DatabasePostgres,
-		IsMySQL:     cfg.Database == DatabaseMySQL,
-		IsMongoDB:   cfg.Database == DatabaseMongoDB,
-		IsBun:       cfg.ORM == ORMBun,
-		IsGORM:      cfg.ORM == ORMGORM,
-		IsPgx:       cfg.ORM == ORMPgx,
-		IsSQLRaw:    cfg.ORM == ORMSQLRaw,
-		IsMongo:     cfg.ORM == ORMMongo,
-		IsPaseto:    cfg.Auth == AuthPaseto,
-		IsJWT:       cfg.Auth == AuthJWT,
-		IsSQL:       cfg.Database != DatabaseMongoDB,
-		HasOAuth:    cfg.HasOAuth,
+		ProjectName:        cfg.ProjectName,
+		ModuleName:         cfg.ModuleName,
+		Database:           cfg.Database,
+		ORM:                cfg.ORM,
+		Auth:               cfg.Auth,
+		IsPostgres:         cfg.Database == DatabasePostgres,
+		IsMySQL:            cfg.Database == DatabaseMySQL,
+		IsMongoDB:          cfg.Database == DatabaseMongoDB,
+		IsSQLite:           cfg.Database == DatabaseSQLite,
+		IsBun:              cfg.ORM == ORMBun,
+		IsGORM:             cfg.ORM == ORMGORM,
+		IsPgx:              cfg.ORM == ORMPgx,
+		IsSQLRaw:           cfg.ORM == ORMSQLRaw,
+		IsMongo:            cfg.ORM == ORMMongo,
+		IsPaseto:           cfg.Auth == AuthPaseto,
+		IsJWT:              cfg.Auth == AuthJWT,
+		IsSQL:              cfg.Database != DatabaseMongoDB,
+		HasOAuth:           cfg.HasOAuth,
+		HasPublicConfig:    cfg.HasPublicConfig,
+		HasErrorCodeExport: cfg.HasErrorCodeExport,
+		HasPasskeys:        cfg.HasPasskeys,
+		HasConfigTest:      cfg.HasConfigTest,
 	}
 }
 
 // renderVariantTemplate parses and executes a Go template from a variant file.
-func renderVariantTemplate(srcPath, content, target string, tplData *TemplateData) error {
+func renderVariantTemplate(w fileWriter, srcPath, content, target string, tplData *TemplateData) error {
 	tmpl, err := template.New(srcPath).Parse(content)
 	if err != nil {
 		return fmt.Errorf("parse variant template %s: %w", srcPath, err)
 	}
 
-	f, err := os.Create(target)
-	if err != nil {
-		return fmt.Errorf("create %s: %w", target, err)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, tplData); err != nil {
+		return fmt.Errorf("execute variant template %s: %w", srcPath, err)
 	}
-	defer f.Close()
-
-	return tmpl.Execute(f, tplData)
+	return w.WriteFile(target, buf.Bytes())
 }
 
 // copyOAuthFiles copies OAuth provider files from templates/static/internal/oauth/.
-func copyOAuthFiles(outDir string, cfg *ProjectConfig) error {
+func copyOAuthFiles(w fileWriter, outDir string, cfg *ProjectConfig) error {
 	root := "static/internal/oauth"
 	return fs.WalkDir(templates.StaticFS, root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -339,7 +394,7 @@ func copyOAuthFiles(outDir string, cfg *ProjectConfig) error {
 		target := filepath.Join(outDir, "internal", "oauth", rel)
 
 		if d.IsDir() {
-			return os.MkdirAll(target, 0o755)
+			return w.MkdirAll(target)
 		}
 
 		data, err := fs.ReadFile(templates.StaticFS, path)
@@ -352,9 +407,9 @@ func copyOAuthFiles(outDir string, cfg *ProjectConfig) error {
 			content = rewriteImports(content, cfg.ModuleName)
 		}
 
-		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		if err := w.MkdirAll(filepath.Dir(target)); err != nil {
 			return err
 		}
-		return os.WriteFile(target, []byte(content), 0o644)
+		return w.WriteFile(target, []byte(content))
 	})
 }
@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePasskeys(t *testing.T) {
+	for _, hasPasskeys := range []bool{false, true} {
+		cfg := &ProjectConfig{
+			ProjectName: "demo",
+			ModuleName:  "example.com/demo",
+			Database:    DatabasePostgres,
+			ORM:         ORMBun,
+			Auth:        AuthPaseto,
+			HasPasskeys: hasPasskeys,
+		}
+		dir := t.TempDir()
+		if err := GenerateTo(dir, cfg); err != nil {
+			t.Fatalf("GenerateTo(HasPasskeys=%v) error = %v", hasPasskeys, err)
+		}
+		parseGoFiles(t, dir)
+
+		_, err := os.Stat(filepath.Join(dir, "internal", "passkey", "service.go"))
+		if hasPasskeys && err != nil {
+			t.Errorf("HasPasskeys: internal/passkey/service.go missing: %v", err)
+		}
+		if !hasPasskeys && err == nil {
+			t.Errorf("without HasPasskeys: internal/passkey/service.go generated")
+		}
+
+		for file, want := range map[string]string{
+			"go.mod":                    "github.com/go-webauthn/webauthn",
+			"internal/http/router.go":   `"/webauthn/login/begin"`,
+			"internal/config/config.go": `"WEBAUTHN_RP_ID"`,
+			".env.example":              "WEBAUTHN_ENABLED=",
+		} {
+			data, err := os.ReadFile(filepath.Join(dir, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(string(data), want); got != hasPasskeys {
+				t.Errorf("HasPasskeys=%v: %s contains %s = %v", hasPasskeys, file, want, got)
+			}
+		}
+	}
+}
+
+// parseGoFiles fails t if any generated Go file in dir doesn't parse
+func parseGoFiles(t *testing.T, dir string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		if _, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution); err != nil {
+			t.Errorf("generated %s does not parse: %v", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateConfigTest(t *testing.T) {
+	for _, hasConfigTest := range []bool{false, true} {
+		for _, auth := range []AuthToken{AuthPaseto, AuthJWT} {
+			cfg := &ProjectConfig{
+				ProjectName:   "demo",
+				ModuleName:    "example.com/demo",
+				Database:      DatabasePostgres,
+				ORM:           ORMBun,
+				Auth:          auth,
+				HasConfigTest: hasConfigTest,
+			}
+			dir := t.TempDir()
+			if err := GenerateTo(dir, cfg); err != nil {
+				t.Fatalf("GenerateTo(HasConfigTest=%v, Auth=%s) error = %v", hasConfigTest, auth, err)
+			}
+			parseGoFiles(t, dir)
+
+			data, err := os.ReadFile(filepath.Join(dir, "internal", "config", "config_test.go"))
+			if !hasConfigTest {
+				if err == nil {
+					t.Errorf("without HasConfigTest: internal/config/config_test.go generated")
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("HasConfigTest: internal/config/config_test.go missing: %v", err)
+			}
+
+			content := string(data)
+			if !strings.Contains(content, "Load()") {
+				t.Errorf("Auth=%s: config_test.go does not call config.Load", auth)
+			}
+			missingKeyTest := map[AuthToken]string{AuthPaseto: "TestLoadMissingPasetoKey", AuthJWT: "TestLoadMissingJWTSecret"}[auth]
+			if !strings.Contains(content, missingKeyTest) {
+				t.Errorf("Auth=%s: config_test.go missing %s", auth, missingKeyTest)
+			}
+		}
+	}
+}
+
+func TestGenerateClaimsProvider(t *testing.T) {
+	for _, auth := range []AuthToken{AuthPaseto, AuthJWT} {
+		cfg := &ProjectConfig{
+			ProjectName: "demo",
+			ModuleName:  "example.com/demo",
+			Database:    DatabasePostgres,
+			ORM:         ORMBun,
+			Auth:        auth,
+		}
+		dir := t.TempDir()
+		if err := GenerateTo(dir, cfg); err != nil {
+			t.Fatalf("GenerateTo(Auth=%s) error = %v", auth, err)
+		}
+		parseGoFiles(t, dir)
+
+		for file, want := range map[string]string{
+			"internal/auth/claims.go":             "type ClaimsProvider func(",
+			"internal/auth/interfaces.go":         "duration time.Duration, extra map[string]any) (string, error)",
+			"internal/auth/token_service.go":      "checkExtraClaims(extra)",
+			"internal/auth/token_service_test.go": "ErrReservedClaim",
+			"internal/auth/middleware.go":         "func GetExtraClaimsFromContext(",
+			"cmd/api/main.go":                     "var claimsProvider auth.ClaimsProvider",
+		} {
+			data, err := os.ReadFile(filepath.Join(dir, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), want) {
+				t.Errorf("Auth=%s: %s doesn't contain %s", auth, file, want)
+			}
+		}
+	}
+}
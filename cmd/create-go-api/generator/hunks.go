@@ -0,0 +1,182 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hunkContext is the number of unchanged lines kept around each hunk, as in
+// diff -u. Hunks closer together than twice this are merged.
+const hunkContext = 3
+
+// hunk is one change between two versions of a file. The lines oldLines
+// (context and removals) starting at oldStart in the old version are
+// replaced by newLines (the same context and the additions).
+type hunk struct {
+	oldStart int // 0-based
+	oldLines []string
+	newLines []string
+}
+
+// diffOp is one step of a line edit script
+type diffOp struct {
+	kind byte // '=' keep, '-' remove, '+' add
+	line string
+	aPos int // Lines of the old version consumed before this step
+}
+
+// splitLines splits content into lines that keep their newline, so joining
+// them restores content exactly, missing final newline included.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.SplitAfter(string(content), "\n")
+}
+
+// diffHunks returns the hunks that turn a into b, with hunkContext lines of
+// context, like a unified diff.
+func diffHunks(a, b []string) []hunk {
+	ops := diffLines(a, b)
+
+	var hunks []hunk
+	prevStop := 0
+	for i := 0; i < len(ops); {
+		if ops[i].kind == '=' {
+			i++
+			continue
+		}
+
+		// Extend over changes separated by little enough context to share a hunk
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != '=' {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == '=' {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*hunkContext {
+				end = next
+				continue
+			}
+			break
+		}
+
+		start := max(prevStop, i-hunkContext)
+		stop := min(len(ops), end+hunkContext)
+		h := hunk{oldStart: ops[start].aPos}
+		for _, op := range ops[start:stop] {
+			if op.kind != '+' {
+				h.oldLines = append(h.oldLines, op.line)
+			}
+			if op.kind != '-' {
+				h.newLines = append(h.newLines, op.line)
+			}
+		}
+		hunks = append(hunks, h)
+		prevStop = stop
+		i = stop
+	}
+	return hunks
+}
+
+// diffLines returns an edit script turning a into b along their longest
+// common subsequence. Generated files are small, so the quadratic table is
+// cheap.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: '=', line: a[i], aPos: i})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			ops = append(ops, diffOp{kind: '+', line: b[j], aPos: i})
+			j++
+		default:
+			ops = append(ops, diffOp{kind: '-', line: a[i], aPos: i})
+			i++
+		}
+	}
+	return ops
+}
+
+// applyHunks applies hunks to lines, a version of the hunks' old file that
+// may have been edited elsewhere. Like git apply, each hunk's context and
+// removed lines must match exactly, but may have moved; the nearest match to
+// where the hunk is expected wins.
+func applyHunks(lines []string, hunks []hunk) ([]string, error) {
+	var out []string
+	pos := 0    // Lines of lines already copied or replaced
+	offset := 0 // How far the previous hunk had moved
+	for n, h := range hunks {
+		at, ok := findHunk(lines, h.oldLines, h.oldStart+offset, pos)
+		if !ok {
+			return nil, fmt.Errorf("hunk %d (generated line %d) does not match", n+1, h.oldStart+1)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, h.newLines...)
+		pos = at + len(h.oldLines)
+		offset = at - h.oldStart
+	}
+	return append(out, lines[pos:]...), nil
+}
+
+// findHunk returns where want occurs in lines at or after from, searching
+// outward from expected
+func findHunk(lines, want []string, expected, from int) (int, bool) {
+	last := len(lines) - len(want) // Last position want fits at
+	if last < from {
+		return 0, false
+	}
+	expected = min(max(expected, from), last)
+	for d := 0; expected-d >= from || expected+d <= last; d++ {
+		if at := expected + d; at <= last && linesMatch(lines[at:], want) {
+			return at, true
+		}
+		if at := expected - d; d > 0 && at >= from && linesMatch(lines[at:], want) {
+			return at, true
+		}
+	}
+	return 0, false
+}
+
+// linesMatch reports whether lines starts with want
+func linesMatch(lines, want []string) bool {
+	for i, line := range want {
+		if lines[i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeLocalEdits applies the change from baseline to content onto current,
+// a locally edited copy of baseline, hunk by hunk
+func mergeLocalEdits(baseline, content, current []byte) ([]byte, error) {
+	hunks := diffHunks(splitLines(baseline), splitLines(content))
+	merged, err := applyHunks(splitLines(current), hunks)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(merged, "")), nil
+}
@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeLocalEdits(t *testing.T) {
+	baseline := lines("package main", "", "import (", "\t\"fmt\"", ")", "", "func main() {", "\tfmt.Println(\"hi\")", "}", "")
+	content := lines("package main", "", "import (", "\t\"fmt\"", "\t\"os\"", ")", "", "func main() {", "\tfmt.Println(\"hi\")", "\tos.Exit(0)", "}", "")
+
+	tests := []struct {
+		name    string
+		current string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "unedited",
+			current: baseline,
+			want:    content,
+		},
+		{
+			name:    "edit away from the hunks",
+			current: "// Copyright\n\n" + baseline,
+			want:    "// Copyright\n\n" + content,
+		},
+		{
+			name:    "edit inside a hunk's context",
+			current: strings.Replace(baseline, "fmt.Println(\"hi\")", "fmt.Println(\"hello\")", 1),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeLocalEdits([]byte(baseline), []byte(content), []byte(tt.current))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("mergeLocalEdits() = %q, want a conflict", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeLocalEdits() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergeLocalEdits() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeLocalEditsKeepsMissingFinalNewline(t *testing.T) {
+	got, err := mergeLocalEdits([]byte("a\nb"), []byte("a\nb\nc"), []byte("z\na\nb"))
+	if err != nil {
+		t.Fatalf("mergeLocalEdits() error = %v", err)
+	}
+	if want := "z\na\nb\nc"; string(got) != want {
+		t.Errorf("mergeLocalEdits() = %q, want %q", got, want)
+	}
+}
+
+func TestMergeChangesEditedFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", "// local\n"+lines("a", "b", "c", "d", "e", "f", "g", "h"))
+
+	changes := []FileChange{{
+		Path:     "main.go",
+		Kind:     ChangeModify,
+		Baseline: []byte(lines("a", "b", "c", "d", "e", "f", "g", "h")),
+		Content:  []byte(lines("a", "b", "c", "d", "e", "f", "g", "h", "i")),
+	}}
+	if err := mergeChanges(dir, changes); err != nil {
+		t.Fatalf("mergeChanges() error = %v", err)
+	}
+	if want := "// local\n" + lines("a", "b", "c", "d", "e", "f", "g", "h", "i"); string(changes[0].Content) != want {
+		t.Errorf("merged content = %q, want %q", changes[0].Content, want)
+	}
+}
+
+func TestMergeChangesEditedDeletion(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "old.go", "edited\n")
+
+	changes := []FileChange{{Path: "old.go", Kind: ChangeDelete, Baseline: []byte("generated\n")}}
+	if err := mergeChanges(dir, changes); err == nil || !strings.Contains(err.Error(), "old.go (modified locally)") {
+		t.Errorf("mergeChanges() error = %v, want old.go modified locally", err)
+	}
+}
+
+// lines joins ls with newlines, ending in one
+func lines(ls ...string) string {
+	return strings.Join(ls, "\n") + "\n"
+}
+
+// writeFile writes content to name in dir
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -7,6 +7,7 @@ var validCombinations = map[Database][]ORM{
 	DatabasePostgres: {ORMBun, ORMGORM, ORMPgx},
 	DatabaseMySQL:    {ORMGORM, ORMBun, ORMSQLRaw},
 	DatabaseMongoDB:  {ORMMongo},
+	DatabaseSQLite:   {ORMBun, ORMGORM, ORMSQLRaw},
 }
 
 // ValidateConfig checks that the ProjectConfig has a valid DB+ORM combination
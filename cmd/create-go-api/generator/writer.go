@@ -0,0 +1,59 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// fileWriter abstracts how generated files are persisted so the same
+// generation steps can write to disk or just record what they would create.
+type fileWriter interface {
+	MkdirAll(dir string) error
+	WriteFile(path string, data []byte) error
+}
+
+// diskWriter writes generated files to the local filesystem.
+type diskWriter struct{}
+
+func (diskWriter) MkdirAll(dir string) error {
+	return os.MkdirAll(dir, 0o755)
+}
+
+func (diskWriter) WriteFile(path string, data []byte) error {
+	return os.WriteFile(path, data, 0o644)
+}
+
+// PlannedFile is a file that a dry run would create.
+type PlannedFile struct {
+	Path string // slash-separated, relative to the project directory
+	Size int    // size in bytes
+}
+
+// dryRunWriter records file paths and sizes without touching the filesystem.
+type dryRunWriter struct {
+	files map[string]int
+}
+
+func newDryRunWriter() *dryRunWriter {
+	return &dryRunWriter{files: make(map[string]int)}
+}
+
+func (d *dryRunWriter) MkdirAll(dir string) error {
+	return nil
+}
+
+func (d *dryRunWriter) WriteFile(path string, data []byte) error {
+	d.files[filepath.ToSlash(filepath.Clean(path))] = len(data)
+	return nil
+}
+
+// planned returns the recorded files sorted by path.
+func (d *dryRunWriter) planned() []PlannedFile {
+	files := make([]PlannedFile, 0, len(d.files))
+	for path, size := range d.files {
+		files = append(files, PlannedFile{Path: path, Size: size})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
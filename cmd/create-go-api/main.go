@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -26,10 +27,15 @@ func main() {
 	// Flags for non-interactive mode (CI/scripting)
 	createCmd.Flags().String("name", "", "Project name")
 	createCmd.Flags().String("module", "", "Go module name")
-	createCmd.Flags().String("database", "", "Database (postgres, mysql, mongodb)")
+	createCmd.Flags().String("database", "", "Database (postgres, mysql, mongodb, sqlite)")
 	createCmd.Flags().String("orm", "", "ORM/driver (bun, gorm, pgx, sqlraw, mongo)")
 	createCmd.Flags().String("auth", "", "Auth token strategy (paseto, jwt)")
 	createCmd.Flags().Bool("oauth", false, "Include OAuth support (Google, GitHub, Discord)")
+	createCmd.Flags().Bool("public-config", false, "Include a public GET /config endpoint for frontends")
+	createCmd.Flags().Bool("error-codes-export", false, "Include a make target that exports API error codes as TypeScript/JSON")
+	createCmd.Flags().Bool("passkeys", false, "Include passkey (WebAuthn) registration and login")
+	createCmd.Flags().Bool("config-test", false, "Include a test that loads the config from representative env vars")
+	createCmd.Flags().Bool("dry-run", false, "Print the files that would be generated without writing them")
 
 	// add command group
 	addCmd := &cobra.Command{
@@ -43,9 +49,19 @@ func main() {
 		RunE:  runAddOAuth,
 	}
 	addOAuthCmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt")
+	addOAuthCmd.Flags().Bool("dry-run", false, "Show the files that would change without modifying the project")
+	addOAuthCmd.Flags().Bool("backup", false, "Copy modified and deleted files to "+generator.BackupDirName+" before applying")
 
 	addCmd.AddCommand(addOAuthCmd)
-	rootCmd.AddCommand(createCmd, addCmd)
+
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that a generated project's environment is ready to run",
+		Long:  "Reads " + generator.ConfigFileName + " and checks the .env file, database and Redis connectivity, and required tools. Exits non-zero if a critical check fails.",
+		RunE:  runDoctor,
+	}
+
+	rootCmd.AddCommand(createCmd, addCmd, doctorCmd)
 
 	// Allow running without subcommand (default to create)
 	rootCmd.RunE = createCmd.RunE
@@ -58,13 +74,15 @@ func main() {
 
 func runAddOAuth(cmd *cobra.Command, args []string) error {
 	yes, _ := cmd.Flags().GetBool("yes")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	backup, _ := cmd.Flags().GetBool("backup")
 
 	cwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("get working directory: %w", err)
 	}
 
-	if !yes {
+	if !yes && !dryRun {
 		fmt.Println("This will add OAuth support (Google, GitHub, Discord) to your project.")
 		fmt.Print("Continue? [y/N] ")
 		var answer string
@@ -75,16 +93,51 @@ func runAddOAuth(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	fmt.Println("Adding OAuth support...")
-	if err := generator.AddOAuth(cwd); err != nil {
+	if dryRun {
+		fmt.Println("Planning OAuth changes (dry run)...")
+	} else {
+		fmt.Println("Adding OAuth support...")
+	}
+	changes, err := generator.AddOAuth(cwd, generator.AddOAuthOptions{
+		DryRun: dryRun,
+		Backup: backup,
+	})
+	if err != nil {
 		ui.PrintError(err.Error())
 		return err
 	}
 
+	ui.PrintChanges(changes)
+	if dryRun {
+		return nil
+	}
+
 	ui.PrintAddOAuthSuccess()
 	return nil
 }
 
+func runDoctor(cmd *cobra.Command, args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get working directory: %w", err)
+	}
+
+	checks, err := generator.Doctor(cwd)
+	if err != nil {
+		ui.PrintError(err.Error())
+		return err
+	}
+
+	ui.PrintDoctorReport(checks)
+	if generator.HasCriticalFailure(checks) {
+		// The report already explains the failures; skip cobra's error output
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return errors.New("critical checks failed")
+	}
+	return nil
+}
+
 func runCreate(cmd *cobra.Command, args []string) error {
 	name, _ := cmd.Flags().GetString("name")
 	module, _ := cmd.Flags().GetString("module")
@@ -92,16 +145,29 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	orm, _ := cmd.Flags().GetString("orm")
 	auth, _ := cmd.Flags().GetString("auth")
 	oauth, _ := cmd.Flags().GetBool("oauth")
+	publicConfig, _ := cmd.Flags().GetBool("public-config")
+	errorCodesExport, _ := cmd.Flags().GetBool("error-codes-export")
+	passkeys, _ := cmd.Flags().GetBool("passkeys")
+	configTest, _ := cmd.Flags().GetBool("config-test")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
 
 	// If all required flags are provided, run non-interactively
 	if name != "" && module != "" && database != "" && orm != "" && auth != "" {
 		cfg := &generator.ProjectConfig{
-			ProjectName: name,
-			ModuleName:  module,
-			Database:    generator.Database(database),
-			ORM:         generator.ORM(orm),
-			Auth:        generator.AuthToken(auth),
-			HasOAuth:    oauth,
+			ProjectName:        name,
+			ModuleName:         module,
+			Database:           generator.Database(database),
+			ORM:                generator.ORM(orm),
+			Auth:               generator.AuthToken(auth),
+			HasOAuth:           oauth,
+			HasPublicConfig:    publicConfig,
+			HasErrorCodeExport: errorCodesExport,
+			HasPasskeys:        passkeys,
+			HasConfigTest:      configTest,
+		}
+
+		if dryRun {
+			return runDryRun(cfg)
 		}
 
 		fmt.Printf("Generating project %q...\n", cfg.ProjectName)
@@ -126,6 +192,10 @@ func runCreate(cmd *cobra.Command, args []string) error {
 
 	ui.PrintSummary(cfg)
 
+	if dryRun {
+		return runDryRun(cfg)
+	}
+
 	fmt.Println("Generating project...")
 	if err := generator.Generate(cfg); err != nil {
 		ui.PrintError(err.Error())
@@ -135,3 +205,14 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	ui.PrintSuccess(cfg)
 	return nil
 }
+
+func runDryRun(cfg *generator.ProjectConfig) error {
+	files, err := generator.DryRun(cfg)
+	if err != nil {
+		ui.PrintError(err.Error())
+		return err
+	}
+
+	ui.PrintFileTree(cfg.ProjectName, files)
+	return nil
+}
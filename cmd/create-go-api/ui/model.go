@@ -12,12 +12,16 @@ import (
 // RunForm displays the interactive project setup form and returns a ProjectConfig.
 func RunForm() (*generator.ProjectConfig, error) {
 	var (
-		projectName string
-		moduleName  string
-		database    string
-		orm         string
-		auth        string
-		hasOAuth    bool
+		projectName        string
+		moduleName         string
+		database           string
+		orm                string
+		auth               string
+		hasOAuth           bool
+		hasPublicConfig    bool
+		hasErrorCodeExport bool
+		hasPasskeys        bool
+		hasConfigTest      bool
 	)
 
 	// Stage 1: Project info + database selection
@@ -53,6 +57,7 @@ func RunForm() (*generator.ProjectConfig, error) {
 					huh.NewOption("PostgreSQL", string(generator.DatabasePostgres)),
 					huh.NewOption("MySQL", string(generator.DatabaseMySQL)),
 					huh.NewOption("MongoDB", string(generator.DatabaseMongoDB)),
+					huh.NewOption("SQLite", string(generator.DatabaseSQLite)),
 				).
 				Value(&database),
 
@@ -62,6 +67,34 @@ func RunForm() (*generator.ProjectConfig, error) {
 				Affirmative("Yes").
 				Negative("No").
 				Value(&hasOAuth),
+
+			huh.NewConfirm().
+				Title("Include a public config endpoint?").
+				Description("Adds GET /config with non-secret settings (auth methods, OAuth providers) for frontends").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&hasPublicConfig),
+
+			huh.NewConfirm().
+				Title("Export error codes for the frontend?").
+				Description("Adds 'make error-codes', which writes the API error codes as a TypeScript or JSON file").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&hasErrorCodeExport),
+
+			huh.NewConfirm().
+				Title("Include passkeys?").
+				Description("Adds WebAuthn passkey registration and passwordless login").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&hasPasskeys),
+
+			huh.NewConfirm().
+				Title("Include a config loading test?").
+				Description("Adds a test that loads the config from representative env vars, documenting what a deployment must set").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&hasConfigTest),
 		),
 	).WithTheme(huh.ThemeCatppuccin())
 
@@ -95,12 +128,16 @@ func RunForm() (*generator.ProjectConfig, error) {
 	}
 
 	cfg := &generator.ProjectConfig{
-		ProjectName: strings.TrimSpace(projectName),
-		ModuleName:  strings.TrimSpace(moduleName),
-		Database:    db,
-		ORM:         generator.ORM(orm),
-		Auth:        generator.AuthToken(auth),
-		HasOAuth:    hasOAuth,
+		ProjectName:        strings.TrimSpace(projectName),
+		ModuleName:         strings.TrimSpace(moduleName),
+		Database:           db,
+		ORM:                generator.ORM(orm),
+		Auth:               generator.AuthToken(auth),
+		HasOAuth:           hasOAuth,
+		HasPublicConfig:    hasPublicConfig,
+		HasErrorCodeExport: hasErrorCodeExport,
+		HasPasskeys:        hasPasskeys,
+		HasConfigTest:      hasConfigTest,
 	}
 
 	return cfg, nil
@@ -119,6 +156,18 @@ func PrintSummary(cfg *generator.ProjectConfig) {
 	} else {
 		fmt.Printf("  OAuth:    No\n")
 	}
+	if cfg.HasPublicConfig {
+		fmt.Printf("  Config:   GET /config\n")
+	}
+	if cfg.HasErrorCodeExport {
+		fmt.Printf("  Errors:   make error-codes\n")
+	}
+	if cfg.HasPasskeys {
+		fmt.Printf("  Passkeys: Yes (WebAuthn)\n")
+	}
+	if cfg.HasConfigTest {
+		fmt.Printf("  Tests:    internal/config/config_test.go\n")
+	}
 	fmt.Println()
 }
 
@@ -144,6 +193,86 @@ func PrintAddOAuthSuccess() {
 	fmt.Println()
 }
 
+// PrintFileTree prints the files a dry run would generate as an indented tree
+// rooted at the project directory, followed by the total count and size.
+func PrintFileTree(root string, files []generator.PlannedFile) {
+	fmt.Println(titleStyle.Render("Dry run — no files written"))
+	fmt.Printf("%s/\n", root)
+
+	var printed []string // directory components already printed
+	total := 0
+	for _, f := range files {
+		parts := strings.Split(f.Path, "/")
+		dirs := parts[:len(parts)-1]
+
+		// Find how many leading directories are shared with the previous file
+		common := 0
+		for common < len(dirs) && common < len(printed) && dirs[common] == printed[common] {
+			common++
+		}
+		for i := common; i < len(dirs); i++ {
+			fmt.Printf("%s%s/\n", strings.Repeat("  ", i+1), dirs[i])
+		}
+		printed = dirs
+
+		name := parts[len(parts)-1]
+		fmt.Printf("%s%s %s\n", strings.Repeat("  ", len(dirs)+1), name, subtleStyle.Render(fmt.Sprintf("(%d B)", f.Size)))
+		total += f.Size
+	}
+
+	fmt.Println()
+	fmt.Printf("%d files, %d bytes total\n", len(files), total)
+	fmt.Println()
+}
+
+// PrintChanges prints the file-level changes made (or planned) by an add command.
+func PrintChanges(changes []generator.FileChange) {
+	for _, c := range changes {
+		var marker string
+		switch c.Kind {
+		case generator.ChangeAdd:
+			marker = "+"
+		case generator.ChangeModify:
+			marker = "~"
+		case generator.ChangeDelete:
+			marker = "-"
+		}
+		fmt.Printf("  %s %s\n", marker, c.Path)
+	}
+	fmt.Println(subtleStyle.Render(fmt.Sprintf("  %d file(s)", len(changes))))
+	fmt.Println()
+}
+
+// PrintDoctorReport prints the doctor checks as a pass/warn/fail checklist
+// followed by a one-line verdict.
+func PrintDoctorReport(checks []generator.Check) {
+	fmt.Println(titleStyle.Render("Project environment"))
+	for _, c := range checks {
+		var marker string
+		switch c.Status {
+		case generator.CheckPass:
+			marker = SuccessStyle.Render("✓")
+		case generator.CheckWarn:
+			marker = warnStyle.Render("!")
+		case generator.CheckFail:
+			marker = errorStyle.Render("✗")
+		}
+		line := fmt.Sprintf("  %s %s", marker, c.Name)
+		if c.Detail != "" {
+			line += " " + subtleStyle.Render("("+c.Detail+")")
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+
+	if generator.HasCriticalFailure(checks) {
+		fmt.Println(errorStyle.Render("Some critical checks failed; fix them before running the API."))
+	} else {
+		fmt.Println(SuccessStyle.Render("Environment looks ready."))
+	}
+	fmt.Println()
+}
+
 // PrintError prints an error message.
 func PrintError(msg string) {
 	fmt.Println(errorStyle.Render("Error: " + msg))
@@ -16,6 +16,10 @@ var (
 	subtleStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241"))
 
+	warnStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("214"))
+
 	errorStyle = lipgloss.NewStyle().
 			Bold(true).
 			Foreground(lipgloss.Color("196"))
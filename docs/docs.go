@@ -22,58 +22,53 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/forgot-password": {
-            "post": {
-                "description": "Send a password reset link to the user's email. Always returns success to prevent email enumeration.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/invites": {
+            "get": {
+                "description": "List invites that haven't expired, including used ones",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
-                ],
-                "summary": "Request password reset",
-                "parameters": [
-                    {
-                        "description": "Email address",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/internal_auth.ForgotPasswordRequest"
-                        }
-                    }
+                    "admin"
                 ],
+                "summary": "List invites",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_auth.Invite"
                             }
                         }
                     },
-                    "400": {
-                        "description": "Invalid request body",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
                     },
-                    "429": {
-                        "description": "Too many requests",
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/auth/login": {
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
             "post": {
-                "description": "Authenticate user and receive access and refresh tokens",
+                "description": "Issue a single-use invite token for invite-only registration",
                 "consumes": [
                     "application/json"
                 ],
@@ -81,35 +76,41 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "User login",
+                "summary": "Create an invite",
                 "parameters": [
                     {
-                        "description": "Login credentials",
+                        "description": "Optional email binding and lifetime",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.LoginRequest"
+                            "$ref": "#/definitions/internal_auth.CreateInviteRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.AuthTokens"
+                            "$ref": "#/definitions/internal_auth.CreateInviteResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid request or email",
                         "schema": {
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
                     },
                     "401": {
-                        "description": "Invalid credentials",
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
                         "schema": {
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
@@ -120,30 +121,31 @@ const docTemplate = `{
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "/auth/logout": {
-            "post": {
-                "description": "Logout user by revoking refresh token and clearing cookies",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/invites/{id}": {
+            "delete": {
+                "description": "Delete an invite so it can no longer be used",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "User logout",
+                "summary": "Revoke an invite",
                 "parameters": [
                     {
-                        "description": "Optional refresh token",
-                        "name": "request",
-                        "in": "body",
-                        "schema": {
-                            "$ref": "#/definitions/internal_auth.RefreshRequest"
-                        }
+                        "type": "string",
+                        "description": "Invite ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -155,49 +157,27 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
-                    }
-                }
-            }
-        },
-        "/auth/refresh": {
-            "post": {
-                "description": "Use a refresh token to get a new access token",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "auth"
-                ],
-                "summary": "Refresh access token",
-                "parameters": [
-                    {
-                        "description": "Refresh token",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
+                    },
+                    "400": {
+                        "description": "Invalid invite ID",
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.RefreshRequest"
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.AuthTokens"
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
                     },
-                    "400": {
-                        "description": "Invalid request body",
+                    "403": {
+                        "description": "Admin access required",
                         "schema": {
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
                     },
-                    "401": {
-                        "description": "Invalid or expired refresh token",
+                    "404": {
+                        "description": "Invite not found",
                         "schema": {
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
@@ -208,12 +188,52 @@ const docTemplate = `{
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "/auth/register": {
-            "post": {
-                "description": "Create a new user account with email and password. A verification email will be sent.",
+        "/admin/maintenance": {
+            "get": {
+                "description": "Report whether this instance is in maintenance mode. Requires an admin outside any tenant.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get maintenance mode",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_http.MaintenanceResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin required",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "Turn maintenance mode on or off for this instance. While it is on, every route except health checks and admin routes answers 503. Requires an admin outside any tenant.",
                 "consumes": [
                     "application/json"
                 ],
@@ -221,51 +241,56 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Register a new user",
+                "summary": "Set maintenance mode",
                 "parameters": [
                     {
-                        "description": "Registration credentials",
+                        "description": "Maintenance mode state",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.RegisterRequest"
+                            "$ref": "#/definitions/internal_http.MaintenanceRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.RegisterResponse"
+                            "$ref": "#/definitions/internal_http.MaintenanceResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request or validation error",
+                        "description": "Invalid request body",
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorResponse"
                         }
                     },
-                    "409": {
-                        "description": "Email already exists",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "403": {
+                        "description": "Admin required",
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorResponse"
                         }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
             }
         },
-        "/auth/resend-verification": {
+        "/auth/forgot-password": {
             "post": {
-                "description": "Send a new verification email to the user. Always returns success to prevent email enumeration.",
+                "description": "Send a password reset link to the user's email. Always returns success to prevent email enumeration.",
                 "consumes": [
                     "application/json"
                 ],
@@ -275,7 +300,7 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Resend verification email",
+                "summary": "Request password reset",
                 "parameters": [
                     {
                         "description": "Email address",
@@ -283,8 +308,14 @@ const docTemplate = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.ResendVerificationRequest"
+                            "$ref": "#/definitions/internal_auth.ForgotPasswordRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for repeated keys",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -312,9 +343,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/reset-password": {
+        "/auth/login": {
             "post": {
-                "description": "Reset a user's password using a valid reset token",
+                "description": "Authenticate with an email or username (per LOGIN_IDENTIFIER) and password, and receive access and refresh tokens",
                 "consumes": [
                     "application/json"
                 ],
@@ -324,46 +355,67 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Reset password",
+                "summary": "User login",
                 "parameters": [
                     {
-                        "description": "Reset token and new password",
+                        "description": "Login credentials",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_auth.ResetPasswordRequest"
+                            "$ref": "#/definitions/internal_auth.LoginRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to \\",
+                        "name": "token_delivery",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/internal_auth.AuthTokens"
                         }
                     },
                     "400": {
-                        "description": "Invalid request or token",
+                        "description": "Invalid request body",
                         "schema": {
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Email not verified",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.EmailNotVerifiedResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
                             "$ref": "#/definitions/internal_auth.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Session store unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/auth/verify-email": {
-            "get": {
-                "description": "Verify a user's email address using the verification token sent via email",
+        "/auth/logout": {
+            "post": {
+                "description": "Logout user by revoking refresh token and clearing cookies",
                 "consumes": [
                     "application/json"
                 ],
@@ -373,14 +425,15 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Verify email address",
+                "summary": "User logout",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Verification token",
-                        "name": "token",
-                        "in": "query",
-                        "required": true
+                        "description": "Optional refresh token",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.RefreshRequest"
+                        }
                     }
                 ],
                 "responses": {
@@ -392,74 +445,797 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
-                    },
-                    "400": {
-                        "description": "Invalid, expired, or already used token",
-                        "schema": {
-                            "$ref": "#/definitions/internal_auth.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "$ref": "#/definitions/internal_auth.ErrorResponse"
-                        }
                     }
                 }
             }
         },
-        "/health": {
-            "get": {
-                "description": "Check if the API is running",
+        "/auth/logout-all": {
+            "post": {
+                "description": "Revoke every refresh token for the current user and clear cookies",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "auth"
+                ],
+                "summary": "Logout all sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.LogoutAllResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Session store unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/auth/magic-link": {
+            "post": {
+                "description": "Email a single-use login link. Always returns success to prevent email enumeration.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Request magic link",
+                "parameters": [
+                    {
+                        "description": "Email address",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.MagicLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/magic-link/verify": {
+            "get": {
+                "description": "Exchange a single-use magic link token for access and refresh tokens",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Verify magic link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Magic link token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to \\",
+                        "name": "token_delivery",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.AuthTokens"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing token",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid, expired, or already used token",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Session store unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/refresh": {
+            "post": {
+                "description": "Use a refresh token to get a new access token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh access token",
+                "parameters": [
+                    {
+                        "description": "Refresh token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.RefreshRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to \\",
+                        "name": "token_delivery",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.AuthTokens"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid, expired, inactive or IP-mismatched refresh token",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many refreshes for this user",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Session store unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/register": {
+            "post": {
+                "description": "Create a new user account with email and password. A verification email will be sent; with REGISTRATION_EMAIL_SYNC, email_sent reports whether it was.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Register a new user",
+                "parameters": [
+                    {
+                        "description": "Registration credentials",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.RegisterRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for repeated keys",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.RegisterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or validation error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Registration is closed, or the invite is missing or invalid",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Email or username already exists",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/resend-verification": {
+            "post": {
+                "description": "Send a new verification email to the user. Always returns success to prevent email enumeration.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Resend verification email",
+                "parameters": [
+                    {
+                        "description": "Email address",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ResendVerificationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/reset-password": {
+            "post": {
+                "description": "Reset a user's password using a valid reset token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Reset password",
+                "parameters": [
+                    {
+                        "description": "Reset token and new password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ResetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, validation error, or invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/sessions": {
+            "get": {
+                "description": "List the devices the current user is logged in on",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "List active sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_auth.Session"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Session store unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/auth/sessions/{id}": {
+            "delete": {
+                "description": "Log the current user out of one device",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Revoke a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid session ID",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Session not found",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Session store unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/auth/verify-email": {
+            "get": {
+                "description": "Verify a user's email address using the verification token sent via email. Prefer POST /auth/verify-email, which keeps the token out of URLs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Verify email address (legacy)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Verification token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid, expired, or already used token",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Verify a user's email address using the verification token sent via email",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Verify email address",
+                "parameters": [
+                    {
+                        "description": "Verification token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.VerifyEmailRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, or invalid, expired or already used token",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/login/begin": {
+            "post": {
+                "description": "Returns assertion options for a discoverable passkey login",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Begin passkey login",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_passkey.LoginBeginResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/login/finish": {
+            "post": {
+                "description": "Verifies the authenticator assertion and returns access and refresh tokens",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Finish passkey login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID from the begin step",
+                        "name": "session_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.AuthTokens"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid response, expired or failed ceremony",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Session store unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/register/begin": {
+            "post": {
+                "description": "Returns credential creation options for registering a passkey to the current user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Begin passkey registration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/auth/webauthn/register/finish": {
+            "post": {
+                "description": "Verifies the authenticator attestation and stores the new passkey",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webauthn"
+                ],
+                "summary": "Finish passkey registration",
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid response, expired or failed ceremony",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Check if the API is running and, when the refresh token store has a circuit breaker, whether it is open",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
                 ],
                 "summary": "Health check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/internal_http.HealthResponse"
                         }
                     }
                 }
             }
         },
-        "/test": {
+        "/meta/error-codes": {
             "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Test endpoint that requires authentication",
+                "description": "Every error.code value with its default message and the HTTP status it is usually sent with, for generating client error handling",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "test"
+                    "meta"
                 ],
-                "summary": "Protected test endpoint",
+                "summary": "List error codes",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorCodeInfo"
                             }
                         }
                     }
@@ -468,6 +1244,225 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "github_com_redmonkez12_go-api-template_internal_auth.AuthTokens": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "type": "string"
+                },
+                "expires_in": {
+                    "description": "seconds until access token expires",
+                    "type": "integer"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "token_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_redmonkez12_go-api-template_internal_auth.BreakerState": {
+            "type": "string",
+            "enum": [
+                "closed",
+                "open",
+                "half_open"
+            ],
+            "x-enum-comments": {
+                "BreakerClosed": "Calls go to the primary store",
+                "BreakerHalfOpen": "One trial call checks whether the primary store is back",
+                "BreakerOpen": "Calls skip the primary store"
+            },
+            "x-enum-descriptions": [
+                "Calls go to the primary store",
+                "Calls skip the primary store",
+                "One trial call checks whether the primary store is back"
+            ],
+            "x-enum-varnames": [
+                "BreakerClosed",
+                "BreakerOpen",
+                "BreakerHalfOpen"
+            ]
+        },
+        "github_com_redmonkez12_go-api-template_internal_auth.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_redmonkez12_go-api-template_internal_httputil.ErrorCode": {
+            "type": "string",
+            "enum": [
+                "UNAUTHORIZED",
+                "INVALID_REQUEST_BODY",
+                "VALIDATION_FAILED",
+                "TOO_MANY_REQUESTS",
+                "INTERNAL_ERROR",
+                "HTTPS_REQUIRED",
+                "REQUEST_CANCELED",
+                "REQUEST_TIMEOUT",
+                "SERVER_BUSY",
+                "MAINTENANCE",
+                "NOT_FOUND",
+                "METHOD_NOT_ALLOWED",
+                "IDEMPOTENCY_KEY_REUSED",
+                "IDEMPOTENCY_IN_PROGRESS",
+                "EMAIL_ALREADY_EXISTS",
+                "EMAIL_REQUIRED",
+                "PASSWORD_REQUIRED",
+                "PASSWORD_TOO_SHORT",
+                "PASSWORD_TOO_WEAK",
+                "INVALID_EMAIL_FORMAT",
+                "REGISTRATION_CLOSED",
+                "INVITE_REQUIRED",
+                "INVALID_INVITE",
+                "USERNAME_REQUIRED",
+                "INVALID_USERNAME",
+                "USERNAME_TAKEN",
+                "INVALID_CREDENTIALS",
+                "EMAIL_NOT_VERIFIED",
+                "REFRESH_TOKEN_REQUIRED",
+                "INVALID_REFRESH_TOKEN",
+                "SESSION_INACTIVE",
+                "REFRESH_TOKEN_IP_MISMATCH",
+                "AUTH_STORE_UNAVAILABLE",
+                "INVALID_SESSION_ID",
+                "SESSION_NOT_FOUND",
+                "INVALID_INVITE_ID",
+                "INVITE_NOT_FOUND",
+                "VERIFICATION_TOKEN_REQUIRED",
+                "VERIFICATION_FAILED",
+                "TOKEN_EXPIRED",
+                "ALREADY_VERIFIED",
+                "VERIFICATION_SUPERSEDED",
+                "INVALID_RESET_TOKEN",
+                "MAGIC_LINK_TOKEN_REQUIRED",
+                "INVALID_MAGIC_LINK_TOKEN",
+                "WEBAUTHN_SESSION_EXPIRED",
+                "WEBAUTHN_VERIFICATION_FAILED",
+                "INVALID_AUTH_HEADER",
+                "MISSING_AUTH",
+                "INVALID_TOKEN",
+                "INVALID_TOKEN_USER_ID",
+                "REAUTH_REQUIRED",
+                "ADMIN_REQUIRED",
+                "TENANT_REQUIRED",
+                "INVALID_TENANT",
+                "TENANT_MISMATCH",
+                "COOLDOWN_ACTIVE"
+            ],
+            "x-enum-varnames": [
+                "CodeUnauthorized",
+                "CodeInvalidRequestBody",
+                "CodeValidationFailed",
+                "CodeTooManyRequests",
+                "CodeInternalError",
+                "CodeHTTPSRequired",
+                "CodeRequestCanceled",
+                "CodeRequestTimeout",
+                "CodeServerBusy",
+                "CodeMaintenance",
+                "CodeNotFound",
+                "CodeMethodNotAllowed",
+                "CodeIdempotencyKeyReused",
+                "CodeIdempotencyInProgress",
+                "CodeEmailAlreadyExists",
+                "CodeEmailRequired",
+                "CodePasswordRequired",
+                "CodePasswordTooShort",
+                "CodePasswordTooWeak",
+                "CodeInvalidEmailFormat",
+                "CodeRegistrationClosed",
+                "CodeInviteRequired",
+                "CodeInvalidInvite",
+                "CodeUsernameRequired",
+                "CodeInvalidUsername",
+                "CodeUsernameTaken",
+                "CodeInvalidCredentials",
+                "CodeEmailNotVerified",
+                "CodeRefreshTokenRequired",
+                "CodeInvalidRefreshToken",
+                "CodeSessionInactive",
+                "CodeRefreshTokenIPMismatch",
+                "CodeAuthStoreUnavailable",
+                "CodeInvalidSessionID",
+                "CodeSessionNotFound",
+                "CodeInvalidInviteID",
+                "CodeInviteNotFound",
+                "CodeVerificationTokenRequired",
+                "CodeVerificationFailed",
+                "CodeTokenExpired",
+                "CodeAlreadyVerified",
+                "CodeVerificationSuperseded",
+                "CodeInvalidResetToken",
+                "CodeMagicLinkTokenRequired",
+                "CodeInvalidMagicLinkToken",
+                "CodeWebAuthnSessionExpired",
+                "CodeWebAuthnVerificationFailed",
+                "CodeInvalidAuthHeader",
+                "CodeMissingAuth",
+                "CodeInvalidToken",
+                "CodeInvalidTokenUserID",
+                "CodeReauthRequired",
+                "CodeAdminRequired",
+                "CodeTenantRequired",
+                "CodeInvalidTenant",
+                "CodeTenantMismatch",
+                "CodeCooldownActive"
+            ]
+        },
+        "github_com_redmonkez12_go-api-template_internal_httputil.ErrorCodeInfo": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorCode"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_redmonkez12_go-api-template_internal_httputil.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorCode"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "fields": {
+                    "description": "Set for VALIDATION_FAILED",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.FieldError"
+                    }
+                },
+                "request_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_redmonkez12_go-api-template_internal_httputil.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                }
+            }
+        },
         "internal_auth.AuthTokens": {
             "type": "object",
             "properties": {
@@ -486,6 +1481,67 @@ const docTemplate = `{
                 }
             }
         },
+        "internal_auth.CreateInviteRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "description": "Email binds the invite to one address; omit to let anyone use it",
+                    "type": "string"
+                },
+                "expires_in": {
+                    "description": "ExpiresIn is the invite lifetime in seconds; omit for the default",
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_auth.CreateInviteResponse": {
+            "type": "object",
+            "properties": {
+                "invite": {
+                    "$ref": "#/definitions/internal_auth.Invite"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_auth.EmailNotVerifiedResponse": {
+            "type": "object",
+            "properties": {
+                "can_resend_verification": {
+                    "description": "CanResendVerification tells the frontend to offer POST\n/auth/resend-verification; it is false while the address is on cooldown",
+                    "type": "boolean"
+                },
+                "code": {
+                    "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.ErrorCode"
+                },
+                "email": {
+                    "description": "Email is the account's address, so the frontend can resend without\nasking for it when the user logged in with a username",
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "fields": {
+                    "description": "Set for VALIDATION_FAILED",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_httputil.FieldError"
+                    }
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "resend_available_in": {
+                    "description": "ResendAvailableIn is the seconds left on the resend cooldown",
+                    "type": "integer"
+                },
+                "verification_sent": {
+                    "description": "VerificationSent is true when a fresh link was emailed automatically\n(AUTO_RESEND_VERIFICATION)",
+                    "type": "boolean"
+                }
+            }
+        },
         "internal_auth.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -502,14 +1558,74 @@ const docTemplate = `{
                 }
             }
         },
+        "internal_auth.Invite": {
+            "type": "object",
+            "properties": {
+                "consumed_at": {
+                    "type": "string"
+                },
+                "consumed_by": {
+                    "description": "User who registered with the invite",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "email": {
+                    "description": "If set, only this address may register with the invite",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "description": "Tenant the invite registers users in",
+                    "type": "string"
+                }
+            }
+        },
         "internal_auth.LoginRequest": {
             "type": "object",
             "properties": {
                 "email": {
+                    "description": "Email is accepted in place of Identifier for existing clients",
+                    "type": "string"
+                },
+                "identifier": {
+                    "description": "Identifier is the email or username, depending on LOGIN_IDENTIFIER",
                     "type": "string"
                 },
                 "password": {
                     "type": "string"
+                },
+                "remember_me": {
+                    "description": "RememberMe keeps the session across browser restarts. Omitted means true.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "internal_auth.LogoutAllResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "revoked_sessions": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_auth.MagicLinkRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
                 }
             }
         },
@@ -523,11 +1639,25 @@ const docTemplate = `{
         },
         "internal_auth.RegisterRequest": {
             "type": "object",
+            "required": [
+                "email",
+                "password"
+            ],
             "properties": {
                 "email": {
+                    "type": "string",
+                    "maxLength": 254
+                },
+                "invite_token": {
+                    "description": "InviteToken is required while registration is invite-only",
                     "type": "string"
                 },
                 "password": {
+                    "type": "string",
+                    "minLength": 8
+                },
+                "username": {
+                    "description": "Username is optional unless LOGIN_IDENTIFIER=username",
                     "type": "string"
                 }
             }
@@ -535,11 +1665,19 @@ const docTemplate = `{
         "internal_auth.RegisterResponse": {
             "type": "object",
             "properties": {
+                "email_sent": {
+                    "description": "Whether the verification email was sent; only returned with\nREGISTRATION_EMAIL_SYNC, as background sends have no outcome yet",
+                    "type": "boolean"
+                },
                 "message": {
                     "type": "string"
                 },
                 "user": {
                     "$ref": "#/definitions/internal_auth.UserResponse"
+                },
+                "verification_token": {
+                    "description": "Only returned with EXPOSE_TOKENS_DEV in dev",
+                    "type": "string"
                 }
             }
         },
@@ -553,15 +1691,43 @@ const docTemplate = `{
         },
         "internal_auth.ResetPasswordRequest": {
             "type": "object",
+            "required": [
+                "new_password",
+                "token"
+            ],
             "properties": {
                 "new_password": {
-                    "type": "string"
+                    "type": "string",
+                    "minLength": 8
                 },
                 "token": {
                     "type": "string"
                 }
             }
         },
+        "internal_auth.Session": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
         "internal_auth.UserResponse": {
             "type": "object",
             "properties": {
@@ -570,8 +1736,225 @@ const docTemplate = `{
                 },
                 "id": {
                     "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_auth.VerifyEmailRequest": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_http.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "refresh_token_fallback": {
+                    "description": "RefreshTokenFallback is true when refresh tokens go to the SQL fallback\nwhile the breaker is open",
+                    "type": "boolean"
+                },
+                "refresh_token_store": {
+                    "description": "RefreshTokenStore is the breaker's state: closed, open or half_open",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_redmonkez12_go-api-template_internal_auth.BreakerState"
+                        }
+                    ],
+                    "example": "closed"
+                },
+                "status": {
+                    "description": "\"api is running\", or \"degraded\" while the refresh token store's breaker\nisn't closed",
+                    "type": "string",
+                    "example": "api is running"
+                }
+            }
+        },
+        "internal_http.MaintenanceRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "internal_http.MaintenanceResponse": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "internal_passkey.LoginBeginResponse": {
+            "type": "object",
+            "properties": {
+                "options": {
+                    "$ref": "#/definitions/protocol.CredentialAssertion"
+                },
+                "session_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "protocol.AuthenticationExtensions": {
+            "type": "object",
+            "additionalProperties": {}
+        },
+        "protocol.AuthenticatorTransport": {
+            "type": "string",
+            "enum": [
+                "usb",
+                "nfc",
+                "ble",
+                "smart-card",
+                "hybrid",
+                "internal"
+            ],
+            "x-enum-varnames": [
+                "USB",
+                "NFC",
+                "BLE",
+                "SmartCard",
+                "Hybrid",
+                "Internal"
+            ]
+        },
+        "protocol.CredentialAssertion": {
+            "type": "object",
+            "properties": {
+                "mediation": {
+                    "$ref": "#/definitions/protocol.CredentialMediationRequirement"
+                },
+                "publicKey": {
+                    "$ref": "#/definitions/protocol.PublicKeyCredentialRequestOptions"
+                }
+            }
+        },
+        "protocol.CredentialDescriptor": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "CredentialID The ID of a credential to allow/disallow.",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "transports": {
+                    "description": "The authenticator transports that can be used.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/protocol.AuthenticatorTransport"
+                    }
+                },
+                "type": {
+                    "description": "The valid credential types.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/protocol.CredentialType"
+                        }
+                    ]
+                }
+            }
+        },
+        "protocol.CredentialMediationRequirement": {
+            "type": "string",
+            "enum": [
+                "",
+                "silent",
+                "optional",
+                "conditional",
+                "required"
+            ],
+            "x-enum-varnames": [
+                "MediationDefault",
+                "MediationSilent",
+                "MediationOptional",
+                "MediationConditional",
+                "MediationRequired"
+            ]
+        },
+        "protocol.CredentialType": {
+            "type": "string",
+            "enum": [
+                "public-key"
+            ],
+            "x-enum-varnames": [
+                "PublicKeyCredentialType"
+            ]
+        },
+        "protocol.PublicKeyCredentialHints": {
+            "type": "string",
+            "enum": [
+                "security-key",
+                "client-device",
+                "hybrid"
+            ],
+            "x-enum-varnames": [
+                "PublicKeyCredentialHintSecurityKey",
+                "PublicKeyCredentialHintClientDevice",
+                "PublicKeyCredentialHintHybrid"
+            ]
+        },
+        "protocol.PublicKeyCredentialRequestOptions": {
+            "type": "object",
+            "properties": {
+                "allowCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/protocol.CredentialDescriptor"
+                    }
+                },
+                "challenge": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "extensions": {
+                    "$ref": "#/definitions/protocol.AuthenticationExtensions"
+                },
+                "hints": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/protocol.PublicKeyCredentialHints"
+                    }
+                },
+                "rpId": {
+                    "type": "string"
+                },
+                "timeout": {
+                    "type": "integer"
+                },
+                "userVerification": {
+                    "$ref": "#/definitions/protocol.UserVerificationRequirement"
                 }
             }
+        },
+        "protocol.UserVerificationRequirement": {
+            "type": "string",
+            "enum": [
+                "required",
+                "preferred",
+                "discouraged"
+            ],
+            "x-enum-comments": {
+                "VerificationPreferred": "This is the default."
+            },
+            "x-enum-descriptions": [
+                "",
+                "This is the default.",
+                ""
+            ],
+            "x-enum-varnames": [
+                "VerificationRequired",
+                "VerificationPreferred",
+                "VerificationDiscouraged"
+            ]
         }
     },
     "securityDefinitions": {
@@ -588,7 +1971,7 @@ const docTemplate = `{
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
 	Host:             "localhost:8080",
-	BasePath:         "/",
+	BasePath:         "/v1",
 	Schemes:          []string{},
 	Title:            "Go API Template",
 	Description:      "A production-ready Go REST API template with authentication, email verification, and observability.",
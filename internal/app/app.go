@@ -0,0 +1,538 @@
+// Package app wires the API together: it builds the database and Redis
+// connections, repositories, services, handlers and router from the
+// configuration, and runs the HTTP server until asked to stop.
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+
+	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/config"
+	"github.com/redmonkez12/go-api-template/internal/database"
+	"github.com/redmonkez12/go-api-template/internal/email"
+	httpServer "github.com/redmonkez12/go-api-template/internal/http"
+	"github.com/redmonkez12/go-api-template/internal/idempotency"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/passkey"
+	"github.com/redmonkez12/go-api-template/internal/ratelimit"
+	"github.com/redmonkez12/go-api-template/internal/user"
+	"github.com/redmonkez12/go-api-template/migrations"
+)
+
+// App is the assembled API. Create it with New, then call Run.
+type App struct {
+	cfg         *config.Config
+	logger      *logging.Logger
+	db          *bun.DB
+	redisClient *redis.Client
+	rateLimiter *ratelimit.Limiter
+	maintenance *httpServer.Maintenance
+	authService *auth.Service
+	router      http.Handler
+	server      *httpServer.Server
+	hooks       []shutdownHook
+}
+
+// ShutdownHook is called during graceful shutdown, for example to tell a
+// control plane the instance is going away or to flush metrics. ctx carries
+// the shutdown deadline.
+type ShutdownHook func(ctx context.Context) error
+
+// shutdownHook is a ShutdownHook with the name its errors are reported under
+type shutdownHook struct {
+	name string
+	run  ShutdownHook
+}
+
+// Dependencies are components New uses instead of building its own, for
+// example fakes in tests. Nil fields are built from the configuration as
+// usual. The App takes ownership of DB and Redis and closes them on Shutdown.
+type Dependencies struct {
+	// DB is used as is, without waiting for it or configuring its pool
+	DB    *bun.DB
+	Redis *redis.Client
+
+	Users         user.RepositoryInterface
+	RefreshTokens auth.RefreshTokenRepository
+	Email         auth.EmailService
+}
+
+// New connects to the database and Redis and builds every component the API
+// needs, using those in deps where given. It waits for both connections to
+// come up within STARTUP_TIMEOUT, retrying as STARTUP_RETRY_ATTEMPTS and
+// STARTUP_RETRY_INTERVAL allow, and gives up early if ctx is canceled. On
+// error, connections opened so far are closed again.
+func New(ctx context.Context, cfg *config.Config, logger *logging.Logger, deps Dependencies) (*App, error) {
+	if cfg.Server.StartupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Server.StartupTimeout)
+		defer cancel()
+	}
+	retry := retryPolicy{
+		attempts: cfg.Server.StartupRetryAttempts,
+		interval: cfg.Server.StartupRetryInterval,
+	}
+
+	// Initialize database connection
+	db := deps.DB
+	if db == nil {
+		var err error
+		db, err = initDB(ctx, cfg.Database, logger, retry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize database: %w", err)
+		}
+		logger.Info("database connection pool configured",
+			"max_open_conns", cfg.Database.MaxOpenConns,
+			"max_idle_conns", cfg.Database.MaxIdleConns,
+			"conn_max_lifetime", cfg.Database.ConnMaxLifetime.String(),
+			"conn_max_idle_time", cfg.Database.ConnMaxIdleTime.String(),
+		)
+	}
+
+	// Apply pending migrations (AUTO_MIGRATE). New returns before the server
+	// exists, so nothing is served until the schema is current.
+	if cfg.Database.AutoMigrate {
+		applied, err := database.Migrate(context.WithoutCancel(ctx), db.DB, migrations.FS)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		logger.Info("database migrations applied", "applied", applied)
+	}
+
+	// Initialize Redis connection. With the in-memory store, Redis is only
+	// needed for one-time email tokens, invites, passkeys and idempotency, so
+	// the API can still start without it and doesn't wait for it.
+	redisClient := deps.Redis
+	if redisClient == nil {
+		redisClient = newRedisClient(cfg.Redis)
+	}
+	redisRetry := retry
+	if cfg.Redis.UsesMemoryStore() {
+		redisRetry.attempts = 1
+	}
+	if err := waitFor(ctx, logger, "Redis", redisRetry, func(ctx context.Context) error {
+		return pingRedis(ctx, redisClient)
+	}); err != nil {
+		if !cfg.Redis.UsesMemoryStore() {
+			redisClient.Close()
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize Redis: %w", err)
+		}
+		logger.Warn("Redis unavailable; password reset, email verification, magic links, invites, passkeys and idempotency keys will fail",
+			"error", err.Error(),
+		)
+	}
+
+	rateLimiter := newRateLimiter(cfg, redisClient)
+	maintenance := httpServer.NewMaintenance(cfg.Server.MaintenanceMode, cfg.Server.MaintenanceRetryAfter)
+
+	router, authService, err := newRouter(cfg, logger, db, redisClient, rateLimiter, maintenance, deps)
+	if err != nil {
+		rateLimiter.Close()
+		redisClient.Close()
+		db.Close()
+		return nil, err
+	}
+
+	return &App{
+		cfg:         cfg,
+		logger:      logger,
+		db:          db,
+		redisClient: redisClient,
+		rateLimiter: rateLimiter,
+		maintenance: maintenance,
+		authService: authService,
+		router:      router,
+		server: httpServer.NewServer(
+			":"+cfg.Server.Port,
+			router,
+			cfg.Server.ReadTimeout,
+			cfg.Server.WriteTimeout,
+		),
+	}, nil
+}
+
+// Handler returns the API's router, for serving it without a listener
+func (a *App) Handler() http.Handler {
+	return a.router
+}
+
+// Run serves HTTP until ctx is canceled, then shuts down gracefully within
+// SHUTDOWN_TIMEOUT. If the server fails it shuts down the same way and
+// returns the server's error. While it runs, SIGHUP reloads maintenance mode.
+func (a *App) Run(ctx context.Context) error {
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- a.server.Start()
+	}()
+
+	hangupCtx, stopHangups := context.WithCancel(ctx)
+	defer stopHangups()
+	go a.reloadOnHangup(hangupCtx)
+
+	select {
+	case err := <-serverErrors:
+		// Emails already queued still get to finish before connections close
+		if shutdownErr := a.shutdownWithin(ctx); shutdownErr != nil {
+			a.logger.Warn("shutdown after server error incomplete", "error", shutdownErr.Error())
+		}
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+		log.Println("Shutdown requested")
+		return a.shutdownWithin(ctx)
+	}
+}
+
+// shutdownWithin runs Shutdown bounded by SHUTDOWN_TIMEOUT. ctx's values are
+// kept but not its cancellation, which is usually what triggered shutdown.
+func (a *App) shutdownWithin(ctx context.Context) error {
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.cfg.Server.ShutdownTimeout)
+	defer cancel()
+	return a.Shutdown(shutdownCtx)
+}
+
+// OnShutdown registers hook to run during Shutdown, after in-flight requests
+// and emails have finished and before the database and Redis connections
+// close. Hooks run in the order they were registered; an error is reported
+// under name and doesn't stop later hooks. Register hooks before calling Run.
+// None are registered by default.
+func (a *App) OnShutdown(name string, hook ShutdownHook) {
+	a.hooks = append(a.hooks, shutdownHook{name: name, run: hook})
+}
+
+// reloadOnHangup re-reads MAINTENANCE_MODE each time the process gets SIGHUP,
+// until ctx is canceled
+func (a *App) reloadOnHangup(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	for {
+		select {
+		case <-hangups:
+			enabled := config.ReloadMaintenanceMode()
+			a.maintenance.Set(enabled)
+			a.logger.Warn("maintenance mode reloaded", "enabled", enabled)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Shutdown tears the API down in order: it stops the server and drains
+// in-flight requests (SHUTDOWN_HTTP_TIMEOUT), waits for emails still being
+// sent (SHUTDOWN_WORKER_TIMEOUT), runs the hooks registered with OnShutdown,
+// then closes the database and Redis connections. Each phase gets its own
+// timeout so a slow one can't starve the next, and ctx bounds the whole
+// sequence. Later phases run even if an earlier one timed out.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := shutdownPhase(ctx, a.cfg.Server.ShutdownHTTPTimeout, a.server.Shutdown); err != nil {
+		errs = append(errs, fmt.Errorf("HTTP server: %w", err))
+	}
+	if err := shutdownPhase(ctx, a.cfg.Server.ShutdownWorkerTimeout, a.authService.WaitForEmails); err != nil {
+		errs = append(errs, fmt.Errorf("email workers: %w", err))
+	}
+	for _, hook := range a.hooks {
+		if err := hook.run(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %s: %w", hook.name, err))
+		}
+	}
+	a.close()
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	return nil
+}
+
+// shutdownPhase runs one step of Shutdown with its own timeout, which can only
+// shorten the deadline ctx already has. A zero timeout leaves ctx as it is.
+func shutdownPhase(ctx context.Context, timeout time.Duration, stop func(context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return stop(ctx)
+}
+
+// close releases the connections opened by New and stops the in-memory rate
+// limiter's sweeper
+func (a *App) close() {
+	a.rateLimiter.Close()
+	if err := a.redisClient.Close(); err != nil {
+		a.logger.Warn("failed to close Redis client", "error", err.Error())
+	}
+	if err := a.db.Close(); err != nil {
+		a.logger.Warn("failed to close database", "error", err.Error())
+	}
+}
+
+// newRouter builds the repositories, services and handlers, other than those
+// in deps, and mounts them on the router. The auth service is returned too, so
+// shutdown can wait for the emails it sends in the background.
+func newRouter(cfg *config.Config, logger *logging.Logger, db *bun.DB, redisClient *redis.Client, rateLimiter *ratelimit.Limiter, maintenance *httpServer.Maintenance, deps Dependencies) (http.Handler, *auth.Service, error) {
+	// Initialize repositories
+	var userRepo user.RepositoryInterface = user.NewRepository(db)
+	if deps.Users != nil {
+		userRepo = deps.Users
+	}
+	passwordResetRepo := auth.NewPasswordResetRepository(redisClient, cfg.Auth.PasswordResetTTL)
+	magicLinkRepo := auth.NewMagicLinkRepository(redisClient)
+	verificationRepo := auth.NewVerificationTokenRepository(redisClient, cfg.Auth.EmailVerificationTTL)
+	inviteRepo := auth.NewInviteRepository(redisClient, cfg.Auth.InviteTTL)
+
+	// Refresh tokens and rate limits can be kept in memory for tests and
+	// single-instance deployments
+	var authRepo auth.RefreshTokenRepository
+	var authStoreBreaker *auth.BreakerRepository
+	switch {
+	case deps.RefreshTokens != nil:
+		authRepo = deps.RefreshTokens
+	case cfg.Redis.UsesMemoryStore():
+		logger.Warn("keeping refresh tokens and rate limits in memory; they are lost on restart and not shared between instances")
+		authRepo = auth.NewMemoryRepository()
+	case cfg.Redis.BreakerThreshold > 0:
+		// Fail fast with 503s, or keep refresh tokens in Postgres, while
+		// Redis is down
+		var fallback auth.RefreshTokenRepository
+		if cfg.Redis.RefreshTokenFallback == "sql" {
+			fallback = auth.NewRepository(db)
+		}
+		authStoreBreaker = auth.NewBreakerRepository(auth.NewRedisRepository(redisClient), fallback, logger, cfg.Redis.BreakerThreshold, cfg.Redis.BreakerCooldown)
+		authRepo = authStoreBreaker
+	default:
+		authRepo = auth.NewRedisRepository(redisClient)
+	}
+
+	// Initialize PASETO service
+	pasetoService, err := auth.NewPasetoService(cfg.Auth.PasetoKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize PASETO service: %w", err)
+	}
+
+	// Initialize email service
+	emailService := deps.Email
+	if emailService == nil {
+		emailService = newEmailService(cfg)
+	}
+
+	// claimsProvider adds custom claims, such as roles or scopes, to
+	// every access token. Assign one here; nil adds none.
+	var claimsProvider auth.ClaimsProvider
+
+	// Initialize auth service
+	authService := auth.NewService(
+		userRepo,
+		authRepo,
+		passwordResetRepo,
+		magicLinkRepo,
+		verificationRepo,
+		inviteRepo,
+		database.NewUnitOfWork(db),
+		rateLimiter,
+		pasetoService,
+		claimsProvider,
+		emailService,
+		logger,
+		cfg.Auth.AccessTokenDuration,
+		cfg.Auth.RefreshTokenDuration,
+		cfg.Auth.SessionRefreshDuration,
+		cfg.Auth.VerificationGracePeriod,
+		cfg.Auth.InactivityTimeout,
+		cfg.Auth.RefreshReuseGrace,
+		auth.IPBinding{
+			Enabled:       cfg.Auth.RefreshTokenBindIP,
+			IPv4PrefixLen: cfg.Auth.RefreshTokenBindIPv4Prefix,
+			IPv6PrefixLen: cfg.Auth.RefreshTokenBindIPv6Prefix,
+		},
+		cfg.Auth.RegistrationMode == "invite", // inviteOnly
+		cfg.Auth.RegistrationEmailSync,
+		auth.LoginIdentifier(cfg.Auth.LoginIdentifier),
+		cfg.Auth.PasswordMinScore,
+	)
+
+	// Initialize HTTP handlers. Cookies are Secure outside dev.
+	cookies := auth.CookieOptions{
+		Domain:   cfg.Auth.CookieDomain,
+		Path:     cfg.Auth.CookiePath,
+		SameSite: cookieSameSite(cfg.Auth.CookieSameSite),
+		Secure:   !cfg.Server.IsDevelopment(),
+	}
+	authHandler := auth.NewHandler(
+		authService,
+		rateLimiter,
+		ratelimit.LoginBackoff{
+			Enabled:   cfg.Auth.LoginBackoffEnabled,
+			BaseDelay: cfg.Auth.LoginBackoffBaseDelay,
+			MaxDelay:  cfg.Auth.LoginBackoffMaxDelay,
+		},
+		logger,
+		cookies,
+		cfg.Auth.AccessCookieMaxAge,
+		cfg.Auth.RefreshCookieMaxAge,
+		cfg.Auth.ExposeTokensDev && cfg.Server.IsDevelopment(), // exposeTokens
+		cfg.RateLimit.LoginFailuresOnly,
+		cfg.Auth.AutoResendVerification,
+	)
+	authMiddleware := auth.NewMiddleware(pasetoService, userRepo, auth.TenantResolver{
+		Mode:       auth.TenantMode(cfg.Auth.TenantMode),
+		Header:     cfg.Auth.TenantHeader,
+		BaseDomain: cfg.Auth.TenantBaseDomain,
+	})
+
+	// Initialize passkey (WebAuthn) support if enabled
+	var passkeyHandler *passkey.Handler
+	if cfg.WebAuthn.Enabled {
+		webAuthn, err := webauthn.New(&webauthn.Config{
+			RPID:          cfg.WebAuthn.RPID,
+			RPDisplayName: cfg.WebAuthn.RPDisplayName,
+			RPOrigins:     cfg.WebAuthn.RPOrigins,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize WebAuthn: %w", err)
+		}
+
+		passkeyService := passkey.NewService(
+			webAuthn,
+			passkey.NewCredentialRepository(db),
+			passkey.NewSessionStore(redisClient),
+			userRepo,
+			authService,
+			logger,
+		)
+		passkeyHandler = passkey.NewHandler(
+			passkeyService,
+			logger,
+			cookies,
+			cfg.Auth.AccessCookieMaxAge,
+			cfg.Auth.RefreshCookieMaxAge,
+		)
+	}
+
+	return httpServer.NewRouter(cfg, authHandler, authMiddleware, passkeyHandler, idempotency.NewStore(redisClient), maintenance, authStoreBreaker, logger), authService, nil
+}
+
+// cookieSameSite converts COOKIE_SAMESITE, already checked by Validate, to
+// its http.SameSite mode
+func cookieSameSite(mode string) http.SameSite {
+	switch mode {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// newEmailService creates the SMTP email service
+func newEmailService(cfg *config.Config) *email.Service {
+	return email.NewService(
+		email.SMTPConfig{
+			Host:               cfg.Email.SMTPHost,
+			Port:               cfg.Email.SMTPPort,
+			User:               cfg.Email.SMTPUser,
+			Password:           cfg.Email.SMTPPassword,
+			From:               cfg.Email.SMTPFrom,
+			FromName:           cfg.Email.FromName,
+			Encryption:         email.Encryption(cfg.Email.SMTPEncryption),
+			InsecureSkipVerify: cfg.Email.SMTPInsecureSkipVerify,
+			Timeout:            cfg.Email.SMTPTimeout,
+			MinTLSVersion:      cfg.Server.MinTLSVersion,
+		},
+		cfg.Email.FrontendURL,
+		cfg.Email.AppName,
+		cfg.Auth.EmailVerificationTTL,
+		cfg.Auth.PasswordResetTTL,
+	)
+}
+
+// newRateLimiter creates the rate limiter, in memory or in Redis to match
+// where refresh tokens are kept
+func newRateLimiter(cfg *config.Config, redisClient *redis.Client) *ratelimit.Limiter {
+	limiterConfig := ratelimit.LimiterConfig{
+		IPMax:         cfg.RateLimit.IPMax,
+		IPWindow:      cfg.RateLimit.IPWindow,
+		RefreshMax:    cfg.RateLimit.RefreshMax,
+		RefreshWindow: cfg.RateLimit.RefreshWindow,
+		EmailCooldown: cfg.RateLimit.EmailCooldown,
+		EmailCooldowns: map[string]time.Duration{
+			ratelimit.EmailPurposeVerification:  cfg.RateLimit.VerificationEmailCooldown,
+			ratelimit.EmailPurposePasswordReset: cfg.RateLimit.PasswordResetEmailCooldown,
+			ratelimit.EmailPurposeMagicLink:     cfg.RateLimit.MagicLinkEmailCooldown,
+		},
+		SweepInterval: cfg.RateLimit.SweepInterval,
+	}
+
+	if cfg.Redis.UsesMemoryStore() {
+		return ratelimit.NewMemoryLimiter(limiterConfig)
+	}
+	return ratelimit.NewLimiter(redisClient, limiterConfig)
+}
+
+// initDB initializes the database connection and returns a Bun DB instance,
+// waiting for the database to accept connections as retry allows
+func initDB(ctx context.Context, cfg config.DatabaseConfig, logger *logging.Logger, retry retryPolicy) (*bun.DB, error) {
+	sqlDB, err := sql.Open("postgres", cfg.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Verify connection
+	if err := waitFor(ctx, logger, "database", retry, func(ctx context.Context) error {
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping database: %w", err)
+		}
+		return nil
+	}); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	// Set connection pool settings
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	// Create Bun DB wrapper
+	db := bun.NewDB(sqlDB, pgdialect.New())
+
+	return db, nil
+}
+
+// newRedisClient creates a Redis client. It connects lazily; use pingRedis to
+// verify the connection.
+func newRedisClient(cfg config.RedisConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Address(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
+
+// pingRedis verifies the Redis connection
+func pingRedis(ctx context.Context, client *redis.Client) error {
+	if err := client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to ping Redis: %w", err)
+	}
+	return nil
+}
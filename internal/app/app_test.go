@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+
+	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/config"
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/user"
+)
+
+// fakeUsers and fakeEmails panic if called, so the tests must not reach them
+type fakeUsers struct{ user.RepositoryInterface }
+
+type fakeEmails struct{ auth.EmailService }
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	app, err := newApp(t)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return app
+}
+
+// newApp builds an App from the environment with fake dependencies
+func newApp(t *testing.T) (*App, error) {
+	t.Helper()
+	t.Setenv("PASETO_KEY", strings.Repeat("k", 32))
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sql.Open doesn't connect, and nothing here queries the database unless
+	// AUTO_MIGRATE is on. Port 1 refuses connections, so that fails fast.
+	sqlDB, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := miniredis.RunT(t)
+
+	return New(context.Background(), cfg, logging.NewLogger("json", slog.LevelError+1, logging.RedactOptions{}), Dependencies{
+		DB:            bun.NewDB(sqlDB, pgdialect.New()),
+		Redis:         redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		Users:         fakeUsers{},
+		RefreshTokens: auth.NewMemoryRepository(),
+		Email:         fakeEmails{},
+	})
+}
+
+func TestNewServesHealth(t *testing.T) {
+	app := newTestApp(t)
+	defer app.Shutdown(context.Background())
+
+	w := httptest.NewRecorder()
+	app.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /health = %d %s, want 200", w.Code, w.Body)
+	}
+}
+
+func TestShutdownRunsHooks(t *testing.T) {
+	app := newTestApp(t)
+
+	var ran []string
+	app.OnShutdown("first", func(ctx context.Context) error {
+		ran = append(ran, "first")
+		return nil
+	})
+	app.OnShutdown("second", func(ctx context.Context) error {
+		ran = append(ran, "second")
+		return nil
+	})
+
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if strings.Join(ran, ",") != "first,second" {
+		t.Errorf("hooks ran %q, want first then second", ran)
+	}
+}
+
+// serve sends a request through the app's full handler chain
+func serve(app *App, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	app.Handler().ServeHTTP(w, r)
+	return w
+}
+
+func TestDisabledEndpointsAreNotFound(t *testing.T) {
+	t.Setenv("ENDPOINT_LOGIN_ENABLED", "false")
+	t.Setenv("ENDPOINT_PASSWORD_RESET_ENABLED", "false")
+	app := newTestApp(t)
+	defer app.Shutdown(context.Background())
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/v1/auth/login", http.StatusNotFound},
+		{"/v1/auth/forgot-password", http.StatusNotFound},
+		{"/v1/auth/reset-password", http.StatusNotFound},
+		{"/v1/auth/register", http.StatusBadRequest}, // Enabled, so the malformed body is rejected
+	}
+	for _, tt := range tests {
+		if w := serve(app, http.MethodPost, tt.path, "{"); w.Code != tt.want {
+			t.Errorf("POST %s = %d %s, want %d", tt.path, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+func TestRoutingErrorsAreJSON(t *testing.T) {
+	app := newTestApp(t)
+	defer app.Shutdown(context.Background())
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		code   httputil.ErrorCode
+		allow  string
+	}{
+		{http.MethodGet, "/v1/nope", http.StatusNotFound, httputil.CodeNotFound, ""},
+		{http.MethodGet, "/v1/auth/login", http.StatusMethodNotAllowed, httputil.CodeMethodNotAllowed, "POST"},
+		{http.MethodPut, "/v1/auth/verify-email", http.StatusMethodNotAllowed, httputil.CodeMethodNotAllowed, "GET, POST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := serve(app, tt.method, tt.path, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var resp httputil.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Code != tt.code {
+				t.Errorf("code = %s, want %s", resp.Code, tt.code)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestAutoMigrateFailureAbortsStartup(t *testing.T) {
+	t.Setenv("AUTO_MIGRATE", "true")
+
+	app, err := newApp(t)
+	if err == nil {
+		app.Shutdown(context.Background())
+		t.Fatal("New() error = nil, want the migration failure")
+	}
+	if !strings.Contains(err.Error(), "failed to apply migrations") {
+		t.Errorf("New() error = %v, want one about migrations", err)
+	}
+}
+
+func TestShutdownHooksGetShutdownContext(t *testing.T) {
+	app := newTestApp(t)
+
+	type ctxKey struct{}
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey{}, "shutdown"), time.Minute)
+	defer cancel()
+
+	var sawValue, sawDeadline, laterRan bool
+	app.OnShutdown("notify", func(ctx context.Context) error {
+		sawValue = ctx.Value(ctxKey{}) == "shutdown"
+		_, sawDeadline = ctx.Deadline()
+		return errors.New("control plane unreachable")
+	})
+	app.OnShutdown("flush", func(ctx context.Context) error {
+		laterRan = true
+		return nil
+	})
+
+	err := app.Shutdown(ctx)
+	if !sawValue || !sawDeadline {
+		t.Errorf("hook context had value %v, deadline %v; want the shutdown context", sawValue, sawDeadline)
+	}
+	if !laterRan {
+		t.Error("a failing hook stopped the next one")
+	}
+	if err == nil || !strings.Contains(err.Error(), "shutdown hook notify") {
+		t.Errorf("Shutdown() error = %v, want the notify hook's failure", err)
+	}
+}
+
+func TestTenantAdminCannotToggleMaintenance(t *testing.T) {
+	t.Setenv("TENANT_MODE", "header")
+	t.Setenv("ADMIN_EMAILS", "acme:admin@acme.example")
+	app := newTestApp(t)
+	defer app.Shutdown(context.Background())
+
+	tokens, err := auth.NewPasetoService([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := tokens.CreateToken(uuid.New(), "acme", "admin@acme.example", true, time.Now(), time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveAsAdmin := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("X-Tenant-ID", "acme")
+		app.Handler().ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serveAsAdmin(http.MethodGet, "/v1/admin/invites", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET /v1/admin/invites as the tenant admin = %d %s, want 200", w.Code, w.Body)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		if w := serveAsAdmin(method, "/v1/admin/maintenance", `{"enabled":true}`); w.Code != http.StatusForbidden {
+			t.Errorf("%s /v1/admin/maintenance as a tenant admin = %d %s, want 403", method, w.Code, w.Body)
+		}
+	}
+	if app.maintenance.Enabled() {
+		t.Error("a tenant admin turned on maintenance mode for every tenant")
+	}
+}
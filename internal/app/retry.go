@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redmonkez12/go-api-template/internal/logging"
+)
+
+// maxRetryInterval caps the doubling delay between startup pings
+const maxRetryInterval = 30 * time.Second
+
+// retryPolicy says how long startup waits for a dependency to come up
+type retryPolicy struct {
+	attempts int           // Pings before giving up, at least 1
+	interval time.Duration // Delay before the first retry, doubled after each
+}
+
+// waitFor calls ping until it succeeds, retrying with exponential backoff as
+// policy allows, so the API can start alongside its database in
+// docker-compose instead of crash looping. Each failed attempt is logged.
+// It stops early, with ping's last error, once ctx ends.
+func waitFor(ctx context.Context, logger *logging.Logger, name string, policy retryPolicy, ping func(context.Context) error) error {
+	interval := policy.interval
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.attempts {
+			return fmt.Errorf("%s unavailable after %d attempts: %w", name, attempt, err)
+		}
+
+		logger.Warn("dependency not ready, retrying",
+			"dependency", name,
+			"attempt", attempt,
+			"max_attempts", policy.attempts,
+			"retry_in", interval.String(),
+			"error", err.Error(),
+		)
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up waiting for %s (%v): %w", name, ctx.Err(), err)
+		case <-timer.C:
+		}
+		interval = min(interval*2, maxRetryInterval)
+	}
+}
@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/redmonkez12/go-api-template/internal/logging"
+)
+
+// BreakerState is the state of a BreakerRepository's circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Calls go to the primary store
+	BreakerOpen     BreakerState = "open"      // Calls skip the primary store
+	BreakerHalfOpen BreakerState = "half_open" // One trial call checks whether the primary store is back
+)
+
+// BreakerRepository wraps a refresh token store, normally Redis, in a circuit
+// breaker. After threshold consecutive failures it stops calling the store
+// for cooldown, then lets one trial call through to see whether it has
+// recovered. Failed and skipped calls go to the fallback store if there is
+// one, and otherwise return ErrAuthStoreUnavailable, so clients get a 503
+// they can retry instead of waiting on a store that is down.
+//
+// While the primary store is down, sessions it holds get
+// ErrAuthStoreUnavailable rather than being treated as unknown, so clients
+// retry instead of logging out. Sessions started on the fallback keep working
+// once the primary is back: lookups that miss the primary try the fallback,
+// and revocations and session listings cover both stores.
+type BreakerRepository struct {
+	primary   RefreshTokenRepository
+	fallback  RefreshTokenRepository // Nil for none
+	logger    *logging.Logger
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int       // Consecutive failures while closed
+	openedAt time.Time // When the breaker last opened
+	probing  bool      // A half-open trial call is in flight
+}
+
+// NewBreakerRepository wraps primary in a circuit breaker that opens after
+// threshold consecutive failures and tries primary again after cooldown.
+// fallback may be nil.
+func NewBreakerRepository(primary, fallback RefreshTokenRepository, logger *logging.Logger, threshold int, cooldown time.Duration) *BreakerRepository {
+	return &BreakerRepository{
+		primary:   primary,
+		fallback:  fallback,
+		logger:    logger,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// State returns the breaker's current state. An open breaker whose cooldown
+// has passed reports half-open, as its next call will be a trial.
+func (b *BreakerRepository) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// HasFallback reports whether calls skipping the primary store go to a
+// fallback store
+func (b *BreakerRepository) HasFallback() bool {
+	return b.fallback != nil
+}
+
+// allow reports whether a call may go to the primary store. A half-open
+// breaker lets one trial call through at a time.
+func (b *BreakerRepository) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+	default:
+		return true
+	}
+	b.probing = true
+	return true
+}
+
+// record counts the outcome of a call to the primary store and reports
+// whether err was a failure of the store itself. Not-found errors are
+// answers, and a canceled request says nothing about the store.
+func (b *BreakerRepository) record(err error) bool {
+	canceled := errors.Is(err, context.Canceled)
+	failed := err != nil && !canceled &&
+		!errors.Is(err, ErrRefreshTokenNotFound) && !errors.Is(err, ErrSessionNotFound)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+		switch {
+		case failed:
+			b.open(err)
+		case !canceled:
+			b.failures = 0
+			b.setState(BreakerClosed)
+		}
+		return failed
+	}
+
+	if !failed {
+		if !canceled {
+			b.failures = 0
+		}
+		return false
+	}
+	b.failures++
+	if b.state == BreakerClosed && b.failures >= b.threshold {
+		b.open(err)
+	}
+	return true
+}
+
+// open trips the breaker after err. The caller must hold b.mu.
+func (b *BreakerRepository) open(err error) {
+	b.openedAt = time.Now()
+	b.failures = 0
+	b.setState(BreakerOpen)
+	b.logger.Error("refresh token store circuit breaker opened",
+		"error", err.Error(), "cooldown", b.cooldown.String(), "fallback", b.fallback != nil)
+}
+
+// setState moves the breaker to state, logging the change. The caller must
+// hold b.mu.
+func (b *BreakerRepository) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	if state == BreakerClosed {
+		b.logger.Info("refresh token store circuit breaker closed")
+	}
+	b.state = state
+}
+
+// breakerCall runs call against b's primary store unless the breaker is
+// open. Calls that skip or fail on the primary run against the fallback, or
+// without one return ErrAuthStoreUnavailable. primary reports whether the
+// result came from the primary store.
+func breakerCall[T any](b *BreakerRepository, call func(repo RefreshTokenRepository) (T, error)) (result T, primary bool, err error) {
+	if b.allow() {
+		result, err = call(b.primary)
+		if !b.record(err) {
+			return result, true, err
+		}
+		if b.fallback == nil {
+			return result, true, fmt.Errorf("%w: %w", ErrAuthStoreUnavailable, err)
+		}
+		b.logger.Warn("refresh token store failed, using fallback", "error", err.Error())
+	} else if b.fallback == nil {
+		return result, false, ErrAuthStoreUnavailable
+	}
+
+	result, err = call(b.fallback)
+	return result, false, err
+}
+
+// breakerDo is breakerCall for calls without a result
+func breakerDo(b *BreakerRepository, call func(repo RefreshTokenRepository) error) (primary bool, err error) {
+	_, primary, err = breakerCall(b, func(repo RefreshTokenRepository) (struct{}, error) {
+		return struct{}{}, call(repo)
+	})
+	return primary, err
+}
+
+// notFoundInFallback reports whether err is a not-found answer from the
+// fallback alone. The primary store may still have what was asked for, so
+// the answer is ErrAuthStoreUnavailable rather than not found.
+func notFoundInFallback(primary bool, err, notFound error) bool {
+	return !primary && errors.Is(err, notFound)
+}
+
+// StoreRefreshToken stores a token in the primary store, or in the fallback
+// while the primary is unavailable
+func (b *BreakerRepository) StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time, meta SessionMeta) error {
+	_, err := breakerDo(b, func(repo RefreshTokenRepository) error {
+		return repo.StoreRefreshToken(ctx, userID, token, expiresAt, meta)
+	})
+	return err
+}
+
+// GetRefreshToken looks a token up in the primary store, then in the
+// fallback if the primary doesn't have it
+func (b *BreakerRepository) GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	rt, primary, err := breakerCall(b, func(repo RefreshTokenRepository) (*RefreshToken, error) {
+		return repo.GetRefreshToken(ctx, token)
+	})
+	if notFoundInFallback(primary, err, ErrRefreshTokenNotFound) {
+		return nil, ErrAuthStoreUnavailable
+	}
+	if primary && b.fallback != nil && errors.Is(err, ErrRefreshTokenNotFound) {
+		return b.fallback.GetRefreshToken(ctx, token)
+	}
+	return rt, err
+}
+
+// RevokeRefreshToken revokes a token in whichever store has it
+func (b *BreakerRepository) RevokeRefreshToken(ctx context.Context, token string) error {
+	primary, err := breakerDo(b, func(repo RefreshTokenRepository) error {
+		return repo.RevokeRefreshToken(ctx, token)
+	})
+	if notFoundInFallback(primary, err, ErrRefreshTokenNotFound) {
+		return ErrAuthStoreUnavailable
+	}
+	if primary && b.fallback != nil && errors.Is(err, ErrRefreshTokenNotFound) {
+		return b.fallback.RevokeRefreshToken(ctx, token)
+	}
+	return err
+}
+
+// RevokeAllUserTokens revokes the user's tokens in both stores. It fails if
+// the primary store is unavailable, as tokens left active there would work
+// again once it is back.
+func (b *BreakerRepository) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) (int, error) {
+	revoked, primary, err := breakerCall(b, func(repo RefreshTokenRepository) (int, error) {
+		return repo.RevokeAllUserTokens(ctx, userID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !primary {
+		return 0, ErrAuthStoreUnavailable
+	}
+	if b.fallback == nil {
+		return revoked, nil
+	}
+
+	fallbackRevoked, err := b.fallback.RevokeAllUserTokens(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return revoked + fallbackRevoked, nil
+}
+
+// ListUserSessions lists the user's sessions from both stores, newest first.
+// While the primary store is unavailable only the fallback's are listed.
+func (b *BreakerRepository) ListUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	sessions, primary, err := breakerCall(b, func(repo RefreshTokenRepository) ([]Session, error) {
+		return repo.ListUserSessions(ctx, userID)
+	})
+	if err != nil || !primary || b.fallback == nil {
+		return sessions, err
+	}
+
+	fallbackSessions, err := b.fallback.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// A session rotated into the primary store after an outage may have a
+	// token in each; the primary's is the current one
+	listed := make(map[uuid.UUID]bool, len(sessions))
+	for _, s := range sessions {
+		listed[s.ID] = true
+	}
+	for _, s := range fallbackSessions {
+		if !listed[s.ID] {
+			sessions = append(sessions, s)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession revokes a session in both stores, returning
+// ErrSessionNotFound only if neither has it
+func (b *BreakerRepository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	primary, err := breakerDo(b, func(repo RefreshTokenRepository) error {
+		return repo.RevokeSession(ctx, userID, sessionID)
+	})
+	if notFoundInFallback(primary, err, ErrSessionNotFound) {
+		return ErrAuthStoreUnavailable
+	}
+	if !primary || b.fallback == nil || (err != nil && !errors.Is(err, ErrSessionNotFound)) {
+		return err
+	}
+
+	fallbackErr := b.fallback.RevokeSession(ctx, userID, sessionID)
+	if errors.Is(fallbackErr, ErrSessionNotFound) {
+		return err
+	}
+	return fallbackErr
+}
+
+// CleanupExpiredTokens removes expired tokens from both stores
+func (b *BreakerRepository) CleanupExpiredTokens(ctx context.Context) error {
+	primary, err := breakerDo(b, func(repo RefreshTokenRepository) error {
+		return repo.CleanupExpiredTokens(ctx)
+	})
+	if err != nil || !primary || b.fallback == nil {
+		return err
+	}
+	return b.fallback.CleanupExpiredTokens(ctx)
+}
+
+// ClaimRotation caches a rotation in the primary store. Without one to ask,
+// every rotation counts as claimed.
+func (b *BreakerRepository) ClaimRotation(ctx context.Context, oldToken string, tokens *AuthTokens, ttl time.Duration) (bool, error) {
+	claimed := true
+	err := b.rotationCall(func(cache RotationCache) error {
+		var err error
+		claimed, err = cache.ClaimRotation(ctx, oldToken, tokens, ttl)
+		return err
+	})
+	return claimed, err
+}
+
+// GetRotation returns a rotation cached in the primary store
+func (b *BreakerRepository) GetRotation(ctx context.Context, oldToken string) (*AuthTokens, error) {
+	var tokens *AuthTokens
+	err := b.rotationCall(func(cache RotationCache) error {
+		var err error
+		tokens, err = cache.GetRotation(ctx, oldToken)
+		return err
+	})
+	return tokens, err
+}
+
+// rotationCall runs call against the primary store's rotation cache, if it
+// has one, unless the breaker is open. The fallback caches no rotations, so
+// while the primary is unavailable refresh reuse gets no grace window.
+func (b *BreakerRepository) rotationCall(call func(cache RotationCache) error) error {
+	cache, ok := b.primary.(RotationCache)
+	if !ok || !b.allow() {
+		return nil
+	}
+	err := call(cache)
+	if b.record(err) {
+		return fmt.Errorf("%w: %w", ErrAuthStoreUnavailable, err)
+	}
+	return err
+}
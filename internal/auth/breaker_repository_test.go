@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+)
+
+// flakyStore is a refresh token store that fails every call while down
+type flakyStore struct {
+	*MemoryRepository
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+var errStoreDown = errors.New("connection refused")
+
+func (f *flakyStore) StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time, meta SessionMeta) error {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return errStoreDown
+	}
+	return f.MemoryRepository.StoreRefreshToken(ctx, userID, token, expiresAt, meta)
+}
+
+func (f *flakyStore) GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return nil, errStoreDown
+	}
+	return f.MemoryRepository.GetRefreshToken(ctx, token)
+}
+
+func newTestBreaker(primary, fallback RefreshTokenRepository, cooldown time.Duration) *BreakerRepository {
+	logger := logging.NewLogger("json", slog.LevelError+1, logging.RedactOptions{})
+	return NewBreakerRepository(primary, fallback, logger, 2, cooldown)
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyStore{MemoryRepository: NewMemoryRepository()}
+	b := newTestBreaker(primary, nil, 20*time.Millisecond)
+	expires := time.Now().Add(time.Hour)
+
+	primary.down.Store(true)
+	for i := range 2 {
+		err := b.StoreRefreshToken(ctx, uuid.New(), "t", expires, SessionMeta{})
+		if !errors.Is(err, ErrAuthStoreUnavailable) || !errors.Is(err, errStoreDown) {
+			t.Fatalf("call %d error = %v, want ErrAuthStoreUnavailable wrapping the store's error", i+1, err)
+		}
+	}
+	if state := b.State(); state != BreakerOpen {
+		t.Fatalf("State() = %s after 2 failures, want open", state)
+	}
+
+	calls := primary.calls.Load()
+	if err := b.StoreRefreshToken(ctx, uuid.New(), "t", expires, SessionMeta{}); !errors.Is(err, ErrAuthStoreUnavailable) {
+		t.Errorf("open breaker error = %v, want ErrAuthStoreUnavailable", err)
+	}
+	if primary.calls.Load() != calls {
+		t.Error("open breaker called the primary store")
+	}
+
+	primary.down.Store(false)
+	time.Sleep(30 * time.Millisecond)
+	if state := b.State(); state != BreakerHalfOpen {
+		t.Fatalf("State() = %s after the cooldown, want half_open", state)
+	}
+	if err := b.StoreRefreshToken(ctx, uuid.New(), "t", expires, SessionMeta{}); err != nil {
+		t.Fatalf("trial call error = %v", err)
+	}
+	if state := b.State(); state != BreakerClosed {
+		t.Errorf("State() = %s after a successful trial, want closed", state)
+	}
+}
+
+func TestBreakerNotFoundIsNotAFailure(t *testing.T) {
+	b := newTestBreaker(&flakyStore{MemoryRepository: NewMemoryRepository()}, nil, time.Minute)
+	for range 3 {
+		if _, err := b.GetRefreshToken(context.Background(), "missing"); !errors.Is(err, ErrRefreshTokenNotFound) {
+			t.Fatalf("GetRefreshToken() error = %v, want ErrRefreshTokenNotFound", err)
+		}
+	}
+	if state := b.State(); state != BreakerClosed {
+		t.Errorf("State() = %s, want closed", state)
+	}
+}
+
+func TestBreakerFallback(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyStore{MemoryRepository: NewMemoryRepository()}
+	b := newTestBreaker(primary, NewMemoryRepository(), time.Minute)
+	expires := time.Now().Add(time.Hour)
+
+	if err := b.StoreRefreshToken(ctx, uuid.New(), "before", expires, SessionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	primary.down.Store(true)
+	if err := b.StoreRefreshToken(ctx, uuid.New(), "during", expires, SessionMeta{}); err != nil {
+		t.Fatalf("StoreRefreshToken() with a fallback error = %v", err)
+	}
+
+	// Tokens the primary holds aren't reported missing while it is down
+	if _, err := b.GetRefreshToken(ctx, "before"); !errors.Is(err, ErrAuthStoreUnavailable) {
+		t.Errorf("GetRefreshToken(before) error = %v, want ErrAuthStoreUnavailable", err)
+	}
+	if _, err := b.GetRefreshToken(ctx, "during"); err != nil {
+		t.Errorf("GetRefreshToken(during) error = %v", err)
+	}
+
+	// Sessions started on the fallback survive the primary's recovery
+	primary.down.Store(false)
+	b.cooldown = 0
+	for _, token := range []string{"before", "during"} {
+		if _, err := b.GetRefreshToken(ctx, token); err != nil {
+			t.Errorf("GetRefreshToken(%s) after recovery error = %v", token, err)
+		}
+	}
+}
+
+func TestRespondServiceErrorMapsUnavailableStore(t *testing.T) {
+	logger := logging.NewLogger("json", slog.LevelError+1, logging.RedactOptions{})
+	w := httptest.NewRecorder()
+	respondServiceError(w, logger, "token refresh failed", fmt.Errorf("%w: %w", ErrAuthStoreUnavailable, errStoreDown))
+
+	var resp httputil.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || resp.Code != httputil.CodeAuthStoreUnavailable {
+		t.Errorf("got %d %s, want 503 %s", w.Code, resp.Code, httputil.CodeAuthStoreUnavailable)
+	}
+}
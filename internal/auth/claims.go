@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redmonkez12/go-api-template/internal/user"
+)
+
+// ErrReservedClaim is returned when extra claims try to set a claim the token
+// service sets itself
+var ErrReservedClaim = errors.New("claim is reserved")
+
+// reservedClaims are the registered PASETO claims and the ones CreateToken
+// sets. Extra claims can't overwrite them, so a provider can never change
+// whose token it is, its tenant or when it expires.
+var reservedClaims = map[string]bool{
+	"iss":            true,
+	"sub":            true,
+	"aud":            true,
+	"exp":            true,
+	"nbf":            true,
+	"iat":            true,
+	"jti":            true,
+	"user_id":        true,
+	"tenant_id":      true,
+	"email":          true,
+	"email_verified": true,
+	"auth_time":      true,
+}
+
+// ClaimsProvider computes extra access token claims for a user, such as
+// roles or scopes. It runs each time tokens are issued, refreshes
+// included, so the claims follow changes to the user. Values must encode as
+// JSON; returning a reserved claim fails token creation.
+type ClaimsProvider func(ctx context.Context, u *user.User) (map[string]any, error)
+
+// Claims returns p's claims for u, or nil if p is nil
+func (p ClaimsProvider) Claims(ctx context.Context, u *user.User) (map[string]any, error) {
+	if p == nil {
+		return nil, nil
+	}
+	claims, err := p(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get extra claims: %w", err)
+	}
+	return claims, nil
+}
+
+// checkExtraClaims rejects extra claims that use a reserved name
+func checkExtraClaims(extra map[string]any) error {
+	for name := range extra {
+		if reservedClaims[name] {
+			return fmt.Errorf("%w: %q", ErrReservedClaim, name)
+		}
+	}
+	return nil
+}
+
+// extraClaims returns the claims in a verified token that aren't reserved,
+// or nil if there are none
+func extraClaims(claims map[string]any) map[string]any {
+	var extra map[string]any
+	for name, value := range claims {
+		if reservedClaims[name] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]any)
+		}
+		extra[name] = value
+	}
+	return extra
+}
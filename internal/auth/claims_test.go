@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/redmonkez12/go-api-template/internal/user"
+)
+
+func TestPasetoExtraClaims(t *testing.T) {
+	tokens, err := NewPasetoService([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := tokens.CreateToken(uuid.New(), "", "user@example.com", true, time.Now(), time.Minute, map[string]any{"role": "admin"})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	claims, err := tokens.VerifyToken(token)
+	if err != nil {
+		t.Fatalf("VerifyToken() error = %v", err)
+	}
+	if claims.Extra["role"] != "admin" || len(claims.Extra) != 1 {
+		t.Errorf("Extra = %v, want only role admin", claims.Extra)
+	}
+
+	for name := range reservedClaims {
+		if _, err := tokens.CreateToken(uuid.New(), "", "user@example.com", true, time.Now(), time.Minute, map[string]any{name: "x"}); !errors.Is(err, ErrReservedClaim) {
+			t.Errorf("CreateToken(extra %s) error = %v, want ErrReservedClaim", name, err)
+		}
+	}
+}
+
+func TestClaimsProviderAddsClaimsOnLogin(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.service.claimsProvider = func(ctx context.Context, u *user.User) (map[string]any, error) {
+		return map[string]any{"scope": "read " + u.Email}, nil
+	}
+
+	u, verificationToken := env.register(t, "claims@example.com")
+	if err := env.service.VerifyEmail(ctx, verificationToken); err != nil {
+		t.Fatal(err)
+	}
+	login, err := env.service.Login(ctx, u.Email, "correct horse battery staple", SessionMeta{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	claims, err := env.service.tokenService.VerifyToken(login.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Extra["scope"] != "read claims@example.com" {
+		t.Errorf("Extra = %v, want the provider's scope", claims.Extra)
+	}
+}
+
+func TestClaimsProviderCantOverrideReservedClaims(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.service.claimsProvider = func(ctx context.Context, u *user.User) (map[string]any, error) {
+		return map[string]any{"user_id": uuid.NewString()}, nil
+	}
+
+	u, verificationToken := env.register(t, "claims@example.com")
+	if err := env.service.VerifyEmail(ctx, verificationToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.service.Login(ctx, u.Email, "correct horse battery staple", SessionMeta{}); !errors.Is(err, ErrReservedClaim) {
+		t.Errorf("Login() error = %v, want ErrReservedClaim", err)
+	}
+}
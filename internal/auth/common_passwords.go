@@ -0,0 +1,39 @@
+package auth
+
+import "strings"
+
+// commonPasswords are frequently leaked passwords and the words they're built
+// from, most common first. Digit runs, sequences and years are left to the
+// other matchers.
+const commonPasswords = `
+password 123456 qwerty iloveyou admin welcome monkey login abc123 dragon
+letmein football baseball master hello sunshine princess shadow superman
+trustno1 freedom whatever michael jennifer jordan hunter soccer batman
+charlie passw0rd starwars secret changeme access flower mustang killer
+pepper ginger cheese computer internet summer winter spring autumn love
+lovely loveme iloveu angel angels babygirl baby butterfly jessica ashley
+daniel thomas robert andrew joshua matthew anthony william david george
+harley ranger buster tigger hockey yankees cowboys dallas chelsea liverpool
+arsenal barcelona madrid pokemon naruto minecraft fortnite guitar music
+purple orange yellow silver golden diamond chocolate cookie banana apple
+coffee pizza qazwsx asdfgh zxcvbn qwertyuiop asdfghjkl zaq12wsx 1q2w3e4r
+1qaz2wsx qweasd default guest root user test tester testing demo system
+server office company business manager admin123 administrator support
+service private family friends forever together happy smile sweet honey
+sexy lucky magic dragons phoenix tiger lion eagle falcon wolf bear
+mother father sister brother daughter children nicole michelle jasmine
+samantha amanda melissa sarah hannah jasper maggie bailey buddy rocky
+shadow1 welcome1 password1 abcdef abcd1234 p@ssword hallo bonjour hola
+ciao hej privet
+`
+
+// commonPasswordRanks maps each common password to its rank, starting at 1
+var commonPasswordRanks = func() map[string]int {
+	ranks := make(map[string]int)
+	for i, word := range strings.Fields(commonPasswords) {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}()
@@ -11,48 +11,56 @@ const (
 	refreshTokenCookieName = "refresh_token"
 )
 
-// SetAuthCookies sets both access and refresh token cookies
-func SetAuthCookies(w http.ResponseWriter, accessToken, refreshToken string, isProduction bool, accessDuration, refreshDuration time.Duration) {
-	// Set access token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     accessTokenCookieName,
-		Value:    accessToken,
-		Path:     "/",
-		MaxAge:   int(accessDuration.Seconds()),
-		HttpOnly: true,
-		Secure:   isProduction, // Only send over HTTPS in production
-		SameSite: http.SameSiteLaxMode,
-	})
+// CookieOptions sets the attributes shared by the auth cookies, so they can be
+// scoped for a frontend on another subdomain (COOKIE_DOMAIN, COOKIE_PATH,
+// COOKIE_SAMESITE)
+type CookieOptions struct {
+	Domain   string // Empty scopes cookies to the API's host only
+	Path     string // Empty means "/"
+	SameSite http.SameSite
+	Secure   bool // Only send over HTTPS; always on with SameSite=None
+}
 
-	// Set refresh token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     refreshTokenCookieName,
-		Value:    refreshToken,
-		Path:     "/",
-		MaxAge:   int(refreshDuration.Seconds()),
+// newCookie builds an auth cookie with opts' attributes
+func (opts CookieOptions) newCookie(name, value string, maxAge int) *http.Cookie {
+	path := opts.Path
+	if path == "" {
+		path = "/"
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   opts.Domain,
+		Path:     path,
+		MaxAge:   maxAge,
 		HttpOnly: true,
-		Secure:   isProduction, // Only send over HTTPS in production
-		SameSite: http.SameSiteLaxMode,
-	})
+		// Browsers reject SameSite=None cookies that aren't Secure
+		Secure:   opts.Secure || opts.SameSite == http.SameSiteNoneMode,
+		SameSite: opts.SameSite,
+	}
 }
 
-// ClearAuthCookies expires both auth cookies immediately
-func ClearAuthCookies(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     accessTokenCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+// SetAuthCookies sets both access and refresh token cookies. A zero max-age
+// makes a session cookie that the browser drops when it closes.
+func SetAuthCookies(w http.ResponseWriter, accessToken, refreshToken string, opts CookieOptions, accessMaxAge, refreshMaxAge time.Duration) {
+	http.SetCookie(w, opts.newCookie(accessTokenCookieName, accessToken, cookieMaxAge(accessMaxAge)))
+	http.SetCookie(w, opts.newCookie(refreshTokenCookieName, refreshToken, cookieMaxAge(refreshMaxAge)))
+}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     refreshTokenCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+// cookieMaxAge converts a lifetime to a cookie Max-Age. Anything under a second
+// leaves Max-Age unset, since a negative value would delete the cookie.
+func cookieMaxAge(d time.Duration) int {
+	if d < time.Second {
+		return 0
+	}
+	return int(d.Seconds())
+}
+
+// ClearAuthCookies expires both auth cookies immediately. opts must match the
+// ones the cookies were set with, or the browser keeps them.
+func ClearAuthCookies(w http.ResponseWriter, opts CookieOptions) {
+	http.SetCookie(w, opts.newCookie(accessTokenCookieName, "", -1))
+	http.SetCookie(w, opts.newCookie(refreshTokenCookieName, "", -1))
 }
 
 // ShouldUseCookies determines if the request should receive cookies
@@ -61,6 +69,28 @@ func ShouldUseCookies(r *http.Request) bool {
 	return r.Header.Get("Origin") != ""
 }
 
+// TokenDeliveryHeader lets a client choose how tokens are delivered. The
+// token_delivery query parameter does the same for clients that can't set headers.
+const TokenDeliveryHeader = "X-Token-Delivery"
+
+// tokenDeliveryBoth asks for cookies and the token body together, for hybrid
+// clients such as mobile webviews moving between storage strategies
+const tokenDeliveryBoth = "both"
+
+// ShouldReturnTokensInBody determines if the tokens belong in the response body.
+// Non-browser clients always get them; browsers only when they ask for both.
+func ShouldReturnTokensInBody(r *http.Request) bool {
+	if !ShouldUseCookies(r) {
+		return true
+	}
+
+	delivery := r.Header.Get(TokenDeliveryHeader)
+	if delivery == "" {
+		delivery = r.URL.Query().Get("token_delivery")
+	}
+	return delivery == tokenDeliveryBoth
+}
+
 // GetAccessTokenFromCookie retrieves the access token from cookies
 func GetAccessTokenFromCookie(r *http.Request) (string, error) {
 	cookie, err := r.Cookie(accessTokenCookieName)
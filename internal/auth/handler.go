@@ -1,50 +1,76 @@
 package auth
 
 import (
-	"encoding/json"
+	"cmp"
 	"errors"
+	"io"
+	"math"
+	"net"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/redmonkez12/go-api-template/internal/httputil"
 	"github.com/redmonkez12/go-api-template/internal/logging"
 	"github.com/redmonkez12/go-api-template/internal/ratelimit"
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
 	"github.com/redmonkez12/go-api-template/internal/user"
 )
 
 // Handler contains HTTP handlers for authentication endpoints
 type Handler struct {
-	service          *Service
-	rateLimiter      *ratelimit.Limiter
-	logger           *logging.Logger
-	isProduction     bool
-	accessDuration   time.Duration
-	refreshDuration  time.Duration
+	service             *Service
+	rateLimiter         *ratelimit.Limiter
+	loginBackoff        ratelimit.LoginBackoff
+	logger              *logging.Logger
+	cookies             CookieOptions
+	accessCookieMaxAge  time.Duration
+	refreshCookieMaxAge time.Duration
+	// Include email tokens in responses for end-to-end tests (EXPOSE_TOKENS_DEV)
+	exposeTokens bool
+	// Count only failed logins toward the IP limit (RATE_LIMIT_LOGIN_FAILURES_ONLY)
+	countFailedLoginsOnly bool
+	// Email a fresh verification link on unverified logins (AUTO_RESEND_VERIFICATION)
+	autoResendVerification bool
 }
 
-func NewHandler(service *Service, rateLimiter *ratelimit.Limiter, logger *logging.Logger, isProduction bool, accessDuration, refreshDuration time.Duration) *Handler {
+func NewHandler(service *Service, rateLimiter *ratelimit.Limiter, loginBackoff ratelimit.LoginBackoff, logger *logging.Logger, cookies CookieOptions, accessCookieMaxAge, refreshCookieMaxAge time.Duration, exposeTokens, countFailedLoginsOnly, autoResendVerification bool) *Handler {
 	return &Handler{
-		service:          service,
-		rateLimiter:      rateLimiter,
-		logger:           logger,
-		isProduction:     isProduction,
-		accessDuration:   accessDuration,
-		refreshDuration:  refreshDuration,
+		service:                service,
+		rateLimiter:            rateLimiter,
+		loginBackoff:           loginBackoff,
+		logger:                 logger,
+		cookies:                cookies,
+		accessCookieMaxAge:     accessCookieMaxAge,
+		refreshCookieMaxAge:    refreshCookieMaxAge,
+		exposeTokens:           exposeTokens,
+		countFailedLoginsOnly:  countFailedLoginsOnly,
+		autoResendVerification: autoResendVerification,
 	}
 }
 
 // RegisterRequest represents the registration request body
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,max=254,email"`
+	Password string `json:"password" validate:"required,min=8"`
+	// Username is optional unless LOGIN_IDENTIFIER=username
+	Username string `json:"username,omitempty"`
+	// InviteToken is required while registration is invite-only
+	InviteToken string `json:"invite_token,omitempty"`
 }
 
 // LoginRequest represents the login request body
 type LoginRequest struct {
-	Email    string `json:"email"`
+	// Identifier is the email or username, depending on LOGIN_IDENTIFIER
+	Identifier string `json:"identifier,omitempty"`
+	// Email is accepted in place of Identifier for existing clients
+	Email    string `json:"email,omitempty"`
 	Password string `json:"password"`
+	// RememberMe keeps the session across browser restarts. Omitted means true.
+	RememberMe *bool `json:"remember_me,omitempty"`
 }
 
 // RefreshRequest represents the token refresh request body
@@ -57,16 +83,40 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// EmailNotVerifiedResponse is the 403 body for a login blocked until the email
+// is verified. It is only sent after the password checked out, so it reveals
+// nothing about the account to someone who doesn't know it.
+type EmailNotVerifiedResponse struct {
+	httputil.ErrorResponse
+	// Email is the account's address, so the frontend can resend without
+	// asking for it when the user logged in with a username
+	Email string `json:"email,omitempty"`
+	// CanResendVerification tells the frontend to offer POST
+	// /auth/resend-verification; it is false while the address is on cooldown
+	CanResendVerification bool `json:"can_resend_verification"`
+	// ResendAvailableIn is the seconds left on the resend cooldown
+	ResendAvailableIn int `json:"resend_available_in,omitempty"`
+	// VerificationSent is true when a fresh link was emailed automatically
+	// (AUTO_RESEND_VERIFICATION)
+	VerificationSent bool `json:"verification_sent"`
+}
+
 // UserResponse represents a user in API responses
 type UserResponse struct {
-	ID    uuid.UUID `json:"id"`
-	Email string    `json:"email"`
+	ID       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Username *string   `json:"username,omitempty"`
 }
 
 // RegisterResponse represents the registration response
 type RegisterResponse struct {
 	User    UserResponse `json:"user"`
 	Message string       `json:"message"`
+	// Whether the verification email was sent; only returned with
+	// REGISTRATION_EMAIL_SYNC, as background sends have no outcome yet
+	EmailSent *bool `json:"email_sent,omitempty"`
+	// Only returned with EXPOSE_TOKENS_DEV in dev
+	VerificationToken string `json:"verification_token,omitempty"`
 }
 
 // VerifyEmailRequest represents the email verification request
@@ -81,8 +131,8 @@ type ForgotPasswordRequest struct {
 
 // ResetPasswordRequest represents the password reset confirmation
 type ResetPasswordRequest struct {
-	Token       string `json:"token"`
-	NewPassword string `json:"new_password"`
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
 // ResendVerificationRequest represents the resend verification email request
@@ -90,164 +140,323 @@ type ResendVerificationRequest struct {
 	Email string `json:"email"`
 }
 
+// MagicLinkRequest represents the passwordless login link request
+type MagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
 // Register handles user registration
 // @Summary      Register a new user
-// @Description  Create a new user account with email and password. A verification email will be sent.
+// @Description  Create a new user account with email and password. A verification email will be sent; with REGISTRATION_EMAIL_SYNC, email_sent reports whether it was.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request body RegisterRequest true "Registration credentials"
+// @Param        Idempotency-Key header string false "Replays the first response for repeated keys"
 // @Success      201 {object} RegisterResponse
-// @Failure      400 {object} ErrorResponse "Invalid request or validation error"
-// @Failure      409 {object} ErrorResponse "Email already exists"
+// @Failure      400 {object} httputil.ErrorResponse "Invalid request body or validation error"
+// @Failure      403 {object} ErrorResponse "Registration is closed, or the invite is missing or invalid"
+// @Failure      409 {object} ErrorResponse "Email or username already exists"
 // @Failure      500 {object} ErrorResponse "Internal server error"
 // @Router       /auth/register [post]
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLoggerFromContext(r.Context())
 
 	// Rate limit by IP
-	ip := getClientIP(r)
-	exceeded, err := h.rateLimiter.CheckIPRateLimitWithPurpose(r.Context(), ip, "register")
+	ip := GetClientIP(r)
+	limit, err := h.rateLimiter.Allow(r.Context(), ip, "register")
 	if err != nil {
 		logger.Error("failed to check IP rate limit", "error", err.Error())
-	} else if exceeded {
+	} else if !limit.Allowed {
 		logger.Warn("IP rate limit exceeded for register", "ip", ip)
-		respondError(w, "too many requests, please try again later", httputil.CodeTooManyRequests, http.StatusTooManyRequests)
+		httputil.RespondError(w, httputil.CodeTooManyRequests, http.StatusTooManyRequests)
 		return
 	}
 
 	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Warn("invalid registration request body", "error", err.Error())
-		respondError(w, "invalid request body", httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+	if err := httputil.BindAndValidate(w, r, &req); err != nil {
+		logger.Warn("invalid registration request", "error", err.Error())
+		respondBindError(w, err)
 		return
 	}
 
 	logger = logger.WithFields(map[string]any{"email": req.Email})
 
-	// Record IP request for rate limiting
-	if err := h.rateLimiter.RecordIPRequestWithPurpose(r.Context(), ip, "register"); err != nil {
-		logger.Error("failed to record IP request", "error", err.Error())
-	}
-
 	// Register user
-	newUser, err := h.service.Register(r.Context(), req.Email, req.Password)
+	registration, err := h.service.Register(r.Context(), req.Email, req.Username, req.Password, req.InviteToken)
 	if err != nil {
 		if errors.Is(err, user.ErrDuplicateEmail) {
 			logger.Warn("registration failed: email already exists")
-			respondError(w, "email already exists", httputil.CodeEmailAlreadyExists, http.StatusConflict)
+			httputil.RespondError(w, httputil.CodeEmailAlreadyExists, http.StatusConflict)
 			return
 		}
 		if errors.Is(err, ErrEmailRequired) {
 			logger.Warn("registration failed: validation error", "error", err.Error())
-			respondError(w, err.Error(), httputil.CodeEmailRequired, http.StatusBadRequest)
+			httputil.RespondError(w, httputil.CodeEmailRequired, http.StatusBadRequest)
 			return
 		}
 		if errors.Is(err, ErrPasswordRequired) {
 			logger.Warn("registration failed: validation error", "error", err.Error())
-			respondError(w, err.Error(), httputil.CodePasswordRequired, http.StatusBadRequest)
+			httputil.RespondError(w, httputil.CodePasswordRequired, http.StatusBadRequest)
 			return
 		}
 		if errors.Is(err, ErrPasswordTooShort) {
 			logger.Warn("registration failed: validation error", "error", err.Error())
-			respondError(w, err.Error(), httputil.CodePasswordTooShort, http.StatusBadRequest)
+			httputil.RespondError(w, httputil.CodePasswordTooShort, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrPasswordTooWeak) {
+			logger.Warn("registration failed: validation error", "error", err.Error())
+			httputil.RespondErrorWithCode(w, err.Error(), httputil.CodePasswordTooWeak, http.StatusBadRequest)
 			return
 		}
 		if errors.Is(err, ErrInvalidEmailFormat) {
 			logger.Warn("registration failed: validation error", "error", err.Error())
-			respondError(w, err.Error(), httputil.CodeInvalidEmailFormat, http.StatusBadRequest)
+			httputil.RespondError(w, httputil.CodeInvalidEmailFormat, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, user.ErrDuplicateUsername) {
+			logger.Warn("registration failed: username already taken")
+			httputil.RespondError(w, httputil.CodeUsernameTaken, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, ErrUsernameRequired) {
+			logger.Warn("registration failed: validation error", "error", err.Error())
+			httputil.RespondError(w, httputil.CodeUsernameRequired, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrInvalidUsername) {
+			logger.Warn("registration failed: validation error", "error", err.Error())
+			httputil.RespondError(w, httputil.CodeInvalidUsername, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrInviteRequired) {
+			logger.Warn("registration failed: no invite")
+			httputil.RespondError(w, httputil.CodeInviteRequired, http.StatusForbidden)
 			return
 		}
-		logger.Error("registration failed: internal error", "error", err.Error())
-		respondError(w, "failed to register user", httputil.CodeInternalError, http.StatusInternalServerError)
+		if errors.Is(err, ErrInvalidInvite) {
+			logger.Warn("registration failed: invalid invite")
+			httputil.RespondError(w, httputil.CodeInvalidInvite, http.StatusForbidden)
+			return
+		}
+		httputil.RespondInternalError(w, logger, "registration failed: internal error", err)
 		return
 	}
 
+	newUser := registration.User
 	logger.Info("user registered successfully", "user_id", newUser.ID)
 
 	userResponse := UserResponse{
-		ID:    newUser.ID,
-		Email: newUser.Email,
+		ID:       newUser.ID,
+		Email:    newUser.Email,
+		Username: newUser.Username,
 	}
 
-	respondJSON(w, RegisterResponse{
-		User:    userResponse,
-		Message: "Registration successful. Please check your email to verify your account.",
-	}, http.StatusCreated)
+	resp := RegisterResponse{
+		User:      userResponse,
+		Message:   "Registration successful. Please check your email to verify your account.",
+		EmailSent: registration.EmailSent,
+	}
+	if registration.EmailSent != nil && !*registration.EmailSent {
+		resp.Message = "Registration successful, but the verification email could not be sent. Please request a new one."
+	}
+	if h.exposeTokens {
+		resp.VerificationToken = registration.VerificationToken
+	}
+
+	respondJSON(w, resp, http.StatusCreated)
 }
 
 // Login handles user login
 // @Summary      User login
-// @Description  Authenticate user and receive access and refresh tokens
+// @Description  Authenticate with an email or username (per LOGIN_IDENTIFIER) and password, and receive access and refresh tokens
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request body LoginRequest true "Login credentials"
+// @Param        token_delivery query string false "Set to \"both\" to also get tokens in the body when cookies are set"
 // @Success      200 {object} AuthTokens
 // @Failure      400 {object} ErrorResponse "Invalid request body"
 // @Failure      401 {object} ErrorResponse "Invalid credentials"
+// @Failure      403 {object} EmailNotVerifiedResponse "Email not verified"
 // @Failure      500 {object} ErrorResponse "Internal server error"
+// @Failure      503 {object} ErrorResponse "Session store unavailable"
 // @Router       /auth/login [post]
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLoggerFromContext(r.Context())
 
 	// Rate limit by IP
-	ip := getClientIP(r)
-	exceeded, err := h.rateLimiter.CheckIPRateLimitWithPurpose(r.Context(), ip, "login")
+	meta := SessionMetaFromRequest(r)
+	ip := meta.IP
+	limit, err := h.checkLoginLimit(r, ip)
 	if err != nil {
 		logger.Error("failed to check IP rate limit", "error", err.Error())
-	} else if exceeded {
+	} else if !limit.Allowed {
 		logger.Warn("IP rate limit exceeded for login", "ip", ip)
-		respondError(w, "too many requests, please try again later", httputil.CodeTooManyRequests, http.StatusTooManyRequests)
+		httputil.RespondError(w, httputil.CodeTooManyRequests, http.StatusTooManyRequests)
 		return
 	}
 
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := httputil.DecodeJSON(w, r, &req); err != nil {
 		logger.Warn("invalid login request body", "error", err.Error())
-		respondError(w, "invalid request body", httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		h.countFailedLogin(r, logger, ip)
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
 		return
 	}
 
-	logger = logger.WithFields(map[string]any{"email": req.Email})
+	identifier := cmp.Or(req.Identifier, req.Email)
+	logger = logger.WithFields(map[string]any{"identifier": identifier})
 
-	// Record IP request for rate limiting
-	if err := h.rateLimiter.RecordIPRequestWithPurpose(r.Context(), ip, "login"); err != nil {
-		logger.Error("failed to record IP request", "error", err.Error())
-	}
+	meta.SessionOnly = req.RememberMe != nil && !*req.RememberMe
 
-	tokens, err := h.service.Login(r.Context(), req.Email, req.Password)
+	tokens, err := h.service.Login(r.Context(), identifier, req.Password, meta)
 	if err != nil {
 		if errors.Is(err, ErrInvalidCredentials) {
 			logger.Warn("login failed: invalid credentials")
-			respondError(w, "invalid email or password", httputil.CodeInvalidCredentials, http.StatusUnauthorized)
+			h.countFailedLogin(r, logger, ip)
+			if !h.delayFailedLogin(r, logger, identifier, ip) {
+				return
+			}
+			httputil.RespondError(w, httputil.CodeInvalidCredentials, http.StatusUnauthorized)
 			return
 		}
 		if errors.Is(err, ErrEmailNotVerified) {
 			logger.Warn("login failed: email not verified")
-			respondError(w, "email not verified, please check your inbox", httputil.CodeEmailNotVerified, http.StatusForbidden)
+			h.respondEmailNotVerified(w, r, logger, identifier)
 			return
 		}
-		logger.Error("login failed: internal error", "error", err.Error())
-		respondError(w, "failed to login", httputil.CodeInternalError, http.StatusInternalServerError)
+		respondServiceError(w, logger, "login failed: internal error", err)
 		return
 	}
 
-	logger.Info("user logged in successfully")
+	logger.Info("user logged in successfully", "ip", meta.IP, "user_agent", meta.UserAgent)
+
+	if h.loginBackoff.Enabled {
+		if err := h.rateLimiter.ResetLoginFailures(r.Context(), contextTenantID(r.Context()), identifier); err != nil {
+			logger.Error("failed to reset login failures", "error", err.Error())
+		}
+	}
 
 	// Set cookies if request is from browser
 	if ShouldUseCookies(r) {
-		SetAuthCookies(w, tokens.AccessToken, tokens.RefreshToken, h.isProduction, h.accessDuration, h.refreshDuration)
+		h.setAuthCookies(w, tokens)
+	}
+
+	if ShouldReturnTokensInBody(r) {
+		// Return tokens in response body for non-browser and hybrid clients
+		respondJSON(w, tokens, http.StatusOK)
+	} else {
 		// Don't return tokens in response body when using cookies
 		respondJSON(w, map[string]string{
 			"message": "logged in successfully",
 		}, http.StatusOK)
-	} else {
-		// Return tokens in response body for non-browser clients
-		respondJSON(w, tokens, http.StatusOK)
 	}
 }
 
+// respondEmailNotVerified answers a login blocked on verification, first
+// emailing a fresh link when AUTO_RESEND_VERIFICATION is on and the address
+// isn't on the verification email cooldown that POST /auth/resend-verification
+// also uses
+func (h *Handler) respondEmailNotVerified(w http.ResponseWriter, r *http.Request, logger *logging.Logger, identifier string) {
+	resp := EmailNotVerifiedResponse{
+		ErrorResponse: httputil.ErrorResponse{
+			Error:     httputil.CodeEmailNotVerified.Message(),
+			Code:      httputil.CodeEmailNotVerified,
+			RequestID: w.Header().Get(httputil.RequestIDHeader),
+		},
+		CanResendVerification: true,
+	}
+
+	email, err := h.service.LoginEmail(r.Context(), identifier)
+	if err != nil {
+		logger.Error("failed to look up email for verification resend", "error", err.Error())
+		respondJSON(w, resp, http.StatusForbidden)
+		return
+	}
+	resp.Email = email
+
+	if h.autoResendVerification {
+		resp.VerificationSent = h.resendVerificationOnLogin(r, logger, email)
+	}
+
+	// Report the cooldown, including one an automatic resend just started
+	remaining, err := h.rateLimiter.EmailCooldownRemaining(r.Context(), contextTenantID(r.Context()), email, ratelimit.EmailPurposeVerification)
+	if err != nil {
+		logger.Error("failed to check email cooldown", "error", err.Error())
+	} else if remaining > 0 {
+		resp.CanResendVerification = false
+		resp.ResendAvailableIn = int(math.Ceil(remaining.Seconds()))
+	}
+
+	respondJSON(w, resp, http.StatusForbidden)
+}
+
+// resendVerificationOnLogin emails a fresh verification link to email.
+// Returns false if the address is on cooldown.
+func (h *Handler) resendVerificationOnLogin(r *http.Request, logger *logging.Logger, email string) bool {
+	onCooldown, err := h.rateLimiter.CheckEmailCooldownWithPurpose(r.Context(), contextTenantID(r.Context()), email, ratelimit.EmailPurposeVerification)
+	if err != nil {
+		logger.Error("failed to check email cooldown", "error", err.Error())
+		// Continue despite error, as ResendVerificationEmail does
+	} else if onCooldown {
+		return false
+	}
+
+	if err := h.rateLimiter.SetEmailCooldownWithPurpose(r.Context(), contextTenantID(r.Context()), email, ratelimit.EmailPurposeVerification); err != nil {
+		logger.Error("failed to set email cooldown", "error", err.Error())
+	}
+
+	_ = h.service.ResendVerificationEmail(r.Context(), email)
+	return true
+}
+
+// checkLoginLimit applies the per-IP login limit. Normally every attempt is
+// counted up front; when only failures count, the attempt is just checked here
+// and countFailedLogin counts it once it has failed, so users sharing an IP
+// can log in successfully as often as they need.
+func (h *Handler) checkLoginLimit(r *http.Request, ip string) (ratelimit.Result, error) {
+	if h.countFailedLoginsOnly {
+		return h.rateLimiter.Check(r.Context(), ip, "login")
+	}
+	return h.rateLimiter.Allow(r.Context(), ip, "login")
+}
+
+// countFailedLogin counts a failed attempt toward the IP limit when only
+// failures count; otherwise checkLoginLimit already counted it
+func (h *Handler) countFailedLogin(r *http.Request, logger *logging.Logger, ip string) {
+	if !h.countFailedLoginsOnly {
+		return
+	}
+	if err := h.rateLimiter.Record(r.Context(), ip, "login"); err != nil {
+		logger.Error("failed to record failed login for IP rate limit", "error", err.Error())
+	}
+}
+
+// delayFailedLogin records a failed login and, when backoff is enabled, waits
+// before the response is written. Returns false if the client went away
+// while waiting, in which case there is no one left to respond to.
+func (h *Handler) delayFailedLogin(r *http.Request, logger *logging.Logger, identifier, ip string) bool {
+	if !h.loginBackoff.Enabled {
+		return true
+	}
+
+	failures, err := h.rateLimiter.RecordLoginFailure(r.Context(), contextTenantID(r.Context()), identifier, ip)
+	if err != nil {
+		logger.Error("failed to record login failure", "error", err.Error())
+		return true
+	}
+
+	delay := h.loginBackoff.Delay(failures)
+	if err := ratelimit.Wait(r.Context(), delay); err != nil {
+		logger.Warn("client disconnected during login backoff", "delay", delay)
+		return false
+	}
+
+	return true
+}
+
 // Refresh handles access token refresh
 // @Summary      Refresh access token
 // @Description  Use a refresh token to get a new access token
@@ -255,22 +464,27 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Param        request body RefreshRequest true "Refresh token"
+// @Param        token_delivery query string false "Set to \"both\" to also get tokens in the body when cookies are set"
 // @Success      200 {object} AuthTokens
 // @Failure      400 {object} ErrorResponse "Invalid request body"
-// @Failure      401 {object} ErrorResponse "Invalid or expired refresh token"
+// @Failure      401 {object} ErrorResponse "Invalid, expired, inactive or IP-mismatched refresh token"
+// @Failure      429 {object} ErrorResponse "Too many refreshes for this user"
 // @Failure      500 {object} ErrorResponse "Internal server error"
+// @Failure      503 {object} ErrorResponse "Session store unavailable"
 // @Router       /auth/refresh [post]
 func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLoggerFromContext(r.Context())
 
-	// Try to get refresh token from JSON body first
-	var refreshToken string
+	// Try to get refresh token from JSON body first (the body is optional)
 	var req RefreshRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
-		refreshToken = req.RefreshToken
+	if err := httputil.DecodeJSON(w, r, &req); err != nil && !errors.Is(err, io.EOF) {
+		logger.Warn("invalid refresh request body", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
 	}
+	refreshToken := req.RefreshToken
 
-	// Fallback to cookie if body empty/invalid
+	// Fallback to cookie if body is empty
 	if refreshToken == "" {
 		cookieToken, err := GetRefreshTokenFromCookie(r)
 		if err == nil {
@@ -280,22 +494,35 @@ func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 	if refreshToken == "" {
 		logger.Warn("refresh token missing from both body and cookie")
-		respondError(w, "refresh token required", httputil.CodeRefreshTokenRequired, http.StatusBadRequest)
+		httputil.RespondError(w, httputil.CodeRefreshTokenRequired, http.StatusBadRequest)
 		return
 	}
 
 	// Trim whitespace that might have been accidentally added
 	refreshToken = strings.TrimSpace(refreshToken)
 
-	tokens, err := h.service.RefreshAccessToken(r.Context(), refreshToken)
+	tokens, err := h.service.RefreshAccessToken(r.Context(), refreshToken, SessionMetaFromRequest(r))
 	if err != nil {
 		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrRefreshTokenRevoked) || errors.Is(err, ErrRefreshTokenExpired) {
 			logger.Warn("token refresh failed: invalid or expired token", "error", err.Error())
-			respondError(w, "invalid or expired refresh token", httputil.CodeInvalidRefreshToken, http.StatusUnauthorized)
+			httputil.RespondError(w, httputil.CodeInvalidRefreshToken, http.StatusUnauthorized)
 			return
 		}
-		logger.Error("token refresh failed: internal error", "error", err.Error())
-		respondError(w, "failed to refresh token", httputil.CodeInternalError, http.StatusInternalServerError)
+		if errors.Is(err, ErrSessionInactive) {
+			logger.Warn("token refresh failed: session inactive")
+			httputil.RespondError(w, httputil.CodeSessionInactive, http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, ErrRefreshTokenIPMismatch) {
+			logger.Warn("token refresh failed: ip mismatch")
+			httputil.RespondError(w, httputil.CodeRefreshTokenIPMismatch, http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, ErrRefreshRateLimited) {
+			httputil.RespondError(w, httputil.CodeTooManyRequests, http.StatusTooManyRequests)
+			return
+		}
+		respondServiceError(w, logger, "token refresh failed: internal error", err)
 		return
 	}
 
@@ -303,22 +530,26 @@ func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 	// Set cookies if request is from browser
 	if ShouldUseCookies(r) {
-		SetAuthCookies(w, tokens.AccessToken, tokens.RefreshToken, h.isProduction, h.accessDuration, h.refreshDuration)
+		h.setAuthCookies(w, tokens)
+	}
+
+	if ShouldReturnTokensInBody(r) {
+		// Return tokens in response body for non-browser and hybrid clients
+		respondJSON(w, tokens, http.StatusOK)
+	} else {
 		// Don't return tokens in response body when using cookies
 		respondJSON(w, map[string]string{
 			"message": "token refreshed successfully",
 		}, http.StatusOK)
-	} else {
-		// Return tokens in response body for non-browser clients
-		respondJSON(w, tokens, http.StatusOK)
 	}
 }
 
-// VerifyEmail handles email verification
-// @Summary      Verify email address
-// @Description  Verify a user's email address using the verification token sent via email
+// VerifyEmail handles email verification with the token in the query string.
+// Kept for existing links; prefer VerifyEmailFromBody, since URLs end up in
+// server logs and browser history.
+// @Summary      Verify email address (legacy)
+// @Description  Verify a user's email address using the verification token sent via email. Prefer POST /auth/verify-email, which keeps the token out of URLs.
 // @Tags         auth
-// @Accept       json
 // @Produce      json
 // @Param        token query string true "Verification token"
 // @Success      200 {object} map[string]string
@@ -326,13 +557,38 @@ func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 // @Failure      500 {object} ErrorResponse "Internal server error"
 // @Router       /auth/verify-email [get]
 func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	h.verifyEmail(w, r, r.URL.Query().Get("token"))
+}
+
+// VerifyEmailFromBody handles email verification with the token in the JSON
+// body
+// @Summary      Verify email address
+// @Description  Verify a user's email address using the verification token sent via email
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body VerifyEmailRequest true "Verification token"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} ErrorResponse "Invalid request body, or invalid, expired or already used token"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Router       /auth/verify-email [post]
+func (h *Handler) VerifyEmailFromBody(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := httputil.DecodeJSON(w, r, &req); err != nil {
+		logging.GetLoggerFromContext(r.Context()).Warn("invalid verify email request", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+	h.verifyEmail(w, r, req.Token)
+}
+
+// verifyEmail verifies token and answers for both VerifyEmail routes
+func (h *Handler) verifyEmail(w http.ResponseWriter, r *http.Request, token string) {
 	logger := logging.GetLoggerFromContext(r.Context())
 
-	// Get token from query parameter
-	token := r.URL.Query().Get("token")
 	if token == "" {
 		logger.Warn("email verification failed: token missing")
-		respondError(w, "verification token required", httputil.CodeVerificationTokenRequired, http.StatusBadRequest)
+		httputil.RespondError(w, httputil.CodeVerificationTokenRequired, http.StatusBadRequest)
 		return
 	}
 
@@ -348,13 +604,17 @@ func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
 			httputil.RespondErrorWithCode(w, "This email is already verified. You can login now.", httputil.CodeAlreadyVerified, http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, ErrVerificationSuperseded) {
+			logger.Warn("email verification failed: superseded token")
+			httputil.RespondErrorWithCode(w, "This link was replaced by a newer one. Please use the most recent email or request a new link.", httputil.CodeVerificationSuperseded, http.StatusBadRequest)
+			return
+		}
 		if errors.Is(err, ErrInvalidVerificationToken) {
 			logger.Warn("email verification failed: invalid token")
 			httputil.RespondErrorWithCode(w, "Invalid verification token.", httputil.CodeVerificationFailed, http.StatusBadRequest)
 			return
 		}
-		logger.Error("email verification failed: internal error", "error", err.Error())
-		respondError(w, "failed to verify email", httputil.CodeInternalError, http.StatusInternalServerError)
+		httputil.RespondInternalError(w, logger, "email verification failed: internal error", err)
 		return
 	}
 
@@ -378,11 +638,13 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLoggerFromContext(r.Context())
 
 	// Get refresh token from either source
-	var refreshToken string
 	var req RefreshRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
-		refreshToken = req.RefreshToken
+	if err := httputil.DecodeJSON(w, r, &req); err != nil && !errors.Is(err, io.EOF) {
+		logger.Warn("invalid logout request body", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
 	}
+	refreshToken := req.RefreshToken
 	if refreshToken == "" {
 		cookieToken, _ := GetRefreshTokenFromCookie(r)
 		refreshToken = cookieToken
@@ -397,21 +659,185 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Clear cookies
-	ClearAuthCookies(w)
+	ClearAuthCookies(w, h.cookies)
 
 	logger.Info("user logged out successfully")
 
 	respondJSON(w, map[string]string{"message": "logged out"}, http.StatusOK)
 }
 
+// LogoutAllResponse represents the logout-all response
+type LogoutAllResponse struct {
+	Message         string `json:"message"`
+	RevokedSessions int    `json:"revoked_sessions"`
+}
+
+// LogoutAll handles logging out of every session
+// @Summary      Logout all sessions
+// @Description  Revoke every refresh token for the current user and clear cookies
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} LogoutAllResponse
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Failure      503 {object} ErrorResponse "Session store unavailable"
+// @Router       /auth/logout-all [post]
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	userID, ok := GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := h.service.RevokeAllSessions(r.Context(), userID)
+	if err != nil {
+		respondServiceError(w, logger, "failed to revoke all sessions", err)
+		return
+	}
+
+	// Clear cookies
+	ClearAuthCookies(w, h.cookies)
+
+	logger.Info("user logged out of all sessions", "revoked_sessions", revoked)
+
+	respondJSON(w, LogoutAllResponse{
+		Message:         "logged out of all sessions",
+		RevokedSessions: revoked,
+	}, http.StatusOK)
+}
+
+// ListSessions handles listing the current user's sessions
+// @Summary      List active sessions
+// @Description  List the devices the current user is logged in on
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {array} Session
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Failure      503 {object} ErrorResponse "Session store unavailable"
+// @Router       /auth/sessions [get]
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	userID, ok := GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.service.ListSessions(r.Context(), userID)
+	if err != nil {
+		respondServiceError(w, logger, "failed to list sessions", err)
+		return
+	}
+
+	respondJSON(w, sessions, http.StatusOK)
+}
+
+// RevokeSession handles revoking one of the current user's sessions
+// @Summary      Revoke a session
+// @Description  Log the current user out of one device
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Session ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} ErrorResponse "Invalid session ID"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      404 {object} ErrorResponse "Session not found"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Failure      503 {object} ErrorResponse "Session store unavailable"
+// @Router       /auth/sessions/{id} [delete]
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	userID, ok := GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httputil.RespondError(w, httputil.CodeInvalidSessionID, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			httputil.RespondError(w, httputil.CodeSessionNotFound, http.StatusNotFound)
+			return
+		}
+		respondServiceError(w, logger, "failed to revoke session", err)
+		return
+	}
+
+	logger.Info("session revoked", "user_id", userID, "session_id", sessionID)
+
+	respondJSON(w, map[string]string{
+		"message": "session revoked",
+	}, http.StatusOK)
+}
+
+// setAuthCookies sets the auth cookies for tokens. Sessions without "remember
+// me" get a refresh cookie that ends with the browser session.
+func (h *Handler) setAuthCookies(w http.ResponseWriter, tokens *AuthTokens) {
+	refreshMaxAge := h.refreshCookieMaxAge
+	if tokens.SessionOnly {
+		refreshMaxAge = 0
+	}
+	SetAuthCookies(w, tokens.AccessToken, tokens.RefreshToken, h.cookies, h.accessCookieMaxAge, refreshMaxAge)
+}
+
 // respondJSON sends a JSON response
 func respondJSON(w http.ResponseWriter, data any, statusCode int) {
 	httputil.RespondJSON(w, data, statusCode)
 }
 
-// respondError sends an error response with a machine-readable code
-func respondError(w http.ResponseWriter, message string, code string, statusCode int) {
-	httputil.RespondErrorWithCode(w, message, code, statusCode)
+// legacyValidationCodes maps request fields and the tag rules they failed to
+// the codes the service's own checks returned before requests were validated
+// by tags, so clients matching on those codes keep working
+var legacyValidationCodes = map[httputil.FieldError]httputil.ErrorCode{
+	{Field: "email", Rule: "required"}:        httputil.CodeEmailRequired,
+	{Field: "email", Rule: "max"}:             httputil.CodeInvalidEmailFormat,
+	{Field: "email", Rule: "email"}:           httputil.CodeInvalidEmailFormat,
+	{Field: "password", Rule: "required"}:     httputil.CodePasswordRequired,
+	{Field: "password", Rule: "min"}:          httputil.CodePasswordTooShort,
+	{Field: "new_password", Rule: "required"}: httputil.CodePasswordRequired,
+	{Field: "new_password", Rule: "min"}:      httputil.CodePasswordTooShort,
+	{Field: "token", Rule: "required"}:        httputil.CodeInvalidResetToken,
+}
+
+// respondBindError responds to an error from httputil.BindAndValidate with
+// the legacy code for the first failing field that has one, and like
+// httputil.RespondBindError otherwise
+func respondBindError(w http.ResponseWriter, err error) {
+	var verr *httputil.ValidationError
+	if errors.As(err, &verr) {
+		for _, f := range verr.Fields {
+			if code, ok := legacyValidationCodes[httputil.FieldError{Field: f.Field, Rule: f.Rule}]; ok {
+				httputil.RespondError(w, code, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	httputil.RespondBindError(w, err)
+}
+
+// respondServiceError responds to an unexpected service error like
+// httputil.RespondInternalError, except that an unavailable refresh token
+// store gets a 503 clients can retry
+func respondServiceError(w http.ResponseWriter, logger *logging.Logger, msg string, err error) {
+	if errors.Is(err, ErrAuthStoreUnavailable) {
+		logger.Error(msg, "error", err.Error())
+		httputil.RespondError(w, httputil.CodeAuthStoreUnavailable, http.StatusServiceUnavailable)
+		return
+	}
+	httputil.RespondInternalError(w, logger, msg, err)
 }
 
 // ForgotPassword handles password reset requests
@@ -421,6 +847,7 @@ func respondError(w http.ResponseWriter, message string, code string, statusCode
 // @Accept       json
 // @Produce      json
 // @Param        request body ForgotPasswordRequest true "Email address"
+// @Param        Idempotency-Key header string false "Replays the first response for repeated keys"
 // @Success      200 {object} map[string]string
 // @Failure      400 {object} ErrorResponse "Invalid request body"
 // @Failure      429 {object} ErrorResponse "Too many requests"
@@ -429,54 +856,53 @@ func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLoggerFromContext(r.Context())
 
 	var req ForgotPasswordRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := httputil.DecodeJSON(w, r, &req); err != nil {
 		logger.Warn("invalid forgot password request body", "error", err.Error())
-		respondError(w, "invalid request body", httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
 		return
 	}
 
 	// Get client IP for rate limiting
-	ip := getClientIP(r)
+	ip := GetClientIP(r)
 
-	// Check IP rate limit (10 req/15 min)
-	exceeded, err := h.rateLimiter.CheckIPRateLimit(r.Context(), ip)
+	// Check IP rate limit
+	limit, err := h.rateLimiter.Allow(r.Context(), ip, "auth")
 	if err != nil {
 		logger.Error("failed to check IP rate limit", "error", err.Error())
 		// Continue despite error to avoid blocking legitimate requests
-	} else if exceeded {
+	} else if !limit.Allowed {
 		logger.Warn("IP rate limit exceeded", "ip", ip)
-		respondError(w, "too many requests, please try again later", httputil.CodeTooManyRequests, http.StatusTooManyRequests)
+		httputil.RespondError(w, httputil.CodeTooManyRequests, http.StatusTooManyRequests)
 		return
 	}
 
-	// Check email cooldown (2 min)
-	onCooldown, err := h.rateLimiter.CheckEmailCooldown(r.Context(), req.Email)
+	// Check email cooldown (tracked per purpose)
+	onCooldown, err := h.rateLimiter.CheckEmailCooldownWithPurpose(r.Context(), contextTenantID(r.Context()), req.Email, ratelimit.EmailPurposePasswordReset)
 	if err != nil {
 		logger.Error("failed to check email cooldown", "error", err.Error())
 		// Continue despite error
 	} else if onCooldown {
 		logger.Warn("email on cooldown", "email", req.Email)
-		respondError(w, "please wait before requesting another reset", httputil.CodeCooldownActive, http.StatusTooManyRequests)
+		httputil.RespondErrorWithCode(w, "please wait before requesting another reset", httputil.CodeCooldownActive, http.StatusTooManyRequests)
 		return
 	}
 
-	// Record IP request for rate limiting
-	if err := h.rateLimiter.RecordIPRequest(r.Context(), ip); err != nil {
-		logger.Error("failed to record IP request", "error", err.Error())
-	}
-
 	// Set email cooldown
-	if err := h.rateLimiter.SetEmailCooldown(r.Context(), req.Email); err != nil {
+	if err := h.rateLimiter.SetEmailCooldownWithPurpose(r.Context(), contextTenantID(r.Context()), req.Email, ratelimit.EmailPurposePasswordReset); err != nil {
 		logger.Error("failed to set email cooldown", "error", err.Error())
 	}
 
-	// Process request (always returns nil for security)
-	_ = h.service.RequestPasswordReset(r.Context(), req.Email)
+	// Process request (never fails, for security)
+	token := h.service.RequestPasswordReset(r.Context(), req.Email)
 
 	// Always return success (prevent email enumeration)
-	respondJSON(w, map[string]string{
+	resp := map[string]string{
 		"message": "If an account exists with that email, a password reset link has been sent.",
-	}, http.StatusOK)
+	}
+	if h.exposeTokens && token != "" {
+		resp["reset_token"] = token
+	}
+	respondJSON(w, resp, http.StatusOK)
 }
 
 // ResetPassword handles password reset with token
@@ -487,16 +913,16 @@ func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        request body ResetPasswordRequest true "Reset token and new password"
 // @Success      200 {object} map[string]string
-// @Failure      400 {object} ErrorResponse "Invalid request or token"
+// @Failure      400 {object} httputil.ErrorResponse "Invalid request body, validation error, or invalid token"
 // @Failure      500 {object} ErrorResponse "Internal server error"
 // @Router       /auth/reset-password [post]
 func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLoggerFromContext(r.Context())
 
 	var req ResetPasswordRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Warn("invalid reset password request body", "error", err.Error())
-		respondError(w, "invalid request body", httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+	if err := httputil.BindAndValidate(w, r, &req); err != nil {
+		logger.Warn("invalid reset password request", "error", err.Error())
+		respondBindError(w, err)
 		return
 	}
 
@@ -504,21 +930,25 @@ func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, ErrPasswordResetTokenNotFound) {
 			logger.Warn("password reset failed: invalid or expired token")
-			respondError(w, "invalid or expired reset token", httputil.CodeInvalidResetToken, http.StatusBadRequest)
+			httputil.RespondError(w, httputil.CodeInvalidResetToken, http.StatusBadRequest)
 			return
 		}
 		if errors.Is(err, ErrPasswordRequired) {
 			logger.Warn("password reset failed: validation error", "error", err.Error())
-			respondError(w, err.Error(), httputil.CodePasswordRequired, http.StatusBadRequest)
+			httputil.RespondError(w, httputil.CodePasswordRequired, http.StatusBadRequest)
 			return
 		}
 		if errors.Is(err, ErrPasswordTooShort) {
 			logger.Warn("password reset failed: validation error", "error", err.Error())
-			respondError(w, err.Error(), httputil.CodePasswordTooShort, http.StatusBadRequest)
+			httputil.RespondError(w, httputil.CodePasswordTooShort, http.StatusBadRequest)
 			return
 		}
-		logger.Error("password reset failed: internal error", "error", err.Error())
-		respondError(w, "failed to reset password", httputil.CodeInternalError, http.StatusInternalServerError)
+		if errors.Is(err, ErrPasswordTooWeak) {
+			logger.Warn("password reset failed: validation error", "error", err.Error())
+			httputil.RespondErrorWithCode(w, err.Error(), httputil.CodePasswordTooWeak, http.StatusBadRequest)
+			return
+		}
+		httputil.RespondInternalError(w, logger, "password reset failed: internal error", err)
 		return
 	}
 
@@ -544,44 +974,39 @@ func (h *Handler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request
 	logger := logging.GetLoggerFromContext(r.Context())
 
 	var req ResendVerificationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := httputil.DecodeJSON(w, r, &req); err != nil {
 		logger.Warn("invalid resend verification request body", "error", err.Error())
-		respondError(w, "invalid request body", httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
 		return
 	}
 
 	// Get client IP for rate limiting
-	ip := getClientIP(r)
+	ip := GetClientIP(r)
 
-	// Check IP rate limit (10 req/15 min)
-	exceeded, err := h.rateLimiter.CheckIPRateLimit(r.Context(), ip)
+	// Check IP rate limit
+	limit, err := h.rateLimiter.Allow(r.Context(), ip, "auth")
 	if err != nil {
 		logger.Error("failed to check IP rate limit", "error", err.Error())
 		// Continue despite error
-	} else if exceeded {
+	} else if !limit.Allowed {
 		logger.Warn("IP rate limit exceeded", "ip", ip)
-		respondError(w, "too many requests, please try again later", httputil.CodeTooManyRequests, http.StatusTooManyRequests)
+		httputil.RespondError(w, httputil.CodeTooManyRequests, http.StatusTooManyRequests)
 		return
 	}
 
-	// Check email cooldown (2 min)
-	onCooldown, err := h.rateLimiter.CheckEmailCooldown(r.Context(), req.Email)
+	// Check email cooldown (tracked per purpose)
+	onCooldown, err := h.rateLimiter.CheckEmailCooldownWithPurpose(r.Context(), contextTenantID(r.Context()), req.Email, ratelimit.EmailPurposeVerification)
 	if err != nil {
 		logger.Error("failed to check email cooldown", "error", err.Error())
 		// Continue despite error
 	} else if onCooldown {
 		logger.Warn("email on cooldown", "email", req.Email)
-		respondError(w, "please wait before requesting another email", httputil.CodeCooldownActive, http.StatusTooManyRequests)
+		httputil.RespondErrorWithCode(w, "please wait before requesting another email", httputil.CodeCooldownActive, http.StatusTooManyRequests)
 		return
 	}
 
-	// Record IP request for rate limiting
-	if err := h.rateLimiter.RecordIPRequest(r.Context(), ip); err != nil {
-		logger.Error("failed to record IP request", "error", err.Error())
-	}
-
 	// Set email cooldown
-	if err := h.rateLimiter.SetEmailCooldown(r.Context(), req.Email); err != nil {
+	if err := h.rateLimiter.SetEmailCooldownWithPurpose(r.Context(), contextTenantID(r.Context()), req.Email, ratelimit.EmailPurposeVerification); err != nil {
 		logger.Error("failed to set email cooldown", "error", err.Error())
 	}
 
@@ -594,29 +1019,280 @@ func (h *Handler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request
 	}, http.StatusOK)
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (behind proxy/load balancer)
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+// RequestMagicLink handles passwordless login link requests
+// @Summary      Request magic link
+// @Description  Email a single-use login link. Always returns success to prevent email enumeration.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body MagicLinkRequest true "Email address"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} ErrorResponse "Invalid request body"
+// @Failure      429 {object} ErrorResponse "Too many requests"
+// @Router       /auth/magic-link [post]
+func (h *Handler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	var req MagicLinkRequest
+	if err := httputil.DecodeJSON(w, r, &req); err != nil {
+		logger.Warn("invalid magic link request body", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	// Get client IP for rate limiting
+	ip := GetClientIP(r)
+
+	// Check IP rate limit
+	limit, err := h.rateLimiter.Allow(r.Context(), ip, "magic_link")
+	if err != nil {
+		logger.Error("failed to check IP rate limit", "error", err.Error())
+		// Continue despite error
+	} else if !limit.Allowed {
+		logger.Warn("IP rate limit exceeded", "ip", ip)
+		httputil.RespondError(w, httputil.CodeTooManyRequests, http.StatusTooManyRequests)
+		return
+	}
+
+	// Check email cooldown (tracked per purpose)
+	onCooldown, err := h.rateLimiter.CheckEmailCooldownWithPurpose(r.Context(), contextTenantID(r.Context()), req.Email, ratelimit.EmailPurposeMagicLink)
+	if err != nil {
+		logger.Error("failed to check email cooldown", "error", err.Error())
+		// Continue despite error
+	} else if onCooldown {
+		logger.Warn("email on cooldown", "email", req.Email)
+		httputil.RespondErrorWithCode(w, "please wait before requesting another link", httputil.CodeCooldownActive, http.StatusTooManyRequests)
+		return
+	}
+
+	// Set email cooldown
+	if err := h.rateLimiter.SetEmailCooldownWithPurpose(r.Context(), contextTenantID(r.Context()), req.Email, ratelimit.EmailPurposeMagicLink); err != nil {
+		logger.Error("failed to set email cooldown", "error", err.Error())
+	}
+
+	// Process request (always returns nil for security)
+	_ = h.service.RequestMagicLink(r.Context(), req.Email)
+
+	// Always return success (prevent email enumeration)
+	respondJSON(w, map[string]string{
+		"message": "If an account exists with that email, a login link has been sent.",
+	}, http.StatusOK)
+}
+
+// VerifyMagicLink handles magic link login
+// @Summary      Verify magic link
+// @Description  Exchange a single-use magic link token for access and refresh tokens
+// @Tags         auth
+// @Produce      json
+// @Param        token query string true "Magic link token"
+// @Param        token_delivery query string false "Set to \"both\" to also get tokens in the body when cookies are set"
+// @Success      200 {object} AuthTokens
+// @Failure      400 {object} ErrorResponse "Missing token"
+// @Failure      401 {object} ErrorResponse "Invalid, expired, or already used token"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Failure      503 {object} ErrorResponse "Session store unavailable"
+// @Router       /auth/magic-link/verify [get]
+func (h *Handler) VerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		logger.Warn("magic link login failed: token missing")
+		httputil.RespondError(w, httputil.CodeMagicLinkTokenRequired, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.service.LoginWithMagicLink(r.Context(), token, SessionMetaFromRequest(r))
+	if err != nil {
+		if errors.Is(err, ErrMagicLinkTokenNotFound) {
+			logger.Warn("magic link login failed: invalid or expired token")
+			httputil.RespondError(w, httputil.CodeInvalidMagicLinkToken, http.StatusUnauthorized)
+			return
+		}
+		respondServiceError(w, logger, "magic link login failed: internal error", err)
+		return
+	}
+
+	logger.Info("user logged in with magic link")
+
+	// Set cookies if request is from browser
+	if ShouldUseCookies(r) {
+		h.setAuthCookies(w, tokens)
+	}
+
+	if ShouldReturnTokensInBody(r) {
+		respondJSON(w, tokens, http.StatusOK)
+	} else {
+		respondJSON(w, map[string]string{
+			"message": "logged in successfully",
+		}, http.StatusOK)
+	}
+}
+
+// maxUserAgentLen bounds the stored User-Agent so clients can't bloat session storage
+const maxUserAgentLen = 512
+
+// CreateInviteRequest represents the invite creation request body
+type CreateInviteRequest struct {
+	// Email binds the invite to one address; omit to let anyone use it
+	Email string `json:"email,omitempty"`
+	// ExpiresIn is the invite lifetime in seconds; omit for the default
+	ExpiresIn int `json:"expires_in,omitempty"`
+}
+
+// CreateInviteResponse represents a newly created invite. The token is only
+// ever returned here.
+type CreateInviteResponse struct {
+	Invite *Invite `json:"invite"`
+	Token  string  `json:"token"`
+}
+
+// CreateInvite handles issuing a registration invite
+// @Summary      Create an invite
+// @Description  Issue a single-use invite token for invite-only registration
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body CreateInviteRequest true "Optional email binding and lifetime"
+// @Success      201 {object} CreateInviteResponse
+// @Failure      400 {object} ErrorResponse "Invalid request or email"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Admin access required"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Router       /admin/invites [post]
+func (h *Handler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	userID, ok := GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateInviteRequest
+	if err := httputil.DecodeJSON(w, r, &req); err != nil {
+		logger.Warn("invalid invite request body", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresIn < 0 {
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	invite, token, err := h.service.CreateInvite(r.Context(), userID, req.Email, time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		if errors.Is(err, ErrInvalidEmailFormat) {
+			httputil.RespondError(w, httputil.CodeInvalidEmailFormat, http.StatusBadRequest)
+			return
 		}
+		httputil.RespondInternalError(w, logger, "failed to create invite", err)
+		return
+	}
+
+	logger.Info("invite created", "invite_id", invite.ID, "created_by", userID)
+
+	respondJSON(w, CreateInviteResponse{
+		Invite: invite,
+		Token:  token,
+	}, http.StatusCreated)
+}
+
+// ListInvites handles listing registration invites
+// @Summary      List invites
+// @Description  List invites that haven't expired, including used ones
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {array} Invite
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Admin access required"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Router       /admin/invites [get]
+func (h *Handler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	invites, err := h.service.ListInvites(r.Context())
+	if err != nil {
+		httputil.RespondInternalError(w, logger, "failed to list invites", err)
+		return
+	}
+
+	respondJSON(w, invites, http.StatusOK)
+}
+
+// RevokeInvite handles revoking a registration invite
+// @Summary      Revoke an invite
+// @Description  Delete an invite so it can no longer be used
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Invite ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} ErrorResponse "Invalid invite ID"
+// @Failure      401 {object} ErrorResponse "Unauthorized"
+// @Failure      403 {object} ErrorResponse "Admin access required"
+// @Failure      404 {object} ErrorResponse "Invite not found"
+// @Failure      500 {object} ErrorResponse "Internal server error"
+// @Router       /admin/invites/{id} [delete]
+func (h *Handler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	inviteID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httputil.RespondError(w, httputil.CodeInvalidInviteID, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RevokeInvite(r.Context(), inviteID); err != nil {
+		if errors.Is(err, ErrInviteNotFound) {
+			httputil.RespondError(w, httputil.CodeInviteNotFound, http.StatusNotFound)
+			return
+		}
+		httputil.RespondInternalError(w, logger, "failed to revoke invite", err)
+		return
+	}
+
+	logger.Info("invite revoked", "invite_id", inviteID)
+
+	respondJSON(w, map[string]string{
+		"message": "invite revoked",
+	}, http.StatusOK)
+}
+
+// SessionMetaFromRequest captures the client details stored with a new session
+func SessionMetaFromRequest(r *http.Request) SessionMeta {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLen {
+		// Cut at a rune boundary so the stored value stays valid UTF-8
+		cut := maxUserAgentLen
+		for cut > 0 && !utf8.RuneStart(userAgent[cut]) {
+			cut--
+		}
+		userAgent = userAgent[:cut]
+	}
+	return SessionMeta{
+		IP:        GetClientIP(r),
+		UserAgent: userAgent,
 	}
+}
 
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return strings.TrimSpace(xri)
+// GetClientIP extracts the client IP address from the request. Requests that
+// passed through reqmeta.Middleware reuse the address resolved there.
+// Forwarded headers aren't read here, since a client can set them to anything;
+// the router's RealIP middleware puts the address a trusted proxy reported in
+// RemoteAddr instead.
+func GetClientIP(r *http.Request) string {
+	if meta, ok := reqmeta.FromContext(r.Context()); ok && meta.ClientIP != "" {
+		return meta.ClientIP
 	}
 
-	// Fallback to RemoteAddr
-	ip := r.RemoteAddr
-	// RemoteAddr format is "IP:port", extract just the IP
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
+	// RemoteAddr is "IP:port" ("[IP]:port" for IPv6), or a bare IP once
+	// RealIP has rewritten it
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
 	return ip
 }
@@ -0,0 +1,350 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/ratelimit"
+)
+
+func newTestHandler(t *testing.T, env *testEnv) *Handler {
+	t.Helper()
+	limiter := ratelimit.NewMemoryLimiter(ratelimit.LimiterConfig{})
+	t.Cleanup(limiter.Close)
+	return NewHandler(env.service, limiter, ratelimit.LoginBackoff{}, env.service.logger, CookieOptions{}, 0, 0, false, false, false)
+}
+
+// errorCode serves body to h and returns the response's status and error code
+func errorCode(t *testing.T, h http.HandlerFunc, body string) (int, httputil.ErrorCode) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	var resp httputil.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return w.Code, resp.Code
+}
+
+func TestValidationFailuresKeepLegacyCodes(t *testing.T) {
+	h := newTestHandler(t, newTestEnv(t))
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		want    httputil.ErrorCode
+	}{
+		{"register without email", h.Register, `{"password":"long enough"}`, httputil.CodeEmailRequired},
+		{"register with a bad email", h.Register, `{"email":"nope","password":"long enough"}`, httputil.CodeInvalidEmailFormat},
+		{"register without password", h.Register, `{"email":"a@example.com"}`, httputil.CodePasswordRequired},
+		{"register with a short password", h.Register, `{"email":"a@example.com","password":"short"}`, httputil.CodePasswordTooShort},
+		{"reset without token", h.ResetPassword, `{"new_password":"long enough"}`, httputil.CodeInvalidResetToken},
+		{"reset without password", h.ResetPassword, `{"token":"t"}`, httputil.CodePasswordRequired},
+		{"reset with a short password", h.ResetPassword, `{"token":"t","new_password":"short"}`, httputil.CodePasswordTooShort},
+		{"malformed body", h.Register, `{`, httputil.CodeInvalidRequestBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := errorCode(t, tt.handler, tt.body)
+			if status != http.StatusBadRequest || code != tt.want {
+				t.Errorf("got %d %s, want %d %s", status, code, http.StatusBadRequest, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionMetaFromRequestTruncatesUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		wantLen   int
+	}{
+		{"short", "Mozilla/5.0", len("Mozilla/5.0")},
+		{"ascii over the limit", strings.Repeat("a", maxUserAgentLen+10), maxUserAgentLen},
+		// 3-byte runes straddle the limit (512 isn't a multiple of 3)
+		{"multibyte over the limit", strings.Repeat("€", maxUserAgentLen), maxUserAgentLen - maxUserAgentLen%3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+
+			got := SessionMetaFromRequest(r).UserAgent
+			if len(got) != tt.wantLen {
+				t.Errorf("len(UserAgent) = %d, want %d", len(got), tt.wantLen)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("UserAgent %q is not valid UTF-8", got)
+			}
+			if !strings.HasPrefix(tt.userAgent, got) {
+				t.Errorf("UserAgent is not a prefix of the original")
+			}
+		})
+	}
+}
+
+func TestExposeTokensOnlyWhenEnabled(t *testing.T) {
+	for _, expose := range []bool{false, true} {
+		t.Run(fmt.Sprintf("expose=%v", expose), func(t *testing.T) {
+			env := newTestEnv(t)
+			limiter := ratelimit.NewMemoryLimiter(ratelimit.LimiterConfig{})
+			t.Cleanup(limiter.Close)
+			h := NewHandler(env.service, limiter, ratelimit.LoginBackoff{}, env.service.logger, CookieOptions{}, 0, 0, expose, false, false)
+
+			tests := []struct {
+				name    string
+				handler http.HandlerFunc
+				body    string
+				field   string
+			}{
+				{"register", h.Register, `{"email":"a@example.com","password":"correct horse battery staple"}`, "verification_token"},
+				{"forgot password", h.ForgotPassword, `{"email":"a@example.com"}`, "reset_token"},
+			}
+			for _, tt := range tests {
+				w := httptest.NewRecorder()
+				tt.handler(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+				var resp map[string]any
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("%s: decode response: %v", tt.name, err)
+				}
+				if token, _ := resp[tt.field].(string); (token != "") != expose {
+					t.Errorf("%s: %s = %q, want it only when exposing tokens", tt.name, tt.field, token)
+				}
+			}
+		})
+	}
+}
+
+func TestCountFailedLoginsOnly(t *testing.T) {
+	const (
+		good = `{"email":"a@example.com","password":"correct horse battery staple"}`
+		bad  = `{"email":"a@example.com","password":"wrong password"}`
+	)
+	tests := []struct {
+		name         string
+		failuresOnly bool
+		attempts     []string
+		want         []int
+	}{
+		{
+			name:         "every attempt counts",
+			failuresOnly: false,
+			attempts:     []string{good, good, good},
+			want:         []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:         "only failures count",
+			failuresOnly: true,
+			attempts:     []string{good, good, good, good, bad, bad, good},
+			want:         []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			u, _ := env.register(t, "a@example.com")
+			if err := env.users.MarkEmailAsVerified(context.Background(), u.ID); err != nil {
+				t.Fatal(err)
+			}
+			limiter := ratelimit.NewMemoryLimiter(ratelimit.LimiterConfig{IPMax: 2})
+			t.Cleanup(limiter.Close)
+			h := NewHandler(env.service, limiter, ratelimit.LoginBackoff{}, env.service.logger, CookieOptions{}, 0, 0, false, tt.failuresOnly, false)
+
+			for i, body := range tt.attempts {
+				w := httptest.NewRecorder()
+				h.Login(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+				if w.Code != tt.want[i] {
+					t.Fatalf("attempt %d: status = %d, want %d", i+1, w.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUnverifiedLoginResendHint(t *testing.T) {
+	const body = `{"email":"a@example.com","password":"correct horse battery staple"}`
+	login := func(t *testing.T, h *Handler) EmailNotVerifiedResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.Login(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want 403", w.Code)
+		}
+		var resp EmailNotVerifiedResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return resp
+	}
+	sent := func(t *testing.T, env *testEnv) int {
+		t.Helper()
+		if err := env.service.WaitForEmails(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		env.emails.mu.Lock()
+		defer env.emails.mu.Unlock()
+		return len(env.emails.verification)
+	}
+	newHandler := func(t *testing.T, env *testEnv, autoResend bool) *Handler {
+		limiter := ratelimit.NewMemoryLimiter(ratelimit.LimiterConfig{EmailCooldown: time.Minute})
+		t.Cleanup(limiter.Close)
+		return NewHandler(env.service, limiter, ratelimit.LoginBackoff{}, env.service.logger, CookieOptions{}, 0, 0, false, false, autoResend)
+	}
+
+	t.Run("hint only", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.register(t, "a@example.com")
+		h := newHandler(t, env, false)
+
+		resp := login(t, h)
+		if resp.Code != httputil.CodeEmailNotVerified || !resp.CanResendVerification || resp.VerificationSent {
+			t.Errorf("response = %+v, want a resend hint and no automatic send", resp)
+		}
+		if n := sent(t, env); n != 1 {
+			t.Errorf("sent %d verification emails, want only the one from registering", n)
+		}
+	})
+
+	t.Run("auto resend honors the cooldown", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.register(t, "a@example.com")
+		h := newHandler(t, env, true)
+
+		first := login(t, h)
+		if !first.VerificationSent || first.CanResendVerification || first.ResendAvailableIn <= 0 {
+			t.Errorf("first response = %+v, want a fresh link and the cooldown", first)
+		}
+		second := login(t, h)
+		if second.VerificationSent || second.CanResendVerification {
+			t.Errorf("second response = %+v, want no send during the cooldown", second)
+		}
+		if n := sent(t, env); n != 2 {
+			t.Errorf("sent %d verification emails, want registration plus one resend", n)
+		}
+	})
+}
+
+func TestUnverifiedLoginReportsEmailAndCooldown(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	if _, err := env.service.Register(ctx, "a@example.com", "alice", "correct horse battery staple", ""); err != nil {
+		t.Fatal(err)
+	}
+	env.service.loginIdentifier = LoginByEither
+	limiter := ratelimit.NewMemoryLimiter(ratelimit.LimiterConfig{EmailCooldown: time.Minute})
+	t.Cleanup(limiter.Close)
+	h := NewHandler(env.service, limiter, ratelimit.LoginBackoff{}, env.service.logger, CookieOptions{}, 0, 0, false, false, false)
+	login := func(body string) (int, EmailNotVerifiedResponse) {
+		w := httptest.NewRecorder()
+		h.Login(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		var resp EmailNotVerifiedResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	// Only matching credentials reveal that the account is unverified
+	if status, resp := login(`{"identifier":"alice","password":"wrong password"}`); status != http.StatusUnauthorized || resp.Email != "" {
+		t.Errorf("wrong password got %d with email %q, want 401 without it", status, resp.Email)
+	}
+
+	status, resp := login(`{"identifier":"alice","password":"correct horse battery staple"}`)
+	if status != http.StatusForbidden || resp.Email != "a@example.com" || !resp.CanResendVerification || resp.ResendAvailableIn != 0 {
+		t.Errorf("got %d %+v, want 403 with the account's email and a resend offer", status, resp)
+	}
+
+	if err := limiter.SetEmailCooldownWithPurpose(ctx, "", "a@example.com", ratelimit.EmailPurposeVerification); err != nil {
+		t.Fatal(err)
+	}
+	_, resp = login(`{"identifier":"alice","password":"correct horse battery staple"}`)
+	if resp.CanResendVerification || resp.ResendAvailableIn <= 0 || resp.ResendAvailableIn > 60 {
+		t.Errorf("during the cooldown got %+v, want no resend offer and the seconds left", resp)
+	}
+}
+
+func TestMagicLinkFlow(t *testing.T) {
+	env := newTestEnv(t)
+	h := newTestHandler(t, env)
+	u, _ := env.register(t, "a@example.com")
+
+	request := func(email string) map[string]string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.RequestMagicLink(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"`+email+`"}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("RequestMagicLink(%s) status = %d, want 200", email, w.Code)
+		}
+		var resp map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	verify := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.VerifyMagicLink(w, httptest.NewRequest(http.MethodGet, "/?token="+token, nil))
+		return w
+	}
+
+	// Unknown addresses get the same answer and no email
+	if known, unknown := request(u.Email), request("nobody@example.com"); known["message"] != unknown["message"] {
+		t.Errorf("responses differ for known and unknown emails: %q vs %q", known, unknown)
+	}
+	token := env.emails.last(t, env.service, &env.emails.magicLink)
+	if len(env.emails.magicLink) != 1 {
+		t.Errorf("sent %d magic links, want 1", len(env.emails.magicLink))
+	}
+
+	w := verify(token)
+	var tokens AuthTokens
+	if err := json.NewDecoder(w.Body).Decode(&tokens); err != nil || w.Code != http.StatusOK || tokens.AccessToken == "" {
+		t.Fatalf("first VerifyMagicLink = %d %+v, want 200 with tokens", w.Code, tokens)
+	}
+	claims, err := env.service.tokenService.VerifyToken(tokens.AccessToken)
+	if err != nil || claims.UserID != u.ID.String() {
+		t.Errorf("access token claims = %+v, %v, want the user's", claims, err)
+	}
+
+	if w := verify(token); w.Code != http.StatusUnauthorized {
+		t.Errorf("reused VerifyMagicLink = %d, want 401", w.Code)
+	}
+}
+
+func TestOldVerificationLinkAfterResend(t *testing.T) {
+	env := newTestEnv(t)
+	h := newTestHandler(t, env)
+	u, oldToken := env.register(t, "a@example.com")
+
+	if err := env.service.ResendVerificationEmail(context.Background(), u.Email); err != nil {
+		t.Fatalf("ResendVerificationEmail() error = %v", err)
+	}
+	newToken := env.emails.last(t, env.service, &env.emails.verification)
+	if newToken == oldToken {
+		t.Fatal("resend reused the old token")
+	}
+
+	if status, code := errorCode(t, h.VerifyEmailFromBody, `{"token":"`+oldToken+`"}`); status != http.StatusBadRequest || code != httputil.CodeVerificationSuperseded {
+		t.Errorf("old link got %d %s, want 400 %s", status, code, httputil.CodeVerificationSuperseded)
+	}
+	if status, code := errorCode(t, h.VerifyEmailFromBody, `{"token":"never-issued"}`); code != httputil.CodeVerificationFailed {
+		t.Errorf("unknown link got %d %s, want %s", status, code, httputil.CodeVerificationFailed)
+	}
+
+	w := httptest.NewRecorder()
+	h.VerifyEmailFromBody(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"token":"`+newToken+`"}`)))
+	if w.Code != http.StatusOK {
+		t.Errorf("newest link got %d, want 200", w.Code)
+	}
+}
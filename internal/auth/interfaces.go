@@ -7,8 +7,10 @@ import (
 )
 
 // TokenService defines the interface for token creation and validation.
-// Implementations include PasetoService (PASETO v4.local) and JWTService (HS256).
+// PasetoService (PASETO v4.local) implements it; generated projects may use
+// JWTService (HS256) instead.
+// CreateToken adds extra as custom claims and must reject reserved names.
 type TokenService interface {
-	CreateToken(userID uuid.UUID, email string, duration time.Duration) (string, error)
+	CreateToken(userID uuid.UUID, tenantID, email string, emailVerified bool, authTime time.Time, duration time.Duration, extra map[string]any) (string, error)
 	VerifyToken(tokenStr string) (*TokenClaims, error)
 }
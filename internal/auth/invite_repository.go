@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// invitesKey indexes the invite IDs of the tenant-less deployment, scored by
+// their expiry. Each tenant's invites are indexed separately (invitesKeyFor).
+const invitesKey = "invites"
+
+// InviteRepository handles invite storage in Redis. Each invite is a hash
+// keyed by its ID; a separate single-use key maps the hashed token to the ID.
+// Both expire with the invite.
+type InviteRepository struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewInviteRepository creates a new invite repository instance. ttl is how
+// long invites stay valid when no expiry is given.
+func NewInviteRepository(client *redis.Client, ttl time.Duration) *InviteRepository {
+	return &InviteRepository{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// TTL returns how long invites stay valid by default
+func (r *InviteRepository) TTL() time.Duration {
+	return r.ttl
+}
+
+// getInviteKey generates the Redis key for an invite's data
+func getInviteKey(id uuid.UUID) string {
+	return fmt.Sprintf("invite:id:%s", id.String())
+}
+
+// invitesKeyFor returns the index of a tenant's invites, keeping the original
+// key when tenancy is off
+func invitesKeyFor(tenantID string) string {
+	if tenantID == "" {
+		return invitesKey
+	}
+	return fmt.Sprintf("%s:%s", invitesKey, tenantID)
+}
+
+// inviteTokenKey generates the Redis key mapping an invite token to its ID
+func inviteTokenKey(token string) string {
+	return purposeInvite.key(token)
+}
+
+// StoreInvite stores an invite and its token until the invite expires
+func (r *InviteRepository) StoreInvite(ctx context.Context, invite *Invite, token string) error {
+	ttl := time.Until(invite.ExpiresAt)
+	if ttl <= 0 {
+		return fmt.Errorf("invite expiration time is in the past")
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, getInviteKey(invite.ID), map[string]interface{}{
+		"email":      invite.Email,
+		"tenant_id":  invite.TenantID,
+		"created_by": invite.CreatedBy.String(),
+		"created_at": invite.CreatedAt.Unix(),
+		"expires_at": invite.ExpiresAt.Unix(),
+		"token_key":  inviteTokenKey(token),
+	})
+	pipe.ExpireAt(ctx, getInviteKey(invite.ID), invite.ExpiresAt)
+	pipe.Set(ctx, inviteTokenKey(token), invite.ID.String(), ttl)
+	pipe.ZAdd(ctx, invitesKeyFor(invite.TenantID), redis.Z{Score: float64(invite.ExpiresAt.Unix()), Member: invite.ID.String()})
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store invite: %w", err)
+	}
+
+	return nil
+}
+
+// GetInviteByToken returns the unconsumed invite a token belongs to
+func (r *InviteRepository) GetInviteByToken(ctx context.Context, token string) (*Invite, error) {
+	idStr, err := r.client.Get(ctx, inviteTokenKey(token)).Result()
+	if err == redis.Nil {
+		return nil, ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite token: %w", err)
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse invite ID: %w", err)
+	}
+
+	return r.getInvite(ctx, id)
+}
+
+// ClaimInvite removes an invite's token so nobody else can register with it.
+// GETDEL makes this atomic, so concurrent registrations can't share an invite.
+func (r *InviteRepository) ClaimInvite(ctx context.Context, token string) error {
+	err := r.client.GetDel(ctx, inviteTokenKey(token)).Err()
+	if err == redis.Nil {
+		return ErrInviteNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim invite: %w", err)
+	}
+	return nil
+}
+
+// ReleaseInvite restores a claimed invite's token after registration failed
+func (r *InviteRepository) ReleaseInvite(ctx context.Context, invite *Invite, token string) error {
+	ttl := time.Until(invite.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	if err := r.client.Set(ctx, inviteTokenKey(token), invite.ID.String(), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to release invite: %w", err)
+	}
+	return nil
+}
+
+// MarkInviteConsumed records who registered with a claimed invite. The record
+// is kept until the invite would have expired so admins can see it was used.
+func (r *InviteRepository) MarkInviteConsumed(ctx context.Context, invite *Invite, userID uuid.UUID) error {
+	key := getInviteKey(invite.ID)
+
+	// Re-apply the expiry in case the invite expired since it was claimed,
+	// which would otherwise leave a hash behind that never expires
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"consumed_at": time.Now().Unix(),
+		"consumed_by": userID.String(),
+	})
+	pipe.ExpireAt(ctx, key, invite.ExpiresAt)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to mark invite consumed: %w", err)
+	}
+	return nil
+}
+
+// ListInvites returns the tenant's invites that haven't expired, soonest to
+// expire first
+func (r *InviteRepository) ListInvites(ctx context.Context, tenantID string) ([]*Invite, error) {
+	now := time.Now().Unix()
+	indexKey := invitesKeyFor(tenantID)
+
+	// Drop expired invites from the index; their data has already expired
+	if err := r.client.ZRemRangeByScore(ctx, indexKey, "-inf", fmt.Sprintf("%d", now)).Err(); err != nil {
+		return nil, fmt.Errorf("failed to clean up expired invites: %w", err)
+	}
+
+	ids, err := r.client.ZRange(ctx, indexKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, idStr := range ids {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			continue
+		}
+		cmds[i] = pipe.HGetAll(ctx, getInviteKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get invites: %w", err)
+	}
+
+	invites := make([]*Invite, 0, len(ids))
+	for i, cmd := range cmds {
+		if cmd == nil || len(cmd.Val()) == 0 {
+			continue
+		}
+		invite, err := parseInviteHash(uuid.MustParse(ids[i]), cmd.Val())
+		if err != nil || invite.TenantID != tenantID {
+			continue
+		}
+		invites = append(invites, invite)
+	}
+
+	return invites, nil
+}
+
+// RevokeInvite deletes one of the tenant's invites and its token. Another
+// tenant's invite is reported as not found.
+func (r *InviteRepository) RevokeInvite(ctx context.Context, tenantID string, id uuid.UUID) error {
+	key := getInviteKey(id)
+
+	data, err := r.client.HMGet(ctx, key, "token_key", "tenant_id").Result()
+	if err != nil {
+		return fmt.Errorf("failed to get invite: %w", err)
+	}
+	tokenKey, ok := data[0].(string)
+	if !ok {
+		return ErrInviteNotFound
+	}
+	if inviteTenant, _ := data[1].(string); inviteTenant != tenantID {
+		return ErrInviteNotFound
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, key, tokenKey)
+	pipe.ZRem(ctx, invitesKeyFor(tenantID), id.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+
+	return nil
+}
+
+// getInvite loads an invite by ID
+func (r *InviteRepository) getInvite(ctx context.Context, id uuid.UUID) (*Invite, error) {
+	data, err := r.client.HGetAll(ctx, getInviteKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, ErrInviteNotFound
+	}
+
+	return parseInviteHash(id, data)
+}
+
+// parseInviteHash builds an Invite from its stored Redis hash
+func parseInviteHash(id uuid.UUID, data map[string]string) (*Invite, error) {
+	createdBy, err := uuid.Parse(data["created_by"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse invite creator: %w", err)
+	}
+
+	var createdAtUnix, expiresAtUnix int64
+	fmt.Sscanf(data["created_at"], "%d", &createdAtUnix)
+	fmt.Sscanf(data["expires_at"], "%d", &expiresAtUnix)
+
+	invite := &Invite{
+		ID:        id,
+		Email:     data["email"],
+		TenantID:  data["tenant_id"],
+		CreatedBy: createdBy,
+		CreatedAt: time.Unix(createdAtUnix, 0),
+		ExpiresAt: time.Unix(expiresAtUnix, 0),
+	}
+
+	if consumedAt, ok := data["consumed_at"]; ok {
+		var consumedAtUnix int64
+		fmt.Sscanf(consumedAt, "%d", &consumedAtUnix)
+		t := time.Unix(consumedAtUnix, 0)
+		invite.ConsumedAt = &t
+	}
+	if consumedBy, err := uuid.Parse(data["consumed_by"]); err == nil {
+		invite.ConsumedBy = &consumedBy
+	}
+
+	return invite, nil
+}
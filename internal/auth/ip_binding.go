@@ -0,0 +1,46 @@
+package auth
+
+import "net/netip"
+
+// IPBinding ties refresh tokens to the network they were issued from.
+// A refresh from an address outside the issuing subnet revokes the token.
+type IPBinding struct {
+	Enabled bool
+	// IPv4PrefixLen and IPv6PrefixLen set how much of the address must match,
+	// e.g. 24 tolerates changes within a /24
+	IPv4PrefixLen int
+	IPv6PrefixLen int
+}
+
+// Allows reports whether a token issued to issuedIP may be used from currentIP.
+// Tokens without a recorded IP (issued before binding was enabled) are allowed.
+func (b IPBinding) Allows(issuedIP, currentIP string) bool {
+	if !b.Enabled || issuedIP == "" {
+		return true
+	}
+
+	issued, err := netip.ParseAddr(issuedIP)
+	if err != nil {
+		return false
+	}
+	current, err := netip.ParseAddr(currentIP)
+	if err != nil {
+		return false
+	}
+	issued, current = issued.Unmap(), current.Unmap()
+
+	if issued.Is4() != current.Is4() {
+		return false
+	}
+
+	bits := b.IPv6PrefixLen
+	if issued.Is4() {
+		bits = b.IPv4PrefixLen
+	}
+
+	prefix, err := issued.Prefix(bits)
+	if err != nil {
+		return false
+	}
+	return prefix.Contains(current)
+}
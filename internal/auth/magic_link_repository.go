@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const magicLinkTokenTTL = 15 * time.Minute
+
+// MagicLinkRepository handles passwordless login token storage in Redis
+type MagicLinkRepository struct {
+	client *redis.Client
+}
+
+// NewMagicLinkRepository creates a new magic link repository instance
+func NewMagicLinkRepository(client *redis.Client) *MagicLinkRepository {
+	return &MagicLinkRepository{
+		client: client,
+	}
+}
+
+// StoreMagicLinkToken stores a magic link token with 15-minute TTL
+func (r *MagicLinkRepository) StoreMagicLinkToken(ctx context.Context, userID uuid.UUID, token string) error {
+	key := magicLinkKey(token)
+
+	err := r.client.Set(ctx, key, userID.String(), magicLinkTokenTTL).Err()
+	if err != nil {
+		return fmt.Errorf("failed to store magic link token: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeMagicLinkToken returns the user ID for a magic link token and deletes it.
+// GETDEL makes this atomic, so a link can only ever be exchanged once.
+func (r *MagicLinkRepository) ConsumeMagicLinkToken(ctx context.Context, token string) (uuid.UUID, error) {
+	userIDStr, err := r.client.GetDel(ctx, magicLinkKey(token)).Result()
+	if err == redis.Nil {
+		return uuid.Nil, ErrMagicLinkTokenNotFound
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to consume magic link token: %w", err)
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to parse user ID: %w", err)
+	}
+
+	return userID, nil
+}
+
+// magicLinkKey generates a Redis key for magic link tokens
+func magicLinkKey(token string) string {
+	return purposeMagicLink.key(token)
+}
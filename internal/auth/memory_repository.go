@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memorySweepInterval is how often expired tokens are evicted on writes
+const memorySweepInterval = time.Minute
+
+// MemoryRepository keeps refresh tokens in process memory. Tokens are lost on
+// restart and aren't shared between instances, so it only suits tests and
+// single-instance deployments. Revocation behaves like the Redis repository:
+// revoked tokens stay retrievable with RevokedAt set until they expire, so
+// reuse of a rotated token is still detected.
+type MemoryRepository struct {
+	mu         sync.Mutex
+	tokens     map[string]*RefreshToken          // By token hash
+	userTokens map[uuid.UUID]map[string]struct{} // Token hashes per user, pruned lazily
+	rotations  map[string]memoryRotation         // Sealed replacements by old token hash
+	nextSweep  time.Time
+}
+
+// memoryRotation is a cached rotation kept for the refresh reuse grace window
+type memoryRotation struct {
+	sealed    []byte
+	expiresAt time.Time
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		tokens:     make(map[string]*RefreshToken),
+		userTokens: make(map[uuid.UUID]map[string]struct{}),
+		rotations:  make(map[string]memoryRotation),
+	}
+}
+
+// StoreRefreshToken stores a refresh token until it expires
+func (r *MemoryRepository) StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time, meta SessionMeta) error {
+	if time.Until(expiresAt) <= 0 {
+		return fmt.Errorf("token expiration time is in the past")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.sweep(now)
+
+	tokenHash := hashToken(token)
+	r.tokens[tokenHash] = &RefreshToken{
+		UserID:       userID,
+		TokenHash:    tokenHash,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    now,
+		LastActiveAt: now,
+		SessionID:    meta.ID,
+		IssuedIP:     meta.IP,
+		UserAgent:    meta.UserAgent,
+		AuthTime:     meta.AuthTime,
+		SessionOnly:  meta.SessionOnly,
+	}
+
+	hashes, ok := r.userTokens[userID]
+	if !ok {
+		hashes = make(map[string]struct{})
+		r.userTokens[userID] = hashes
+	}
+	hashes[tokenHash] = struct{}{}
+
+	return nil
+}
+
+// GetRefreshToken returns a copy of the stored token with its revoked state set.
+// Expired tokens are evicted and reported as not found, as they are in Redis.
+func (r *MemoryRepository) GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rt, ok := r.token(hashToken(token), time.Now())
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+
+	cp := *rt
+	return &cp, nil
+}
+
+// RevokeRefreshToken marks a refresh token as revoked
+func (r *MemoryRepository) RevokeRefreshToken(ctx context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	rt, ok := r.token(hashToken(token), now)
+	if !ok {
+		return ErrRefreshTokenNotFound
+	}
+
+	rt.RevokedAt = &now
+	return nil
+}
+
+// RevokeAllUserTokens revokes all refresh tokens for a user and returns how
+// many were still active
+func (r *MemoryRepository) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	active := 0
+	for tokenHash := range r.userTokens[userID] {
+		rt, ok := r.token(tokenHash, now)
+		if !ok || rt.RevokedAt != nil {
+			continue // expired or already revoked
+		}
+		rt.RevokedAt = &now
+		active++
+	}
+	delete(r.userTokens, userID)
+
+	return active, nil
+}
+
+// activeUserTokens returns the user's live tokens, pruning hashes of expired
+// and revoked ones from the user's set. The caller must hold r.mu.
+func (r *MemoryRepository) activeUserTokens(userID uuid.UUID, now time.Time) []*RefreshToken {
+	hashes := r.userTokens[userID]
+
+	var tokens []*RefreshToken
+	for tokenHash := range hashes {
+		rt, ok := r.token(tokenHash, now)
+		if !ok || rt.RevokedAt != nil {
+			delete(hashes, tokenHash)
+			continue
+		}
+		tokens = append(tokens, rt)
+	}
+
+	if len(hashes) == 0 {
+		delete(r.userTokens, userID)
+	}
+
+	return tokens
+}
+
+// ListUserSessions returns the user's active sessions, newest first
+func (r *MemoryRepository) ListUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens := r.activeUserTokens(userID, time.Now())
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, rt := range tokens {
+		sessions = append(sessions, sessionFromToken(rt))
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession revokes the user's active tokens for one session
+func (r *MemoryRepository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	found := false
+	for _, rt := range r.activeUserTokens(userID, now) {
+		if rt.SessionID != sessionID {
+			continue
+		}
+		rt.RevokedAt = &now
+		found = true
+	}
+
+	if !found {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// ClaimRotation remembers the tokens oldToken was rotated into for ttl, unless
+// another rotation of it is remembered already
+func (r *MemoryRepository) ClaimRotation(ctx context.Context, oldToken string, tokens *AuthTokens, ttl time.Duration) (bool, error) {
+	sealed, err := sealRotation(oldToken, tokens)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokenHash := hashToken(oldToken)
+	now := time.Now()
+	if rot, ok := r.rotations[tokenHash]; ok && now.Before(rot.expiresAt) {
+		return false, nil
+	}
+	r.rotations[tokenHash] = memoryRotation{sealed: sealed, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// GetRotation returns the tokens oldToken was rotated into, or nil if the
+// rotation was never cached or has expired
+func (r *MemoryRepository) GetRotation(ctx context.Context, oldToken string) (*AuthTokens, error) {
+	tokenHash := hashToken(oldToken)
+
+	r.mu.Lock()
+	rot, ok := r.rotations[tokenHash]
+	if ok && !time.Now().Before(rot.expiresAt) {
+		delete(r.rotations, tokenHash)
+		ok = false
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+	return openRotation(oldToken, rot.sealed)
+}
+
+// CleanupExpiredTokens evicts every expired token
+func (r *MemoryRepository) CleanupExpiredTokens(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextSweep = time.Time{}
+	r.sweep(time.Now())
+	return nil
+}
+
+// token returns the stored token for a hash, evicting it if it has expired.
+// The caller must hold r.mu.
+func (r *MemoryRepository) token(tokenHash string, now time.Time) (*RefreshToken, bool) {
+	rt, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(rt.ExpiresAt) {
+		delete(r.tokens, tokenHash)
+		return nil, false
+	}
+	return rt, true
+}
+
+// sweep evicts expired tokens, rotations and empty user sets at most once per
+// memorySweepInterval, so tokens nobody presents again don't accumulate.
+// The caller must hold r.mu.
+func (r *MemoryRepository) sweep(now time.Time) {
+	if now.Before(r.nextSweep) {
+		return
+	}
+	r.nextSweep = now.Add(memorySweepInterval)
+
+	for tokenHash, rt := range r.tokens {
+		if !now.Before(rt.ExpiresAt) {
+			delete(r.tokens, tokenHash)
+		}
+	}
+	for tokenHash, rot := range r.rotations {
+		if !now.Before(rot.expiresAt) {
+			delete(r.rotations, tokenHash)
+		}
+	}
+	for userID, hashes := range r.userTokens {
+		for tokenHash := range hashes {
+			if _, ok := r.tokens[tokenHash]; !ok {
+				delete(hashes, tokenHash)
+			}
+		}
+		if len(hashes) == 0 {
+			delete(r.userTokens, userID)
+		}
+	}
+}
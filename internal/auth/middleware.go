@@ -2,10 +2,15 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
+	"github.com/redmonkez12/go-api-template/internal/user"
 
 	"github.com/google/uuid"
 )
@@ -14,17 +19,52 @@ import (
 type ContextKey string
 
 const (
-	UserIDContextKey    ContextKey = "user_id"
-	UserEmailContextKey ContextKey = "user_email"
+	UserIDContextKey            ContextKey = "user_id"
+	UserEmailContextKey         ContextKey = "user_email"
+	UserEmailVerifiedContextKey ContextKey = "user_email_verified"
+	UserAuthTimeContextKey      ContextKey = "user_auth_time"
+	UserExtraClaimsContextKey   ContextKey = "user_extra_claims"
+	TenantIDContextKey          ContextKey = "tenant_id"
 )
 
 // Middleware handles authentication for protected routes
 type Middleware struct {
 	tokenService TokenService
+	// userRepo lets RequireVerifiedEmail recheck the database
+	userRepo user.RepositoryInterface
+	tenants  TenantResolver
 }
 
-func NewMiddleware(tokenService TokenService) *Middleware {
-	return &Middleware{tokenService: tokenService}
+func NewMiddleware(tokenService TokenService, userRepo user.RepositoryInterface, tenants TenantResolver) *Middleware {
+	return &Middleware{tokenService: tokenService, userRepo: userRepo, tenants: tenants}
+}
+
+// RequireTenant resolves the tenant a request is for and scopes its context
+// to it, answering 400 if the request names none or a malformed one. It does
+// nothing with tenancy off. Routes that look users up by email or username
+// need it, and RequireAuth then only accepts tokens issued in that tenant.
+func (m *Middleware) RequireTenant(next http.Handler) http.Handler {
+	if !m.tenants.Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, err := m.tenants.Resolve(r)
+		if err != nil {
+			if errors.Is(err, ErrInvalidTenant) {
+				httputil.RespondError(w, httputil.CodeInvalidTenant, http.StatusBadRequest)
+				return
+			}
+			httputil.RespondError(w, httputil.CodeTenantRequired, http.StatusBadRequest)
+			return
+		}
+
+		if meta, ok := reqmeta.FromContext(r.Context()); ok {
+			meta.TenantID = tenantID
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithTenantID(r.Context(), tenantID)))
+	})
 }
 
 // RequireAuth is a middleware that validates the access token
@@ -39,7 +79,7 @@ func (m *Middleware) RequireAuth(next http.Handler) http.Handler {
 			if len(parts) == 2 && parts[0] == "Bearer" {
 				token = parts[1]
 			} else {
-				httputil.RespondErrorWithCode(w, "invalid authorization header format", httputil.CodeInvalidAuthHeader, http.StatusUnauthorized)
+				httputil.RespondError(w, httputil.CodeInvalidAuthHeader, http.StatusUnauthorized)
 				return
 			}
 		}
@@ -48,7 +88,7 @@ func (m *Middleware) RequireAuth(next http.Handler) http.Handler {
 		if token == "" {
 			cookieToken, err := GetAccessTokenFromCookie(r)
 			if err != nil {
-				httputil.RespondErrorWithCode(w, "missing authentication", httputil.CodeMissingAuth, http.StatusUnauthorized)
+				httputil.RespondError(w, httputil.CodeMissingAuth, http.StatusUnauthorized)
 				return
 			}
 			token = cookieToken
@@ -58,29 +98,148 @@ func (m *Middleware) RequireAuth(next http.Handler) http.Handler {
 		claims, err := m.tokenService.VerifyToken(token)
 		if err != nil {
 			if err == ErrExpiredToken {
-				httputil.RespondErrorWithCode(w, "token has expired", httputil.CodeTokenExpired, http.StatusUnauthorized)
+				httputil.RespondError(w, httputil.CodeTokenExpired, http.StatusUnauthorized)
 				return
 			}
-			httputil.RespondErrorWithCode(w, "invalid token", httputil.CodeInvalidToken, http.StatusUnauthorized)
+			httputil.RespondError(w, httputil.CodeInvalidToken, http.StatusUnauthorized)
 			return
 		}
 
 		// Parse UUID from claims
 		userID, err := uuid.Parse(claims.UserID)
 		if err != nil {
-			httputil.RespondErrorWithCode(w, "invalid user ID in token", httputil.CodeInvalidTokenUserID, http.StatusUnauthorized)
+			httputil.RespondError(w, httputil.CodeInvalidTokenUserID, http.StatusUnauthorized)
 			return
 		}
 
+		// With tenancy on, a token only works in the tenant it was issued for.
+		// Routes without RequireTenant have no tenant, so tenant tokens fail.
+		if m.tenants.Enabled() {
+			tenantID, _ := GetTenantIDFromContext(r.Context())
+			if claims.TenantID != tenantID {
+				httputil.RespondError(w, httputil.CodeTenantMismatch, http.StatusForbidden)
+				return
+			}
+		}
+
 		// Add user info to request context
 		ctx := context.WithValue(r.Context(), UserIDContextKey, userID)
 		ctx = context.WithValue(ctx, UserEmailContextKey, claims.Email)
+		ctx = context.WithValue(ctx, UserEmailVerifiedContextKey, claims.EmailVerified)
+		ctx = context.WithValue(ctx, UserAuthTimeContextKey, claims.AuthTime)
+		ctx = context.WithValue(ctx, UserExtraClaimsContextKey, claims.Extra)
+
+		if meta, ok := reqmeta.FromContext(ctx); ok {
+			meta.UserID = userID
+		}
 
 		// Call next handler with updated context
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RequireFreshAuth rejects requests whose session was authenticated more than
+// maxAge ago, so sensitive actions need a recent login even with a valid
+// session. Refreshing tokens does not reset the clock. Must run after RequireAuth.
+func (m *Middleware) RequireFreshAuth(maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authTime, ok := GetAuthTimeFromContext(r.Context())
+			if !ok {
+				httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+				return
+			}
+
+			if time.Since(authTime) > maxAge {
+				httputil.RespondError(w, httputil.CodeReauthRequired, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin only lets through users whose email is one of adminEmails and
+// was verified when their token was issued, so an unverified account can't
+// claim an admin address. An entry like "acme:admin@example.com" is an admin
+// of tenant acme only; a plain email is one of users outside any tenant, so
+// registering the same address in a tenant grants nothing. Must run after
+// RequireAuth.
+func (m *Middleware) RequireAdmin(adminEmails []string) func(http.Handler) http.Handler {
+	admins := make(map[string]bool, len(adminEmails))
+	for _, entry := range adminEmails {
+		tenantID, email, ok := strings.Cut(entry, ":")
+		if !ok {
+			tenantID, email = "", entry
+		}
+		admins[adminKey(tenantID, email)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			email, ok := GetUserEmailFromContext(r.Context())
+			if !ok {
+				httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+				return
+			}
+
+			verified, _ := GetEmailVerifiedFromContext(r.Context())
+			if !verified || !admins[adminKey(contextTenantID(r.Context()), email)] {
+				httputil.RespondError(w, httputil.CodeAdminRequired, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// adminKey identifies an admin by tenant and email. Emails are unique per
+// tenant, so an address only grants admin in the tenant it is listed for.
+func adminKey(tenantID, email string) string {
+	return tenantID + ":" + strings.ToLower(email)
+}
+
+// RequireVerifiedEmail rejects users whose email isn't verified with 403
+// EMAIL_NOT_VERIFIED, such as those logged in during the verification grace
+// period. Must run after RequireAuth.
+//
+// Without recheck it trusts the token's email_verified claim, which costs
+// nothing but can be stale: a user who verifies after logging in is refused
+// until their next token refresh. With recheck, a token that says unverified
+// is checked against the database, so verification counts immediately at the
+// cost of a query for each request from an unverified token.
+func (m *Middleware) RequireVerifiedEmail(recheck bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			verified, ok := GetEmailVerifiedFromContext(r.Context())
+			if !ok {
+				httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+				return
+			}
+
+			if !verified && recheck {
+				userID, _ := GetUserIDFromContext(r.Context())
+				existingUser, err := m.userRepo.GetByID(r.Context(), userID)
+				if err != nil {
+					logger := logging.GetLoggerFromContext(r.Context())
+					httputil.RespondInternalError(w, logger, "failed to recheck email verification", err)
+					return
+				}
+				verified = existingUser.EmailVerified
+			}
+
+			if !verified {
+				httputil.RespondError(w, httputil.CodeEmailNotVerified, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUserIDFromContext extracts the user ID from the request context
 func GetUserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
 	userID, ok := ctx.Value(UserIDContextKey).(uuid.UUID)
@@ -92,3 +251,25 @@ func GetUserEmailFromContext(ctx context.Context) (string, bool) {
 	email, ok := ctx.Value(UserEmailContextKey).(string)
 	return email, ok
 }
+
+// GetEmailVerifiedFromContext reports whether the authenticated user's email was
+// verified when their access token was issued. Users logging in during the
+// verification grace period carry false here.
+func GetEmailVerifiedFromContext(ctx context.Context) (bool, bool) {
+	verified, ok := ctx.Value(UserEmailVerifiedContextKey).(bool)
+	return verified, ok
+}
+
+// GetAuthTimeFromContext returns when the authenticated user last logged in
+func GetAuthTimeFromContext(ctx context.Context) (time.Time, bool) {
+	authTime, ok := ctx.Value(UserAuthTimeContextKey).(time.Time)
+	return authTime, ok
+}
+
+// GetExtraClaimsFromContext returns the custom claims a ClaimsProvider added
+// to the authenticated user's access token. Numbers come back as float64,
+// as with any decoded JSON.
+func GetExtraClaimsFromContext(ctx context.Context) (map[string]any, bool) {
+	extra, ok := ctx.Value(UserExtraClaimsContextKey).(map[string]any)
+	return extra, ok
+}
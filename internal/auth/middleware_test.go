@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+)
+
+func TestRequireAdminScopesByTenant(t *testing.T) {
+	m := &Middleware{}
+	handler := m.RequireAdmin([]string{"root@example.com", "acme:Boss@Example.com"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }),
+	)
+
+	tests := []struct {
+		name     string
+		tenantID string
+		email    string
+		want     int
+	}{
+		{"plain entry without tenant", "", "root@example.com", http.StatusNoContent},
+		{"plain entry in a tenant", "acme", "root@example.com", http.StatusForbidden},
+		{"tenant entry in its tenant", "acme", "boss@example.com", http.StatusNoContent},
+		{"tenant entry in another tenant", "other", "boss@example.com", http.StatusForbidden},
+		{"tenant entry without tenant", "", "boss@example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), UserEmailContextKey, tt.email)
+			ctx = context.WithValue(ctx, UserEmailVerifiedContextKey, true)
+			if tt.tenantID != "" {
+				ctx = WithTenantID(ctx, tt.tenantID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireFreshAuth(t *testing.T) {
+	tokens, err := NewPasetoService([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMiddleware(tokens, nil, TenantResolver{})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	normal := m.RequireAuth(ok)
+	sensitive := m.RequireAuth(m.RequireFreshAuth(5 * time.Minute)(ok))
+
+	tests := []struct {
+		name      string
+		authAge   time.Duration
+		handler   http.Handler
+		wantCode  int
+		wantError httputil.ErrorCode
+	}{
+		{"fresh on a sensitive route", time.Minute, sensitive, http.StatusNoContent, ""},
+		{"stale on a sensitive route", 10 * time.Minute, sensitive, http.StatusForbidden, httputil.CodeReauthRequired},
+		{"stale on a normal route", 10 * time.Minute, normal, http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tokens.CreateToken(uuid.New(), "", "a@example.com", true, time.Now().Add(-tt.authAge), time.Minute, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantError != "" {
+				var resp httputil.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Code != tt.wantError {
+					t.Errorf("code = %s, %v, want %s", resp.Code, err, tt.wantError)
+				}
+			}
+		})
+	}
+}
@@ -8,12 +8,59 @@ import (
 
 // RefreshToken represents a stored refresh token in the database
 type RefreshToken struct {
-	ID        int64      `json:"id"`
-	UserID    uuid.UUID  `json:"user_id"`
-	TokenHash string     `json:"-"` // Never expose token hash
-	ExpiresAt time.Time  `json:"expires_at"`
-	CreatedAt time.Time  `json:"created_at"`
-	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	ID           int64      `json:"id"`
+	UserID       uuid.UUID  `json:"user_id"`
+	TokenHash    string     `json:"-"` // Never expose token hash
+	ExpiresAt    time.Time  `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastActiveAt time.Time  `json:"last_active_at"` // Last time the session was refreshed
+	SessionID    uuid.UUID  `json:"session_id"`     // Stable across refresh token rotation
+	IssuedIP     string     `json:"-"`              // Client IP the token was issued to, if recorded
+	UserAgent    string     `json:"-"`              // Client User-Agent the token was issued to, if recorded
+	SessionOnly  bool       `json:"-"`              // Issued without "remember me"; short-lived with a session cookie
+	AuthTime     time.Time  `json:"auth_time"`      // When the user authenticated to start this session
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// SessionMeta describes the session a refresh token belongs to. Handlers fill
+// in the client details; the service assigns the ID and auth time.
+type SessionMeta struct {
+	ID        uuid.UUID // Stable across refresh token rotation
+	AuthTime  time.Time // When the user authenticated to start the session
+	IP        string
+	UserAgent string
+	// SessionOnly gives the session the short refresh lifetime and a refresh
+	// cookie that ends with the browser session ("remember me" unchecked)
+	SessionOnly bool
+}
+
+// Start returns the metadata for a new session: a fresh ID, authenticated now
+func (m SessionMeta) Start() SessionMeta {
+	m.ID = uuid.New()
+	m.AuthTime = time.Now()
+	return m
+}
+
+// Session is an active login as shown to the user
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// sessionFromToken builds the user-facing view of the session a token belongs to
+func sessionFromToken(rt *RefreshToken) Session {
+	return Session{
+		ID:         rt.SessionID,
+		IP:         rt.IssuedIP,
+		UserAgent:  rt.UserAgent,
+		CreatedAt:  rt.AuthTime,
+		LastUsedAt: rt.LastActiveAt,
+		ExpiresAt:  rt.ExpiresAt,
+	}
 }
 
 // IsRevoked checks if the refresh token has been revoked
@@ -26,6 +73,12 @@ func (rt *RefreshToken) IsExpired() bool {
 	return time.Now().After(rt.ExpiresAt)
 }
 
+// IsInactive checks if the session has been idle longer than timeout.
+// A zero timeout disables the check.
+func (rt *RefreshToken) IsInactive(timeout time.Duration) bool {
+	return timeout > 0 && time.Since(rt.LastActiveAt) > timeout
+}
+
 // IsValid checks if the refresh token is valid (not revoked and not expired)
 func (rt *RefreshToken) IsValid() bool {
 	return !rt.IsRevoked() && !rt.IsExpired()
@@ -37,4 +90,22 @@ type AuthTokens struct {
 	RefreshToken string `json:"refresh_token"`
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int64  `json:"expires_in"` // seconds until access token expires
+	SessionOnly  bool   `json:"-"`          // refresh cookie should be a session cookie
+}
+
+// Invite lets one person register while registration is invite-only
+type Invite struct {
+	ID         uuid.UUID  `json:"id"`
+	Email      string     `json:"email,omitempty"`     // If set, only this address may register with the invite
+	TenantID   string     `json:"tenant_id,omitempty"` // Tenant the invite registers users in
+	CreatedBy  uuid.UUID  `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	ConsumedBy *uuid.UUID `json:"consumed_by,omitempty"` // User who registered with the invite
+}
+
+// IsConsumed checks if someone has already registered with the invite
+func (i *Invite) IsConsumed() bool {
+	return i.ConsumedAt != nil
 }
@@ -16,10 +16,15 @@ var (
 
 // TokenClaims represents the claims stored in a PASETO token
 type TokenClaims struct {
-	UserID    string    `json:"user_id"` // UUID stored as string in token
-	Email     string    `json:"email"`
-	IssuedAt  time.Time `json:"iat"`
-	ExpiresAt time.Time `json:"exp"`
+	UserID        string    `json:"user_id"` // UUID stored as string in token
+	TenantID      string    `json:"tenant_id,omitempty"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	IssuedAt      time.Time `json:"iat"`
+	AuthTime      time.Time `json:"auth_time"` // When the user last actually authenticated; kept across refreshes
+	ExpiresAt     time.Time `json:"exp"`
+	// Extra holds the claims a ClaimsProvider added, decoded from JSON
+	Extra map[string]any `json:"extra,omitempty"`
 }
 
 // PasetoService handles PASETO token creation and validation
@@ -43,15 +48,33 @@ func NewPasetoService(symmetricKey []byte) (*PasetoService, error) {
 	}, nil
 }
 
-// CreateToken generates a new PASETO v4.local token with the given claims and duration
-func (s *PasetoService) CreateToken(userID uuid.UUID, email string, duration time.Duration) (string, error) {
+// CreateToken generates a new PASETO v4.local token with the given claims and duration.
+// tenantID is omitted when empty. authTime is when the user last authenticated, which refreshed tokens carry over.
+// extra adds custom claims and may be nil; reserved claims return ErrReservedClaim.
+func (s *PasetoService) CreateToken(userID uuid.UUID, tenantID, email string, emailVerified bool, authTime time.Time, duration time.Duration, extra map[string]any) (string, error) {
+	if err := checkExtraClaims(extra); err != nil {
+		return "", err
+	}
+
 	now := time.Now()
 
 	token := paseto.NewToken()
 	token.SetIssuedAt(now)
 	token.SetExpiration(now.Add(duration))
 	token.SetString("user_id", userID.String())
+	if tenantID != "" {
+		token.SetString("tenant_id", tenantID)
+	}
 	token.SetString("email", email)
+	token.SetTime("auth_time", authTime)
+	if err := token.Set("email_verified", emailVerified); err != nil {
+		return "", fmt.Errorf("failed to set email_verified claim: %w", err)
+	}
+	for name, value := range extra {
+		if err := token.Set(name, value); err != nil {
+			return "", fmt.Errorf("failed to set %s claim: %w", name, err)
+		}
+	}
 
 	return token.V4Encrypt(s.symmetricKey, nil), nil
 }
@@ -62,8 +85,10 @@ func (s *PasetoService) VerifyToken(tokenStr string) (*TokenClaims, error) {
 
 	token, err := parser.ParseV4Local(s.symmetricKey, tokenStr, nil)
 	if err != nil {
-		// The parser checks expiration by default; distinguish expired from invalid
-		if errors.Is(err, &paseto.RuleError{}) {
+		// The parser's only rule is NotExpired, so a rule failure on an otherwise
+		// valid token means it expired. The library returns RuleError by value.
+		var ruleErr paseto.RuleError
+		if errors.As(err, &ruleErr) {
 			return nil, ErrExpiredToken
 		}
 		return nil, ErrInvalidToken
@@ -74,11 +99,20 @@ func (s *PasetoService) VerifyToken(tokenStr string) (*TokenClaims, error) {
 		return nil, ErrInvalidToken
 	}
 
+	// Tokens without a tenant belong to users outside any tenant
+	tenantID, _ := token.GetString("tenant_id")
+
 	email, err := token.GetString("email")
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
+	// Tokens issued before the claim existed were only given to verified users
+	emailVerified := true
+	if err := token.Get("email_verified", &emailVerified); err != nil {
+		emailVerified = true
+	}
+
 	issuedAt, err := token.GetIssuedAt()
 	if err != nil {
 		return nil, ErrInvalidToken
@@ -89,10 +123,20 @@ func (s *PasetoService) VerifyToken(tokenStr string) (*TokenClaims, error) {
 		return nil, ErrInvalidToken
 	}
 
+	// Tokens issued before the claim existed fall back to their issue time
+	authTime, err := token.GetTime("auth_time")
+	if err != nil {
+		authTime = issuedAt
+	}
+
 	return &TokenClaims{
-		UserID:    userID,
-		Email:     email,
-		IssuedAt:  issuedAt,
-		ExpiresAt: expiresAt,
+		UserID:        userID,
+		TenantID:      tenantID,
+		Email:         email,
+		EmailVerified: emailVerified,
+		IssuedAt:      issuedAt,
+		AuthTime:      authTime,
+		ExpiresAt:     expiresAt,
+		Extra:         extraClaims(token.Claims()),
 	}, nil
 }
@@ -9,21 +9,24 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-const passwordResetTokenTTL = 1 * time.Hour
-
 // PasswordResetRepository handles password reset token storage in Redis
 type PasswordResetRepository struct {
 	client *redis.Client
+	ttl    time.Duration
 }
 
 // NewPasswordResetRepository creates a new password reset repository instance
-func NewPasswordResetRepository(client *redis.Client) *PasswordResetRepository {
+// whose tokens stay valid for ttl
+func NewPasswordResetRepository(client *redis.Client, ttl time.Duration) *PasswordResetRepository {
 	return &PasswordResetRepository{
 		client: client,
+		ttl:    ttl,
 	}
 }
 
-// StorePasswordResetToken stores a password reset token with 1-hour TTL
+// StorePasswordResetToken stores a password reset token with the configured
+// TTL and invalidates the user's previous one, so only the most recently
+// emailed link works
 func (r *PasswordResetRepository) StorePasswordResetToken(ctx context.Context, userID uuid.UUID, token string) error {
 	key := passwordResetKey(token)
 
@@ -33,19 +36,34 @@ func (r *PasswordResetRepository) StorePasswordResetToken(ctx context.Context, u
 		return fmt.Errorf("failed to store password reset token: %w", err)
 	}
 
-	err = r.client.Expire(ctx, key, passwordResetTokenTTL).Err()
+	err = r.client.Expire(ctx, key, r.ttl).Err()
 	if err != nil {
 		return fmt.Errorf("failed to set TTL on password reset token: %w", err)
 	}
 
+	// Point the user at the new token and delete the one it replaces. The
+	// pointer holds the token's key, which is hashed, never the token itself.
+	previous, err := r.client.SetArgs(ctx, userPasswordResetKey(userID), key, redis.SetArgs{
+		Get: true,
+		TTL: r.ttl,
+	}).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to track current password reset token: %w", err)
+	}
+	if previous != "" && previous != key {
+		if err := r.client.Del(ctx, previous).Err(); err != nil {
+			return fmt.Errorf("failed to invalidate previous password reset token: %w", err)
+		}
+	}
+
 	return nil
 }
 
-// GetPasswordResetToken retrieves the user ID associated with a password reset token
+// GetPasswordResetToken retrieves the user ID associated with a password reset
+// token. Tokens stored before purposes were hashed into keys aren't looked up:
+// a new request couldn't invalidate them, so they stop working on upgrade.
 func (r *PasswordResetRepository) GetPasswordResetToken(ctx context.Context, token string) (uuid.UUID, error) {
-	key := passwordResetKey(token)
-
-	userIDStr, err := r.client.HGet(ctx, key, "user_id").Result()
+	userIDStr, err := r.client.HGet(ctx, passwordResetKey(token), "user_id").Result()
 	if err == redis.Nil {
 		return uuid.Nil, ErrPasswordResetTokenNotFound
 	}
@@ -63,9 +81,7 @@ func (r *PasswordResetRepository) GetPasswordResetToken(ctx context.Context, tok
 
 // DeletePasswordResetToken removes a used password reset token
 func (r *PasswordResetRepository) DeletePasswordResetToken(ctx context.Context, token string) error {
-	key := passwordResetKey(token)
-
-	err := r.client.Del(ctx, key).Err()
+	err := r.client.Del(ctx, passwordResetKey(token)).Err()
 	if err != nil {
 		return fmt.Errorf("failed to delete password reset token: %w", err)
 	}
@@ -75,7 +91,11 @@ func (r *PasswordResetRepository) DeletePasswordResetToken(ctx context.Context,
 
 // passwordResetKey generates a Redis key for password reset tokens
 func passwordResetKey(token string) string {
-	// Hash the token for security
-	hashedToken := hashToken(token)
-	return fmt.Sprintf("password_reset:%s", hashedToken)
+	return purposePasswordReset.key(token)
+}
+
+// userPasswordResetKey generates the Redis key holding the key of a user's
+// current password reset token
+func userPasswordResetKey(userID uuid.UUID) string {
+	return fmt.Sprintf("%s:user:%s", purposePasswordReset, userID)
 }
@@ -0,0 +1,364 @@
+package auth
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrPasswordTooWeak matches every *PasswordTooWeakError
+var ErrPasswordTooWeak = errors.New("password is too weak")
+
+// PasswordTooWeakError reports a password whose estimated strength is below
+// PASSWORD_MIN_SCORE. Reason tells the user what to change.
+type PasswordTooWeakError struct {
+	Score  int // 0 (trivial to guess) to 4 (very hard), as in zxcvbn
+	Reason string
+}
+
+func (e *PasswordTooWeakError) Error() string {
+	return "password is too weak: " + e.Reason
+}
+
+func (e *PasswordTooWeakError) Unwrap() error {
+	return ErrPasswordTooWeak
+}
+
+// Reasons a password is weak, from the pattern that gives it away
+const (
+	reasonEmail    = "it is based on your email address"
+	reasonUsername = "it is based on your username"
+	reasonCommon   = "it is too common"
+	reasonSequence = "it uses a predictable sequence like abc or 123"
+	reasonKeyboard = "it uses a keyboard pattern like qwerty"
+	reasonRepeat   = "it repeats characters like aaa"
+	reasonYear     = "years are easy to guess"
+	reasonSimple   = "it is too simple; add more words or characters"
+)
+
+// passwordPattern is a kind of guessable substring. Earlier kinds are
+// reported first when a password uses several.
+type passwordPattern int
+
+const (
+	patternBruteforce passwordPattern = iota
+	patternUserInput
+	patternCommon
+	patternSequence
+	patternKeyboard
+	patternRepeat
+	patternYear
+)
+
+var patternReasons = map[passwordPattern]string{
+	patternUserInput: reasonEmail,
+	patternCommon:    reasonCommon,
+	patternSequence:  reasonSequence,
+	patternKeyboard:  reasonKeyboard,
+	patternRepeat:    reasonRepeat,
+	patternYear:      reasonYear,
+}
+
+// passwordMatch is a guessable substring [start, end) of a password, in runes
+type passwordMatch struct {
+	pattern    passwordPattern
+	start, end int
+	guesses    float64
+}
+
+// maxWordLen bounds the dictionary words looked for, so long passwords don't
+// cost a lookup for every substring
+const maxWordLen = 32
+
+// minMatchGuesses keeps any multi-character match from counting as free,
+// as zxcvbn does
+const minMatchGuesses = 50
+
+// scoreThresholds are the log10 guesses a password needs for scores 1-4,
+// zxcvbn's 10^3, 10^6, 10^8 and 10^10
+var scoreThresholds = [...]float64{3, 6, 8, 10}
+
+// passwordStrength is the outcome of estimatePasswordStrength
+type passwordStrength struct {
+	score  int
+	reason string // Empty for score 4
+}
+
+// estimatePasswordStrength scores a password from 0 to 4 in the style of
+// zxcvbn: it finds the cheapest way to guess it as a sequence of common
+// passwords, sequences, keyboard runs, repeats, years and brute-forced
+// characters. Passwords containing the email's local part or the username
+// score 0, and pieces of the local part count as very common words.
+func estimatePasswordStrength(password, email, username string) passwordStrength {
+	lower := strings.ToLower(password)
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	if len(localPart) >= 3 && containsNormalized(lower, localPart) {
+		return passwordStrength{score: 0, reason: reasonEmail}
+	}
+	if username = strings.ToLower(username); len(username) >= 3 && containsNormalized(lower, username) {
+		return passwordStrength{score: 0, reason: reasonUsername}
+	}
+
+	runes := []rune(password)
+	var matches []passwordMatch
+	matches = append(matches, dictionaryMatches(runes, userInputWords(localPart), patternUserInput)...)
+	matches = append(matches, dictionaryMatches(runes, commonPasswordRanks, patternCommon)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	logGuesses, patterns := cheapestGuess(len(runes), matches)
+
+	score := 0
+	for score < len(scoreThresholds) && logGuesses >= scoreThresholds[score] {
+		score++
+	}
+	if score == len(scoreThresholds) {
+		return passwordStrength{score: score}
+	}
+
+	reason := reasonSimple
+	for p := patternUserInput; p <= patternYear; p++ {
+		if patterns[p] {
+			reason = patternReasons[p]
+			break
+		}
+	}
+	return passwordStrength{score: score, reason: reason}
+}
+
+// cheapestGuess returns the log10 guesses of the cheapest way to cover a
+// password of n runes with matches and brute-forced characters, and the
+// patterns that way uses
+func cheapestGuess(n int, matches []passwordMatch) (float64, map[passwordPattern]bool) {
+	// best[i] is the cheapest cover of the first i runes and via[i] the match
+	// ending it; a brute-forced character costs a factor of 10, as in zxcvbn
+	best := make([]float64, n+1)
+	via := make([]*passwordMatch, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = best[i-1] + 1
+		for j := range matches {
+			m := &matches[j]
+			if m.end != i {
+				continue
+			}
+			if cost := best[m.start] + math.Log10(max(m.guesses, minMatchGuesses)); cost < best[i] {
+				best[i] = cost
+				via[i] = m
+			}
+		}
+	}
+
+	patterns := make(map[passwordPattern]bool)
+	for i := n; i > 0; {
+		if m := via[i]; m != nil {
+			patterns[m.pattern] = true
+			i = m.start
+		} else {
+			i--
+		}
+	}
+	return best[n], patterns
+}
+
+// leetSubstitutions undoes common character swaps, like 0 for o
+var leetSubstitutions = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i',
+	'!': 'i', '|': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+// unleet lowercases r and undoes any leet substitution
+func unleet(r rune) rune {
+	r = unicode.ToLower(r)
+	if sub, ok := leetSubstitutions[r]; ok {
+		return sub
+	}
+	return r
+}
+
+// containsNormalized reports whether password contains word, as typed or
+// with leet substitutions undone
+func containsNormalized(password, word string) bool {
+	if strings.Contains(password, word) {
+		return true
+	}
+	return strings.Contains(strings.Map(unleet, password), strings.Map(unleet, word))
+}
+
+// dictionaryMatches finds the words of a ranked dictionary in password, as
+// typed or with leet substitutions undone. A word's guesses are its rank,
+// doubled for leet and for capitals other than a leading one.
+func dictionaryMatches(password []rune, ranks map[string]int, pattern passwordPattern) []passwordMatch {
+	lower := make([]rune, len(password))
+	plain := make([]rune, len(password))
+	for i, r := range password {
+		lower[i] = unicode.ToLower(r)
+		plain[i] = unleet(r)
+	}
+
+	var matches []passwordMatch
+	for start := range password {
+		for end := start + 3; end <= min(len(password), start+maxWordLen); end++ {
+			rank, ok := ranks[string(lower[start:end])]
+			leet := false
+			if !ok {
+				rank, ok = ranks[string(plain[start:end])]
+				leet = true
+			}
+			if !ok {
+				continue
+			}
+
+			guesses := float64(rank)
+			if leet {
+				guesses *= 2
+			}
+			if hasInnerCapital(password[start:end]) {
+				guesses *= 2
+			}
+			matches = append(matches, passwordMatch{pattern: pattern, start: start, end: end, guesses: guesses})
+		}
+	}
+	return matches
+}
+
+// hasInnerCapital reports whether word has an uppercase letter after its first
+func hasInnerCapital(word []rune) bool {
+	for _, r := range word[1:] {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// userInputWords ranks the pieces of an email's local part, like john and
+// smith in john.smith, as the most likely words of all
+func userInputWords(localPart string) map[string]int {
+	words := make(map[string]int)
+	for _, word := range strings.FieldsFunc(localPart, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		if len(word) >= 3 {
+			words[word] = 1
+		}
+	}
+	return words
+}
+
+// sequenceMatches finds runs of 3 or more characters stepping by one, like
+// abc, 321 or xyz
+func sequenceMatches(password []rune) []passwordMatch {
+	var matches []passwordMatch
+	for start := 0; start < len(password)-2; {
+		delta := password[start+1] - password[start]
+		end := start + 1
+		if delta == 1 || delta == -1 {
+			for end < len(password) && password[end]-password[end-1] == delta {
+				end++
+			}
+		}
+		if end-start >= 3 {
+			guesses := float64(sequenceBase(password[start]) * (end - start))
+			if delta < 0 {
+				guesses *= 2
+			}
+			matches = append(matches, passwordMatch{pattern: patternSequence, start: start, end: end, guesses: guesses})
+			start = end - 1
+			continue
+		}
+		start++
+	}
+	return matches
+}
+
+// sequenceBase is how many sequences start like one starting with r
+func sequenceBase(r rune) int {
+	switch {
+	case strings.ContainsRune("aAzZ019", r):
+		return 4
+	case unicode.IsDigit(r):
+		return 10
+	default:
+		return 26
+	}
+}
+
+// keyboardRows are QWERTY rows, whose runs are typed without thinking
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// keyboardMatches finds runs of 4 or more adjacent keys along a keyboard
+// row, in either direction
+func keyboardMatches(password []rune) []passwordMatch {
+	lower := []rune(strings.ToLower(string(password)))
+	var matches []passwordMatch
+	for _, row := range keyboardRows {
+		backwards := []rune(row)
+		slices.Reverse(backwards)
+		for _, keys := range [][]rune{[]rune(row), backwards} {
+			for start := range lower {
+				at := slices.Index(keys, lower[start])
+				if at < 0 {
+					continue
+				}
+				end := start + 1
+				for end < len(lower) && at+end-start < len(keys) && lower[end] == keys[at+end-start] {
+					end++
+				}
+				if end-start >= 4 {
+					matches = append(matches, passwordMatch{pattern: patternKeyboard, start: start, end: end, guesses: float64(minMatchGuesses * (end - start))})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// repeatMatches finds runs of 3 or more of the same character
+func repeatMatches(password []rune) []passwordMatch {
+	var matches []passwordMatch
+	for start := 0; start < len(password); {
+		end := start + 1
+		for end < len(password) && password[end] == password[start] {
+			end++
+		}
+		if end-start >= 3 {
+			matches = append(matches, passwordMatch{pattern: patternRepeat, start: start, end: end, guesses: float64(charCardinality(password[start]) * (end - start))})
+		}
+		start = end
+	}
+	return matches
+}
+
+// charCardinality is the size of the character class r belongs to
+func charCardinality(r rune) int {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLetter(r):
+		return 26
+	default:
+		return 33
+	}
+}
+
+// yearMatches finds years from 1900 to 2099. Recent years are the likeliest,
+// so guesses grow with the distance from now, as in zxcvbn.
+func yearMatches(password []rune) []passwordMatch {
+	now := time.Now().Year()
+	var matches []passwordMatch
+	for start := 0; start+4 <= len(password); start++ {
+		year, err := strconv.Atoi(string(password[start : start+4]))
+		if err != nil || year < 1900 || year > 2099 {
+			continue
+		}
+		distance := max(year-now, now-year, 20)
+		matches = append(matches, passwordMatch{pattern: patternYear, start: start, end: start + 4, guesses: float64(distance)})
+	}
+	return matches
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEstimatePasswordStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		maxScore int
+		reason   string
+	}{
+		{"password123", 1, reasonCommon},
+		{"p@ssw0rd", 0, reasonCommon},
+		{"Alice.Smith", 0, reasonEmail},
+		{"smithpass", 1, reasonEmail},
+		{"alice2024!", 0, reasonUsername},
+		{"abcdefgh", 0, reasonSequence},
+		{"aaaaaaaaaa", 0, reasonRepeat},
+		{"19871987", 1, reasonYear},
+		{"correct horse battery staple", 4, ""},
+		{"xK9#mQ2$vL7!", 4, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			got := estimatePasswordStrength(tt.password, "alice.smith@example.com", "alice")
+			if got.score > tt.maxScore || got.reason != tt.reason {
+				t.Errorf("estimatePasswordStrength() = %d %q, want at most %d %q", got.score, got.reason, tt.maxScore, tt.reason)
+			}
+			if tt.reason == "" && got.score != 4 {
+				t.Errorf("score = %d, want 4", got.score)
+			}
+		})
+	}
+}
+
+func TestWeakPasswordsAreRejected(t *testing.T) {
+	env := newTestEnv(t)
+	env.service.minPasswordScore = 3
+	ctx := context.Background()
+
+	_, err := env.service.Register(ctx, "alice@example.com", "", "password123", "")
+	var weak *PasswordTooWeakError
+	if !errors.As(err, &weak) || !errors.Is(err, ErrPasswordTooWeak) || weak.Reason != reasonCommon {
+		t.Fatalf("Register() error = %v, want a PasswordTooWeakError about common passwords", err)
+	}
+
+	u, _ := env.register(t, "bob@example.com")
+	token := env.service.RequestPasswordReset(ctx, u.Email)
+	if err := env.service.ResetPassword(ctx, token, "bob12345"); !errors.As(err, &weak) || weak.Reason != reasonEmail {
+		t.Errorf("ResetPassword() error = %v, want a PasswordTooWeakError about the email", err)
+	}
+	if err := env.service.ResetPassword(ctx, token, "xK9#mQ2$vL7!"); err != nil {
+		t.Errorf("ResetPassword() with a strong password error = %v", err)
+	}
+}
@@ -3,6 +3,8 @@ package auth
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,18 +25,30 @@ func getTokenKey(tokenHash string) string {
 	return fmt.Sprintf("refresh_token:%s", tokenHash)
 }
 
-// getRevokedKey generates the Redis key for a revoked token marker
+// getRevokedKey generates the Redis key for a revoked token marker. The
+// marker holds the Unix time the token was revoked at.
 func getRevokedKey(tokenHash string) string {
 	return fmt.Sprintf("refresh_token:revoked:%s", tokenHash)
 }
 
+// revokedMarker returns the value stored under a token's revoked key
+func revokedMarker() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+// getRotationKey generates the Redis key for the sealed tokens a refresh
+// token was rotated into
+func getRotationKey(tokenHash string) string {
+	return fmt.Sprintf("refresh_token:rotated:%s", tokenHash)
+}
+
 // getUserTokensKey generates the Redis key for user's token set
 func getUserTokensKey(userID uuid.UUID) string {
 	return fmt.Sprintf("user_tokens:%s", userID.String())
 }
 
 // StoreRefreshToken stores a refresh token in Redis with TTL
-func (r *RedisRepository) StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error {
+func (r *RedisRepository) StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time, meta SessionMeta) error {
 	tokenHash := hashToken(token)
 	tokenKey := getTokenKey(tokenHash)
 	userTokensKey := getUserTokensKey(userID)
@@ -48,16 +62,26 @@ func (r *RedisRepository) StoreRefreshToken(ctx context.Context, userID uuid.UUI
 	pipe := r.client.Pipeline()
 
 	// Store token with user_id and expiration as a hash
+	now := time.Now().Unix()
 	pipe.HSet(ctx, tokenKey, map[string]interface{}{
-		"user_id":    userID.String(),
-		"expires_at": expiresAt.Unix(),
-		"created_at": time.Now().Unix(),
+		"user_id":        userID.String(),
+		"expires_at":     expiresAt.Unix(),
+		"created_at":     now,
+		"last_active_at": now,
+		"session_id":     meta.ID.String(),
+		"ip":             meta.IP,
+		"user_agent":     meta.UserAgent,
+		"auth_time":      meta.AuthTime.Unix(),
+		"session_only":   meta.SessionOnly,
 	})
 	pipe.Expire(ctx, tokenKey, ttl)
 
-	// Add token hash to user's set of tokens (also with TTL)
+	// Add token hash to user's set of tokens. The set must outlive its
+	// longest-lived token, so a shorter token (a session-only login) never
+	// shortens its TTL: NX sets it on a new set, GT only ever extends it.
 	pipe.SAdd(ctx, userTokensKey, tokenHash)
-	pipe.Expire(ctx, userTokensKey, ttl)
+	pipe.ExpireNX(ctx, userTokensKey, ttl)
+	pipe.ExpireGT(ctx, userTokensKey, ttl)
 
 	_, err := pipe.Exec(ctx)
 	if err != nil {
@@ -67,31 +91,45 @@ func (r *RedisRepository) StoreRefreshToken(ctx context.Context, userID uuid.UUI
 	return nil
 }
 
-// GetRefreshToken retrieves a refresh token by its hash
+// GetRefreshToken retrieves a refresh token by its hash. Like the SQL
+// repository, revoked and expired tokens are returned with their state set
+// rather than as errors.
 func (r *RedisRepository) GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
 	tokenHash := hashToken(token)
-	tokenKey := getTokenKey(tokenHash)
-	revokedKey := getRevokedKey(tokenHash)
 
-	// Check if token is revoked
-	revoked, err := r.client.Exists(ctx, revokedKey).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to check revocation: %w", err)
+	// Get token data and its revocation marker in one round trip
+	pipe := r.client.Pipeline()
+	dataCmd := pipe.HGetAll(ctx, getTokenKey(tokenHash))
+	revokedCmd := pipe.Get(ctx, getRevokedKey(tokenHash))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
 	}
-	if revoked > 0 {
-		return nil, ErrRefreshTokenRevoked
+
+	data := dataCmd.Val()
+	if len(data) == 0 {
+		return nil, ErrRefreshTokenNotFound
 	}
 
-	// Get token data
-	data, err := r.client.HGetAll(ctx, tokenKey).Result()
+	rt, err := parseTokenHash(tokenHash, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+		return nil, err
 	}
 
-	if len(data) == 0 {
-		return nil, ErrRefreshTokenNotFound
+	if revokedCmd.Err() == nil {
+		// Markers written before the revocation time was stored hold "1"
+		revokedAt := time.Now()
+		if unix, err := strconv.ParseInt(revokedCmd.Val(), 10, 64); err == nil && unix > 1 {
+			revokedAt = time.Unix(unix, 0)
+		}
+		rt.RevokedAt = &revokedAt
 	}
 
+	return rt, nil
+}
+
+// parseTokenHash builds a RefreshToken from its stored Redis hash. Fields added
+// after a token was stored fall back to values derived from older fields.
+func parseTokenHash(tokenHash string, data map[string]string) (*RefreshToken, error) {
 	// Parse user_id
 	userID, err := uuid.Parse(data["user_id"])
 	if err != nil {
@@ -103,22 +141,46 @@ func (r *RedisRepository) GetRefreshToken(ctx context.Context, token string) (*R
 	fmt.Sscanf(data["expires_at"], "%d", &expiresAtUnix)
 	expiresAt := time.Unix(expiresAtUnix, 0)
 
-	// Check expiration
-	if time.Now().After(expiresAt) {
-		return nil, ErrRefreshTokenExpired
-	}
-
 	// Parse created_at
 	var createdAtUnix int64
 	fmt.Sscanf(data["created_at"], "%d", &createdAtUnix)
 	createdAt := time.Unix(createdAtUnix, 0)
 
+	// Parse last_active_at (tokens stored before it existed fall back to created_at)
+	lastActiveAt := createdAt
+	if v, ok := data["last_active_at"]; ok {
+		var lastActiveUnix int64
+		fmt.Sscanf(v, "%d", &lastActiveUnix)
+		lastActiveAt = time.Unix(lastActiveUnix, 0)
+	}
+
+	// Parse auth_time (tokens stored before it existed fall back to created_at)
+	authTime := createdAt
+	if v, ok := data["auth_time"]; ok {
+		var authTimeUnix int64
+		fmt.Sscanf(v, "%d", &authTimeUnix)
+		authTime = time.Unix(authTimeUnix, 0)
+	}
+
+	// Parse session_id (tokens stored before it existed get an ID derived from
+	// their hash, so they can still be listed and revoked individually)
+	sessionID, err := uuid.Parse(data["session_id"])
+	if err != nil {
+		sessionID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(tokenHash))
+	}
+
 	return &RefreshToken{
-		UserID:    userID,
-		TokenHash: tokenHash,
-		ExpiresAt: expiresAt,
-		CreatedAt: createdAt,
-		RevokedAt: nil, // Not revoked if we got here
+		UserID:       userID,
+		TokenHash:    tokenHash,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    createdAt,
+		LastActiveAt: lastActiveAt,
+		SessionID:    sessionID,
+		IssuedIP:     data["ip"],
+		UserAgent:    data["user_agent"],
+		AuthTime:     authTime,
+		SessionOnly:  data["session_only"] == "1",
+		RevokedAt:    nil, // Set by the caller from the revoked marker
 	}, nil
 }
 
@@ -145,10 +207,10 @@ func (r *RedisRepository) RevokeRefreshToken(ctx context.Context, token string)
 
 	// Mark as revoked with same TTL as the token
 	if ttl > 0 {
-		err = r.client.Set(ctx, revokedKey, "1", ttl).Err()
+		err = r.client.Set(ctx, revokedKey, revokedMarker(), ttl).Err()
 	} else {
 		// Fallback if TTL is not available
-		err = r.client.Set(ctx, revokedKey, "1", 7*24*time.Hour).Err()
+		err = r.client.Set(ctx, revokedKey, revokedMarker(), 7*24*time.Hour).Err()
 	}
 
 	if err != nil {
@@ -158,43 +220,188 @@ func (r *RedisRepository) RevokeRefreshToken(ctx context.Context, token string)
 	return nil
 }
 
-// RevokeAllUserTokens revokes all refresh tokens for a user
-func (r *RedisRepository) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error {
+// RevokeAllUserTokens revokes all refresh tokens for a user and returns how
+// many were still active. Revoked hashes are removed from the user's token set
+// so a later call doesn't walk them again.
+func (r *RedisRepository) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) (int, error) {
 	userTokensKey := getUserTokensKey(userID)
 
 	// Get all token hashes for this user
 	tokenHashes, err := r.client.SMembers(ctx, userTokensKey).Result()
 	if err != nil {
-		return fmt.Errorf("failed to get user tokens: %w", err)
+		return 0, fmt.Errorf("failed to get user tokens: %w", err)
 	}
 
 	if len(tokenHashes) == 0 {
-		return nil // No tokens to revoke
+		return 0, nil // No tokens to revoke
 	}
 
-	// Revoke each token
+	// Look up each token's remaining TTL and revocation state in one round trip
 	pipe := r.client.Pipeline()
-	for _, tokenHash := range tokenHashes {
-		tokenKey := getTokenKey(tokenHash)
-		revokedKey := getRevokedKey(tokenHash)
-
-		// Get TTL from original token
-		ttl, _ := r.client.TTL(ctx, tokenKey).Result()
-		if ttl > 0 {
-			pipe.Set(ctx, revokedKey, "1", ttl)
-		} else {
-			pipe.Set(ctx, revokedKey, "1", 7*24*time.Hour)
+	ttls := make([]*redis.DurationCmd, len(tokenHashes))
+	revoked := make([]*redis.IntCmd, len(tokenHashes))
+	for i, tokenHash := range tokenHashes {
+		ttls[i] = pipe.TTL(ctx, getTokenKey(tokenHash))
+		revoked[i] = pipe.Exists(ctx, getRevokedKey(tokenHash))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to get user token state: %w", err)
+	}
+
+	// Revoke tokens that are still live, marking each for the rest of its lifetime
+	pipe = r.client.TxPipeline()
+	active := 0
+	for i, tokenHash := range tokenHashes {
+		ttl := ttls[i].Val()
+		if ttl <= 0 || revoked[i].Val() > 0 {
+			continue // expired or already revoked
 		}
+		pipe.Set(ctx, getRevokedKey(tokenHash), revokedMarker(), ttl)
+		active++
+	}
+
+	// Remove only the hashes we saw so tokens issued meanwhile stay tracked
+	members := make([]interface{}, len(tokenHashes))
+	for i, tokenHash := range tokenHashes {
+		members[i] = tokenHash
+	}
+	pipe.SRem(ctx, userTokensKey, members...)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to revoke all user tokens: %w", err)
 	}
 
-	_, err = pipe.Exec(ctx)
+	return active, nil
+}
+
+// activeUserTokens loads the user's live tokens, skipping expired and revoked ones.
+// Token keys expire on their own while their hashes stay in the user's set, so
+// hashes that no longer lead to a live token are pruned from the set on the way.
+func (r *RedisRepository) activeUserTokens(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error) {
+	userTokensKey := getUserTokensKey(userID)
+
+	tokenHashes, err := r.client.SMembers(ctx, userTokensKey).Result()
 	if err != nil {
-		return fmt.Errorf("failed to revoke all user tokens: %w", err)
+		return nil, fmt.Errorf("failed to get user tokens: %w", err)
+	}
+
+	if len(tokenHashes) == 0 {
+		return nil, nil
+	}
+
+	// Fetch every token and its revocation marker in one round trip
+	pipe := r.client.Pipeline()
+	data := make([]*redis.MapStringStringCmd, len(tokenHashes))
+	revoked := make([]*redis.IntCmd, len(tokenHashes))
+	for i, tokenHash := range tokenHashes {
+		data[i] = pipe.HGetAll(ctx, getTokenKey(tokenHash))
+		revoked[i] = pipe.Exists(ctx, getRevokedKey(tokenHash))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get user tokens: %w", err)
+	}
+
+	tokens := make([]*RefreshToken, 0, len(tokenHashes))
+	var stale []interface{}
+	for i, tokenHash := range tokenHashes {
+		if revoked[i].Val() > 0 || len(data[i].Val()) == 0 {
+			stale = append(stale, tokenHash)
+			continue
+		}
+
+		rt, err := parseTokenHash(tokenHash, data[i].Val())
+		if err != nil || rt.IsExpired() {
+			stale = append(stale, tokenHash)
+			continue
+		}
+		tokens = append(tokens, rt)
+	}
+
+	// Pruning is best effort: a failure only leaves the set as it was
+	if len(stale) > 0 {
+		r.client.SRem(ctx, userTokensKey, stale...)
+	}
+
+	return tokens, nil
+}
+
+// ListUserSessions returns the user's active sessions, newest first
+func (r *RedisRepository) ListUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	tokens, err := r.activeUserTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, rt := range tokens {
+		sessions = append(sessions, sessionFromToken(rt))
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession revokes the user's active tokens for one session
+func (r *RedisRepository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	tokens, err := r.activeUserTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	found := false
+	for _, rt := range tokens {
+		if rt.SessionID != sessionID {
+			continue
+		}
+		if ttl := time.Until(rt.ExpiresAt); ttl > 0 {
+			pipe.Set(ctx, getRevokedKey(rt.TokenHash), revokedMarker(), ttl)
+			found = true
+		}
+	}
+
+	if !found {
+		return ErrSessionNotFound
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
 	}
 
 	return nil
 }
 
+// ClaimRotation remembers the tokens oldToken was rotated into for ttl, unless
+// another rotation of it is remembered already
+func (r *RedisRepository) ClaimRotation(ctx context.Context, oldToken string, tokens *AuthTokens, ttl time.Duration) (bool, error) {
+	sealed, err := sealRotation(oldToken, tokens)
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := r.client.SetNX(ctx, getRotationKey(hashToken(oldToken)), sealed, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to store rotation: %w", err)
+	}
+	return claimed, nil
+}
+
+// GetRotation returns the tokens oldToken was rotated into, or nil if the
+// rotation was never cached or has expired
+func (r *RedisRepository) GetRotation(ctx context.Context, oldToken string) (*AuthTokens, error) {
+	sealed, err := r.client.Get(ctx, getRotationKey(hashToken(oldToken))).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rotation: %w", err)
+	}
+	return openRotation(oldToken, sealed)
+}
+
 // CleanupExpiredTokens is not needed for Redis as TTL handles expiration automatically
 // This method is kept for interface compatibility but does nothing
 func (r *RedisRepository) CleanupExpiredTokens(ctx context.Context) error {
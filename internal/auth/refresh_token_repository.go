@@ -9,9 +9,16 @@ import (
 
 // RefreshTokenRepository defines the interface for refresh token storage
 type RefreshTokenRepository interface {
-	StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error
+	StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time, meta SessionMeta) error
+	// GetRefreshToken returns the stored token with its revoked and expired
+	// state populated, or ErrRefreshTokenNotFound. Validity is left to the caller.
 	GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error)
 	RevokeRefreshToken(ctx context.Context, token string) error
-	RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error
+	RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) (int, error)
+	// ListUserSessions returns the user's active (unrevoked, unexpired) sessions
+	ListUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error)
+	// RevokeSession revokes the user's tokens for one session, returning
+	// ErrSessionNotFound if it has none active
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
 	CleanupExpiredTokens(ctx context.Context) error
 }
@@ -15,21 +15,32 @@ import (
 
 // Repository handles refresh token persistence
 type Repository struct {
-	db *bun.DB
+	db bun.IDB
 }
 
 func NewRepository(db *bun.DB) *Repository {
 	return &Repository{db: db}
 }
 
+// WithDB returns a copy of the repository that runs its queries on db,
+// usually a transaction from database.UnitOfWork
+func (r *Repository) WithDB(db bun.IDB) *Repository {
+	return &Repository{db: db}
+}
+
 // StoreRefreshToken stores a refresh token in the database
-func (r *Repository) StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time) error {
+func (r *Repository) StoreRefreshToken(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time, meta SessionMeta) error {
 	tokenHash := hashToken(token)
 
 	dbToken := &database.RefreshToken{
-		UserID:    userID,
-		TokenHash: tokenHash,
-		ExpiresAt: expiresAt,
+		UserID:      userID,
+		TokenHash:   tokenHash,
+		SessionID:   meta.ID,
+		IPAddress:   meta.IP,
+		UserAgent:   meta.UserAgent,
+		AuthTime:    meta.AuthTime,
+		SessionOnly: meta.SessionOnly,
+		ExpiresAt:   expiresAt,
 	}
 
 	_, err := r.db.NewInsert().
@@ -87,16 +98,72 @@ func (r *Repository) RevokeRefreshToken(ctx context.Context, token string) error
 	return nil
 }
 
-// RevokeAllUserTokens revokes all refresh tokens for a user
-func (r *Repository) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error {
-	_, err := r.db.NewUpdate().
+// RevokeAllUserTokens revokes all refresh tokens for a user and returns how
+// many were still active
+func (r *Repository) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) (int, error) {
+	result, err := r.db.NewUpdate().
 		Model((*database.RefreshToken)(nil)).
 		Set("revoked_at = NOW()").
 		Where("user_id = ?", userID).
 		Where("revoked_at IS NULL").
+		Where("expires_at > NOW()").
 		Exec(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to revoke all user tokens: %w", err)
+		return 0, fmt.Errorf("failed to revoke all user tokens: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count revoked tokens: %w", err)
+	}
+
+	return int(rows), nil
+}
+
+// ListUserSessions returns the user's active sessions. Rotation leaves one
+// live token per session, which carries the session's latest activity.
+func (r *Repository) ListUserSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	var dbTokens []database.RefreshToken
+	err := r.db.NewSelect().
+		Model(&dbTokens).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Where("expires_at > NOW()").
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user sessions: %w", err)
+	}
+
+	sessions := make([]Session, 0, len(dbTokens))
+	for i := range dbTokens {
+		sessions = append(sessions, sessionFromToken(mapDBRefreshTokenToModel(&dbTokens[i])))
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes the user's active tokens for one session
+func (r *Repository) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	result, err := r.db.NewUpdate().
+		Model((*database.RefreshToken)(nil)).
+		Set("revoked_at = NOW()").
+		Where("user_id = ?", userID).
+		Where("session_id = ?", sessionID).
+		Where("revoked_at IS NULL").
+		Where("expires_at > NOW()").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
 	}
 
 	return nil
@@ -124,6 +191,13 @@ func mapDBRefreshTokenToModel(dbt *database.RefreshToken) *RefreshToken {
 		TokenHash: dbt.TokenHash,
 		ExpiresAt: dbt.ExpiresAt,
 		CreatedAt: dbt.CreatedAt,
-		RevokedAt: dbt.RevokedAt,
+		// Tokens are rotated on every refresh, so creation marks the last activity
+		LastActiveAt: dbt.CreatedAt,
+		SessionID:    dbt.SessionID,
+		IssuedIP:     dbt.IPAddress,
+		UserAgent:    dbt.UserAgent,
+		AuthTime:     dbt.AuthTime,
+		SessionOnly:  dbt.SessionOnly,
+		RevokedAt:    dbt.RevokedAt,
 	}
 }
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/redmonkez12/go-api-template/internal/database"
+)
+
+// tokenBackend is a refresh token store under test, with a way to push a
+// stored token past its expiry without waiting for it
+type tokenBackend struct {
+	repo   RefreshTokenRepository
+	expire func(t *testing.T, token string)
+}
+
+// sqlRowRepository stands in for the SQL repository, which needs Postgres:
+// it keeps rows as the refresh_tokens table would and reads them back through
+// the same mapping Repository.GetRefreshToken uses
+type sqlRowRepository struct {
+	RefreshTokenRepository
+	rows map[string]*database.RefreshToken
+}
+
+func (r *sqlRowRepository) StoreRefreshToken(_ context.Context, userID uuid.UUID, token string, expiresAt time.Time, meta SessionMeta) error {
+	r.rows[hashToken(token)] = &database.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		SessionID: meta.ID,
+		AuthTime:  meta.AuthTime,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (r *sqlRowRepository) GetRefreshToken(_ context.Context, token string) (*RefreshToken, error) {
+	row, ok := r.rows[hashToken(token)]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+	return mapDBRefreshTokenToModel(row), nil
+}
+
+func (r *sqlRowRepository) RevokeRefreshToken(_ context.Context, token string) error {
+	row, ok := r.rows[hashToken(token)]
+	if !ok {
+		return ErrRefreshTokenNotFound
+	}
+	now := time.Now()
+	row.RevokedAt = &now
+	return nil
+}
+
+func tokenBackends(t *testing.T) map[string]tokenBackend {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	memory := NewMemoryRepository()
+	rows := &sqlRowRepository{rows: make(map[string]*database.RefreshToken)}
+
+	return map[string]tokenBackend{
+		"memory": {
+			repo: memory,
+			expire: func(t *testing.T, token string) {
+				memory.mu.Lock()
+				defer memory.mu.Unlock()
+				memory.tokens[hashToken(token)].ExpiresAt = time.Now().Add(-time.Second)
+			},
+		},
+		"redis": {
+			repo: NewRedisRepository(client),
+			expire: func(t *testing.T, token string) {
+				// The key outlives expires_at by up to a second of TTL rounding
+				mr.HSet(getTokenKey(hashToken(token)), "expires_at", "1")
+			},
+		},
+		"sql": {
+			repo: rows,
+			expire: func(t *testing.T, token string) {
+				rows.rows[hashToken(token)].ExpiresAt = time.Now().Add(-time.Second)
+			},
+		},
+	}
+}
+
+// getRejected reports whether the store presents token as unusable: either
+// gone, or returned with a state that fails IsValid
+func getRejected(t *testing.T, repo RefreshTokenRepository, token string) (*RefreshToken, bool) {
+	t.Helper()
+	rt, err := repo.GetRefreshToken(context.Background(), token)
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return nil, true
+	}
+	if err != nil {
+		t.Fatalf("GetRefreshToken() error = %v", err)
+	}
+	return rt, !rt.IsValid()
+}
+
+func TestRefreshTokenValidityMatchesAcrossBackends(t *testing.T) {
+	ctx := context.Background()
+	meta := SessionMeta{}.Start()
+
+	for name, backend := range tokenBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			repo := backend.repo
+			for _, token := range []string{"active", "revoked", "expired"} {
+				if err := repo.StoreRefreshToken(ctx, uuid.New(), token, time.Now().Add(time.Hour), meta); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if rt, rejected := getRejected(t, repo, "active"); rejected || rt.IsRevoked() || rt.IsExpired() {
+				t.Error("active token is not valid")
+			}
+
+			if err := repo.RevokeRefreshToken(ctx, "revoked"); err != nil {
+				t.Fatal(err)
+			}
+			rt, rejected := getRejected(t, repo, "revoked")
+			if !rejected {
+				t.Error("revoked token is valid")
+			}
+			// The service tells reuse of a revoked token apart from an
+			// unknown one, so the revoked state must come back populated
+			if rt == nil || !rt.IsRevoked() || rt.RevokedAt.IsZero() {
+				t.Errorf("revoked token = %+v, want one with RevokedAt set", rt)
+			}
+
+			backend.expire(t, "expired")
+			if rt, rejected := getRejected(t, repo, "expired"); !rejected || (rt != nil && !rt.IsExpired()) {
+				t.Errorf("expired token = %+v, rejected = %v, want not found or IsExpired", rt, rejected)
+			}
+		})
+	}
+}
+
+func TestRevokeAllOutlivesShorterTokens(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	memory := NewMemoryRepository()
+
+	backends := map[string]struct {
+		repo    RefreshTokenRepository
+		advance func(d time.Duration)
+	}{
+		"redis": {NewRedisRepository(client), mr.FastForward},
+		"memory": {memory, func(d time.Duration) {
+			memory.mu.Lock()
+			defer memory.mu.Unlock()
+			for _, rt := range memory.tokens {
+				rt.ExpiresAt = rt.ExpiresAt.Add(-d)
+			}
+		}},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			userID := uuid.New()
+			now := time.Now()
+			// A remembered login followed by a session-only one
+			if err := backend.repo.StoreRefreshToken(ctx, userID, name+"-long", now.Add(7*24*time.Hour), SessionMeta{}.Start()); err != nil {
+				t.Fatal(err)
+			}
+			if err := backend.repo.StoreRefreshToken(ctx, userID, name+"-short", now.Add(24*time.Hour), SessionMeta{}.Start()); err != nil {
+				t.Fatal(err)
+			}
+			backend.advance(25 * time.Hour)
+
+			revoked, err := backend.repo.RevokeAllUserTokens(ctx, userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != 1 {
+				t.Errorf("RevokeAllUserTokens() = %d, want the long-lived token revoked", revoked)
+			}
+			rt, err := backend.repo.GetRefreshToken(ctx, name+"-long")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rt.IsRevoked() {
+				t.Error("long-lived token is still valid after RevokeAllUserTokens")
+			}
+		})
+	}
+}
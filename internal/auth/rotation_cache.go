@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RotationCache remembers, for a short grace window, which tokens a refresh
+// token was rotated into. A client that submits the same refresh twice, as
+// mobile apps do when two requests race, then gets the same replacement both
+// times instead of being logged out for reusing a revoked token.
+// RedisRepository and MemoryRepository implement it; other repositories get
+// no grace window.
+type RotationCache interface {
+	// ClaimRotation caches tokens as oldToken's replacement for ttl unless
+	// another rotation of oldToken is cached already, and reports whether it
+	// did. Of concurrent refreshes with the same token, only one claims it.
+	ClaimRotation(ctx context.Context, oldToken string, tokens *AuthTokens, ttl time.Duration) (bool, error)
+	// GetRotation returns the tokens oldToken was rotated into, or nil once
+	// the grace window has passed
+	GetRotation(ctx context.Context, oldToken string) (*AuthTokens, error)
+}
+
+// rotatedTokens is the cached form of AuthTokens, which leaves SessionOnly
+// out of its JSON
+type rotatedTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	SessionOnly  bool   `json:"session_only"`
+}
+
+// sealRotation encrypts tokens with a key derived from the old token. Like
+// refresh tokens themselves, the cached replacement is then useless to anyone
+// reading the store without the old token in hand.
+func sealRotation(oldToken string, tokens *AuthTokens) ([]byte, error) {
+	plaintext, err := json.Marshal(rotatedTokens{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    tokens.TokenType,
+		ExpiresIn:    tokens.ExpiresIn,
+		SessionOnly:  tokens.SessionOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rotated tokens: %w", err)
+	}
+
+	gcm, err := rotationCipher(oldToken)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// openRotation decrypts tokens sealed by sealRotation
+func openRotation(oldToken string, sealed []byte) (*AuthTokens, error) {
+	gcm, err := rotationCipher(oldToken)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("cached rotation is truncated")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cached rotation: %w", err)
+	}
+
+	var cached rotatedTokens
+	if err := json.Unmarshal(plaintext, &cached); err != nil {
+		return nil, fmt.Errorf("failed to decode rotated tokens: %w", err)
+	}
+	return &AuthTokens{
+		AccessToken:  cached.AccessToken,
+		RefreshToken: cached.RefreshToken,
+		TokenType:    cached.TokenType,
+		ExpiresIn:    cached.ExpiresIn,
+		SessionOnly:  cached.SessionOnly,
+	}, nil
+}
+
+// rotationCipher derives the AES-GCM cipher for an old token's rotation. The
+// label keeps the key distinct from the token's storage hash.
+func rotationCipher(oldToken string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("refresh_rotation:" + oldToken))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redmonkez12/go-api-template/internal/ratelimit"
+)
+
+// allowRefreshes is a RefreshLimiter that never throttles
+type allowRefreshes struct{}
+
+func (allowRefreshes) AllowRefresh(ctx context.Context, userID string) (ratelimit.Result, error) {
+	return ratelimit.Result{Allowed: true}, nil
+}
+
+func TestClaimRotation(t *testing.T) {
+	env := newTestEnv(t)
+	caches := map[string]RotationCache{
+		"memory": NewMemoryRepository(),
+		"redis":  NewRedisRepository(env.client),
+	}
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			first := &AuthTokens{AccessToken: "a1", RefreshToken: "r1", TokenType: "Bearer"}
+			second := &AuthTokens{AccessToken: "a2", RefreshToken: "r2", TokenType: "Bearer"}
+
+			if claimed, err := cache.ClaimRotation(ctx, "old", first, time.Minute); err != nil || !claimed {
+				t.Fatalf("first ClaimRotation() = %v, %v, want true", claimed, err)
+			}
+			if claimed, err := cache.ClaimRotation(ctx, "old", second, time.Minute); err != nil || claimed {
+				t.Fatalf("second ClaimRotation() = %v, %v, want false", claimed, err)
+			}
+			got, err := cache.GetRotation(ctx, "old")
+			if err != nil || got == nil || got.RefreshToken != "r1" {
+				t.Errorf("GetRotation() = %+v, %v, want the first rotation", got, err)
+			}
+		})
+	}
+}
+
+func TestConcurrentRefreshesShareOneRotation(t *testing.T) {
+	env := newTestEnv(t)
+	env.service.refreshLimiter = allowRefreshes{}
+	env.service.refreshReuseGrace = time.Minute
+	ctx := context.Background()
+
+	u, verificationToken := env.register(t, "racer@example.com")
+	if err := env.service.VerifyEmail(ctx, verificationToken); err != nil {
+		t.Fatal(err)
+	}
+	login, err := env.service.Login(ctx, u.Email, "correct horse battery staple", SessionMeta{})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	const requests = 10
+	results := make([]*AuthTokens, requests)
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Go(func() {
+			results[i], errs[i] = env.service.RefreshAccessToken(ctx, login.RefreshToken, SessionMeta{})
+		})
+	}
+	wg.Wait()
+
+	for i := range requests {
+		if errs[i] != nil {
+			t.Fatalf("refresh %d error = %v", i, errs[i])
+		}
+		if results[i].RefreshToken != results[0].RefreshToken {
+			t.Fatalf("refresh %d got a different refresh token than refresh 0", i)
+		}
+	}
+	sessions, err := env.service.ListSessions(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("ListSessions() returned %d sessions, want 1", len(sessions))
+	}
+	if _, err := env.service.RefreshAccessToken(ctx, results[0].RefreshToken, SessionMeta{}); err != nil {
+		t.Errorf("refreshing the shared replacement error = %v", err)
+	}
+}
+
+func TestRefreshRateLimitIsPerUser(t *testing.T) {
+	env := newTestEnv(t)
+	limiter := ratelimit.NewMemoryLimiter(ratelimit.LimiterConfig{RefreshMax: 2, RefreshWindow: time.Minute})
+	t.Cleanup(limiter.Close)
+	env.service.refreshLimiter = limiter
+	ctx := context.Background()
+
+	login := func(email string) string {
+		t.Helper()
+		u, verificationToken := env.register(t, email)
+		if err := env.service.VerifyEmail(ctx, verificationToken); err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := env.service.Login(ctx, u.Email, "correct horse battery staple", SessionMeta{})
+		if err != nil {
+			t.Fatalf("Login() error = %v", err)
+		}
+		return tokens.RefreshToken
+	}
+	alice, bob := login("alice@example.com"), login("bob@example.com")
+
+	for i := range 2 {
+		tokens, err := env.service.RefreshAccessToken(ctx, alice, SessionMeta{})
+		if err != nil {
+			t.Fatalf("alice refresh %d error = %v", i+1, err)
+		}
+		alice = tokens.RefreshToken
+	}
+	if _, err := env.service.RefreshAccessToken(ctx, alice, SessionMeta{}); !errors.Is(err, ErrRefreshRateLimited) {
+		t.Errorf("alice refresh 3 error = %v, want ErrRefreshRateLimited", err)
+	}
+	if _, err := env.service.RefreshAccessToken(ctx, bob, SessionMeta{}); err != nil {
+		t.Errorf("bob refresh error = %v, want bob unaffected", err)
+	}
+}
+
+func TestRefreshRejectsInactiveSessions(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		idle    time.Duration
+		wantErr error
+	}{
+		{"disabled", 0, 30 * time.Millisecond, nil},
+		{"active", time.Hour, 30 * time.Millisecond, nil},
+		{"inactive", 10 * time.Millisecond, 30 * time.Millisecond, ErrSessionInactive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.service.refreshLimiter = allowRefreshes{}
+			env.service.inactivityTimeout = tt.timeout
+			ctx := context.Background()
+
+			u, verificationToken := env.register(t, "idle@example.com")
+			if err := env.service.VerifyEmail(ctx, verificationToken); err != nil {
+				t.Fatal(err)
+			}
+			login, err := env.service.Login(ctx, u.Email, "correct horse battery staple", SessionMeta{})
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+			time.Sleep(tt.idle)
+
+			if _, err := env.service.RefreshAccessToken(ctx, login.RefreshToken, SessionMeta{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RefreshAccessToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				sessions, err := env.service.ListSessions(ctx, u.ID)
+				if err != nil || len(sessions) != 0 {
+					t.Errorf("ListSessions() = %d sessions, %v, want the inactive one revoked", len(sessions), err)
+				}
+			}
+		})
+	}
+}
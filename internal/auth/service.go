@@ -8,13 +8,18 @@ import (
 	"errors"
 	"fmt"
 	"net/mail"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/argon2"
 	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/ratelimit"
 	"github.com/redmonkez12/go-api-template/internal/user"
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -25,10 +30,29 @@ var (
 	ErrEmailNotVerified         = errors.New("email not verified, please check your inbox")
 	ErrInvalidVerificationToken = errors.New("invalid verification token")
 	ErrTokenExpired             = errors.New("verification token has expired")
+	ErrVerificationSuperseded   = errors.New("verification token was replaced by a newer one")
 	ErrEmailAlreadyVerified     = errors.New("email already verified")
 	ErrInvalidEmailFormat       = errors.New("invalid email format")
+	ErrInviteRequired           = errors.New("an invite is required to register")
+	ErrInvalidInvite            = errors.New("invite is invalid, expired or already used")
+	ErrUsernameRequired         = errors.New("username is required")
+	ErrInvalidUsername          = errors.New("invalid username format")
 )
 
+// LoginIdentifier selects what users log in with (LOGIN_IDENTIFIER)
+type LoginIdentifier string
+
+const (
+	LoginByEmail    LoginIdentifier = "email"
+	LoginByUsername LoginIdentifier = "username"
+	LoginByEither   LoginIdentifier = "either" // Identifiers containing @ are emails
+)
+
+// usernamePattern allows 3-32 letters, digits, dots, underscores and hyphens,
+// starting with a letter or digit. It never matches an email address, which
+// lets LoginByEither tell the two apart.
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{2,31}$`)
+
 // Argon2id parameters - tuned for security vs performance balance
 // Time: 3, Memory: 64MB, Threads: 4, KeyLen: 32 bytes
 const (
@@ -43,6 +67,20 @@ const (
 type EmailService interface {
 	SendVerificationEmail(ctx context.Context, toEmail, token string) error
 	SendPasswordResetEmail(ctx context.Context, toEmail, token string) error
+	SendMagicLinkEmail(ctx context.Context, toEmail, token string) error
+}
+
+// RefreshLimiter throttles token refreshes per user. *ratelimit.Limiter
+// implements it.
+type RefreshLimiter interface {
+	AllowRefresh(ctx context.Context, userID string) (ratelimit.Result, error)
+}
+
+// Transactor runs fn in a database transaction, committing only if fn
+// returns nil. Repositories join it through WithDB(tx).
+// *database.UnitOfWork implements it.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context, tx bun.IDB) error) error
 }
 
 // Service handles authentication business logic
@@ -50,37 +88,152 @@ type Service struct {
 	userRepo             user.RepositoryInterface
 	authRepo             RefreshTokenRepository
 	passwordResetRepo    *PasswordResetRepository
+	magicLinkRepo        *MagicLinkRepository
+	verificationRepo     *VerificationTokenRepository
+	inviteRepo           *InviteRepository
+	transactor           Transactor
+	refreshLimiter       RefreshLimiter
 	tokenService         TokenService
+	claimsProvider       ClaimsProvider
 	emailService         EmailService
 	logger               *logging.Logger
 	accessTokenDuration  time.Duration
 	refreshTokenDuration time.Duration
+	// sessionRefreshDuration is the refresh lifetime for logins without "remember me"
+	sessionRefreshDuration time.Duration
+	// verificationGracePeriod lets unverified users log in for this long after
+	// registering. Zero enforces verification immediately.
+	verificationGracePeriod time.Duration
+	// inactivityTimeout expires sessions that haven't been refreshed for this
+	// long, regardless of absolute expiry. Zero disables it.
+	inactivityTimeout time.Duration
+	// refreshReuseGrace is how long a rotated refresh token keeps returning
+	// the tokens it was rotated into, for clients that send it twice. Reuse
+	// after that revokes the whole session. Zero disables the grace window.
+	refreshReuseGrace time.Duration
+	ipBinding         IPBinding
+	// inviteOnly requires a valid invite token to register
+	inviteOnly bool
+	// syncRegistrationEmail makes Register wait for the verification email
+	// and report whether it was sent
+	syncRegistrationEmail bool
+	// loginIdentifier decides how Login resolves users; LoginByUsername also
+	// makes a username mandatory at registration
+	loginIdentifier LoginIdentifier
+	// minPasswordScore rejects new passwords whose estimated strength, from
+	// 0 to 4, is lower. Zero accepts any password of the minimum length.
+	minPasswordScore int
+	// emailSends tracks emails being sent in the background, so shutdown can
+	// wait for them. emailsClosed, set once WaitForEmails is called, makes
+	// later sends synchronous so none is added while it waits.
+	emailMu      sync.Mutex
+	emailsClosed bool
+	emailSends   sync.WaitGroup
 }
 
 func NewService(
 	userRepo user.RepositoryInterface,
 	authRepo RefreshTokenRepository,
 	passwordResetRepo *PasswordResetRepository,
+	magicLinkRepo *MagicLinkRepository,
+	verificationRepo *VerificationTokenRepository,
+	inviteRepo *InviteRepository,
+	transactor Transactor,
+	refreshLimiter RefreshLimiter,
 	tokenService TokenService,
+	claimsProvider ClaimsProvider,
 	emailService EmailService,
 	logger *logging.Logger,
 	accessTokenDuration time.Duration,
 	refreshTokenDuration time.Duration,
+	sessionRefreshDuration time.Duration,
+	verificationGracePeriod time.Duration,
+	inactivityTimeout time.Duration,
+	refreshReuseGrace time.Duration,
+	ipBinding IPBinding,
+	inviteOnly bool,
+	syncRegistrationEmail bool,
+	loginIdentifier LoginIdentifier,
+	minPasswordScore int,
 ) *Service {
 	return &Service{
-		userRepo:             userRepo,
-		authRepo:             authRepo,
-		passwordResetRepo:    passwordResetRepo,
-		tokenService:         tokenService,
-		emailService:         emailService,
-		logger:               logger,
-		accessTokenDuration:  accessTokenDuration,
-		refreshTokenDuration: refreshTokenDuration,
+		userRepo:                userRepo,
+		authRepo:                authRepo,
+		passwordResetRepo:       passwordResetRepo,
+		magicLinkRepo:           magicLinkRepo,
+		verificationRepo:        verificationRepo,
+		inviteRepo:              inviteRepo,
+		transactor:              transactor,
+		refreshLimiter:          refreshLimiter,
+		tokenService:            tokenService,
+		claimsProvider:          claimsProvider,
+		emailService:            emailService,
+		logger:                  logger,
+		accessTokenDuration:     accessTokenDuration,
+		refreshTokenDuration:    refreshTokenDuration,
+		sessionRefreshDuration:  sessionRefreshDuration,
+		verificationGracePeriod: verificationGracePeriod,
+		inactivityTimeout:       inactivityTimeout,
+		refreshReuseGrace:       refreshReuseGrace,
+		ipBinding:               ipBinding,
+		inviteOnly:              inviteOnly,
+		syncRegistrationEmail:   syncRegistrationEmail,
+		loginIdentifier:         loginIdentifier,
+		minPasswordScore:        minPasswordScore,
 	}
 }
 
-// Register creates a new user account and sends verification email
-func (s *Service) Register(ctx context.Context, email, password string) (*user.User, error) {
+// WaitForEmails blocks until emails being sent in the background have gone
+// out, or until ctx ends. Emails sent after it's called go out before the
+// request that sends them returns.
+func (s *Service) WaitForEmails(ctx context.Context) error {
+	s.emailMu.Lock()
+	s.emailsClosed = true
+	s.emailMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.emailSends.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("emails still sending: %w", ctx.Err())
+	}
+}
+
+// sendInBackground runs send on its own goroutine, tracked by emailSends, or
+// right away once WaitForEmails has been called
+func (s *Service) sendInBackground(send func()) {
+	s.emailMu.Lock()
+	if !s.emailsClosed {
+		s.emailSends.Go(send)
+		s.emailMu.Unlock()
+		return
+	}
+	s.emailMu.Unlock()
+	send()
+}
+
+// Registration is the result of Register
+type Registration struct {
+	User *user.User
+	// VerificationToken is the token emailed to the user. The user record
+	// only holds its hash.
+	VerificationToken string
+	// EmailSent reports whether the verification email went out. It is nil
+	// when the email is sent in the background and the outcome isn't known.
+	EmailSent *bool
+}
+
+// Register creates a new user account and sends verification email. The
+// username is optional unless users log in by username. When registration is
+// invite-only, inviteToken must belong to a valid invite, which is consumed
+// once the account exists.
+func (s *Service) Register(ctx context.Context, email, username, password, inviteToken string) (*Registration, error) {
 	// Validate input
 	if email == "" {
 		return nil, ErrEmailRequired
@@ -97,6 +250,38 @@ func (s *Service) Register(ctx context.Context, email, password string) (*user.U
 	if len(password) < 8 {
 		return nil, ErrPasswordTooShort
 	}
+	if username == "" {
+		if s.loginIdentifier == LoginByUsername {
+			return nil, ErrUsernameRequired
+		}
+	} else if !usernamePattern.MatchString(username) {
+		return nil, ErrInvalidUsername
+	}
+	if err := s.checkPasswordStrength(password, email, username); err != nil {
+		return nil, err
+	}
+
+	var invite *Invite
+	if s.inviteOnly {
+		if inviteToken == "" {
+			return nil, ErrInviteRequired
+		}
+
+		var err error
+		invite, err = s.inviteRepo.GetInviteByToken(ctx, inviteToken)
+		if errors.Is(err, ErrInviteNotFound) {
+			return nil, ErrInvalidInvite
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get invite: %w", err)
+		}
+		if invite.Email != "" && !strings.EqualFold(invite.Email, email) {
+			return nil, ErrInvalidInvite
+		}
+		if invite.TenantID != contextTenantID(ctx) {
+			return nil, ErrInvalidInvite
+		}
+	}
 
 	// Hash password using argon2id
 	passwordHash, err := s.hashPassword(password)
@@ -105,22 +290,57 @@ func (s *Service) Register(ctx context.Context, email, password string) (*user.U
 	}
 
 	// Generate verification token
-	verificationToken, err := generateRandomToken()
+	verificationToken, err := GenerateRandomToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate verification token: %w", err)
 	}
 
+	// Claim the invite right before creating the user, so a concurrent
+	// registration can't use it too
+	if invite != nil {
+		if err := s.inviteRepo.ClaimInvite(ctx, inviteToken); err != nil {
+			if errors.Is(err, ErrInviteNotFound) {
+				return nil, ErrInvalidInvite
+			}
+			return nil, err
+		}
+	}
+
 	// Create user in database
-	newUser, err := s.userRepo.Create(ctx, email, passwordHash, verificationToken)
+	newUser, err := s.userRepo.Create(ctx, contextTenantID(ctx), email, username, passwordHash, storedVerificationToken(verificationToken))
 	if err != nil {
-		if errors.Is(err, user.ErrDuplicateEmail) {
-			return nil, user.ErrDuplicateEmail
+		// Registration failed, so the invite can still be used
+		if invite != nil {
+			if releaseErr := s.inviteRepo.ReleaseInvite(ctx, invite, inviteToken); releaseErr != nil {
+				s.logger.Error("failed to release invite", "invite_id", invite.ID, "error", releaseErr.Error())
+			}
+		}
+		if errors.Is(err, user.ErrDuplicateEmail) || errors.Is(err, user.ErrDuplicateUsername) {
+			return nil, err
 		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	if invite != nil {
+		if err := s.inviteRepo.MarkInviteConsumed(ctx, invite, newUser.ID); err != nil {
+			s.logger.Warn("failed to record invite use", "invite_id", invite.ID, "error", err.Error())
+		}
+	}
+
+	// In sync mode, wait for the email so the client learns whether it went
+	// out. A failure still doesn't fail registration; the user can request a
+	// new verification email.
+	if s.syncRegistrationEmail {
+		sent := true
+		if err := s.emailService.SendVerificationEmail(context.WithoutCancel(ctx), email, verificationToken); err != nil {
+			s.logger.Warn("failed to send verification email", "email", email, "error", err)
+			sent = false
+		}
+		return &Registration{User: newUser, VerificationToken: verificationToken, EmailSent: &sent}, nil
+	}
+
 	// Send verification email in a goroutine (non-blocking)
-	go func() {
+	s.sendInBackground(func() {
 		// Create a new context for the goroutine to avoid cancellation issues
 		emailCtx := context.Background()
 		if err := s.emailService.SendVerificationEmail(emailCtx, email, verificationToken); err != nil {
@@ -128,20 +348,21 @@ func (s *Service) Register(ctx context.Context, email, password string) (*user.U
 			// User can request a new verification email later
 			s.logger.Warn("failed to send verification email", "email", email, "error", err)
 		}
-	}()
+	})
 
-	return newUser, nil
+	return &Registration{User: newUser, VerificationToken: verificationToken}, nil
 }
 
-// Login authenticates a user and returns tokens
-func (s *Service) Login(ctx context.Context, email, password string) (*AuthTokens, error) {
+// Login authenticates a user and returns tokens for a new session from the
+// given client. identifier is an email or username, per loginIdentifier.
+func (s *Service) Login(ctx context.Context, identifier, password string, meta SessionMeta) (*AuthTokens, error) {
 	// Validate input
-	if email == "" || password == "" {
+	if identifier == "" || password == "" {
 		return nil, ErrInvalidCredentials
 	}
 
 	// Get user from database
-	existingUser, err := s.userRepo.GetByEmail(ctx, email)
+	existingUser, err := s.findLoginUser(ctx, identifier)
 	if err != nil {
 		if errors.Is(err, user.ErrNotFound) {
 			return nil, ErrInvalidCredentials
@@ -154,13 +375,17 @@ func (s *Service) Login(ctx context.Context, email, password string) (*AuthToken
 		return nil, ErrInvalidCredentials
 	}
 
-	// Check if email is verified
-	if !existingUser.EmailVerified {
+	// Check if email is verified (unverified users may still log in during the grace period)
+	if !existingUser.EmailVerified && !s.withinVerificationGracePeriod(existingUser) {
 		return nil, ErrEmailNotVerified
 	}
 
 	// Generate tokens
-	tokens, err := s.generateTokens(ctx, existingUser.ID, existingUser.Email)
+	extra, err := s.claimsProvider.Claims(ctx, existingUser)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.generateTokens(ctx, existingUser, extra, meta.Start())
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
@@ -168,8 +393,31 @@ func (s *Service) Login(ctx context.Context, email, password string) (*AuthToken
 	return tokens, nil
 }
 
+// findLoginUser looks up the user a login identifier names in the request's
+// tenant: an email, a username, or with LoginByEither whichever the
+// identifier looks like
+func (s *Service) findLoginUser(ctx context.Context, identifier string) (*user.User, error) {
+	switch {
+	case s.loginIdentifier == LoginByUsername,
+		s.loginIdentifier == LoginByEither && !strings.Contains(identifier, "@"):
+		return s.userRepo.GetByUsername(ctx, contextTenantID(ctx), identifier)
+	default:
+		return s.userRepo.GetByEmail(ctx, contextTenantID(ctx), identifier)
+	}
+}
+
+// LoginEmail returns the email address of the account a login identifier
+// names
+func (s *Service) LoginEmail(ctx context.Context, identifier string) (string, error) {
+	existingUser, err := s.findLoginUser(ctx, identifier)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	return existingUser.Email, nil
+}
+
 // RefreshAccessToken generates a new access token using a refresh token
-func (s *Service) RefreshAccessToken(ctx context.Context, refreshToken string) (*AuthTokens, error) {
+func (s *Service) RefreshAccessToken(ctx context.Context, refreshToken string, meta SessionMeta) (*AuthTokens, error) {
 	// Get refresh token from database
 	rt, err := s.authRepo.GetRefreshToken(ctx, refreshToken)
 	if err != nil {
@@ -179,51 +427,178 @@ func (s *Service) RefreshAccessToken(ctx context.Context, refreshToken string) (
 		return nil, fmt.Errorf("failed to get refresh token: %w", err)
 	}
 
-	// Validate refresh token
+	// Validate refresh token. Both repositories return revoked and expired
+	// tokens with their state set, so this is where they're rejected.
 	if !rt.IsValid() {
 		if rt.IsRevoked() {
-			return nil, ErrRefreshTokenRevoked
+			return s.reuseRevokedToken(ctx, refreshToken, rt)
 		}
-		if rt.IsExpired() {
-			return nil, ErrRefreshTokenExpired
+		return nil, ErrRefreshTokenExpired
+	}
+
+	// Throttle refresh loops per user. Only valid tokens count, so a client
+	// presenting garbage can't use up someone else's budget. Limiter errors
+	// are logged and the refresh goes ahead.
+	limit, err := s.refreshLimiter.AllowRefresh(ctx, rt.UserID.String())
+	if err != nil {
+		s.logger.Error("failed to check refresh rate limit", "error", err.Error())
+	} else if !limit.Allowed {
+		s.logger.Warn("refresh rate limit exceeded", "user_id", rt.UserID)
+		return nil, ErrRefreshRateLimited
+	}
+
+	// Reject sessions idle past the inactivity timeout and revoke the stale token
+	if rt.IsInactive(s.inactivityTimeout) {
+		if err := s.authRepo.RevokeRefreshToken(ctx, refreshToken); err != nil {
+			s.logger.Warn("failed to revoke inactive refresh token", "error", err)
 		}
+		return nil, ErrSessionInactive
 	}
 
-	// Revoke old refresh token before issuing new ones to prevent reuse
-	if err := s.authRepo.RevokeRefreshToken(ctx, refreshToken); err != nil {
-		return nil, fmt.Errorf("failed to revoke old refresh token: %w", err)
+	// With IP binding on, a token presented from outside its issuing subnet is
+	// treated as stolen and revoked
+	if !s.ipBinding.Allows(rt.IssuedIP, meta.IP) {
+		if err := s.authRepo.RevokeRefreshToken(ctx, refreshToken); err != nil {
+			s.logger.Warn("failed to revoke ip-mismatched refresh token", "error", err)
+		}
+		s.logger.Warn("refresh token ip mismatch", "user_id", rt.UserID, "issued_ip", rt.IssuedIP, "ip", meta.IP)
+		return nil, ErrRefreshTokenIPMismatch
 	}
 
-	// Get user
+	// Get user. A token that outlived its user can never be refreshed again,
+	// so drop every token the deleted user left behind.
 	existingUser, err := s.userRepo.GetByID(ctx, rt.UserID)
 	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			if _, err := s.authRepo.RevokeAllUserTokens(ctx, rt.UserID); err != nil {
+				s.logger.Warn("failed to revoke deleted user's refresh tokens", "error", err)
+			}
+			return nil, ErrInvalidToken
+		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Generate new tokens
-	tokens, err := s.generateTokens(ctx, existingUser.ID, existingUser.Email)
+	// A session only refreshes in the tenant it was issued for
+	if !TenantMatches(ctx, existingUser) {
+		return nil, ErrInvalidToken
+	}
+
+	// Generate new tokens in the same session, keeping the original authentication time
+	meta.ID = rt.SessionID
+	meta.AuthTime = rt.AuthTime
+	meta.SessionOnly = rt.SessionOnly
+	extra, err := s.claimsProvider.Claims(ctx, existingUser)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.generateTokens(ctx, existingUser, extra, meta)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
 
+	// Claim the rotation before revoking the old token, so a duplicate
+	// request that sees it revoked finds the same tokens. When two requests
+	// race past the validity check, only one claims it and the other hands
+	// back the winner's tokens instead of forking the session. A failed cache
+	// only costs the grace window.
+	if cache, ok := s.authRepo.(RotationCache); ok && s.refreshReuseGrace > 0 {
+		claimed, err := cache.ClaimRotation(ctx, refreshToken, tokens, s.refreshReuseGrace)
+		if err != nil {
+			s.logger.Warn("failed to cache refresh token rotation", "error", err)
+		} else if !claimed {
+			return s.lostRotation(ctx, cache, refreshToken, tokens)
+		}
+	}
+
+	// Revoke the old refresh token so any later use counts as reuse
+	if err := s.authRepo.RevokeRefreshToken(ctx, refreshToken); err != nil {
+		return nil, fmt.Errorf("failed to revoke old refresh token: %w", err)
+	}
+
 	return tokens, nil
 }
 
+// lostRotation handles a refresh that raced another with the same token and
+// lost: the tokens it generated are dropped and the winner's returned
+func (s *Service) lostRotation(ctx context.Context, cache RotationCache, refreshToken string, tokens *AuthTokens) (*AuthTokens, error) {
+	if err := s.authRepo.RevokeRefreshToken(ctx, tokens.RefreshToken); err != nil {
+		s.logger.Warn("failed to revoke refresh token from lost rotation", "error", err)
+	}
+	winner, err := cache.GetRotation(ctx, refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached refresh token rotation: %w", err)
+	}
+	if winner == nil {
+		return nil, ErrRefreshTokenRevoked
+	}
+	return winner, nil
+}
+
+// reuseRevokedToken handles a refresh with a revoked token. Within the grace
+// window after rotation it returns the tokens the first request got. Any
+// other reuse suggests the token was stolen, so the whole session it belongs
+// to is revoked, logging out both the thief and the legitimate client.
+func (s *Service) reuseRevokedToken(ctx context.Context, refreshToken string, rt *RefreshToken) (*AuthTokens, error) {
+	if cache, ok := s.authRepo.(RotationCache); ok && s.refreshReuseGrace > 0 {
+		tokens, err := cache.GetRotation(ctx, refreshToken)
+		if err != nil {
+			s.logger.Warn("failed to get cached refresh token rotation", "error", err)
+		} else if tokens != nil {
+			return tokens, nil
+		}
+	}
+
+	if err := s.authRepo.RevokeSession(ctx, rt.UserID, rt.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		s.logger.Warn("failed to revoke session after refresh token reuse", "error", err)
+	}
+	s.logger.Warn("revoked refresh token reused", "user_id", rt.UserID, "session_id", rt.SessionID)
+	return nil, ErrRefreshTokenRevoked
+}
+
 // RevokeRefreshToken revokes a refresh token
 func (s *Service) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
 	return s.authRepo.RevokeRefreshToken(ctx, refreshToken)
 }
 
+// ListSessions returns the user's active sessions
+func (s *Service) ListSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	return s.authRepo.ListUserSessions(ctx, userID)
+}
+
+// RevokeSession ends one of the user's sessions
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	return s.authRepo.RevokeSession(ctx, userID, sessionID)
+}
+
+// RevokeAllSessions revokes every refresh token for a user and returns how
+// many active sessions were ended
+func (s *Service) RevokeAllSessions(ctx context.Context, userID uuid.UUID) (int, error) {
+	return s.authRepo.RevokeAllUserTokens(ctx, userID)
+}
+
 // VerifyEmail verifies a user's email using the verification token
 func (s *Service) VerifyEmail(ctx context.Context, token string) error {
-	// First, try to find user by token (only unverified users)
-	existingUser, err := s.userRepo.GetByVerificationToken(ctx, token)
+	// First, try to find user by token (only unverified users). Tokens
+	// issued before they were stored hashed are still stored as is.
+	existingUser, err := s.userRepo.GetByVerificationToken(ctx, storedVerificationToken(token))
+	if errors.Is(err, user.ErrNotFound) {
+		existingUser, err = s.userRepo.GetByVerificationToken(ctx, token)
+	}
 	if err != nil {
 		if errors.Is(err, user.ErrNotFound) {
 			// Token not found in unverified users - check if it was already used
-			alreadyVerified, checkErr := s.userRepo.CheckIfTokenAlreadyUsed(ctx, token)
-			if checkErr == nil && alreadyVerified {
-				return ErrEmailAlreadyVerified
+			for _, stored := range []string{storedVerificationToken(token), token} {
+				alreadyVerified, checkErr := s.userRepo.CheckIfTokenAlreadyUsed(ctx, stored)
+				if checkErr == nil && alreadyVerified {
+					return ErrEmailAlreadyVerified
+				}
+			}
+			// An older link from before a resend gets a clearer error than a bogus token
+			superseded, checkErr := s.verificationRepo.IsSuperseded(ctx, token)
+			if checkErr != nil {
+				s.logger.Warn("failed to check superseded verification token", "error", checkErr)
+			} else if superseded {
+				return ErrVerificationSuperseded
 			}
 			// Token doesn't exist or is invalid
 			return ErrInvalidVerificationToken
@@ -231,11 +606,15 @@ func (s *Service) VerifyEmail(ctx context.Context, token string) error {
 		return fmt.Errorf("failed to find user by token: %w", err)
 	}
 
-	// Check if token has expired (24 hours)
+	// A token without a sent-at timestamp can't be checked for expiry, so it is
+	// treated as invalid rather than expired (the user can request a new one)
 	if existingUser.EmailVerificationSentAt == nil {
-		return ErrTokenExpired
+		s.logger.Warn("verification token has no sent-at timestamp", "user_id", existingUser.ID)
+		return ErrInvalidVerificationToken
 	}
-	expirationTime := existingUser.EmailVerificationSentAt.Add(24 * time.Hour)
+
+	// Check if token has expired
+	expirationTime := existingUser.EmailVerificationSentAt.Add(s.verificationRepo.TTL())
 	if time.Now().After(expirationTime) {
 		return ErrTokenExpired
 	}
@@ -248,23 +627,37 @@ func (s *Service) VerifyEmail(ctx context.Context, token string) error {
 	return nil
 }
 
-// generateTokens creates both access and refresh tokens
-func (s *Service) generateTokens(ctx context.Context, userID uuid.UUID, email string) (*AuthTokens, error) {
+// withinVerificationGracePeriod reports whether an unverified user registered
+// recently enough to still be allowed to log in
+func (s *Service) withinVerificationGracePeriod(u *user.User) bool {
+	if s.verificationGracePeriod <= 0 {
+		return false
+	}
+	return time.Since(u.CreatedAt) < s.verificationGracePeriod
+}
+
+// generateTokens creates both access and refresh tokens for u. extra adds
+// custom claims to the access token and may be nil.
+func (s *Service) generateTokens(ctx context.Context, u *user.User, extra map[string]any, meta SessionMeta) (*AuthTokens, error) {
 	// Generate access token (short-lived)
-	accessToken, err := s.tokenService.CreateToken(userID, email, s.accessTokenDuration)
+	accessToken, err := s.tokenService.CreateToken(u.ID, u.Tenant(), u.Email, u.EmailVerified, meta.AuthTime, s.accessTokenDuration, extra)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create access token: %w", err)
 	}
 
 	// Generate refresh token (long-lived, random string)
-	refreshToken, err := generateRandomToken()
+	refreshToken, err := GenerateRandomToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
 	// Store refresh token in database
-	expiresAt := time.Now().Add(s.refreshTokenDuration)
-	if err := s.authRepo.StoreRefreshToken(ctx, userID, refreshToken, expiresAt); err != nil {
+	refreshDuration := s.refreshTokenDuration
+	if meta.SessionOnly {
+		refreshDuration = s.sessionRefreshDuration
+	}
+	expiresAt := time.Now().Add(refreshDuration)
+	if err := s.authRepo.StoreRefreshToken(ctx, u.ID, refreshToken, expiresAt, meta); err != nil {
 		return nil, fmt.Errorf("failed to store refresh token: %w", err)
 	}
 
@@ -273,9 +666,23 @@ func (s *Service) generateTokens(ctx context.Context, userID uuid.UUID, email st
 		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
 		ExpiresIn:    int64(s.accessTokenDuration.Seconds()),
+		SessionOnly:  meta.SessionOnly,
 	}, nil
 }
 
+// checkPasswordStrength returns a *PasswordTooWeakError if password scores
+// below minPasswordScore. Passwords built from the email or username fail.
+func (s *Service) checkPasswordStrength(password, email, username string) error {
+	if s.minPasswordScore <= 0 {
+		return nil
+	}
+	strength := estimatePasswordStrength(password, email, username)
+	if strength.score < s.minPasswordScore {
+		return &PasswordTooWeakError{Score: strength.score, Reason: strength.reason}
+	}
+	return nil
+}
+
 // hashPassword creates an argon2id hash of the password
 func (s *Service) hashPassword(password string) (string, error) {
 	// Generate random salt
@@ -309,8 +716,24 @@ func (s *Service) hashPassword(password string) (string, error) {
 	), nil
 }
 
-// verifyPassword checks if a password matches the stored hash
+// verifyPassword checks if a password matches the stored hash. The algorithm
+// is picked by the hash's prefix, so users imported with bcrypt hashes can
+// log in alongside those hashed here with argon2id.
 func (s *Service) verifyPassword(encodedHash, password string) bool {
+	switch {
+	case strings.HasPrefix(encodedHash, "$argon2id$"):
+		return verifyArgon2id(encodedHash, password)
+	case strings.HasPrefix(encodedHash, "$2a$"),
+		strings.HasPrefix(encodedHash, "$2b$"),
+		strings.HasPrefix(encodedHash, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password)) == nil
+	default:
+		return false
+	}
+}
+
+// verifyArgon2id checks a password against a hash in hashPassword's format
+func verifyArgon2id(encodedHash, password string) bool {
 	// Parse the encoded hash
 	parts := strings.Split(encodedHash, "$")
 	if len(parts) != 6 {
@@ -354,8 +777,8 @@ func (s *Service) verifyPassword(encodedHash, password string) bool {
 	return subtle.ConstantTimeCompare(decodedHash, inputHash) == 1
 }
 
-// generateRandomToken creates a cryptographically secure random token
-func generateRandomToken() (string, error) {
+// GenerateRandomToken creates a cryptographically secure random token
+func GenerateRandomToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
@@ -363,43 +786,44 @@ func generateRandomToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-// RequestPasswordReset initiates the password reset process
-// Always returns nil to prevent email enumeration attacks
-func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+// RequestPasswordReset initiates the password reset process and returns the
+// emailed token, or "" when no email was sent. Failures are only logged, so
+// callers can't tell whether the account exists.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) string {
 	// Get user by email
-	existingUser, err := s.userRepo.GetByEmail(ctx, email)
+	existingUser, err := s.userRepo.GetByEmail(ctx, contextTenantID(ctx), email)
 	if err != nil {
 		// Don't reveal if user exists
 		if errors.Is(err, user.ErrNotFound) {
-			return nil
+			return ""
 		}
-		// Log error but return nil to prevent enumeration
+		// Log error but don't fail, to prevent enumeration
 		s.logger.Warn("failed to get user for password reset", "error", err)
-		return nil
+		return ""
 	}
 
 	// Generate password reset token
-	token, err := generateRandomToken()
+	token, err := GenerateRandomToken()
 	if err != nil {
 		s.logger.Warn("failed to generate password reset token", "error", err)
-		return nil
+		return ""
 	}
 
 	// Store token in Redis with 1-hour TTL
 	if err := s.passwordResetRepo.StorePasswordResetToken(ctx, existingUser.ID, token); err != nil {
 		s.logger.Warn("failed to store password reset token", "error", err)
-		return nil
+		return ""
 	}
 
 	// Send password reset email in goroutine (non-blocking)
-	go func() {
+	s.sendInBackground(func() {
 		emailCtx := context.Background()
 		if err := s.emailService.SendPasswordResetEmail(emailCtx, email, token); err != nil {
 			s.logger.Warn("failed to send password reset email", "email", email, "error", err)
 		}
-	}()
+	})
 
-	return nil
+	return token
 }
 
 // ResetPassword resets a user's password using a valid reset token
@@ -421,24 +845,49 @@ func (s *Service) ResetPassword(ctx context.Context, token, newPassword string)
 		return fmt.Errorf("failed to get password reset token: %w", err)
 	}
 
+	// The strength check needs the user's email and username
+	if s.minPasswordScore > 0 {
+		existingUser, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, user.ErrNotFound) {
+				return ErrPasswordResetTokenNotFound
+			}
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		username := ""
+		if existingUser.Username != nil {
+			username = *existingUser.Username
+		}
+		if err := s.checkPasswordStrength(newPassword, existingUser.Email, username); err != nil {
+			return err
+		}
+	}
+
 	// Hash new password
 	passwordHash, err := s.hashPassword(newPassword)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// Update password in database
-	if err := s.userRepo.UpdatePassword(ctx, userID, passwordHash); err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	err = s.transactor.WithTx(ctx, func(ctx context.Context, tx bun.IDB) error {
+		if err := s.userRepo.WithDB(tx).UpdatePassword(ctx, userID, passwordHash); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Delete used token
+	// Consume the token only once the new password is committed. Redis can't
+	// join the transaction, so a failure here leaves the link usable until it
+	// expires rather than undoing the reset.
 	if err := s.passwordResetRepo.DeletePasswordResetToken(ctx, token); err != nil {
 		s.logger.Warn("failed to delete password reset token", "error", err)
 	}
 
 	// Revoke all refresh tokens for security
-	if err := s.authRepo.RevokeAllUserTokens(ctx, userID); err != nil {
+	if _, err := s.authRepo.RevokeAllUserTokens(ctx, userID); err != nil {
 		s.logger.Warn("failed to revoke all user tokens after password reset", "error", err)
 	}
 
@@ -449,7 +898,7 @@ func (s *Service) ResetPassword(ctx context.Context, token, newPassword string)
 // Always returns nil to prevent email enumeration attacks
 func (s *Service) ResendVerificationEmail(ctx context.Context, email string) error {
 	// Get user by email
-	existingUser, err := s.userRepo.GetByEmail(ctx, email)
+	existingUser, err := s.userRepo.GetByEmail(ctx, contextTenantID(ctx), email)
 	if err != nil {
 		// Don't reveal if user exists
 		if errors.Is(err, user.ErrNotFound) {
@@ -467,25 +916,171 @@ func (s *Service) ResendVerificationEmail(ctx context.Context, email string) err
 	}
 
 	// Generate new verification token
-	token, err := generateRandomToken()
+	token, err := GenerateRandomToken()
 	if err != nil {
 		s.logger.Warn("failed to generate verification token", "error", err)
 		return nil
 	}
 
-	// Update verification token in database
-	if err := s.userRepo.UpdateVerificationToken(ctx, existingUser.ID, token); err != nil {
+	// Update verification token in database. This invalidates any earlier link:
+	// only the newest token verifies the email.
+	if err := s.userRepo.UpdateVerificationToken(ctx, existingUser.ID, storedVerificationToken(token)); err != nil {
 		s.logger.Warn("failed to update verification token", "error", err)
 		return nil
 	}
 
+	// Remember the replaced token so clicking its link explains what happened
+	if existingUser.EmailVerificationToken != nil {
+		if err := s.verificationRepo.MarkSuperseded(ctx, *existingUser.EmailVerificationToken); err != nil {
+			s.logger.Warn("failed to mark verification token superseded", "error", err)
+		}
+	}
+
 	// Send verification email in goroutine (non-blocking)
-	go func() {
+	s.sendInBackground(func() {
 		emailCtx := context.Background()
 		if err := s.emailService.SendVerificationEmail(emailCtx, email, token); err != nil {
 			s.logger.Warn("failed to resend verification email", "email", email, "error", err)
 		}
-	}()
+	})
 
 	return nil
 }
+
+// RequestMagicLink emails a single-use passwordless login link
+// Always returns nil to prevent email enumeration attacks
+func (s *Service) RequestMagicLink(ctx context.Context, email string) error {
+	// Get user by email
+	existingUser, err := s.userRepo.GetByEmail(ctx, contextTenantID(ctx), email)
+	if err != nil {
+		// Don't reveal if user exists
+		if errors.Is(err, user.ErrNotFound) {
+			return nil
+		}
+		// Log error but return nil to prevent enumeration
+		s.logger.Warn("failed to get user for magic link", "error", err)
+		return nil
+	}
+
+	// Generate magic link token
+	token, err := GenerateRandomToken()
+	if err != nil {
+		s.logger.Warn("failed to generate magic link token", "error", err)
+		return nil
+	}
+
+	// Store token in Redis with 15-minute TTL
+	if err := s.magicLinkRepo.StoreMagicLinkToken(ctx, existingUser.ID, token); err != nil {
+		s.logger.Warn("failed to store magic link token", "error", err)
+		return nil
+	}
+
+	// Send magic link email in goroutine (non-blocking)
+	s.sendInBackground(func() {
+		emailCtx := context.Background()
+		if err := s.emailService.SendMagicLinkEmail(emailCtx, email, token); err != nil {
+			s.logger.Warn("failed to send magic link email", "email", email, "error", err)
+		}
+	})
+
+	return nil
+}
+
+// LoginWithMagicLink exchanges a magic link token for auth tokens.
+// The token is consumed on first use, so replaying a link fails.
+func (s *Service) LoginWithMagicLink(ctx context.Context, token string, meta SessionMeta) (*AuthTokens, error) {
+	userID, err := s.magicLinkRepo.ConsumeMagicLinkToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrMagicLinkTokenNotFound) {
+			return nil, ErrMagicLinkTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to consume magic link token: %w", err)
+	}
+
+	existingUser, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, ErrMagicLinkTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !TenantMatches(ctx, existingUser) {
+		return nil, ErrMagicLinkTokenNotFound
+	}
+
+	// Following the link proves ownership of the inbox, so it also verifies the email
+	if !existingUser.EmailVerified {
+		if err := s.userRepo.MarkEmailAsVerified(ctx, existingUser.ID); err != nil {
+			return nil, fmt.Errorf("failed to mark email as verified: %w", err)
+		}
+		existingUser.EmailVerified = true
+	}
+
+	return s.IssueTokens(ctx, existingUser, meta)
+}
+
+// IssueTokens starts a new session for a user who authenticated some other
+// way than a password, such as a magic link or a passkey, with the same
+// claims and token lifetimes as a password login
+func (s *Service) IssueTokens(ctx context.Context, u *user.User, meta SessionMeta) (*AuthTokens, error) {
+	extra, err := s.claimsProvider.Claims(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.generateTokens(ctx, u, extra, meta.Start())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// CreateInvite issues an invite and returns it with its token. The token is
+// only available here; just its hash is stored. A non-empty email binds the
+// invite to that address, and a zero expiresIn uses the default lifetime.
+// The invite registers users in the request's tenant.
+func (s *Service) CreateInvite(ctx context.Context, createdBy uuid.UUID, email string, expiresIn time.Duration) (*Invite, string, error) {
+	if email != "" {
+		if len(email) > 254 {
+			return nil, "", ErrInvalidEmailFormat
+		}
+		if _, err := mail.ParseAddress(email); err != nil {
+			return nil, "", ErrInvalidEmailFormat
+		}
+	}
+	if expiresIn <= 0 {
+		expiresIn = s.inviteRepo.TTL()
+	}
+
+	token, err := GenerateRandomToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate invite token: %w", err)
+	}
+
+	now := time.Now()
+	invite := &Invite{
+		ID:        uuid.New(),
+		Email:     email,
+		TenantID:  contextTenantID(ctx),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiresIn),
+	}
+	if err := s.inviteRepo.StoreInvite(ctx, invite, token); err != nil {
+		return nil, "", err
+	}
+
+	return invite, token, nil
+}
+
+// ListInvites returns every invite of the request's tenant that hasn't
+// expired, including used ones
+func (s *Service) ListInvites(ctx context.Context) ([]*Invite, error) {
+	return s.inviteRepo.ListInvites(ctx, contextTenantID(ctx))
+}
+
+// RevokeInvite deletes one of the request's tenant's invites so it can no
+// longer be used
+func (s *Service) RevokeInvite(ctx context.Context, id uuid.UUID) error {
+	return s.inviteRepo.RevokeInvite(ctx, contextTenantID(ctx), id)
+}
@@ -0,0 +1,588 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/user"
+)
+
+// testEnv is a Service wired to in-memory stores
+type testEnv struct {
+	service *Service
+	users   *fakeUsers
+	emails  *fakeEmails
+	redis   *miniredis.Miniredis
+	client  *redis.Client
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	tokens, err := NewPasetoService([]byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &fakeUsers{users: make(map[uuid.UUID]*user.User)}
+	emails := &fakeEmails{}
+	service := NewService(
+		users,
+		NewMemoryRepository(),
+		NewPasswordResetRepository(client, time.Hour),
+		NewMagicLinkRepository(client),
+		NewVerificationTokenRepository(client, 24*time.Hour),
+		NewInviteRepository(client, 24*time.Hour),
+		noTx{},
+		nil,
+		tokens,
+		nil,
+		emails,
+		logging.NewLogger("json", slog.LevelError+1, logging.RedactOptions{}),
+		15*time.Minute,
+		7*24*time.Hour,
+		24*time.Hour,
+		0,
+		0,
+		0,
+		IPBinding{},
+		false,
+		false,
+		LoginByEmail,
+		0,
+	)
+
+	return &testEnv{service: service, users: users, emails: emails, redis: mr, client: client}
+}
+
+// register creates a user through the service and returns it with the
+// verification token emailed to it
+func (e *testEnv) register(t *testing.T, email string) (*user.User, string) {
+	t.Helper()
+	registration, err := e.service.Register(context.Background(), email, "", "correct horse battery staple", "")
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	return registration.User, registration.VerificationToken
+}
+
+// noTx runs fn without a transaction
+type noTx struct{}
+
+func (noTx) WithTx(ctx context.Context, fn func(ctx context.Context, tx bun.IDB) error) error {
+	return fn(ctx, nil)
+}
+
+// failingTx rolls back every transaction without running it
+type failingTx struct{ err error }
+
+func (f failingTx) WithTx(ctx context.Context, fn func(ctx context.Context, tx bun.IDB) error) error {
+	return f.err
+}
+
+// fakeUsers is an in-memory user.RepositoryInterface
+type fakeUsers struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]*user.User
+}
+
+func (f *fakeUsers) Create(ctx context.Context, tenantID, email, username, passwordHash, verificationToken string) (*user.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users {
+		if u.Tenant() == tenantID && u.Email == email {
+			return nil, user.ErrDuplicateEmail
+		}
+	}
+	now := time.Now()
+	u := &user.User{
+		ID:                      uuid.New(),
+		Email:                   email,
+		PasswordHash:            passwordHash,
+		EmailVerificationToken:  &verificationToken,
+		EmailVerificationSentAt: &now,
+		CreatedAt:               now,
+		UpdatedAt:               now,
+	}
+	if tenantID != "" {
+		u.TenantID = &tenantID
+	}
+	if username != "" {
+		u.Username = &username
+	}
+	f.users[u.ID] = u
+	return f.copy(u), nil
+}
+
+func (f *fakeUsers) GetByEmail(ctx context.Context, tenantID, email string) (*user.User, error) {
+	return f.find(func(u *user.User) bool { return u.Tenant() == tenantID && u.Email == email })
+}
+
+func (f *fakeUsers) GetByUsername(ctx context.Context, tenantID, username string) (*user.User, error) {
+	return f.find(func(u *user.User) bool {
+		return u.Tenant() == tenantID && u.Username != nil && strings.EqualFold(*u.Username, username)
+	})
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id uuid.UUID) (*user.User, error) {
+	return f.find(func(u *user.User) bool { return u.ID == id })
+}
+
+func (f *fakeUsers) GetByVerificationToken(ctx context.Context, token string) (*user.User, error) {
+	return f.find(func(u *user.User) bool {
+		return !u.EmailVerified && u.EmailVerificationToken != nil && *u.EmailVerificationToken == token
+	})
+}
+
+func (f *fakeUsers) CheckIfTokenAlreadyUsed(ctx context.Context, token string) (bool, error) {
+	_, err := f.find(func(u *user.User) bool {
+		return u.EmailVerified && u.EmailVerificationToken != nil && *u.EmailVerificationToken == token
+	})
+	return err == nil, nil
+}
+
+func (f *fakeUsers) MarkEmailAsVerified(ctx context.Context, userID uuid.UUID) error {
+	return f.update(userID, func(u *user.User) {
+		u.EmailVerified = true
+		u.EmailVerificationToken = nil
+		u.EmailVerificationSentAt = nil
+	})
+}
+
+func (f *fakeUsers) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	return f.update(userID, func(u *user.User) { u.PasswordHash = passwordHash })
+}
+
+func (f *fakeUsers) UpdateVerificationToken(ctx context.Context, userID uuid.UUID, token string) error {
+	now := time.Now()
+	return f.update(userID, func(u *user.User) {
+		u.EmailVerificationToken = &token
+		u.EmailVerificationSentAt = &now
+	})
+}
+
+func (f *fakeUsers) WithDB(db bun.IDB) user.RepositoryInterface {
+	return f
+}
+
+func (f *fakeUsers) find(match func(*user.User) bool) (*user.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users {
+		if match(u) {
+			return f.copy(u), nil
+		}
+	}
+	return nil, user.ErrNotFound
+}
+
+func (f *fakeUsers) update(userID uuid.UUID, fn func(*user.User)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[userID]
+	if !ok {
+		return user.ErrNotFound
+	}
+	fn(u)
+	return nil
+}
+
+func (f *fakeUsers) copy(u *user.User) *user.User {
+	c := *u
+	return &c
+}
+
+// fakeEmails records the tokens the service emails
+type fakeEmails struct {
+	mu           sync.Mutex
+	verification []string
+	reset        []string
+	magicLink    []string
+	err          error // Returned by SendVerificationEmail instead of sending
+}
+
+func (f *fakeEmails) SendVerificationEmail(ctx context.Context, toEmail, token string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.verification = append(f.verification, token)
+	return nil
+}
+
+func (f *fakeEmails) SendPasswordResetEmail(ctx context.Context, toEmail, token string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reset = append(f.reset, token)
+	return nil
+}
+
+func (f *fakeEmails) SendMagicLinkEmail(ctx context.Context, toEmail, token string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.magicLink = append(f.magicLink, token)
+	return nil
+}
+
+// last returns the most recent token sent of a kind, waiting for background sends
+func (f *fakeEmails) last(t *testing.T, s *Service, kind *[]string) string {
+	t.Helper()
+	if err := s.WaitForEmails(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(*kind) == 0 {
+		t.Fatal("no email sent")
+	}
+	return (*kind)[len(*kind)-1]
+}
+
+func TestEmailsAfterWaitForEmailsSendSynchronously(t *testing.T) {
+	env := newTestEnv(t)
+	if err := env.service.WaitForEmails(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	env.register(t, "late@example.com")
+	env.emails.mu.Lock()
+	defer env.emails.mu.Unlock()
+	if len(env.emails.verification) != 1 {
+		t.Errorf("sent %d verification emails by the time Register returned, want 1", len(env.emails.verification))
+	}
+}
+
+func TestWaitForEmailsDuringSends(t *testing.T) {
+	env := newTestEnv(t)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			env.register(t, fmt.Sprintf("user%d@example.com", i))
+		})
+	}
+	if err := env.service.WaitForEmails(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	env.emails.mu.Lock()
+	defer env.emails.mu.Unlock()
+	if len(env.emails.verification) != 20 {
+		t.Errorf("sent %d verification emails, want 20", len(env.emails.verification))
+	}
+}
+
+func TestResetPasswordConsumesTokenAfterCommit(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u, _ := env.register(t, "reset@example.com")
+	token := env.service.RequestPasswordReset(ctx, u.Email)
+
+	if err := env.service.ResetPassword(ctx, token, "another long password"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if err := env.service.ResetPassword(ctx, token, "a third long password"); !errors.Is(err, ErrPasswordResetTokenNotFound) {
+		t.Errorf("reused ResetPassword() error = %v, want ErrPasswordResetTokenNotFound", err)
+	}
+}
+
+func TestResetPasswordKeepsTokenOnRollback(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u, _ := env.register(t, "reset@example.com")
+	token := env.service.RequestPasswordReset(ctx, u.Email)
+
+	rollback := errors.New("rolled back")
+	env.service.transactor = failingTx{err: rollback}
+	if err := env.service.ResetPassword(ctx, token, "another long password"); !errors.Is(err, rollback) {
+		t.Fatalf("ResetPassword() error = %v, want %v", err, rollback)
+	}
+
+	env.service.transactor = noTx{}
+	if err := env.service.ResetPassword(ctx, token, "another long password"); err != nil {
+		t.Errorf("ResetPassword() after rollback error = %v, want the token still usable", err)
+	}
+}
+
+func TestLoginIdentifier(t *testing.T) {
+	const password = "correct horse battery staple"
+	tests := []struct {
+		mode       LoginIdentifier
+		identifier string
+		wantErr    error
+	}{
+		{LoginByEmail, "alice@example.com", nil},
+		{LoginByEmail, "alice", ErrInvalidCredentials},
+		{LoginByUsername, "Alice", nil},
+		{LoginByUsername, "alice@example.com", ErrInvalidCredentials},
+		{LoginByEither, "alice@example.com", nil},
+		{LoginByEither, "ALICE", nil},
+		{LoginByEither, "bob", ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+" "+tt.identifier, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := context.Background()
+			registration, err := env.service.Register(ctx, "alice@example.com", "alice", password, "")
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+			if err := env.users.MarkEmailAsVerified(ctx, registration.User.ID); err != nil {
+				t.Fatal(err)
+			}
+			env.service.loginIdentifier = tt.mode
+
+			_, err = env.service.Login(ctx, tt.identifier, password, SessionMeta{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Login(%q) error = %v, want %v", tt.identifier, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterValidatesUsername(t *testing.T) {
+	tests := []struct {
+		mode     LoginIdentifier
+		username string
+		wantErr  error
+	}{
+		{LoginByEmail, "", nil},
+		{LoginByUsername, "", ErrUsernameRequired},
+		{LoginByEither, "al", ErrInvalidUsername},
+		{LoginByEither, "has space", ErrInvalidUsername},
+		{LoginByEither, strings.Repeat("a", 33), ErrInvalidUsername},
+		{LoginByUsername, "alice.b_c-1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+" "+tt.username, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.service.loginIdentifier = tt.mode
+
+			_, err := env.service.Register(context.Background(), "alice@example.com", tt.username, "correct horse battery staple", "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Register(username %q) error = %v, want %v", tt.username, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyPasswordDispatchesByHash(t *testing.T) {
+	env := newTestEnv(t)
+	const password = "correct horse battery staple"
+
+	argon2Hash, err := env.service.hashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{"argon2id", argon2Hash, password, true},
+		{"argon2id wrong password", argon2Hash, "wrong password", false},
+		{"bcrypt", string(bcryptHash), password, true},
+		{"bcrypt wrong password", string(bcryptHash), "wrong password", false},
+		{"bcrypt $2y$", "$2y$" + string(bcryptHash)[4:], password, true},
+		{"unknown algorithm", "$pbkdf2$whatever", password, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := env.service.verifyPassword(tt.hash, tt.password); got != tt.want {
+				t.Errorf("verifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPasswordResetInvalidatesEarlierTokens(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u, _ := env.register(t, "reset@example.com")
+
+	first := env.service.RequestPasswordReset(ctx, u.Email)
+	second := env.service.RequestPasswordReset(ctx, u.Email)
+	if first == "" || second == "" || first == second {
+		t.Fatalf("reset tokens = %q, %q, want two distinct tokens", first, second)
+	}
+
+	if err := env.service.ResetPassword(ctx, first, "another long password"); !errors.Is(err, ErrPasswordResetTokenNotFound) {
+		t.Errorf("ResetPassword(first) error = %v, want ErrPasswordResetTokenNotFound", err)
+	}
+	if err := env.service.ResetPassword(ctx, second, "another long password"); err != nil {
+		t.Errorf("ResetPassword(second) error = %v", err)
+	}
+}
+
+func TestRegistrationEmailSentReporting(t *testing.T) {
+	tests := []struct {
+		name     string
+		sync     bool
+		sendErr  error
+		wantSent string // "" when EmailSent should be unset
+	}{
+		{"async", false, nil, ""},
+		{"async with a failing send", false, errors.New("smtp down"), ""},
+		{"sync", true, nil, "true"},
+		{"sync with a failing send", true, errors.New("smtp down"), "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.service.syncRegistrationEmail = tt.sync
+			env.emails.err = tt.sendErr
+
+			registration, err := env.service.Register(context.Background(), "a@example.com", "", "correct horse battery staple", "")
+			if err != nil {
+				t.Fatalf("Register() error = %v, want registration to succeed regardless of the email", err)
+			}
+			got := ""
+			if registration.EmailSent != nil {
+				got = fmt.Sprint(*registration.EmailSent)
+			}
+			if got != tt.wantSent {
+				t.Errorf("EmailSent = %q, want %q", got, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestVerifyEmailSentAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		sentAt  func() *time.Time
+		wantErr error
+	}{
+		{"fresh", func() *time.Time { now := time.Now(); return &now }, nil},
+		{"expired", func() *time.Time { old := time.Now().Add(-48 * time.Hour); return &old }, ErrTokenExpired},
+		{"missing", func() *time.Time { return nil }, ErrInvalidVerificationToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			u, token := env.register(t, "a@example.com")
+			if err := env.users.update(u.ID, func(u *user.User) { u.EmailVerificationSentAt = tt.sentAt() }); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := env.service.VerifyEmail(context.Background(), token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyEmail() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerificationGracePeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		grace     time.Duration
+		createdAt time.Duration // Before now
+		wantErr   error
+	}{
+		{"no grace period", 0, time.Minute, ErrEmailNotVerified},
+		{"within grace", 72 * time.Hour, time.Hour, nil},
+		{"past grace", 72 * time.Hour, 73 * time.Hour, ErrEmailNotVerified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.service.verificationGracePeriod = tt.grace
+			u, _ := env.register(t, "a@example.com")
+			if err := env.users.update(u.ID, func(u *user.User) { u.CreatedAt = time.Now().Add(-tt.createdAt) }); err != nil {
+				t.Fatal(err)
+			}
+
+			tokens, err := env.service.Login(context.Background(), u.Email, "correct horse battery staple", SessionMeta{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			claims, err := env.service.tokenService.VerifyToken(tokens.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.EmailVerified {
+				t.Error("access token claims email_verified = true for an unverified user")
+			}
+		})
+	}
+}
+
+func TestInviteOnlyRegistration(t *testing.T) {
+	ctx := context.Background()
+	const password = "correct horse battery staple"
+	env := newTestEnv(t)
+	env.service.inviteOnly = true
+	admin := uuid.New()
+
+	if _, err := env.service.Register(ctx, "none@example.com", "", password, ""); !errors.Is(err, ErrInviteRequired) {
+		t.Errorf("Register() without an invite error = %v, want ErrInviteRequired", err)
+	}
+
+	invite, token, err := env.service.CreateInvite(ctx, admin, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registration, err := env.service.Register(ctx, "a@example.com", "", password, token)
+	if err != nil {
+		t.Fatalf("Register() with a valid invite error = %v", err)
+	}
+	invites, err := env.service.ListInvites(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 1 || invites[0].ID != invite.ID || !invites[0].IsConsumed() || *invites[0].ConsumedBy != registration.User.ID {
+		t.Errorf("invites = %+v, want %s consumed by %s", invites, invite.ID, registration.User.ID)
+	}
+
+	if _, err := env.service.Register(ctx, "b@example.com", "", password, token); !errors.Is(err, ErrInvalidInvite) {
+		t.Errorf("Register() with a consumed invite error = %v, want ErrInvalidInvite", err)
+	}
+
+	_, token, err = env.service.CreateInvite(ctx, admin, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.redis.FastForward(time.Hour + time.Second)
+	if _, err := env.service.Register(ctx, "c@example.com", "", password, token); !errors.Is(err, ErrInvalidInvite) {
+		t.Errorf("Register() with an expired invite error = %v, want ErrInvalidInvite", err)
+	}
+
+	_, token, err = env.service.CreateInvite(ctx, admin, "d@example.com", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.service.Register(ctx, "e@example.com", "", password, token); !errors.Is(err, ErrInvalidInvite) {
+		t.Errorf("Register() with an invite for another address error = %v, want ErrInvalidInvite", err)
+	}
+	if _, err := env.service.Register(ctx, "D@example.com", "", password, token); err != nil {
+		t.Errorf("Register() with an invite for the address error = %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/redmonkez12/go-api-template/internal/user"
+)
+
+var (
+	ErrTenantRequired = errors.New("tenant is required")
+	ErrInvalidTenant  = errors.New("invalid tenant")
+)
+
+// TenantMode selects where requests name their tenant (TENANT_MODE)
+type TenantMode string
+
+const (
+	TenantOff       TenantMode = "off"
+	TenantHeader    TenantMode = "header"    // From a header such as X-Tenant-ID
+	TenantSubdomain TenantMode = "subdomain" // acme.example.com is tenant acme
+)
+
+// tenantPattern accepts lowercase DNS labels, so every tenant ID also works
+// as a subdomain
+var tenantPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TenantResolver finds the tenant a request is for. Emails and usernames are
+// unique per tenant, so public auth endpoints look users up within it and
+// access tokens only work in the tenant they were issued for.
+type TenantResolver struct {
+	Mode TenantMode
+	// Header carries the tenant ID in TenantHeader mode
+	Header string
+	// BaseDomain is the domain tenants are subdomains of in TenantSubdomain
+	// mode, e.g. example.com
+	BaseDomain string
+}
+
+// Enabled reports whether requests are scoped to tenants
+func (tr TenantResolver) Enabled() bool {
+	return tr.Mode == TenantHeader || tr.Mode == TenantSubdomain
+}
+
+// Resolve returns the tenant ID r names, lowercased. It returns "" with
+// tenancy off, and ErrTenantRequired or ErrInvalidTenant if tenancy is on
+// and r names no tenant or a malformed one.
+func (tr TenantResolver) Resolve(r *http.Request) (string, error) {
+	var tenantID string
+	switch tr.Mode {
+	case TenantHeader:
+		tenantID = r.Header.Get(tr.Header)
+	case TenantSubdomain:
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		subdomain, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(tr.BaseDomain))
+		if !ok {
+			return "", ErrTenantRequired
+		}
+		tenantID = subdomain
+	default:
+		return "", nil
+	}
+
+	tenantID = strings.ToLower(strings.TrimSpace(tenantID))
+	if tenantID == "" {
+		return "", ErrTenantRequired
+	}
+	if !tenantPattern.MatchString(tenantID) {
+		return "", ErrInvalidTenant
+	}
+	return tenantID, nil
+}
+
+// WithTenantID returns a copy of ctx scoped to tenantID, as RequireTenant
+// does for requests
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, TenantIDContextKey, tenantID)
+}
+
+// GetTenantIDFromContext returns the tenant RequireTenant resolved for the
+// request. It is false with tenancy off.
+func GetTenantIDFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(TenantIDContextKey).(string)
+	return tenantID, ok
+}
+
+// contextTenantID returns the tenant in ctx, or "" if there is none
+func contextTenantID(ctx context.Context) string {
+	tenantID, _ := GetTenantIDFromContext(ctx)
+	return tenantID
+}
+
+// TenantMatches reports whether u belongs to the tenant in ctx. It is always
+// true when ctx has no tenant, as with tenancy off.
+func TenantMatches(ctx context.Context, u *user.User) bool {
+	tenantID, ok := GetTenantIDFromContext(ctx)
+	if !ok {
+		return true
+	}
+	return u.Tenant() == tenantID
+}
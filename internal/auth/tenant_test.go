@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
+)
+
+func TestTenantResolverResolve(t *testing.T) {
+	header := TenantResolver{Mode: TenantHeader, Header: "X-Tenant-ID"}
+	subdomain := TenantResolver{Mode: TenantSubdomain, BaseDomain: "example.com"}
+
+	tests := []struct {
+		name     string
+		resolver TenantResolver
+		host     string
+		header   string
+		want     string
+		wantErr  error
+	}{
+		{"off", TenantResolver{Mode: TenantOff}, "acme.example.com", "acme", "", nil},
+		{"header", header, "api.example.com", " Acme ", "acme", nil},
+		{"missing header", header, "api.example.com", "", "", ErrTenantRequired},
+		{"malformed header", header, "api.example.com", "acme:evil", "", ErrInvalidTenant},
+		{"subdomain", subdomain, "Acme.Example.com:8080", "", "acme", nil},
+		{"bare domain", subdomain, "example.com", "", "", ErrTenantRequired},
+		{"other domain", subdomain, "acme.example.org", "", "", ErrTenantRequired},
+		{"nested subdomain", subdomain, "a.b.example.com", "", "", ErrInvalidTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tt.host
+			if tt.header != "" {
+				r.Header.Set("X-Tenant-ID", tt.header)
+			}
+			got, err := tt.resolver.Resolve(r)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Resolve() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUsersAndTokensAreScopedToTenants(t *testing.T) {
+	env := newTestEnv(t)
+	const password = "correct horse battery staple"
+	acme := WithTenantID(context.Background(), "acme")
+	globex := WithTenantID(context.Background(), "globex")
+
+	// The same email can register once per tenant
+	for _, ctx := range []context.Context{acme, globex} {
+		registration, err := env.service.Register(ctx, "a@example.com", "", password, "")
+		if err != nil {
+			t.Fatalf("Register(%s) error = %v", contextTenantID(ctx), err)
+		}
+		if err := env.users.MarkEmailAsVerified(ctx, registration.User.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := env.service.Register(acme, "a@example.com", "", password, ""); err == nil {
+		t.Error("registering the same email twice in one tenant succeeded")
+	}
+
+	tokens, err := env.service.Login(acme, "a@example.com", password, SessionMeta{})
+	if err != nil {
+		t.Fatalf("Login(acme) error = %v", err)
+	}
+	claims, err := env.service.tokenService.VerifyToken(tokens.AccessToken)
+	if err != nil || claims.TenantID != "acme" {
+		t.Fatalf("access token claims = %+v, %v, want tenant acme", claims, err)
+	}
+	if _, err := env.service.Login(context.Background(), "a@example.com", password, SessionMeta{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() outside any tenant error = %v, want ErrInvalidCredentials", err)
+	}
+
+	m := NewMiddleware(env.service.tokenService, env.users, TenantResolver{Mode: TenantHeader, Header: "X-Tenant-ID"})
+	handler := m.RequireTenant(m.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	for tenantID, want := range map[string]int{"acme": http.StatusNoContent, "globex": http.StatusForbidden, "": http.StatusBadRequest} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		if tenantID != "" {
+			r.Header.Set("X-Tenant-ID", tenantID)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("acme token in tenant %q = %d, want %d", tenantID, w.Code, want)
+		}
+	}
+}
+
+func TestInvitesAreScopedToTenants(t *testing.T) {
+	env := newTestEnv(t)
+	acme := WithTenantID(context.Background(), "acme")
+	globex := WithTenantID(context.Background(), "globex")
+
+	invite, _, err := env.service.CreateInvite(acme, uuid.New(), "new@acme.example", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ctx := range map[string]context.Context{"globex": globex, "no tenant": context.Background()} {
+		invites, err := env.service.ListInvites(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(invites) != 0 {
+			t.Errorf("ListInvites() for %s = %+v, want none of acme's", name, invites)
+		}
+		if err := env.service.RevokeInvite(ctx, invite.ID); !errors.Is(err, ErrInviteNotFound) {
+			t.Errorf("RevokeInvite() of acme's invite for %s error = %v, want ErrInviteNotFound", name, err)
+		}
+	}
+
+	invites, err := env.service.ListInvites(acme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 1 || invites[0].ID != invite.ID {
+		t.Fatalf("ListInvites() for acme = %+v, want its invite", invites)
+	}
+	if err := env.service.RevokeInvite(acme, invite.ID); err != nil {
+		t.Errorf("RevokeInvite() for acme error = %v", err)
+	}
+}
+
+func TestRequireTenantRecordsTenantInReqmeta(t *testing.T) {
+	m := NewMiddleware(nil, nil, TenantResolver{Mode: TenantHeader, Header: "X-Tenant-ID"})
+	meta := &reqmeta.Meta{}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Tenant-ID", "Acme")
+	r = r.WithContext(reqmeta.NewContext(r.Context(), meta))
+	m.RequireTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), r)
+
+	if meta.TenantID != "acme" {
+		t.Errorf("reqmeta TenantID = %q, want acme", meta.TenantID)
+	}
+}
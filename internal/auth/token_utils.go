@@ -4,13 +4,21 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 )
 
 var (
-	ErrRefreshTokenNotFound        = errors.New("refresh token not found")
-	ErrRefreshTokenRevoked         = errors.New("refresh token has been revoked")
-	ErrRefreshTokenExpired         = errors.New("refresh token has expired")
-	ErrPasswordResetTokenNotFound  = errors.New("password reset token not found or expired")
+	ErrRefreshTokenNotFound       = errors.New("refresh token not found")
+	ErrRefreshTokenRevoked        = errors.New("refresh token has been revoked")
+	ErrRefreshTokenExpired        = errors.New("refresh token has expired")
+	ErrSessionInactive            = errors.New("session expired due to inactivity")
+	ErrPasswordResetTokenNotFound = errors.New("password reset token not found or expired")
+	ErrMagicLinkTokenNotFound     = errors.New("magic link token not found or expired")
+	ErrRefreshTokenIPMismatch     = errors.New("refresh token used from a different network")
+	ErrRefreshRateLimited         = errors.New("too many token refreshes")
+	ErrSessionNotFound            = errors.New("session not found")
+	ErrInviteNotFound             = errors.New("invite not found or expired")
+	ErrAuthStoreUnavailable       = errors.New("auth store is unavailable")
 )
 
 // hashToken creates a SHA-256 hash of the token for storage
@@ -19,3 +27,26 @@ func hashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(hash[:])
 }
+
+// tokenPurpose namespaces the one-time tokens each email flow stores in Redis.
+// Every flow gets its own purpose, so a token minted for one flow can't be
+// redeemed by another.
+type tokenPurpose string
+
+const (
+	purposeEmailVerification tokenPurpose = "verification_token"
+	purposePasswordReset     tokenPurpose = "password_reset"
+	purposeMagicLink         tokenPurpose = "magic_link"
+	purposeInvite            tokenPurpose = "invite"
+)
+
+// hash hashes the token together with its purpose, so the same token string
+// produces a different hash in every flow
+func (p tokenPurpose) hash(token string) string {
+	return hashToken(string(p) + ":" + token)
+}
+
+// key generates the Redis key for a token issued for this purpose
+func (p tokenPurpose) key(token string) string {
+	return fmt.Sprintf("%s:%s", p, p.hash(token))
+}
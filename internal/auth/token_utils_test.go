@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTokenPurposeKeysDiffer(t *testing.T) {
+	const token = "same-token"
+	seen := make(map[string]tokenPurpose)
+	for _, p := range []tokenPurpose{purposeEmailVerification, purposePasswordReset, purposeMagicLink, purposeInvite} {
+		if other, ok := seen[p.hash(token)]; ok {
+			t.Errorf("%s and %s hash a token the same", p, other)
+		}
+		seen[p.hash(token)] = p
+	}
+}
+
+func TestTokensRejectedByOtherFlows(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u, verificationToken := env.register(t, "user@example.com")
+
+	if err := env.service.ResetPassword(ctx, verificationToken, "another long password"); !errors.Is(err, ErrPasswordResetTokenNotFound) {
+		t.Errorf("ResetPassword(verification token) error = %v, want ErrPasswordResetTokenNotFound", err)
+	}
+	if _, err := env.service.LoginWithMagicLink(ctx, verificationToken, SessionMeta{}); !errors.Is(err, ErrMagicLinkTokenNotFound) {
+		t.Errorf("LoginWithMagicLink(verification token) error = %v, want ErrMagicLinkTokenNotFound", err)
+	}
+
+	resetToken := env.service.RequestPasswordReset(ctx, u.Email)
+	if err := env.service.RequestMagicLink(ctx, u.Email); err != nil {
+		t.Fatal(err)
+	}
+	magicLinkToken := env.emails.last(t, env.service, &env.emails.magicLink)
+
+	for name, token := range map[string]string{"reset": resetToken, "magic link": magicLinkToken} {
+		if err := env.service.VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidVerificationToken) {
+			t.Errorf("VerifyEmail(%s token) error = %v, want ErrInvalidVerificationToken", name, err)
+		}
+	}
+	if _, err := env.service.LoginWithMagicLink(ctx, resetToken, SessionMeta{}); !errors.Is(err, ErrMagicLinkTokenNotFound) {
+		t.Errorf("LoginWithMagicLink(reset token) error = %v, want ErrMagicLinkTokenNotFound", err)
+	}
+	if err := env.service.ResetPassword(ctx, magicLinkToken, "another long password"); !errors.Is(err, ErrPasswordResetTokenNotFound) {
+		t.Errorf("ResetPassword(magic link token) error = %v, want ErrPasswordResetTokenNotFound", err)
+	}
+}
+
+func TestVerificationTokenStoredHashed(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u, token := env.register(t, "user@example.com")
+
+	stored, err := env.users.GetByID(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stored.EmailVerificationToken == token {
+		t.Fatal("verification token stored in plain text")
+	}
+	if emailed := env.emails.last(t, env.service, &env.emails.verification); emailed != token {
+		t.Errorf("emailed token %q, want %q", emailed, token)
+	}
+
+	if err := env.service.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+}
+
+func TestVerificationTokenStoredPlainStillVerifies(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u, _ := env.register(t, "user@example.com")
+
+	// A token issued before tokens were stored hashed
+	if err := env.users.UpdateVerificationToken(ctx, u.ID, "legacy-token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.service.VerifyEmail(ctx, "legacy-token"); err != nil {
+		t.Fatalf("VerifyEmail(legacy token) error = %v", err)
+	}
+}
+
+func TestSupersededVerificationToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	u, first := env.register(t, "user@example.com")
+
+	if err := env.service.ResendVerificationEmail(ctx, u.Email); err != nil {
+		t.Fatal(err)
+	}
+	second := env.emails.last(t, env.service, &env.emails.verification)
+
+	if err := env.service.VerifyEmail(ctx, first); !errors.Is(err, ErrVerificationSuperseded) {
+		t.Errorf("VerifyEmail(first) error = %v, want ErrVerificationSuperseded", err)
+	}
+	if err := env.service.VerifyEmail(ctx, second); err != nil {
+		t.Errorf("VerifyEmail(second) error = %v", err)
+	}
+
+	// Markers for tokens stored in plain text still match the emailed token
+	if err := env.service.verificationRepo.MarkSuperseded(ctx, "legacy-token"); err != nil {
+		t.Fatal(err)
+	}
+	if superseded, err := env.service.verificationRepo.IsSuperseded(ctx, "legacy-token"); err != nil || !superseded {
+		t.Errorf("IsSuperseded(legacy token) = %v, %v, want true", superseded, err)
+	}
+}
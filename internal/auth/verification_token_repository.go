@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// VerificationTokenRepository remembers verification tokens replaced by a
+// resend, so clicking an older link can be told apart from a bogus token.
+// Only the most recent token can verify an email.
+type VerificationTokenRepository struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewVerificationTokenRepository creates a new verification token repository
+// instance for links that stay valid for ttl
+func NewVerificationTokenRepository(client *redis.Client, ttl time.Duration) *VerificationTokenRepository {
+	return &VerificationTokenRepository{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// TTL returns how long an email verification link stays valid
+func (r *VerificationTokenRepository) TTL() time.Duration {
+	return r.ttl
+}
+
+// MarkSuperseded records that a stored token, as returned by
+// storedVerificationToken, was replaced by a newer one. The marker lives as
+// long as the link would have, after which it reads as expired anyway.
+func (r *VerificationTokenRepository) MarkSuperseded(ctx context.Context, storedToken string) error {
+	err := r.client.Set(ctx, supersededVerificationKey(storedToken), "1", r.ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to mark verification token superseded: %w", err)
+	}
+
+	return nil
+}
+
+// IsSuperseded reports whether token, as emailed, was replaced by a newer one
+func (r *VerificationTokenRepository) IsSuperseded(ctx context.Context, token string) (bool, error) {
+	// Tokens issued before they were stored hashed were stored as is
+	exists, err := r.client.Exists(ctx,
+		supersededVerificationKey(storedVerificationToken(token)),
+		supersededVerificationKey(token),
+	).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check superseded verification token: %w", err)
+	}
+
+	return exists > 0, nil
+}
+
+// storedVerificationToken returns the value a verification token is stored as
+// in the users table. It is hashed with the verification purpose, so the
+// column never holds a usable link and a token minted for another flow can
+// never match it.
+func storedVerificationToken(token string) string {
+	return purposeEmailVerification.hash(token)
+}
+
+// supersededVerificationKey generates a Redis key for a superseded stored
+// verification token
+func supersededVerificationKey(storedToken string) string {
+	return fmt.Sprintf("%s:superseded:%s", purposeEmailVerification, hashToken(storedToken))
+}
@@ -0,0 +1,21 @@
+// Package buildinfo reports which build of the API is running.
+package buildinfo
+
+import "runtime/debug"
+
+// version is set at build time:
+//
+//	go build -ldflags "-X github.com/redmonkez12/go-api-template/internal/buildinfo.version=v1.2.3"
+var version string
+
+// Version returns the version injected at build time. Without one it falls
+// back to the module version recorded by the Go toolchain, then "dev".
+func Version() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
@@ -1,21 +1,32 @@
 package config
 
 import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/redmonkez12/go-api-template/internal/logging"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Auth     AuthConfig
-	Email    EmailConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	Auth      AuthConfig
+	Email     EmailConfig
+	WebAuthn  WebAuthnConfig
+	RateLimit RateLimitConfig
+	Log       LogConfig
+	Endpoints EndpointsConfig
 }
 
 type ServerConfig struct {
@@ -25,6 +36,50 @@ type ServerConfig struct {
 	WriteTimeout    time.Duration
 	ShutdownTimeout time.Duration
 	TrustedOrigins  []string // CORS allowed origins for cookie auth
+	MaxBodyBytes    int64    // Maximum request body size in bytes
+	CompressMinSize int      // Responses shorter than this many bytes aren't compressed
+	// Per-phase shutdown limits, bounded overall by ShutdownTimeout: draining HTTP
+	// requests, then waiting for emails still being sent
+	ShutdownHTTPTimeout   time.Duration
+	ShutdownWorkerTimeout time.Duration
+	// Waiting for the database and Redis at startup: attempts per dependency,
+	// the first retry delay (doubling after each failure) and a deadline for
+	// the whole wait (0 = none)
+	StartupRetryAttempts int
+	StartupRetryInterval time.Duration
+	StartupTimeout       time.Duration
+	// Proxies whose X-Forwarded-For, X-Real-IP and X-Forwarded-Proto headers
+	// are believed (addresses or CIDRs)
+	TrustedProxies []netip.Prefix
+	// Longest X-Forwarded-For chain considered; longer ones are truncated (0 = no cap)
+	MaxForwardedFor int
+	// Redirect or reject plain HTTP requests forwarded by a trusted proxy (production only)
+	ForceHTTPS bool
+	// Host that ForceHTTPS redirects to; without it plain HTTP is rejected instead
+	CanonicalHost string
+	// Strict-Transport-Security max-age for HTTPS responses (0 disables; never sent in dev)
+	HSTSMaxAge time.Duration
+	// Content-Security-Policy for everything but Swagger UI
+	ContentSecurityPolicy string
+	// Permissions-Policy on every response
+	PermissionsPolicy string
+	// Log redacted request/response bodies (ignored outside dev)
+	DebugLogBodies bool
+	// Lowest TLS version accepted on outbound connections (tls.VersionTLS12 etc.)
+	MinTLSVersion uint16
+	// Send the build version in an X-API-Version header on every response
+	APIVersionHeader bool
+	// Requests served at once before new ones get a 503 (0 means unlimited)
+	MaxConcurrentRequests int
+	// Start in maintenance mode; admins can switch it at runtime, and SIGHUP
+	// re-reads it (see ReloadMaintenanceMode)
+	MaintenanceMode bool
+	// Retry-After sent with maintenance mode 503s (0 omits the header)
+	MaintenanceRetryAfter time.Duration
+	// Path prefix the API routes are mounted under, e.g. /v1 (empty mounts them at the root)
+	APIPrefix string
+	// Also serve the API routes without APIPrefix, for clients not yet migrated
+	ServeUnversionedRoutes bool
 }
 
 type DatabaseConfig struct {
@@ -35,6 +90,14 @@ type DatabaseConfig struct {
 	DBName         string
 	SSLMode        string
 	ChannelBinding string // "require" for Neon DB, empty for local
+	// Connection pool. A finite ConnMaxLifetime recycles connections before
+	// proxies like PgBouncer or a load balancer drop them.
+	MaxOpenConns    int           // 0 means unlimited
+	MaxIdleConns    int           // Idle connections kept open for reuse
+	ConnMaxLifetime time.Duration // 0 keeps connections forever
+	ConnMaxIdleTime time.Duration // 0 keeps idle connections forever
+	// Apply pending migrations at startup, before serving any request
+	AutoMigrate bool
 }
 
 type RedisConfig struct {
@@ -42,6 +105,16 @@ type RedisConfig struct {
 	Port     string
 	Password string
 	DB       int
+	// StoreBackend holds refresh tokens and rate limits in "redis" or, for
+	// tests and single-instance deployments, in process "memory"
+	StoreBackend string
+	// Consecutive Redis failures that open the refresh token store's circuit
+	// breaker (0 disables it), and how long it stays open before a trial call
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// RefreshTokenFallback keeps refresh tokens in "sql" while the breaker is
+	// open, or in nothing ("none"), answering 503 instead
+	RefreshTokenFallback string
 }
 
 type AuthConfig struct {
@@ -49,6 +122,118 @@ type AuthConfig struct {
 	PasetoKey            []byte
 	AccessTokenDuration  time.Duration
 	RefreshTokenDuration time.Duration
+	// Refresh lifetime for logins without "remember me"
+	SessionRefreshDuration time.Duration
+	// How long unverified users may log in after registering (0 = never)
+	VerificationGracePeriod time.Duration
+	// Expire sessions not refreshed within this window (0 = disabled)
+	InactivityTimeout time.Duration
+	// How long a rotated refresh token may be replayed for the same replacement (0 = disabled)
+	RefreshReuseGrace time.Duration
+	// Allows public sign-up via POST /auth/register
+	RegistrationEnabled bool
+	// "open" lets anyone register; "invite" requires an unused invite token
+	RegistrationMode string
+	// Send the verification email before answering registration and report
+	// the outcome as email_sent, instead of sending it in the background
+	RegistrationEmailSync bool
+	// What users log in with: "email", "username" (required at sign-up) or "either"
+	LoginIdentifier string
+	// Minimum estimated password strength, 0 (any) to 4, for new passwords
+	PasswordMinScore int
+	// Where requests name their tenant: "off", "header" or "subdomain".
+	// Emails and usernames are unique per tenant when it isn't off.
+	TenantMode string
+	// Header carrying the tenant ID with TenantMode "header"
+	TenantHeader string
+	// Domain tenants are subdomains of with TenantMode "subdomain"
+	TenantBaseDomain string
+	// How long invites stay valid unless created with their own lifetime
+	InviteTTL time.Duration
+	// Verified users with these emails can use the admin endpoints. With
+	// tenancy on, each is prefixed with its tenant, as in acme:admin@example.com.
+	AdminEmails []string
+	// Enables passwordless login via emailed single-use links
+	MagicLinkEnabled bool
+	// Bind refresh tokens to the issuing client's subnet
+	RefreshTokenBindIP bool
+	// Prefix lengths a refreshing client must share with the issuing IP
+	RefreshTokenBindIPv4Prefix int
+	RefreshTokenBindIPv6Prefix int
+	// Maximum time since login for sensitive actions
+	FreshAuthMaxAge time.Duration
+	// Progressive delay after failed logins (doubles per failure up to the max)
+	LoginBackoffEnabled   bool
+	LoginBackoffBaseDelay time.Duration
+	LoginBackoffMaxDelay  time.Duration
+	// How long emailed password reset and verification links stay valid
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	// Email a fresh verification link when an unverified user logs in with
+	// the right password, subject to the usual verification email cooldown
+	AutoResendVerification bool
+	// Return verification and reset tokens in API responses for end-to-end
+	// tests. Only honoured with APP_ENV=dev; Validate rejects it elsewhere.
+	ExposeTokensDev bool
+	// Auth cookie lifetimes, independent of the tokens they carry
+	// (0 = session cookie, dropped when the browser closes)
+	AccessCookieMaxAge  time.Duration
+	RefreshCookieMaxAge time.Duration
+	// Auth cookie scope, for a frontend on another subdomain: a Domain such as
+	// example.com shares the cookies with app.example.com
+	CookieDomain string
+	CookiePath   string
+	// lax, strict or none; none makes the cookies Secure
+	CookieSameSite string
+}
+
+type RateLimitConfig struct {
+	IPMax    int           // Requests per IP and endpoint within IPWindow
+	IPWindow time.Duration // Sliding window for IP rate limits
+	// Token refreshes allowed per user within RefreshWindow
+	RefreshMax    int
+	RefreshWindow time.Duration
+	// Minimum time between emails to the same address
+	EmailCooldown time.Duration
+	// Per-kind overrides of EmailCooldown (0 = use EmailCooldown)
+	VerificationEmailCooldown  time.Duration
+	PasswordResetEmailCooldown time.Duration
+	MagicLinkEmailCooldown     time.Duration
+	// Count only failed logins toward the login IP limit, so successful logins
+	// from a shared IP (an office behind NAT) don't use up its budget
+	LoginFailuresOnly bool
+	// How often the in-memory store evicts expired entries (memory store only)
+	SweepInterval time.Duration
+}
+
+type LogConfig struct {
+	Format string     // "json" or "text"; defaults to text in dev and json in prod
+	Level  slog.Level // Defaults to debug in dev and info in prod
+	// Attribute keys whose values are logged as *** (case-insensitive)
+	RedactKeys []string
+	// Log email addresses as a hash instead of in plaintext
+	HashEmails bool
+	// HMAC key for the email hashes
+	EmailHashKey string
+}
+
+// EndpointsConfig switches individual auth endpoints on or off. Disabled
+// endpoints aren't mounted at all, so they return 404.
+type EndpointsConfig struct {
+	Register          bool // POST /auth/register
+	Login             bool // POST /auth/login
+	Refresh           bool // POST /auth/refresh
+	Logout            bool // POST /auth/logout and /auth/logout-all
+	PasswordReset     bool // POST /auth/forgot-password and /auth/reset-password
+	EmailVerification bool // GET /auth/verify-email and POST /auth/resend-verification
+	Sessions          bool // GET /auth/sessions and DELETE /auth/sessions/{id}
+}
+
+type WebAuthnConfig struct {
+	Enabled       bool
+	RPID          string   // Relying party ID, the site's registrable domain (e.g. example.com)
+	RPDisplayName string   // Name shown by the authenticator
+	RPOrigins     []string // Origins allowed to run ceremonies (the frontend URLs)
 }
 
 type EmailConfig struct {
@@ -56,10 +241,20 @@ type EmailConfig struct {
 	SMTPPort     string
 	SMTPUser     string
 	SMTPPassword string
-	FrontendURL  string // Frontend URL for verification links
+	SMTPFrom     string // Sender address, defaults to SMTPUser
+	FromName     string // Sender display name, defaults to AppName
+	AppName      string // Product name used in email branding
+	// SMTPEncryption is "none", "starttls" (required, usually port 587)
+	// or "tls" (implicit TLS, usually port 465)
+	SMTPEncryption string
+	// Skip server certificate verification (self-signed dev servers only)
+	SMTPInsecureSkipVerify bool
+	SMTPTimeout            time.Duration // Upper bound on sending one email
+	FrontendURL            string        // Frontend URL for verification links
 }
 
-// Load reads configuration from environment variables
+// Load reads configuration from environment variables, failing only on values
+// that can't be parsed. Call Validate on the result before using it.
 // Call godotenv.Load() before this if using .env file
 func Load() (*Config, error) {
 	// Try to load .env file (ignore error if it doesn't exist)
@@ -67,45 +262,191 @@ func Load() (*Config, error) {
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Port:            getEnv("SERVER_PORT", "8080"),
-			Env:             getEnv("APP_ENV", "dev"),
-			ReadTimeout:     getDurationEnv("SERVER_READ_TIMEOUT", 10*time.Second),
-			WriteTimeout:    getDurationEnv("SERVER_WRITE_TIMEOUT", 10*time.Second),
-			ShutdownTimeout: getDurationEnv("SERVER_SHUTDOWN_TIMEOUT", 15*time.Second),
-			TrustedOrigins:  getSliceEnv("TRUSTED_ORIGINS", []string{"http://localhost:3000"}),
+			Port:                   getEnv("SERVER_PORT", "8080"),
+			Env:                    getEnv("APP_ENV", "dev"),
+			ReadTimeout:            getDurationEnv("SERVER_READ_TIMEOUT", 10*time.Second),
+			WriteTimeout:           getDurationEnv("SERVER_WRITE_TIMEOUT", 10*time.Second),
+			ShutdownTimeout:        getDurationEnv("SERVER_SHUTDOWN_TIMEOUT", 15*time.Second),
+			ShutdownHTTPTimeout:    getDurationEnv("SHUTDOWN_HTTP_TIMEOUT", 10*time.Second),
+			ShutdownWorkerTimeout:  getDurationEnv("SHUTDOWN_WORKER_TIMEOUT", 5*time.Second),
+			StartupRetryAttempts:   getIntEnv("STARTUP_RETRY_ATTEMPTS", 10),
+			StartupRetryInterval:   getDurationEnv("STARTUP_RETRY_INTERVAL", time.Second),
+			StartupTimeout:         getDurationEnv("STARTUP_TIMEOUT", time.Minute),
+			TrustedOrigins:         getSliceEnv("TRUSTED_ORIGINS", []string{"http://localhost:3000"}),
+			MaxBodyBytes:           int64(getIntEnv("MAX_REQUEST_BODY_BYTES", 1<<20)),
+			CompressMinSize:        getIntEnv("COMPRESS_MIN_SIZE", 1024),
+			ForceHTTPS:             getBoolEnv("FORCE_HTTPS", false),
+			CanonicalHost:          getEnv("CANONICAL_HOST", ""),
+			HSTSMaxAge:             getDurationEnv("HSTS_MAX_AGE", 365*24*time.Hour),
+			ContentSecurityPolicy:  getEnv("CONTENT_SECURITY_POLICY", "default-src 'none'; frame-ancestors 'none'"),
+			PermissionsPolicy:      getEnv("PERMISSIONS_POLICY", "camera=(), microphone=(), geolocation=(), payment=()"),
+			DebugLogBodies:         getBoolEnv("DEBUG_LOG_BODIES", false),
+			APIVersionHeader:       getBoolEnv("API_VERSION_HEADER", true),
+			MaxConcurrentRequests:  getIntEnv("MAX_CONCURRENT_REQUESTS", 0),
+			MaintenanceMode:        getBoolEnv("MAINTENANCE_MODE", false),
+			MaintenanceRetryAfter:  getDurationEnv("MAINTENANCE_RETRY_AFTER", time.Minute),
+			MaxForwardedFor:        getIntEnv("MAX_FORWARDED_FOR", 20),
+			APIPrefix:              strings.TrimSuffix(getEnv("API_PREFIX", "/v1"), "/"),
+			ServeUnversionedRoutes: getBoolEnv("SERVE_UNVERSIONED_ROUTES", false),
 		},
 		Database: DatabaseConfig{
-			Host:           getEnv("DB_HOST", "localhost"),
-			Port:           getEnv("DB_PORT", "5432"),
-			User:           getEnv("DB_USER", "postgres"),
-			Password:       getEnv("DB_PASSWORD", "postgres"),
-			DBName:         getEnv("DB_NAME", "goapi"),
-			SSLMode:        getEnv("DB_SSLMODE", "disable"),
-			ChannelBinding: getEnv("DB_CHANNEL_BINDING", ""),
+			Host:            getEnv("DB_HOST", "localhost"),
+			Port:            getEnv("DB_PORT", "5432"),
+			User:            getEnv("DB_USER", "postgres"),
+			Password:        getEnv("DB_PASSWORD", "postgres"),
+			DBName:          getEnv("DB_NAME", "goapi"),
+			SSLMode:         getEnv("DB_SSLMODE", "disable"),
+			ChannelBinding:  getEnv("DB_CHANNEL_BINDING", ""),
+			MaxOpenConns:    getIntEnv("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    getIntEnv("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: getDurationEnv("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime: getDurationEnv("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			AutoMigrate:     getBoolEnv("AUTO_MIGRATE", false),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
 			Port:     getEnv("REDIS_PORT", "6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getIntEnv("REDIS_DB", 0),
+			// An explicitly empty REDIS_HOST means there is no Redis to use
+			StoreBackend:         getEnv("STORE_BACKEND", defaultStoreBackend()),
+			BreakerThreshold:     getIntEnv("REDIS_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:      getDurationEnv("REDIS_BREAKER_COOLDOWN", 30*time.Second),
+			RefreshTokenFallback: getEnv("REFRESH_TOKEN_FALLBACK", "none"),
 		},
 		Auth: AuthConfig{
-			PasetoKey:            []byte(getEnv("PASETO_KEY", "")),
-			AccessTokenDuration:  getDurationEnv("ACCESS_TOKEN_DURATION", 15*time.Minute),
-			RefreshTokenDuration: getDurationEnv("REFRESH_TOKEN_DURATION", 7*24*time.Hour),
+			PasetoKey:                  []byte(getEnv("PASETO_KEY", "")),
+			AccessTokenDuration:        getDurationEnv("ACCESS_TOKEN_DURATION", 15*time.Minute),
+			RefreshTokenDuration:       getDurationEnv("REFRESH_TOKEN_DURATION", 7*24*time.Hour),
+			SessionRefreshDuration:     getDurationEnv("SESSION_REFRESH_DURATION", 24*time.Hour),
+			VerificationGracePeriod:    getDurationEnv("VERIFICATION_GRACE_PERIOD", 0),
+			InactivityTimeout:          getDurationEnv("INACTIVITY_TIMEOUT", 0),
+			RefreshReuseGrace:          getDurationEnv("REFRESH_REUSE_GRACE", 5*time.Second),
+			RegistrationEnabled:        getBoolEnv("REGISTRATION_ENABLED", true),
+			RegistrationMode:           getEnv("REGISTRATION_MODE", "open"),
+			RegistrationEmailSync:      getBoolEnv("REGISTRATION_EMAIL_SYNC", false),
+			LoginIdentifier:            getEnv("LOGIN_IDENTIFIER", "email"),
+			PasswordMinScore:           getIntEnv("PASSWORD_MIN_SCORE", 2),
+			TenantMode:                 getEnv("TENANT_MODE", "off"),
+			TenantHeader:               getEnv("TENANT_HEADER", "X-Tenant-ID"),
+			TenantBaseDomain:           getEnv("TENANT_BASE_DOMAIN", ""),
+			InviteTTL:                  getDurationEnv("INVITE_TTL", 7*24*time.Hour),
+			AdminEmails:                getSliceEnv("ADMIN_EMAILS", nil),
+			MagicLinkEnabled:           getBoolEnv("MAGIC_LINK_ENABLED", false),
+			RefreshTokenBindIP:         getBoolEnv("REFRESH_TOKEN_BIND_IP", false),
+			RefreshTokenBindIPv4Prefix: getIntEnv("REFRESH_TOKEN_BIND_IPV4_PREFIX", 24),
+			RefreshTokenBindIPv6Prefix: getIntEnv("REFRESH_TOKEN_BIND_IPV6_PREFIX", 64),
+			FreshAuthMaxAge:            getDurationEnv("FRESH_AUTH_MAX_AGE", 5*time.Minute),
+			LoginBackoffEnabled:        getBoolEnv("LOGIN_BACKOFF_ENABLED", false),
+			LoginBackoffBaseDelay:      getDurationEnv("LOGIN_BACKOFF_BASE_DELAY", 1*time.Second),
+			LoginBackoffMaxDelay:       getDurationEnv("LOGIN_BACKOFF_MAX_DELAY", 10*time.Second),
+			PasswordResetTTL:           getDurationEnv("PASSWORD_RESET_TTL", 1*time.Hour),
+			EmailVerificationTTL:       getDurationEnv("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			AutoResendVerification:     getBoolEnv("AUTO_RESEND_VERIFICATION", false),
+			ExposeTokensDev:            getBoolEnv("EXPOSE_TOKENS_DEV", false),
 		},
 		Email: EmailConfig{
-			SMTPHost:     getEnv("SMTP_HOST", ""),
-			SMTPPort:     getEnv("SMTP_PORT", "587"),
-			SMTPUser:     getEnv("SMTP_USER", ""),
-			SMTPPassword: getEnv("SMTP_PASS", ""),
-			FrontendURL:  getEnv("FRONTEND_URL", "http://localhost:3000"),
+			SMTPHost:               getEnv("SMTP_HOST", ""),
+			SMTPPort:               getEnv("SMTP_PORT", "587"),
+			SMTPUser:               getEnv("SMTP_USER", ""),
+			SMTPPassword:           getEnv("SMTP_PASS", ""),
+			SMTPFrom:               getEnv("SMTP_FROM", getEnv("SMTP_USER", "")),
+			FromName:               getEnv("EMAIL_FROM_NAME", getEnv("APP_NAME", "Go API Template")),
+			AppName:                getEnv("APP_NAME", "Go API Template"),
+			SMTPEncryption:         getEnv("SMTP_ENCRYPTION", "starttls"),
+			SMTPInsecureSkipVerify: getBoolEnv("SMTP_INSECURE_SKIP_VERIFY", false),
+			SMTPTimeout:            getDurationEnv("SMTP_TIMEOUT", 30*time.Second),
+			FrontendURL:            getEnv("FRONTEND_URL", "http://localhost:3000"),
+		},
+		WebAuthn: WebAuthnConfig{
+			Enabled:       getBoolEnv("WEBAUTHN_ENABLED", false),
+			RPID:          getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPDisplayName: getEnv("WEBAUTHN_RP_DISPLAY_NAME", "Go API Template"),
+			RPOrigins:     getSliceEnv("WEBAUTHN_RP_ORIGINS", []string{"http://localhost:3000"}),
+		},
+		RateLimit: RateLimitConfig{
+			IPMax:                      getIntEnv("RATE_LIMIT_IP_MAX", 10),
+			IPWindow:                   getDurationEnv("RATE_LIMIT_IP_WINDOW", 15*time.Minute),
+			RefreshMax:                 getIntEnv("RATE_LIMIT_REFRESH_MAX", 30),
+			RefreshWindow:              getDurationEnv("RATE_LIMIT_REFRESH_WINDOW", 15*time.Minute),
+			EmailCooldown:              getDurationEnv("RATE_LIMIT_EMAIL_COOLDOWN", 2*time.Minute),
+			VerificationEmailCooldown:  getDurationEnv("VERIFICATION_EMAIL_COOLDOWN", 0),
+			PasswordResetEmailCooldown: getDurationEnv("PASSWORD_RESET_EMAIL_COOLDOWN", 0),
+			MagicLinkEmailCooldown:     getDurationEnv("MAGIC_LINK_EMAIL_COOLDOWN", 0),
+			LoginFailuresOnly:          getBoolEnv("RATE_LIMIT_LOGIN_FAILURES_ONLY", false),
+			SweepInterval:              getDurationEnv("RATE_LIMIT_SWEEP_INTERVAL", time.Minute),
 		},
+		Endpoints: EndpointsConfig{
+			Register:          getBoolEnv("ENDPOINT_REGISTER_ENABLED", true),
+			Login:             getBoolEnv("ENDPOINT_LOGIN_ENABLED", true),
+			Refresh:           getBoolEnv("ENDPOINT_REFRESH_ENABLED", true),
+			Logout:            getBoolEnv("ENDPOINT_LOGOUT_ENABLED", true),
+			PasswordReset:     getBoolEnv("ENDPOINT_PASSWORD_RESET_ENABLED", true),
+			EmailVerification: getBoolEnv("ENDPOINT_EMAIL_VERIFICATION_ENABLED", true),
+			Sessions:          getBoolEnv("ENDPOINT_SESSIONS_ENABLED", true),
+		},
+	}
+
+	// Cookies live as long as their tokens unless configured otherwise
+	cfg.Auth.AccessCookieMaxAge = getCookieMaxAgeEnv("ACCESS_COOKIE_MAXAGE", cfg.Auth.AccessTokenDuration)
+	cfg.Auth.RefreshCookieMaxAge = getCookieMaxAgeEnv("REFRESH_COOKIE_MAXAGE", cfg.Auth.RefreshTokenDuration)
+	cfg.Auth.CookieDomain = getEnv("COOKIE_DOMAIN", "")
+	cfg.Auth.CookiePath = getEnv("COOKIE_PATH", "/")
+	cfg.Auth.CookieSameSite = strings.ToLower(getEnv("COOKIE_SAMESITE", "lax"))
+
+	cfg.Log.EmailHashKey = getEnv("LOG_EMAIL_HASH_KEY", "")
+
+	var err error
+
+	// Secrets mounted as files (Docker and Kubernetes secrets) take precedence
+	// over the plain env vars
+	pasetoKey := string(cfg.Auth.PasetoKey)
+	for _, secret := range []struct {
+		key string
+		dst *string
+	}{
+		{"PASETO_KEY", &pasetoKey},
+		{"DB_PASSWORD", &cfg.Database.Password},
+		{"REDIS_PASSWORD", &cfg.Redis.Password},
+		{"SMTP_PASS", &cfg.Email.SMTPPassword},
+		{"LOG_EMAIL_HASH_KEY", &cfg.Log.EmailHashKey},
+	} {
+		value, ok, err := readSecretFile(secret.key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s_FILE: %w", secret.key, err)
+		}
+		if ok {
+			*secret.dst = value
+		}
+	}
+	cfg.Auth.PasetoKey, err = decodePasetoKey(pasetoKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASETO_KEY: %w", err)
+	}
+
+	trustedProxies, err := parsePrefixes(getSliceEnv("TRUSTED_PROXIES", nil))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	cfg.Server.TrustedProxies = trustedProxies
+
+	minTLSVersion, err := parseTLSVersion(getEnv("MIN_TLS_VERSION", "1.2"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MIN_TLS_VERSION: %w", err)
 	}
+	cfg.Server.MinTLSVersion = minTLSVersion
 
-	// Validate PASETO key length (must be 32 bytes for v4.local)
-	if len(cfg.Auth.PasetoKey) != 32 {
-		return nil, fmt.Errorf("PASETO_KEY must be exactly 32 bytes, got %d", len(cfg.Auth.PasetoKey))
+	// Readable text with debug output in dev, JSON at info in prod, unless
+	// overridden
+	logFormat, logLevel := "json", "info"
+	if cfg.Server.IsDevelopment() {
+		logFormat, logLevel = "text", "debug"
+	}
+	cfg.Log.Format = getEnv("LOG_FORMAT", logFormat)
+	cfg.Log.RedactKeys = getSliceEnv("LOG_REDACT_KEYS", logging.DefaultRedactKeys)
+	cfg.Log.HashEmails = getBoolEnv("LOG_HASH_EMAILS", false)
+	if err := cfg.Log.Level.UnmarshalText([]byte(getEnv("LOG_LEVEL", logLevel))); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: must be debug, info, warn or error: %w", err)
 	}
 
 	return cfg, nil
@@ -130,11 +471,38 @@ func (c *RedisConfig) Address() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
 
+// UsesMemoryStore reports whether refresh tokens and rate limits are kept in
+// process memory instead of Redis
+func (c *RedisConfig) UsesMemoryStore() bool {
+	return c.StoreBackend == "memory"
+}
+
+// defaultStoreBackend picks memory when REDIS_HOST is set but empty, and Redis otherwise
+func defaultStoreBackend() string {
+	if host, ok := os.LookupEnv("REDIS_HOST"); ok && host == "" {
+		return "memory"
+	}
+	return "redis"
+}
+
 // IsDevelopment returns true if the environment is set to dev
 func (c *ServerConfig) IsDevelopment() bool {
 	return c.Env == "dev"
 }
 
+// ReloadMaintenanceMode re-reads MAINTENANCE_MODE when the server gets
+// SIGHUP. A running process's environment can't be changed from outside, so
+// a value in the .env file takes precedence here; without one, the value from
+// the environment at startup is kept.
+func ReloadMaintenanceMode() bool {
+	if values, err := godotenv.Read(); err == nil {
+		if enabled, err := strconv.ParseBool(values["MAINTENANCE_MODE"]); err == nil {
+			return enabled
+		}
+	}
+	return getBoolEnv("MAINTENANCE_MODE", false)
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -156,12 +524,111 @@ func getIntEnv(key string, defaultValue int) int {
 	return intValue
 }
 
+func getBoolEnv(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+
+	return boolValue
+}
+
+// pasetoKeyLen is the key size PASETO v4.local requires
+const pasetoKeyLen = 32
+
+// minEmailHashKeyLen is the shortest LOG_EMAIL_HASH_KEY accepted, the size of
+// the SHA-256 output
+const minEmailHashKeyLen = 32
+
+// decodePasetoKey decodes PASETO_KEY. A "base64:" or "hex:" prefix selects the
+// encoding explicitly. Without one, a 32-character key is used as raw bytes,
+// and a 64-character hex or 43/44-character base64 string is decoded. Only
+// malformed encodings are errors here; Validate reports a wrong decoded length.
+func decodePasetoKey(value string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(value, "base64:"):
+		key, err := decodeBase64(strings.TrimPrefix(value, "base64:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 encoding: %w", err)
+		}
+		return key, nil
+	case strings.HasPrefix(value, "hex:"):
+		key, err := hex.DecodeString(strings.TrimPrefix(value, "hex:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex encoding: %w", err)
+		}
+		return key, nil
+	case len(value) == pasetoKeyLen:
+		return []byte(value), nil
+	}
+
+	// Unprefixed keys are decoded when the length matches an encoded 32-byte key
+	// and kept raw otherwise, so Validate reports their length
+	if len(value) == hex.EncodedLen(pasetoKeyLen) {
+		if key, err := hex.DecodeString(value); err == nil {
+			return key, nil
+		}
+	}
+	if len(value) == base64.StdEncoding.EncodedLen(pasetoKeyLen) || len(value) == base64.RawStdEncoding.EncodedLen(pasetoKeyLen) {
+		if key, err := decodeBase64(value); err == nil {
+			return key, nil
+		}
+	}
+	return []byte(value), nil
+}
+
+// decodeBase64 accepts standard or URL-safe base64, with or without padding
+func decodeBase64(value string) ([]byte, error) {
+	encodings := []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	}
+
+	var err error
+	for _, enc := range encodings {
+		var key []byte
+		if key, err = enc.DecodeString(value); err == nil {
+			return key, nil
+		}
+	}
+	return nil, err
+}
+
+// readSecretFile reads the secret named by key from the file at key+"_FILE",
+// trimming trailing newlines. ok is false when no file is configured.
+func readSecretFile(key string) (value string, ok bool, err error) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return "", false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+// getDurationEnv reads a duration as a Go duration string ("15m", "168h") or,
+// for backward compatibility, as a bare number of seconds ("900"). Empty or
+// malformed values fall back to defaultValue; negative values are returned as
+// given.
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
 	}
 
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+
 	seconds, err := strconv.Atoi(value)
 	if err != nil {
 		return defaultValue
@@ -170,6 +637,37 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// parsePrefixes parses CIDRs, treating a bare address as a single-host prefix
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		if strings.Contains(v, "/") {
+			prefix, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// getCookieMaxAgeEnv reads a cookie max-age in seconds. "session" yields 0,
+// which sets no Max-Age so the cookie ends with the browser session.
+func getCookieMaxAgeEnv(key string, defaultValue time.Duration) time.Duration {
+	if os.Getenv(key) == "session" {
+		return 0
+	}
+	return getDurationEnv(key, defaultValue)
+}
+
 func getSliceEnv(key string, defaultValue []string) []string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -191,3 +689,19 @@ func getSliceEnv(key string, defaultValue []string) []string {
 
 	return result
 }
+
+// parseTLSVersion converts a version such as "1.2" to its crypto/tls constant
+func parseTLSVersion(value string) (uint16, error) {
+	switch value {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("must be 1.0, 1.1, 1.2 or 1.3, got %q", value)
+	}
+}
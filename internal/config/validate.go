@@ -0,0 +1,286 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists every problem Validate found, so a misconfigured
+// deployment can be fixed in one pass instead of one restart per setting
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d configuration problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// validator collects problems instead of stopping at the first one
+type validator struct {
+	dev      bool
+	problems []string
+	warnings []string
+}
+
+// fail records a problem in every environment
+func (v *validator) fail(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// failInProd records a problem in production and only a warning in dev, for
+// settings local setups routinely leave out
+func (v *validator) failInProd(format string, args ...any) {
+	if v.dev {
+		v.warn(format, args...)
+		return
+	}
+	v.fail(format, args...)
+}
+
+// warn records something worth logging that never stops startup
+func (v *validator) warn(format string, args ...any) {
+	v.warnings = append(v.warnings, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the loaded configuration. It returns a *ValidationError
+// listing every problem, and warnings to log at startup: settings that are
+// only required in production are reported as warnings in dev.
+func (c *Config) Validate() (warnings []string, err error) {
+	v := &validator{dev: c.Server.IsDevelopment()}
+
+	// Server
+	if c.Server.MaxConcurrentRequests < 0 {
+		v.fail("MAX_CONCURRENT_REQUESTS must not be negative, got %d", c.Server.MaxConcurrentRequests)
+	}
+	if c.Server.CompressMinSize < 0 {
+		v.fail("COMPRESS_MIN_SIZE must not be negative, got %d", c.Server.CompressMinSize)
+	}
+	if c.Server.MaxForwardedFor < 0 {
+		v.fail("MAX_FORWARDED_FOR must not be negative, got %d", c.Server.MaxForwardedFor)
+	}
+	if c.Server.APIPrefix != "" && !strings.HasPrefix(c.Server.APIPrefix, "/") {
+		v.fail("API_PREFIX must start with /, got %q", c.Server.APIPrefix)
+	}
+	if c.Server.ServeUnversionedRoutes && c.Server.APIPrefix == "" {
+		v.warn("SERVE_UNVERSIONED_ROUTES has no effect without API_PREFIX")
+	}
+	if c.Server.ShutdownHTTPTimeout+c.Server.ShutdownWorkerTimeout > c.Server.ShutdownTimeout {
+		v.warn("SHUTDOWN_HTTP_TIMEOUT + SHUTDOWN_WORKER_TIMEOUT (%s) exceed SERVER_SHUTDOWN_TIMEOUT (%s), so a slow HTTP drain can leave no time for email workers",
+			c.Server.ShutdownHTTPTimeout+c.Server.ShutdownWorkerTimeout, c.Server.ShutdownTimeout)
+	}
+	if c.Server.ForceHTTPS && !v.dev {
+		if len(c.Server.TrustedProxies) == 0 {
+			v.warn("FORCE_HTTPS has no effect without TRUSTED_PROXIES: X-Forwarded-Proto is only believed from trusted proxies")
+		} else if c.Server.CanonicalHost == "" {
+			v.warn("FORCE_HTTPS without CANONICAL_HOST rejects plain HTTP GET and HEAD requests instead of redirecting them")
+		}
+	}
+	if strings.ContainsAny(c.Server.CanonicalHost, "/?#@") {
+		v.fail("CANONICAL_HOST must be a host name with an optional port, got %q", c.Server.CanonicalHost)
+	}
+	if c.Server.HSTSMaxAge < 0 {
+		v.fail("HSTS_MAX_AGE must not be negative, got %s", c.Server.HSTSMaxAge)
+	}
+	if c.Server.StartupRetryAttempts < 1 {
+		v.fail("STARTUP_RETRY_ATTEMPTS must be at least 1, got %d", c.Server.StartupRetryAttempts)
+	}
+	if c.Server.StartupRetryInterval < 0 || c.Server.StartupTimeout < 0 {
+		v.fail("STARTUP_RETRY_INTERVAL and STARTUP_TIMEOUT must not be negative")
+	}
+	if c.Server.MaintenanceRetryAfter < 0 {
+		v.fail("MAINTENANCE_RETRY_AFTER must not be negative, got %s", c.Server.MaintenanceRetryAfter)
+	}
+	if len(c.Server.TrustedOrigins) == 0 {
+		v.failInProd("TRUSTED_ORIGINS is empty, so browsers can't use cookie auth from any frontend")
+	}
+
+	// Logging
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		v.fail("LOG_FORMAT must be json or text, got %q", c.Log.Format)
+	}
+	if c.Log.HashEmails && len(c.Log.EmailHashKey) < minEmailHashKeyLen {
+		v.fail("LOG_EMAIL_HASH_KEY must be at least %d bytes when LOG_HASH_EMAILS is enabled, got %d", minEmailHashKeyLen, len(c.Log.EmailHashKey))
+	}
+
+	// Database
+	for _, setting := range []struct{ name, value string }{
+		{"DB_HOST", c.Database.Host},
+		{"DB_USER", c.Database.User},
+		{"DB_PASSWORD", c.Database.Password},
+		{"DB_NAME", c.Database.DBName},
+	} {
+		if setting.value == "" {
+			v.failInProd("%s is required", setting.name)
+		}
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		v.fail("DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		v.warn("DB_MAX_IDLE_CONNS (%d) exceeds DB_MAX_OPEN_CONNS (%d); only %d idle connections will be kept",
+			c.Database.MaxIdleConns, c.Database.MaxOpenConns, c.Database.MaxOpenConns)
+	}
+
+	// Refresh token and rate limit store
+	switch c.Redis.StoreBackend {
+	case "redis":
+	case "memory":
+		if !v.dev {
+			v.warn("STORE_BACKEND=memory keeps sessions and rate limits per instance; don't run more than one")
+		}
+	default:
+		v.fail("STORE_BACKEND must be redis or memory, got %q", c.Redis.StoreBackend)
+	}
+	if c.Redis.UsesMemoryStore() && c.RateLimit.SweepInterval <= 0 {
+		v.fail("RATE_LIMIT_SWEEP_INTERVAL must be positive, got %s", c.RateLimit.SweepInterval)
+	}
+	if c.Redis.BreakerThreshold < 0 {
+		v.fail("REDIS_BREAKER_THRESHOLD must not be negative, got %d", c.Redis.BreakerThreshold)
+	}
+	if c.Redis.BreakerThreshold > 0 && c.Redis.BreakerCooldown <= 0 {
+		v.fail("REDIS_BREAKER_COOLDOWN must be positive, got %s", c.Redis.BreakerCooldown)
+	}
+	switch c.Redis.RefreshTokenFallback {
+	case "none":
+	case "sql":
+		if c.Redis.BreakerThreshold == 0 {
+			v.fail("REFRESH_TOKEN_FALLBACK=sql needs the circuit breaker; set REDIS_BREAKER_THRESHOLD above 0")
+		}
+		if c.Redis.UsesMemoryStore() {
+			v.warn("REFRESH_TOKEN_FALLBACK is ignored with STORE_BACKEND=memory")
+		}
+	default:
+		v.fail("REFRESH_TOKEN_FALLBACK must be none or sql, got %q", c.Redis.RefreshTokenFallback)
+	}
+
+	// Auth
+	if len(c.Auth.PasetoKey) != pasetoKeyLen {
+		v.fail("PASETO_KEY must be exactly %d bytes (after base64/hex decoding), got %d", pasetoKeyLen, len(c.Auth.PasetoKey))
+	}
+	switch c.Auth.LoginIdentifier {
+	case "email", "username", "either":
+	default:
+		v.fail("LOGIN_IDENTIFIER must be email, username or either, got %q", c.Auth.LoginIdentifier)
+	}
+	if c.Auth.PasswordMinScore < 0 || c.Auth.PasswordMinScore > 4 {
+		v.fail("PASSWORD_MIN_SCORE must be from 0 to 4, got %d", c.Auth.PasswordMinScore)
+	}
+	switch c.Auth.TenantMode {
+	case "off":
+	case "header":
+		if c.Auth.TenantHeader == "" {
+			v.fail("TENANT_MODE=header needs TENANT_HEADER")
+		}
+	case "subdomain":
+		if c.Auth.TenantBaseDomain == "" {
+			v.fail("TENANT_MODE=subdomain needs TENANT_BASE_DOMAIN")
+		}
+		if c.Auth.CookieDomain != "" {
+			v.warn("TENANT_MODE=subdomain with COOKIE_DOMAIN shares auth cookies between tenants, so logging in to one logs out of the others")
+		}
+	default:
+		v.fail("TENANT_MODE must be off, header or subdomain, got %q", c.Auth.TenantMode)
+	}
+	if c.Auth.TenantMode != "off" {
+		for _, entry := range c.Auth.AdminEmails {
+			if !strings.Contains(entry, ":") {
+				v.warn("ADMIN_EMAILS entry %q has no tenant prefix (tenant:email), so with TENANT_MODE on it matches nobody", entry)
+			}
+		}
+	}
+	if c.Auth.ExposeTokensDev {
+		if !v.dev {
+			v.fail("EXPOSE_TOKENS_DEV must not be set outside APP_ENV=dev")
+		} else {
+			v.warn("EXPOSE_TOKENS_DEV is on: register and forgot-password responses include their email tokens")
+		}
+	}
+	switch c.Auth.CookieSameSite {
+	case "lax", "strict":
+	case "none":
+		if v.dev {
+			v.warn("COOKIE_SAMESITE=none makes auth cookies Secure, so browsers only keep them over HTTPS")
+		}
+	default:
+		v.fail("COOKIE_SAMESITE must be lax, strict or none, got %q", c.Auth.CookieSameSite)
+	}
+	if c.Auth.RefreshReuseGrace < 0 {
+		v.fail("REFRESH_REUSE_GRACE must not be negative, got %s", c.Auth.RefreshReuseGrace)
+	}
+	if c.Auth.LoginBackoffEnabled {
+		if c.Auth.LoginBackoffBaseDelay <= 0 || c.Auth.LoginBackoffMaxDelay <= 0 {
+			v.fail("LOGIN_BACKOFF_BASE_DELAY and LOGIN_BACKOFF_MAX_DELAY must be positive when LOGIN_BACKOFF_ENABLED is set")
+		} else if c.Auth.LoginBackoffMaxDelay < c.Auth.LoginBackoffBaseDelay {
+			v.fail("LOGIN_BACKOFF_MAX_DELAY (%s) must not be less than LOGIN_BACKOFF_BASE_DELAY (%s)",
+				c.Auth.LoginBackoffMaxDelay, c.Auth.LoginBackoffBaseDelay)
+		}
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		v.fail("PASSWORD_RESET_TTL must be positive, got %s", c.Auth.PasswordResetTTL)
+	}
+	if c.Auth.EmailVerificationTTL <= 0 {
+		v.fail("EMAIL_VERIFICATION_TTL must be positive, got %s", c.Auth.EmailVerificationTTL)
+	}
+	if c.Auth.RefreshTokenBindIPv4Prefix < 0 || c.Auth.RefreshTokenBindIPv4Prefix > 32 {
+		v.fail("REFRESH_TOKEN_BIND_IPV4_PREFIX must be from 0 to 32, got %d", c.Auth.RefreshTokenBindIPv4Prefix)
+	}
+	if c.Auth.RefreshTokenBindIPv6Prefix < 0 || c.Auth.RefreshTokenBindIPv6Prefix > 128 {
+		v.fail("REFRESH_TOKEN_BIND_IPV6_PREFIX must be from 0 to 128, got %d", c.Auth.RefreshTokenBindIPv6Prefix)
+	}
+	if !strings.HasPrefix(c.Auth.CookiePath, "/") {
+		v.fail("COOKIE_PATH must start with /, got %q", c.Auth.CookiePath)
+	}
+	switch c.Auth.RegistrationMode {
+	case "open":
+	case "invite":
+		if len(c.Auth.AdminEmails) == 0 {
+			v.failInProd("REGISTRATION_MODE=invite needs ADMIN_EMAILS, or nobody can create invites")
+		}
+	default:
+		v.fail("REGISTRATION_MODE must be open or invite, got %q", c.Auth.RegistrationMode)
+	}
+
+	// Endpoints
+	if !c.Endpoints.Login && !c.Auth.MagicLinkEnabled && !c.WebAuthn.Enabled {
+		v.warn("ENDPOINT_LOGIN_ENABLED=false with magic links and passkeys off leaves no way to log in")
+	}
+	if !c.Endpoints.EmailVerification {
+		v.warn("ENDPOINT_EMAIL_VERIFICATION_ENABLED=false: new users can't verify their email, so password login fails for them once VERIFICATION_GRACE_PERIOD ends")
+	}
+
+	// Email (verification and password reset always send mail)
+	switch c.Email.SMTPEncryption {
+	case "none", "starttls", "tls":
+	default:
+		v.fail("SMTP_ENCRYPTION must be none, starttls or tls, got %q", c.Email.SMTPEncryption)
+	}
+	if c.Email.SMTPHost == "" {
+		v.failInProd("SMTP_HOST is required to send verification and password reset emails")
+	}
+	if c.Email.SMTPFrom == "" {
+		v.failInProd("SMTP_FROM (or SMTP_USER) is required as the sender address")
+	}
+	if c.Email.SMTPInsecureSkipVerify {
+		v.failInProd("SMTP_INSECURE_SKIP_VERIFY disables certificate checks and must not be used in production")
+	}
+	if c.Email.FrontendURL == "" {
+		v.failInProd("FRONTEND_URL is required to build links in emails")
+	}
+
+	// Passkeys
+	if c.WebAuthn.Enabled {
+		if c.WebAuthn.RPID == "" {
+			v.fail("WEBAUTHN_RP_ID is required when WEBAUTHN_ENABLED is set")
+		}
+		if len(c.WebAuthn.RPOrigins) == 0 {
+			v.fail("WEBAUTHN_RP_ORIGINS is required when WEBAUTHN_ENABLED is set")
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return v.warnings, &ValidationError{Problems: v.problems}
+	}
+	return v.warnings, nil
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// loadTestConfig loads the defaults plus env, with a valid PASETO_KEY
+func loadTestConfig(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	t.Setenv("PASETO_KEY", strings.Repeat("k", pasetoKeyLen))
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return cfg
+}
+
+func TestValidateDefaults(t *testing.T) {
+	if _, err := loadTestConfig(t, nil).Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		problem string
+	}{
+		{"IPv4 prefix too long", map[string]string{"REFRESH_TOKEN_BIND_IPV4_PREFIX": "33"}, "REFRESH_TOKEN_BIND_IPV4_PREFIX"},
+		{"negative IPv4 prefix", map[string]string{"REFRESH_TOKEN_BIND_IPV4_PREFIX": "-1"}, "REFRESH_TOKEN_BIND_IPV4_PREFIX"},
+		{"IPv6 prefix too long", map[string]string{"REFRESH_TOKEN_BIND_IPV6_PREFIX": "129"}, "REFRESH_TOKEN_BIND_IPV6_PREFIX"},
+		{"negative IPv6 prefix", map[string]string{"REFRESH_TOKEN_BIND_IPV6_PREFIX": "-1"}, "REFRESH_TOKEN_BIND_IPV6_PREFIX"},
+		{"zero password reset TTL", map[string]string{"PASSWORD_RESET_TTL": "0"}, "PASSWORD_RESET_TTL"},
+		{"negative password reset TTL", map[string]string{"PASSWORD_RESET_TTL": "-1h"}, "PASSWORD_RESET_TTL"},
+		{"zero email verification TTL", map[string]string{"EMAIL_VERIFICATION_TTL": "0"}, "EMAIL_VERIFICATION_TTL"},
+		{"negative email verification TTL", map[string]string{"EMAIL_VERIFICATION_TTL": "-5m"}, "EMAIL_VERIFICATION_TTL"},
+		{"zero backoff delay", map[string]string{"LOGIN_BACKOFF_ENABLED": "true", "LOGIN_BACKOFF_BASE_DELAY": "0"}, "LOGIN_BACKOFF_BASE_DELAY"},
+		{"negative backoff cap", map[string]string{"LOGIN_BACKOFF_ENABLED": "true", "LOGIN_BACKOFF_MAX_DELAY": "-1s"}, "LOGIN_BACKOFF_MAX_DELAY"},
+		{"backoff cap below base", map[string]string{"LOGIN_BACKOFF_ENABLED": "true", "LOGIN_BACKOFF_BASE_DELAY": "5s", "LOGIN_BACKOFF_MAX_DELAY": "1s"}, "LOGIN_BACKOFF_MAX_DELAY"},
+		{"canonical host with a path", map[string]string{"CANONICAL_HOST": "api.example.com/v1"}, "CANONICAL_HOST"},
+		{"email hashing without a key", map[string]string{"LOG_HASH_EMAILS": "true"}, "LOG_EMAIL_HASH_KEY"},
+		{"email hashing with a short key", map[string]string{"LOG_HASH_EMAILS": "true", "LOG_EMAIL_HASH_KEY": "short"}, "LOG_EMAIL_HASH_KEY"},
+		{"exposed tokens outside dev", map[string]string{"APP_ENV": "prod", "EXPOSE_TOKENS_DEV": "true"}, "EXPOSE_TOKENS_DEV"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, tt.env).Validate()
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("Validate() error = %v, want a problem about %s", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestValidateAcceptsPrefixBounds(t *testing.T) {
+	cfg := loadTestConfig(t, map[string]string{
+		"REFRESH_TOKEN_BIND_IPV4_PREFIX": "32",
+		"REFRESH_TOKEN_BIND_IPV6_PREFIX": "128",
+	})
+	if _, err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+}
+
+func TestValidateForceHTTPSWarnings(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		warning string
+	}{
+		{"without trusted proxies", map[string]string{"TRUSTED_PROXIES": ""}, "TRUSTED_PROXIES"},
+		{"without a canonical host", map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8"}, "CANONICAL_HOST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.env["FORCE_HTTPS"] = "true"
+			tt.env["APP_ENV"] = "prod"
+			warnings, _ := loadTestConfig(t, tt.env).Validate()
+			if !containsMention(warnings, tt.warning) {
+				t.Errorf("Validate() warnings = %q, want one about %s", warnings, tt.warning)
+			}
+		})
+	}
+
+	warnings, _ := loadTestConfig(t, map[string]string{
+		"APP_ENV":         "prod",
+		"FORCE_HTTPS":     "true",
+		"TRUSTED_PROXIES": "10.0.0.0/8",
+		"CANONICAL_HOST":  "api.example.com",
+	}).Validate()
+	if containsMention(warnings, "FORCE_HTTPS") {
+		t.Errorf("Validate() warnings = %q, want none about FORCE_HTTPS", warnings)
+	}
+}
+
+func containsMention(messages []string, setting string) bool {
+	for _, m := range messages {
+		if strings.Contains(m, setting) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	_, err := loadTestConfig(t, map[string]string{
+		"LOG_FORMAT":         "xml",
+		"PASSWORD_RESET_TTL": "0",
+		"COOKIE_SAMESITE":    "sometimes",
+	}).Validate()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() error = %v, want *ValidationError", err)
+	}
+	if len(validationErr.Problems) != 3 {
+		t.Errorf("Problems = %q, want 3", validationErr.Problems)
+	}
+}
+
+func TestValidateProductionOnlySettings(t *testing.T) {
+	// SMTP_HOST has no default
+	env := map[string]string{"SMTP_FROM": "noreply@example.com"}
+
+	warnings, err := loadTestConfig(t, env).Validate()
+	if err != nil {
+		t.Fatalf("dev Validate() error = %v, want only warnings", err)
+	}
+	if !containsMention(warnings, "SMTP_HOST") {
+		t.Errorf("dev Validate() warnings = %q, want one about SMTP_HOST", warnings)
+	}
+
+	env["APP_ENV"] = "prod"
+	_, err = loadTestConfig(t, env).Validate()
+	if err == nil || !strings.Contains(err.Error(), "SMTP_HOST") {
+		t.Errorf("prod Validate() error = %v, want a SMTP_HOST problem", err)
+	}
+}
+
+func TestValidateAdminEmailsNeedTenantWithTenancy(t *testing.T) {
+	warnings, _ := loadTestConfig(t, map[string]string{
+		"TENANT_MODE":  "header",
+		"ADMIN_EMAILS": "root@example.com,acme:boss@example.com",
+	}).Validate()
+	if !containsMention(warnings, `"root@example.com"`) {
+		t.Errorf("Validate() warnings = %q, want one about root@example.com", warnings)
+	}
+	if containsMention(warnings, "acme:boss@example.com") {
+		t.Errorf("Validate() warnings = %q, want none about the prefixed entry", warnings)
+	}
+}
+
+func TestMinTLSVersion(t *testing.T) {
+	if got := loadTestConfig(t, nil).Server.MinTLSVersion; got != tls.VersionTLS12 {
+		t.Errorf("default MinTLSVersion = %x, want TLS 1.2", got)
+	}
+	if got := loadTestConfig(t, map[string]string{"MIN_TLS_VERSION": "1.3"}).Server.MinTLSVersion; got != tls.VersionTLS13 {
+		t.Errorf("MinTLSVersion = %x, want TLS 1.3", got)
+	}
+
+	t.Setenv("PASETO_KEY", strings.Repeat("k", pasetoKeyLen))
+	t.Setenv("MIN_TLS_VERSION", "TLS1.2")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MIN_TLS_VERSION") {
+		t.Errorf("Load() error = %v, want one naming MIN_TLS_VERSION", err)
+	}
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrDirtyDatabase is returned by Migrate when an earlier migration failed
+// part way, leaving a schema it can't safely build on
+var ErrDirtyDatabase = errors.New("database is dirty")
+
+// migrationLockID keys the Postgres advisory lock held while migrating, so
+// instances starting together apply each migration once
+const migrationLockID = 7256294061
+
+// migration is one numbered .up.sql file
+type migration struct {
+	version uint64
+	file    string
+}
+
+// Migrate applies the .up.sql files in fsys that are newer than the version
+// recorded in schema_migrations, in order, and returns how many it applied.
+// Files are named and versions recorded as the migrate CLI does, so the two
+// can be used on the same database. Like the CLI, each file runs as one batch
+// outside a transaction; if it fails, the version is left marked dirty and
+// must be fixed and forced with the CLI before Migrate will run again.
+func Migrate(ctx context.Context, db *sql.DB, fsys fs.FS) (int, error) {
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return 0, err
+	}
+
+	// The advisory lock belongs to the session, so lock, migrate and unlock
+	// on one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return 0, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	current, dirty, err := migrationVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d; fix the schema, then force a version with the migrate CLI", ErrDirtyDatabase, current)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		query, err := fs.ReadFile(fsys, m.file)
+		if err != nil {
+			return applied, fmt.Errorf("failed to read migration %s: %w", m.file, err)
+		}
+		if err := setMigrationVersion(ctx, conn, m.version, true); err != nil {
+			return applied, err
+		}
+		if _, err := conn.ExecContext(ctx, string(query)); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %s: %w", m.file, err)
+		}
+		if err := setMigrationVersion(ctx, conn, m.version, false); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// readMigrations returns the .up.sql files in fsys sorted by version
+func readMigrations(fsys fs.FS) ([]migration, error) {
+	files, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(files))
+	seen := make(map[uint64]string, len(files))
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(file, "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s doesn't start with a version number", file)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, file, version)
+		}
+		seen[version] = file
+		migrations = append(migrations, migration{version: version, file: file})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// migrationVersion returns the recorded version, or 0 if none has been applied
+func migrationVersion(ctx context.Context, conn *sql.Conn) (uint64, bool, error) {
+	var version uint64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// setMigrationVersion replaces the recorded version, as the migrate CLI keeps
+// a single row
+func setMigrationVersion(ctx context.Context, conn *sql.Conn, version uint64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return fmt.Errorf("failed to clear migration version: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)", version, dirty); err != nil {
+		return fmt.Errorf("failed to record migration version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/redmonkez12/go-api-template/migrations"
+)
+
+// fakePostgres is just enough of a database for Migrate: it records the
+// statements run against it and keeps schema_migrations' single row
+type fakePostgres struct {
+	mu       sync.Mutex
+	executed []string
+	version  int64
+	dirty    bool
+	hasRow   bool
+	failOn   string // Statements containing this fail
+}
+
+func (db *fakePostgres) Open(string) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+
+type fakeConn struct{ db *fakePostgres }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.failOn != "" && strings.Contains(query, db.failOn) {
+		return nil, errors.New("syntax error")
+	}
+	switch {
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		db.hasRow = false
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		db.version, db.dirty, db.hasRow = args[0].Value.(int64), args[1].Value.(bool), true
+	default:
+		db.executed = append(db.executed, query)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !strings.HasPrefix(query, "SELECT version, dirty") || !db.hasRow {
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{row: []driver.Value{db.version, db.dirty}}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	row  []driver.Value
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"version", "dirty"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.row == nil || r.done {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.done = true
+	return nil
+}
+
+func openFake(t *testing.T, db *fakePostgres) *sql.DB {
+	t.Helper()
+	sqlDB := sql.OpenDB(fakeConnector{db})
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
+
+type fakeConnector struct{ db *fakePostgres }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return c.db.Open("") }
+func (c fakeConnector) Driver() driver.Driver                        { return c.db }
+
+var testMigrations = fstest.MapFS{
+	"000002_add_b.up.sql":   {Data: []byte("CREATE TABLE b ()")},
+	"000001_add_a.up.sql":   {Data: []byte("CREATE TABLE a ()")},
+	"000001_add_a.down.sql": {Data: []byte("DROP TABLE a")},
+	"000010_add_c.up.sql":   {Data: []byte("CREATE TABLE c ()")},
+}
+
+// executedDDL returns the statements run other than the advisory lock calls
+// and the schema_migrations setup
+func (db *fakePostgres) executedDDL() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var ddl []string
+	for _, q := range db.executed {
+		if !strings.Contains(q, "pg_advisory") && !strings.Contains(q, "schema_migrations") {
+			ddl = append(ddl, q)
+		}
+	}
+	return ddl
+}
+
+func TestMigrateAppliesPendingInOrder(t *testing.T) {
+	db := &fakePostgres{}
+	sqlDB := openFake(t, db)
+
+	applied, err := Migrate(context.Background(), sqlDB, testMigrations)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if applied != 3 {
+		t.Errorf("applied = %d, want 3", applied)
+	}
+	if got, want := strings.Join(db.executedDDL(), "; "), "CREATE TABLE a (); CREATE TABLE b (); CREATE TABLE c ()"; got != want {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+	if db.version != 10 || db.dirty {
+		t.Errorf("recorded version %d dirty=%v, want 10 clean", db.version, db.dirty)
+	}
+
+	applied, err = Migrate(context.Background(), sqlDB, testMigrations)
+	if err != nil || applied != 0 {
+		t.Errorf("second Migrate() = %d, %v, want nothing left to apply", applied, err)
+	}
+}
+
+func TestMigrateMarksFailedVersionDirty(t *testing.T) {
+	db := &fakePostgres{failOn: "CREATE TABLE b"}
+	sqlDB := openFake(t, db)
+
+	applied, err := Migrate(context.Background(), sqlDB, testMigrations)
+	if err == nil || !strings.Contains(err.Error(), "000002_add_b.up.sql") {
+		t.Fatalf("Migrate() error = %v, want one naming the failed file", err)
+	}
+	if applied != 1 || db.version != 2 || !db.dirty {
+		t.Errorf("applied %d, recorded version %d dirty=%v, want 1 applied and 2 dirty", applied, db.version, db.dirty)
+	}
+
+	db.failOn = ""
+	if _, err := Migrate(context.Background(), sqlDB, testMigrations); !errors.Is(err, ErrDirtyDatabase) {
+		t.Errorf("Migrate() on a dirty database error = %v, want ErrDirtyDatabase", err)
+	}
+}
+
+func TestReadMigrationsRejectsBadNames(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"no version":     {"add_a.up.sql": {}},
+		"shared version": {"000001_a.up.sql": {}, "1_b.up.sql": {}},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readMigrations(fsys); err == nil {
+				t.Error("readMigrations() error = nil")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsParse(t *testing.T) {
+	ms, err := readMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("readMigrations() error = %v", err)
+	}
+	if len(ms) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+}
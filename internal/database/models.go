@@ -1,6 +1,7 @@
 package database
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,7 +13,9 @@ type User struct {
 	bun.BaseModel `bun:"table:users,alias:u"`
 
 	ID                        uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
-	Email                     string     `bun:"email,notnull,unique" json:"email"`
+	TenantID                  *string    `bun:"tenant_id" json:"tenant_id,omitempty"`
+	Email                     string     `bun:"email,notnull" json:"email"`
+	Username                  *string    `bun:"username" json:"username,omitempty"`
 	PasswordHash              string     `bun:"password_hash,notnull" json:"-"`
 	EmailVerified             bool       `bun:"email_verified,notnull,default:false" json:"email_verified"`
 	EmailVerificationToken    *string    `bun:"email_verification_token" json:"-"`
@@ -21,16 +24,33 @@ type User struct {
 	UpdatedAt                 time.Time  `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
 }
 
+// WebAuthnCredential represents a passkey registered to a user.
+// Credential holds the library's serialized credential (public key, sign count, flags).
+type WebAuthnCredential struct {
+	bun.BaseModel `bun:"table:webauthn_credentials,alias:wc"`
+
+	ID         []byte          `bun:"id,pk,type:bytea" json:"-"`
+	UserID     uuid.UUID       `bun:"user_id,notnull,type:uuid" json:"user_id"`
+	Credential json.RawMessage `bun:"credential,notnull,type:jsonb" json:"-"`
+	CreatedAt  time.Time       `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	LastUsedAt *time.Time      `bun:"last_used_at" json:"last_used_at,omitempty"`
+}
+
 // RefreshToken represents a refresh token in the database
 type RefreshToken struct {
 	bun.BaseModel `bun:"table:refresh_tokens,alias:rt"`
 
-	ID        int64      `bun:"id,pk,autoincrement" json:"id"`
-	UserID    uuid.UUID  `bun:"user_id,notnull,type:uuid" json:"user_id"`
-	TokenHash string     `bun:"token_hash,notnull,unique" json:"-"`
-	ExpiresAt time.Time  `bun:"expires_at,notnull" json:"expires_at"`
-	CreatedAt time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
-	RevokedAt *time.Time `bun:"revoked_at" json:"revoked_at,omitempty"`
+	ID          int64      `bun:"id,pk,autoincrement" json:"id"`
+	UserID      uuid.UUID  `bun:"user_id,notnull,type:uuid" json:"user_id"`
+	TokenHash   string     `bun:"token_hash,notnull,unique" json:"-"`
+	SessionID   uuid.UUID  `bun:"session_id,notnull,type:uuid" json:"session_id"`
+	IPAddress   string     `bun:"ip_address,notnull" json:"ip_address"`
+	UserAgent   string     `bun:"user_agent,notnull" json:"user_agent"`
+	AuthTime    time.Time  `bun:"auth_time,notnull" json:"auth_time"`
+	SessionOnly bool       `bun:"session_only,notnull,default:false" json:"session_only"`
+	ExpiresAt   time.Time  `bun:"expires_at,notnull" json:"expires_at"`
+	CreatedAt   time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	RevokedAt   *time.Time `bun:"revoked_at" json:"revoked_at,omitempty"`
 
 	// Relations
 	User *User `bun:"rel:belongs-to,join:user_id=id"`
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/uptrace/bun"
+)
+
+// ErrNotFound is returned by Repo when no row matches
+var ErrNotFound = errors.New("record not found")
+
+// pgUniqueViolation is the Postgres error code for a broken unique constraint
+const pgUniqueViolation = "23505"
+
+// UniqueViolationError reports an insert or update that broke a unique
+// constraint or index. Constraint names it, so callers can tell which column
+// clashed and return their own error, as user.Repository does for emails and
+// usernames.
+type UniqueViolationError struct {
+	Constraint string
+	Err        error
+}
+
+func (e *UniqueViolationError) Error() string {
+	return fmt.Sprintf("unique constraint %q violated: %v", e.Constraint, e.Err)
+}
+
+func (e *UniqueViolationError) Unwrap() error {
+	return e.Err
+}
+
+// ListOptions pages and orders Repo.List results. Order is passed to Bun's
+// Order, e.g. "created_at DESC"; it must come from code, never from clients.
+type ListOptions struct {
+	Limit  int // 0 means no limit
+	Offset int
+	Order  string
+}
+
+// Repo implements create, read, update, delete and list for a Bun model, so a
+// new model's repository only needs its own queries. T is the model struct,
+// like User, and must have a primary key; Repo[User] works with *User values.
+//
+// A resource repository embeds or wraps one and maps its errors:
+//
+//	type Repository struct {
+//		projects *database.Repo[database.Project]
+//	}
+//
+//	func NewRepository(db *bun.DB) *Repository {
+//		return &Repository{projects: database.NewRepo[database.Project](db)}
+//	}
+//
+//	func (r *Repository) Get(ctx context.Context, id uuid.UUID) (*database.Project, error) {
+//		p, err := r.projects.GetByID(ctx, id)
+//		if errors.Is(err, database.ErrNotFound) {
+//			return nil, ErrNotFound
+//		}
+//		return p, err
+//	}
+//
+// WithDB binds a Repo to the transaction UnitOfWork.WithTx passes in. A GORM variant would keep these method signatures and map
+// gorm.ErrRecordNotFound and duplicate-key errors to the same errors.
+type Repo[T any] struct {
+	db bun.IDB
+}
+
+// NewRepo creates a Repo for model T on db, which may be a *bun.DB or bun.Tx
+func NewRepo[T any](db bun.IDB) *Repo[T] {
+	return &Repo[T]{db: db}
+}
+
+// WithDB returns a copy of r that runs its queries on db, usually a
+// transaction
+func (r *Repo[T]) WithDB(db bun.IDB) *Repo[T] {
+	return &Repo[T]{db: db}
+}
+
+// Create inserts model and fills in columns set by the database, such as
+// generated IDs and timestamps
+func (r *Repo[T]) Create(ctx context.Context, model *T) error {
+	if _, err := r.db.NewInsert().Model(model).Returning("*").Exec(ctx); err != nil {
+		return mapError("create", err)
+	}
+	return nil
+}
+
+// GetByID returns the row whose primary key is id, or ErrNotFound
+func (r *Repo[T]) GetByID(ctx context.Context, id any) (*T, error) {
+	model := new(T)
+	if err := r.db.NewSelect().Model(model).Where("?PKs = ?", id).Scan(ctx); err != nil {
+		return nil, mapError("get", err)
+	}
+	return model, nil
+}
+
+// Update writes every column of model to the row with its primary key, or
+// returns ErrNotFound if there is none
+func (r *Repo[T]) Update(ctx context.Context, model *T) error {
+	result, err := r.db.NewUpdate().Model(model).WherePK().Returning("*").Exec(ctx)
+	if err != nil {
+		return mapError("update", err)
+	}
+	return expectRow(result)
+}
+
+// Delete removes the row whose primary key is id, or returns ErrNotFound
+func (r *Repo[T]) Delete(ctx context.Context, id any) error {
+	result, err := r.db.NewDelete().Model((*T)(nil)).Where("?PKs = ?", id).Exec(ctx)
+	if err != nil {
+		return mapError("delete", err)
+	}
+	return expectRow(result)
+}
+
+// List returns rows in the order and page opts asks for
+func (r *Repo[T]) List(ctx context.Context, opts ListOptions) ([]T, error) {
+	var models []T
+	q := r.db.NewSelect().Model(&models)
+	if opts.Order != "" {
+		q = q.Order(opts.Order)
+	}
+	if opts.Limit > 0 {
+		q = q.Limit(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		q = q.Offset(opts.Offset)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, mapError("list", err)
+	}
+	return models, nil
+}
+
+// mapError turns driver errors into ErrNotFound or *UniqueViolationError and
+// wraps anything else with the operation that failed
+func mapError(op string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+		return &UniqueViolationError{Constraint: pqErr.Constraint, Err: err}
+	}
+
+	return fmt.Errorf("failed to %s record: %w", op, err)
+}
+
+// expectRow returns ErrNotFound if an update or delete matched no rows
+func expectRow(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// UnitOfWork runs several repository calls as one database transaction.
+// Repositories take part through their WithDB method, which binds them to the
+// transaction WithTx passes in, so the same methods work inside and outside
+// a transaction.
+type UnitOfWork struct {
+	db *bun.DB
+}
+
+// NewUnitOfWork creates a UnitOfWork on db
+func NewUnitOfWork(db *bun.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// WithTx calls fn with a new transaction, committing it if fn returns nil and
+// rolling it back if fn returns an error or panics. Work that isn't part of
+// the transaction, such as Redis writes, belongs after WithTx returns.
+func (u *UnitOfWork) WithTx(ctx context.Context, fn func(ctx context.Context, tx bun.IDB) error) error {
+	return u.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(ctx, tx)
+	})
+}
@@ -3,30 +3,80 @@ package email
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"html/template"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"time"
 
 	"github.com/redmonkez12/go-api-template/internal/logging"
 )
 
+// Encryption selects how the SMTP connection is secured
+type Encryption string
+
+const (
+	EncryptionNone     Encryption = "none"     // plaintext, for local relays
+	EncryptionSTARTTLS Encryption = "starttls" // upgrade required, usually port 587
+	EncryptionTLS      Encryption = "tls"      // implicit TLS, usually port 465
+)
+
+// defaultSMTPTimeout bounds a whole send when SMTPConfig.Timeout is unset
+const defaultSMTPTimeout = 30 * time.Second
+
+// defaultMinTLSVersion is used when SMTPConfig.MinTLSVersion is unset
+const defaultMinTLSVersion = tls.VersionTLS12
+
+// SMTPConfig holds the SMTP server settings
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string // Sender address, defaults to User
+	FromName string // Sender display name, optional
+	// Encryption defaults to STARTTLS
+	Encryption Encryption
+	// InsecureSkipVerify disables server certificate checks (dev only)
+	InsecureSkipVerify bool
+	// MinTLSVersion is the lowest TLS version the server may negotiate
+	// (tls.VersionTLS12 etc.), so a misconfigured server can't downgrade us
+	MinTLSVersion uint16
+	// Timeout bounds a whole send, from dialing to QUIT
+	Timeout time.Duration
+}
+
 type Service struct {
-	smtpHost     string
-	smtpPort     string
-	smtpUser     string
-	smtpPassword string
-	fromEmail    string
-	frontendURL  string
+	smtp        SMTPConfig
+	frontendURL string
+	appName     string // Product name shown in emails
+	// Link lifetimes shown in the emails; they must match the token stores
+	verificationTTL  time.Duration
+	passwordResetTTL time.Duration
 }
 
-func NewService(smtpHost, smtpPort, smtpUser, smtpPassword, frontendURL string) *Service {
+func NewService(smtpConfig SMTPConfig, frontendURL, appName string, verificationTTL, passwordResetTTL time.Duration) *Service {
+	if smtpConfig.From == "" {
+		smtpConfig.From = smtpConfig.User
+	}
+	if smtpConfig.Encryption == "" {
+		smtpConfig.Encryption = EncryptionSTARTTLS
+	}
+	if smtpConfig.Timeout <= 0 {
+		smtpConfig.Timeout = defaultSMTPTimeout
+	}
+	if smtpConfig.MinTLSVersion == 0 {
+		smtpConfig.MinTLSVersion = defaultMinTLSVersion
+	}
+
 	return &Service{
-		smtpHost:     smtpHost,
-		smtpPort:     smtpPort,
-		smtpUser:     smtpUser,
-		smtpPassword: smtpPassword,
-		fromEmail:    smtpUser,
-		frontendURL:  frontendURL,
+		smtp:             smtpConfig,
+		frontendURL:      frontendURL,
+		appName:          appName,
+		verificationTTL:  verificationTTL,
+		passwordResetTTL: passwordResetTTL,
 	}
 }
 
@@ -44,7 +94,7 @@ func (s *Service) SendVerificationEmail(ctx context.Context, toEmail, token stri
 		return fmt.Errorf("render template: %w", err)
 	}
 
-	if err := s.sendEmail(toEmail, subject, body); err != nil {
+	if err := s.sendEmail(ctx, toEmail, subject, body); err != nil {
 		logger.Error("failed to send verification email", "email", toEmail, "error", err)
 		return fmt.Errorf("send email: %w", err)
 	}
@@ -67,7 +117,7 @@ func (s *Service) SendPasswordResetEmail(ctx context.Context, toEmail, token str
 		return fmt.Errorf("render template: %w", err)
 	}
 
-	if err := s.sendEmail(toEmail, subject, body); err != nil {
+	if err := s.sendEmail(ctx, toEmail, subject, body); err != nil {
 		logger.Error("failed to send password reset email", "email", toEmail, "error", err)
 		return fmt.Errorf("send email: %w", err)
 	}
@@ -76,9 +126,32 @@ func (s *Service) SendPasswordResetEmail(ctx context.Context, toEmail, token str
 	return nil
 }
 
-func (s *Service) sendEmail(to, subject, body string) error {
-	auth := smtp.PlainAuth("", s.smtpUser, s.smtpPassword, s.smtpHost)
+// SendMagicLinkEmail sends a passwordless login link to the user
+// This method is designed to be called in a goroutine
+func (s *Service) SendMagicLinkEmail(ctx context.Context, toEmail, token string) error {
+	logger := logging.GetLoggerFromContext(ctx)
 
+	loginLink := fmt.Sprintf("%s/magic-link?token=%s", s.frontendURL, token)
+
+	subject := "Your login link"
+	body, err := s.renderMagicLinkEmailTemplate(loginLink)
+	if err != nil {
+		logger.Error("failed to render magic link email template", "error", err)
+		return fmt.Errorf("render template: %w", err)
+	}
+
+	if err := s.sendEmail(ctx, toEmail, subject, body); err != nil {
+		logger.Error("failed to send magic link email", "email", toEmail, "error", err)
+		return fmt.Errorf("send email: %w", err)
+	}
+
+	logger.Info("magic link email sent", "email", toEmail)
+	return nil
+}
+
+// sendEmail delivers one message. It gives up when ctx is cancelled or after the
+// configured timeout, whichever comes first, so a hung server can't block the caller.
+func (s *Service) sendEmail(ctx context.Context, to, subject, body string) error {
 	// Build message
 	msg := []byte(fmt.Sprintf(
 		"From: %s\r\n"+
@@ -88,11 +161,109 @@ func (s *Service) sendEmail(to, subject, body string) error {
 			"Content-Type: text/html; charset=UTF-8\r\n"+
 			"\r\n"+
 			"%s\r\n",
-		s.fromEmail, to, subject, body,
+		s.fromHeader(), to, subject, body,
 	))
 
-	addr := fmt.Sprintf("%s:%s", s.smtpHost, s.smtpPort)
-	return smtp.SendMail(addr, auth, s.fromEmail, []string{to}, msg)
+	ctx, cancel := context.WithTimeout(ctx, s.smtp.Timeout)
+	defer cancel()
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.smtp.User != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", s.smtp.User, s.smtp.Password, s.smtp.Host)); err != nil {
+				return fmt.Errorf("smtp auth: %w", err)
+			}
+		}
+	}
+
+	if err := client.Mail(s.smtp.From); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data close: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// fromHeader formats the sender, with its display name when one is set
+func (s *Service) fromHeader() string {
+	from := mail.Address{Name: s.smtp.FromName, Address: s.smtp.From}
+	return from.String()
+}
+
+// dial connects to the SMTP server and secures the connection as configured.
+// Unlike smtp.SendMail, STARTTLS is required rather than opportunistic.
+// The connection fails any pending I/O once ctx is done.
+func (s *Service) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.smtp.Host, s.smtp.Port)
+	tlsConfig := s.tlsConfig()
+	dialer := &net.Dialer{}
+
+	var conn net.Conn
+	var err error
+	if s.smtp.Encryption == EncryptionTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp dial: %w", err)
+	}
+
+	// net/smtp has no context support, so enforce ctx through the connection:
+	// the deadline covers the timeout, and cancellation expires it immediately
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
+	client, err := smtp.NewClient(conn, s.smtp.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp handshake: %w", err)
+	}
+
+	if s.smtp.Encryption == EncryptionSTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("smtp server %s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// tlsConfig returns the TLS settings for connections to the SMTP server
+func (s *Service) tlsConfig() *tls.Config {
+	return &tls.Config{
+		ServerName:         s.smtp.Host,
+		MinVersion:         s.smtp.MinTLSVersion,
+		InsecureSkipVerify: s.smtp.InsecureSkipVerify, // opt-in for self-signed dev servers
+	}
 }
 
 func (s *Service) renderVerificationEmailTemplate(verificationLink string) (string, error) {
@@ -155,8 +326,8 @@ func (s *Service) renderVerificationEmailTemplate(verificationLink string) (stri
         <p style="margin-top: 30px;">If you didn't create an account, you can safely ignore this email.</p>
     </div>
     <div class="footer">
-        <p>This link will expire in 24 hours.</p>
-        <p>&copy; 2026 Your App. All rights reserved.</p>
+        <p>This link will expire in {{.ExpiresIn}}.</p>
+        <p>&copy; {{.Year}} {{.AppName}}. All rights reserved.</p>
     </div>
 </body>
 </html>
@@ -169,9 +340,13 @@ func (s *Service) renderVerificationEmailTemplate(verificationLink string) (stri
 
 	var buf bytes.Buffer
 	data := struct {
+		branding
 		VerificationLink string
+		ExpiresIn        string
 	}{
+		branding:         s.branding(),
 		VerificationLink: verificationLink,
+		ExpiresIn:        formatExpiry(s.verificationTTL),
 	}
 
 	if err := t.Execute(&buf, data); err != nil {
@@ -241,8 +416,8 @@ func (s *Service) renderPasswordResetEmailTemplate(resetLink string) (string, er
         <p style="margin-top: 30px;">If you didn't request a password reset, you can safely ignore this email. Your password will remain unchanged.</p>
     </div>
     <div class="footer">
-        <p>This link will expire in 1 hour.</p>
-        <p>&copy; 2026 Your App. All rights reserved.</p>
+        <p>This link will expire in {{.ExpiresIn}}.</p>
+        <p>&copy; {{.Year}} {{.AppName}}. All rights reserved.</p>
     </div>
 </body>
 </html>
@@ -255,9 +430,101 @@ func (s *Service) renderPasswordResetEmailTemplate(resetLink string) (string, er
 
 	var buf bytes.Buffer
 	data := struct {
+		branding
 		ResetLink string
+		ExpiresIn string
 	}{
+		branding:  s.branding(),
 		ResetLink: resetLink,
+		ExpiresIn: formatExpiry(s.passwordResetTTL),
+	}
+
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+func (s *Service) renderMagicLinkEmailTemplate(loginLink string) (string, error) {
+	tmpl := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .header {
+            background-color: #4F46E5;
+            color: white;
+            padding: 20px;
+            text-align: center;
+            border-radius: 5px 5px 0 0;
+        }
+        .content {
+            background-color: #f9f9f9;
+            padding: 30px;
+            border-radius: 0 0 5px 5px;
+        }
+        .button {
+            display: inline-block;
+            background-color: #4F46E5;
+            color: white !important;
+            padding: 12px 30px;
+            text-decoration: none;
+            border-radius: 5px;
+            margin: 20px 0;
+        }
+        .footer {
+            margin-top: 30px;
+            font-size: 12px;
+            color: #666;
+            text-align: center;
+        }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>Login Link</h1>
+    </div>
+    <div class="content">
+        <h2>Sign in to your account</h2>
+        <p>Click the button below to sign in. No password needed.</p>
+
+        <a href="{{.LoginLink}}" class="button" style="color: white !important;">Sign In</a>
+
+        <p>Or copy and paste this link into your browser:</p>
+        <p style="word-break: break-all; color: #4F46E5;">{{.LoginLink}}</p>
+
+        <p style="margin-top: 30px;">If you didn't request this link, you can safely ignore this email. The link can only be used once.</p>
+    </div>
+    <div class="footer">
+        <p>This link will expire in 15 minutes.</p>
+        <p>&copy; {{.Year}} {{.AppName}}. All rights reserved.</p>
+    </div>
+</body>
+</html>
+`
+
+	t, err := template.New("magicLink").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parse template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		branding
+		LoginLink string
+	}{
+		branding:  s.branding(),
+		LoginLink: loginLink,
 	}
 
 	if err := t.Execute(&buf, data); err != nil {
@@ -266,3 +533,28 @@ func (s *Service) renderPasswordResetEmailTemplate(resetLink string) (string, er
 
 	return buf.String(), nil
 }
+
+// branding is the data every email template shares
+type branding struct {
+	AppName string
+	Year    int
+}
+
+func (s *Service) branding() branding {
+	return branding{AppName: s.appName, Year: time.Now().Year()}
+}
+
+// formatExpiry renders a link lifetime for an email, e.g. "1 hour" or "30 minutes"
+func formatExpiry(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return pluralize(int(d/time.Hour), "hour")
+	}
+	return pluralize(int(d.Round(time.Minute)/time.Minute), "minute")
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,29 @@
+package email
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestTLSConfigMinVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		minVersion uint16
+		want       uint16
+	}{
+		{"default", 0, tls.VersionTLS12},
+		{"configured", tls.VersionTLS13, tls.VersionTLS13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(SMTPConfig{Host: "smtp.example.com", MinTLSVersion: tt.minVersion}, "", "", 0, 0)
+			cfg := s.tlsConfig()
+			if cfg.MinVersion != tt.want {
+				t.Errorf("MinVersion = %x, want %x", cfg.MinVersion, tt.want)
+			}
+			if cfg.ServerName != "smtp.example.com" {
+				t.Errorf("ServerName = %q, want the SMTP host", cfg.ServerName)
+			}
+		})
+	}
+}
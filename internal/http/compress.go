@@ -0,0 +1,260 @@
+package http
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+)
+
+// compressibleTypes are the response media types Compress encodes; anything
+// else, like images, is usually compressed already
+var compressibleTypes = map[string]bool{
+	"application/json":         true,
+	"application/problem+json": true,
+	"application/javascript":   true,
+	"text/html":                true,
+	"text/css":                 true,
+	"text/plain":               true,
+	"text/javascript":          true,
+	"image/svg+xml":            true,
+}
+
+// encoder is a gzip or flate writer
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressor holds the encoders Compress reuses between responses
+type compressor struct {
+	minSize int
+	gzips   sync.Pool
+	flates  sync.Pool
+}
+
+// Compress gzips or deflates compressible responses for clients whose
+// Accept-Encoding allows it. Bodies shorter than minSize bytes are sent as
+// they are, since compressing them costs more than it saves; a handler that
+// flushes, like a stream, is compressed however little it has written.
+// Compressible responses always carry Vary: Accept-Encoding, so caches keep
+// the variants apart. level is a compress/flate level and panics if invalid.
+func Compress(level, minSize int) func(next http.Handler) http.Handler {
+	if _, err := flate.NewWriter(io.Discard, level); err != nil {
+		panic(fmt.Sprintf("invalid compression level %d: %v", level, err))
+	}
+
+	c := &compressor{minSize: minSize}
+	c.gzips.New = func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, level)
+		return w
+	}
+	c.flates.New = func() any {
+		w, _ := flate.NewWriter(io.Discard, level)
+		return w
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cw := &compressWriter{
+				ResponseWriter: w,
+				compressor:     c,
+				encoding:       negotiateEncoding(r.Header.Values("Accept-Encoding")),
+				status:         http.StatusOK,
+			}
+			next.ServeHTTP(cw, r)
+			// Not deferred: after a panic, flushing a held-back body would
+			// commit a 200 before Recoverer can answer with a 500
+			cw.close()
+		})
+	}
+}
+
+// negotiateEncoding picks gzip or deflate, whichever Accept-Encoding rates
+// higher, preferring gzip on a tie. It returns "" if neither is acceptable.
+func negotiateEncoding(acceptEncoding []string) string {
+	gzipQ, deflateQ, anyQ := -1.0, -1.0, -1.0
+	for _, header := range acceptEncoding {
+		for _, part := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			switch strings.ToLower(strings.TrimSpace(coding)) {
+			case "gzip":
+				gzipQ = httputil.Quality(params)
+			case "deflate":
+				deflateQ = httputil.Quality(params)
+			case "*":
+				anyQ = httputil.Quality(params)
+			}
+		}
+	}
+	if gzipQ < 0 {
+		gzipQ = anyQ
+	}
+	if deflateQ < 0 {
+		deflateQ = anyQ
+	}
+
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressWriter holds back the status and the start of the body until it
+// has minSize bytes, the handler flushes or the handler returns, then decides
+// whether to compress
+type compressWriter struct {
+	http.ResponseWriter
+	*compressor
+	encoding    string // Negotiated encoding, "" for none
+	status      int
+	wroteHeader bool   // The handler called WriteHeader
+	started     bool   // Status and headers have gone out
+	buf         []byte // Body held back until started
+	enc         encoder
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.started {
+		cw.ResponseWriter.WriteHeader(code) // Let net/http report superfluous calls
+		return
+	}
+	// Informational responses go out at once and don't end the header
+	if code < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.started {
+		return cw.writer().Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends everything written so far, compressed if the response is
+// compressible
+func (cw *compressWriter) Flush() {
+	if !cw.started {
+		if err := cw.start(true); err != nil {
+			return
+		}
+	}
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return
+		}
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// start sends the status and headers, compressing the body from here on if
+// compress is set and the response qualifies, then writes what was held back
+func (cw *compressWriter) start(compress bool) error {
+	cw.started = true
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Sniff before compressing, as net/http would sniff the encoded bytes
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if cw.compressible() {
+		h.Add("Vary", "Accept-Encoding")
+		if compress && cw.encoding != "" && bodyAllowed(cw.status) {
+			h.Set("Content-Encoding", cw.encoding)
+			h.Del("Content-Length") // The encoded length isn't known yet
+			cw.enc = cw.newEncoder()
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := cw.writer().Write(buf)
+	return err
+}
+
+// compressible reports whether the response is of a type worth compressing
+// and isn't encoded already
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(h.Get("Content-Type"), ";")
+	return compressibleTypes[strings.ToLower(strings.TrimSpace(mediaType))]
+}
+
+func (cw *compressWriter) writer() io.Writer {
+	if cw.enc != nil {
+		return cw.enc
+	}
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) newEncoder() encoder {
+	var enc encoder
+	if cw.encoding == "gzip" {
+		enc = cw.gzips.Get().(*gzip.Writer)
+	} else {
+		enc = cw.flates.Get().(*flate.Writer)
+	}
+	enc.Reset(cw.ResponseWriter)
+	return enc
+}
+
+// close sends a response still held back, uncompressed since it never
+// reached minSize, and finishes the encoded stream otherwise
+func (cw *compressWriter) close() {
+	if !cw.started {
+		if err := cw.start(false); err != nil {
+			return
+		}
+	}
+	if cw.enc == nil {
+		return
+	}
+	if err := cw.enc.Close(); err != nil {
+		return
+	}
+	switch enc := cw.enc.(type) {
+	case *gzip.Writer:
+		cw.gzips.Put(enc)
+	case *flate.Writer:
+		cw.flates.Put(enc)
+	}
+	cw.enc = nil
+}
+
+// bodyAllowed reports whether a response with status may have a body
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func compressRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	return r
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("compress me ", 100)
+	h := Compress(5, 64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, body)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, compressRequest())
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil || string(decoded) != body {
+		t.Errorf("decoded body = %q, %v", decoded, err)
+	}
+}
+
+func TestCompressSkipsSmallBodies(t *testing.T) {
+	h := Compress(5, 64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "short")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, compressRequest())
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if w.Body.String() != "short" {
+		t.Errorf("body = %q", w.Body)
+	}
+}
+
+func TestCompressLeavesPanicsToRecoverer(t *testing.T) {
+	h := middleware.Recoverer(Compress(5, 64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "partial")
+		panic("boom")
+	})))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, compressRequest())
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "partial") {
+		t.Errorf("body = %q, want the partial response dropped", w.Body)
+	}
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+)
+
+// Maintenance is the maintenance mode switch. It can be flipped while the
+// server runs and takes effect on the next request. The state is per process,
+// so with several instances each one has to be switched.
+type Maintenance struct {
+	enabled    atomic.Bool
+	retryAfter time.Duration
+}
+
+// NewMaintenance creates the switch in its initial state (MAINTENANCE_MODE).
+// retryAfter is sent as Retry-After with each 503; zero omits the header.
+func NewMaintenance(enabled bool, retryAfter time.Duration) *Maintenance {
+	m := &Maintenance{retryAfter: retryAfter}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether the API is in maintenance mode
+func (m *Maintenance) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set turns maintenance mode on or off
+func (m *Maintenance) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// Middleware answers requests with 503 while maintenance mode is on, except
+// for paths under the exempt prefixes (health checks, metrics and the admin
+// routes used to turn it off again)
+func (m *Maintenance) Middleware(exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !m.Enabled() || hasPathPrefix(r.URL.Path, exempt) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if m.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(m.retryAfter.Seconds())))
+			}
+			httputil.RespondError(w, httputil.CodeMaintenance, http.StatusServiceUnavailable)
+		})
+	}
+}
+
+// hasPathPrefix reports whether path is one of prefixes or lies below one
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenanceRequest sets the maintenance mode state
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// MaintenanceResponse reports the maintenance mode state
+type MaintenanceResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+// handleGetMaintenance reports whether maintenance mode is on
+// @Summary      Get maintenance mode
+// @Description  Report whether this instance is in maintenance mode. Requires an admin outside any tenant.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} MaintenanceResponse
+// @Failure      401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure      403 {object} httputil.ErrorResponse "Admin required"
+// @Router       /admin/maintenance [get]
+func (m *Maintenance) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	httputil.RespondJSON(w, MaintenanceResponse{Enabled: m.Enabled()}, http.StatusOK)
+}
+
+// handleSetMaintenance turns maintenance mode on or off
+// @Summary      Set maintenance mode
+// @Description  Turn maintenance mode on or off for this instance. While it is on, every route except health checks and admin routes answers 503. Requires an admin outside any tenant.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body MaintenanceRequest true "Maintenance mode state"
+// @Success      200 {object} MaintenanceResponse
+// @Failure      400 {object} httputil.ErrorResponse "Invalid request body"
+// @Failure      401 {object} httputil.ErrorResponse "Unauthorized"
+// @Failure      403 {object} httputil.ErrorResponse "Admin required"
+// @Router       /admin/maintenance [put]
+func (m *Maintenance) handleSetMaintenance(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	var req MaintenanceRequest
+	if err := httputil.DecodeJSON(w, r, &req); err != nil {
+		logger.Warn("invalid maintenance request body", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	m.Set(req.Enabled)
+
+	userID, _ := auth.GetUserIDFromContext(r.Context())
+	logger.Warn("maintenance mode changed", "enabled", req.Enabled, "user_id", userID.String())
+
+	httputil.RespondJSON(w, MaintenanceResponse{Enabled: req.Enabled}, http.StatusOK)
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	m := NewMaintenance(true, 30*time.Second)
+	h := m.Middleware("/health", "/admin")(okHandler())
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := serve("/users")
+	var resp httputil.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("503 body is not JSON: %v", err)
+	}
+	if w.Code != http.StatusServiceUnavailable || resp.Code != httputil.CodeMaintenance {
+		t.Errorf("normal route = %d %s, want 503 %s", w.Code, resp.Code, httputil.CodeMaintenance)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+
+	for path, want := range map[string]int{
+		"/health":            http.StatusOK,
+		"/admin/maintenance": http.StatusOK,
+		"/healthz":           http.StatusServiceUnavailable, // Only whole path segments are exempt
+	} {
+		if w := serve(path); w.Code != want {
+			t.Errorf("%s = %d, want %d", path, w.Code, want)
+		}
+	}
+
+	m.Set(false)
+	if w := serve("/users"); w.Code != http.StatusOK {
+		t.Errorf("normal route after turning maintenance off = %d, want 200", w.Code)
+	}
+}
+
+func TestSetMaintenance(t *testing.T) {
+	m := NewMaintenance(false, 0)
+
+	r := httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(`{"enabled":true}`))
+	quiet := logging.NewLogger("json", slog.LevelError+1, logging.RedactOptions{})
+	r = r.WithContext(context.WithValue(r.Context(), logging.LoggerContextKey, quiet))
+	w := httptest.NewRecorder()
+	m.handleSetMaintenance(w, r)
+	if w.Code != http.StatusOK || !m.Enabled() {
+		t.Fatalf("PUT enabled=true = %d, Enabled() = %v, want 200 and on", w.Code, m.Enabled())
+	}
+
+	w = httptest.NewRecorder()
+	m.Middleware()(okHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "" {
+		t.Errorf("after switching on = %d with Retry-After %q, want 503 without Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+}
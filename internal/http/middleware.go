@@ -1,24 +1,311 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"net/netip"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/logging"
 )
 
-// SecurityHeaders adds security-related headers to all responses.
-func SecurityHeaders(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+// MaxBodySize limits request bodies to limit bytes. Reads past the limit fail,
+// which handlers decoding with httputil.DecodeJSON report as a 400.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-		// Swagger UI needs scripts, styles, and images to render
-		if strings.HasPrefix(r.URL.Path, "/swagger/") {
-			w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:")
-		} else {
-			w.Header().Set("Content-Security-Policy", "default-src 'none'")
+// MaxConcurrentRequests caps how many requests are served at once, shielding
+// the database and Redis connection pools from saturation under load. Requests
+// over the cap are turned away immediately with 503 and a Retry-After header
+// rather than queued. Requests for the exempt paths (health probes) are never
+// counted or refused. A limit of zero or less disables the cap.
+func MaxConcurrentRequests(limit int, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
 		}
 
+		slots := make(chan struct{}, limit)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				httputil.RespondError(w, httputil.CodeServerBusy, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// SecurityHeadersConfig configures SecurityHeaders
+type SecurityHeadersConfig struct {
+	// ContentSecurityPolicy is sent on every response except Swagger UI's
+	ContentSecurityPolicy string
+	// PermissionsPolicy is omitted when empty
+	PermissionsPolicy string
+	// HSTSMaxAge enables Strict-Transport-Security on HTTPS responses (0 disables)
+	HSTSMaxAge time.Duration
+	// TrustedProxies whose X-Forwarded-Proto: https marks a request as HTTPS
+	TrustedProxies []netip.Prefix
+}
+
+// SecurityHeaders adds security-related headers to all responses.
+// Strict-Transport-Security is only sent on requests that arrived over HTTPS,
+// directly or through a trusted proxy, since browsers ignore it over HTTP.
+func SecurityHeaders(cfg SecurityHeadersConfig) func(http.Handler) http.Handler {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(cfg.HSTSMaxAge.Seconds()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("X-Frame-Options", "DENY")
+			w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+			if cfg.PermissionsPolicy != "" {
+				w.Header().Set("Permissions-Policy", cfg.PermissionsPolicy)
+			}
+			if hsts != "" && isHTTPS(r, cfg.TrustedProxies) {
+				w.Header().Set("Strict-Transport-Security", hsts)
+			}
+
+			// Swagger UI needs scripts, styles, and images to render
+			if strings.HasPrefix(r.URL.Path, "/swagger/") {
+				w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:")
+			} else {
+				w.Header().Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isHTTPS reports whether the request reached us over TLS or was forwarded as
+// HTTPS by a trusted proxy. Like fromTrustedProxy, it must run before RealIP.
+func isHTTPS(r *http.Request, trustedProxies []netip.Prefix) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") &&
+		fromTrustedProxy(r, trustedProxies)
+}
+
+// APIVersionHeader names the header carrying the API version
+const APIVersionHeader = "X-API-Version"
+
+// APIVersion tells clients which API version served each response, which
+// helps correlate client logs with deployments during rollouts.
+func APIVersion(version string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(APIVersionHeader, version)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// correlationIDHeader is the other inbound header gateways commonly use for
+// the request ID
+const correlationIDHeader = "X-Correlation-ID"
+
+// maxRequestIDLen bounds client-supplied request IDs, which end up in logs
+const maxRequestIDLen = 128
+
+// RequestID gives each request an ID that middleware.GetReqID returns and
+// that is echoed in the X-Request-ID response header, so clients can quote it
+// in support requests. A well-formed X-Request-ID or X-Correlation-ID from a
+// gateway is reused so one ID follows the request across services; anything
+// else is replaced by a generated ID.
+func RequestID(next http.Handler) http.Handler {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(httputil.RequestIDHeader, middleware.GetReqID(r.Context()))
 		next.ServeHTTP(w, r)
 	})
+	assign := middleware.RequestID(echo)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(httputil.RequestIDHeader)
+		if !validRequestID(id) {
+			id = r.Header.Get(correlationIDHeader)
+		}
+		// chi's RequestID reuses the header when set and generates an ID otherwise
+		if validRequestID(id) {
+			r.Header.Set(middleware.RequestIDHeader, id)
+		} else {
+			r.Header.Del(middleware.RequestIDHeader)
+		}
+		assign.ServeHTTP(w, r)
+	})
+}
+
+// validRequestID accepts short IDs made of printable ASCII without spaces,
+// which covers UUIDs and the usual gateway formats
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// ForceHTTPS keeps credentials off plaintext connections behind a
+// TLS-terminating proxy. Requests a trusted proxy reports as plain HTTP via
+// X-Forwarded-Proto are redirected to HTTPS on canonicalHost for GET and HEAD
+// and rejected with 400 otherwise, since a redirected body would already have
+// crossed the wire. The redirect never uses the request's Host, which the
+// client controls; without a canonicalHost every plain request is rejected.
+// The header is ignored from peers outside trustedProxies.
+func ForceHTTPS(trustedProxies []netip.Prefix, canonicalHost string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil || !fromTrustedProxy(r, trustedProxies) ||
+				!strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "http") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if canonicalHost != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+				http.Redirect(w, r, "https://"+canonicalHost+r.URL.RequestURI(), http.StatusMovedPermanently)
+				return
+			}
+
+			httputil.RespondError(w, httputil.CodeHTTPSRequired, http.StatusBadRequest)
+		})
+	}
+}
+
+// fromTrustedProxy reports whether the request's direct peer is a trusted proxy.
+// It must run before middleware that rewrites RemoteAddr from forwarded headers.
+func fromTrustedProxy(r *http.Request, trustedProxies []netip.Prefix) bool {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	return isTrustedProxy(addrPort.Addr().Unmap(), trustedProxies)
+}
+
+// isTrustedProxy reports whether addr lies in one of trustedProxies
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP sets RemoteAddr to the client address a trusted proxy reported, so
+// rate limits and logs see the client rather than the proxy. Forwarded
+// headers are only honored when the direct peer is in trustedProxies; from
+// anyone else they could be forged to dodge IP rate limits, and RemoteAddr is
+// left as it is.
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if fromTrustedProxy(r, trustedProxies) {
+				if addr, ok := forwardedClientIP(r.Header, trustedProxies); ok {
+					r.RemoteAddr = addr.String()
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClientIP returns the client address from X-Forwarded-For, or from
+// X-Real-IP when that is absent. The X-Forwarded-For chain is read from the
+// right, skipping trusted proxies, so entries a client prepended itself are
+// never reached. An entry that isn't an IP address stops the search.
+func forwardedClientIP(h http.Header, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	if values := h.Values("X-Forwarded-For"); len(values) > 0 {
+		entries := strings.Split(strings.Join(values, ","), ",")
+		for i := len(entries) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(entries[i]))
+			if err != nil {
+				return netip.Addr{}, false
+			}
+			addr = addr.Unmap()
+			if i == 0 || !isTrustedProxy(addr, trustedProxies) {
+				return addr, true
+			}
+		}
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(h.Get("X-Real-IP")))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// LimitForwardedFor caps X-Forwarded-For at maxEntries addresses before
+// anything parses it, so a header padded with thousands of entries can't make
+// IP extraction do excess work. Longer chains are truncated to their last
+// maxEntries entries (those appended by the proxies nearest this server) and
+// logged as suspicious. Multiple header lines are treated as one chain.
+// A maxEntries of zero or less disables the cap.
+func LimitForwardedFor(maxEntries int, logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxEntries <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			values := r.Header.Values("X-Forwarded-For")
+			if len(values) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			chain := strings.Join(values, ",")
+			if entries := strings.Count(chain, ",") + 1; entries > maxEntries {
+				logger.Warn("suspicious X-Forwarded-For chain truncated",
+					"entries", entries,
+					"max_entries", maxEntries,
+					"header_bytes", len(chain),
+					"remote_addr", r.RemoteAddr,
+				)
+				r.Header.Set("X-Forwarded-For", lastEntries(chain, maxEntries))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// lastEntries returns the last n comma-separated entries of list, scanning
+// back from the end so only the kept part is examined
+func lastEntries(list string, n int) string {
+	end := len(list)
+	for i := 0; i < n; i++ {
+		end = strings.LastIndexByte(list[:end], ',')
+		if end < 0 {
+			return list
+		}
+	}
+	return strings.TrimSpace(list[end+1:])
 }
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
+)
+
+var testProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestForceHTTPS(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		remoteAddr    string
+		proto         string
+		canonicalHost string
+		wantStatus    int
+		wantLocation  string
+	}{
+		{"HTTPS passes", http.MethodGet, "10.0.0.1:1234", "https", "api.example.com", http.StatusOK, ""},
+		{"GET redirects to the canonical host", http.MethodGet, "10.0.0.1:1234", "http", "api.example.com", http.StatusMovedPermanently, "https://api.example.com/users?page=2"},
+		{"POST is rejected", http.MethodPost, "10.0.0.1:1234", "http", "api.example.com", http.StatusBadRequest, ""},
+		{"GET is rejected without a canonical host", http.MethodGet, "10.0.0.1:1234", "http", "", http.StatusBadRequest, ""},
+		{"header ignored from untrusted peers", http.MethodGet, "203.0.113.1:1234", "http", "api.example.com", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/users?page=2", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Host = "evil.example.net"
+			r.Header.Set("X-Forwarded-Proto", tt.proto)
+			w := httptest.NewRecorder()
+
+			ForceHTTPS(testProxies, tt.canonicalHost)(okHandler()).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+// TestClientIPChain runs the router's client IP middleware in order and checks
+// that handlers and the request log agree on the client address
+func TestClientIPChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5555", "", "203.0.113.7"},
+		{"forged header from a direct client", "203.0.113.7:5555", "198.51.100.1", "203.0.113.7"},
+		{"client behind a trusted proxy", "10.0.0.1:5555", "198.51.100.1", "198.51.100.1"},
+		{"prepended entry behind a trusted proxy", "10.0.0.1:5555", "192.0.2.99, 198.51.100.1", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := &logging.Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+			var handlerIP string
+			h := RealIP(testProxies)(reqmeta.Middleware(auth.GetClientIP)(logging.RequestLogger(logger)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					handlerIP = auth.GetClientIP(r)
+				}),
+			)))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if handlerIP != tt.want {
+				t.Errorf("GetClientIP() = %q, want %q", handlerIP, tt.want)
+			}
+			var started map[string]any
+			if err := json.NewDecoder(&buf).Decode(&started); err != nil {
+				t.Fatal(err)
+			}
+			if started["remote_ip"] != tt.want {
+				t.Errorf("logged remote_ip = %v, want %q", started["remote_ip"], tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIVersion(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		APIVersion("v1.2.3")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if got := w.Header().Get(APIVersionHeader); got != "v1.2.3" {
+			t.Errorf("%d response %s = %q, want the injected version", status, APIVersionHeader, got)
+		}
+	}
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	const limit = 2
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := MaxConcurrentRequests(limit, "/health")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	done := make(chan int, limit)
+	for range limit {
+		go func() { done <- serve("/slow").Code }()
+		<-started
+	}
+
+	w := serve("/fast")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("request over the cap = %d with Retry-After %q, want 503 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve("/health"); w.Code != http.StatusOK {
+		t.Errorf("health check at the cap = %d, want 200", w.Code)
+	}
+
+	close(release)
+	for range limit {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("request within the cap = %d, want 200", code)
+		}
+	}
+	if w := serve("/fast"); w.Code != http.StatusOK {
+		t.Errorf("request after the others finished = %d, want 200", w.Code)
+	}
+}
+
+func TestLimitForwardedFor(t *testing.T) {
+	padding := strings.Repeat("192.0.2.1, ", 10000)
+	tests := []struct {
+		name      string
+		headers   []string
+		wantChain string
+		wantWarn  bool
+	}{
+		{"short chain untouched", []string{"198.51.100.1, 10.0.0.2"}, "198.51.100.1, 10.0.0.2", false},
+		{"oversized chain truncated", []string{padding + "198.51.100.1, 10.0.0.2"}, "192.0.2.1, 192.0.2.1, 198.51.100.1, 10.0.0.2", true},
+		{"oversized chain over several lines", []string{strings.TrimSuffix(padding, ", "), "198.51.100.1", "10.0.0.2"}, "192.0.2.1, 192.0.2.1,198.51.100.1,10.0.0.2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := &logging.Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+			var chain []string
+			var clientIP string
+			h := LimitForwardedFor(4, logger)(RealIP(testProxies)(reqmeta.Middleware(auth.GetClientIP)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					chain = r.Header.Values("X-Forwarded-For")
+					clientIP = auth.GetClientIP(r)
+				}),
+			)))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:5555"
+			for _, v := range tt.headers {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if len(chain) != 1 || chain[0] != tt.wantChain {
+				t.Errorf("X-Forwarded-For = %.100q, want %q", chain, tt.wantChain)
+			}
+			if clientIP != "198.51.100.1" {
+				t.Errorf("GetClientIP() = %q, want 198.51.100.1", clientIP)
+			}
+			if warned := strings.Contains(buf.String(), "suspicious X-Forwarded-For"); warned != tt.wantWarn {
+				t.Errorf("logged a warning = %v, want %v", warned, tt.wantWarn)
+			}
+		})
+	}
+}
@@ -1,13 +1,20 @@
 package http
 
 import (
+	"cmp"
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/redmonkez12/go-api-template/docs"
 	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/buildinfo"
 	"github.com/redmonkez12/go-api-template/internal/config"
 	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/idempotency"
 	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/passkey"
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -16,69 +23,289 @@ import (
 )
 
 // NewRouter creates and configures the HTTP router
-func NewRouter(cfg *config.Config, authHandler *auth.Handler, authMiddleware *auth.Middleware, logger *logging.Logger) *chi.Mux {
+func NewRouter(cfg *config.Config, authHandler *auth.Handler, authMiddleware *auth.Middleware, passkeyHandler *passkey.Handler, idempotencyStore *idempotency.Store, maintenance *Maintenance, authStore *auth.BreakerRepository, logger *logging.Logger) *chi.Mux {
 	r := chi.NewRouter()
 
+	// JSON errors for unknown routes and methods. Set before any r.Route or
+	// r.Mount, since subrouters copy these handlers when they're mounted.
+	r.NotFound(handleNotFound)
+	r.MethodNotAllowed(handleMethodNotAllowed)
+
 	// CORS - must be first
 	if len(cfg.Server.TrustedOrigins) > 0 {
+		allowedHeaders := []string{"Accept", "Authorization", "Content-Type", idempotency.HeaderName, auth.TokenDeliveryHeader}
+		if cfg.Auth.TenantMode == string(auth.TenantHeader) {
+			allowedHeaders = append(allowedHeaders, cfg.Auth.TenantHeader)
+		}
 		r.Use(cors.Handler(cors.Options{
 			AllowedOrigins:   cfg.Server.TrustedOrigins,
 			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-			ExposedHeaders:   []string{"Content-Length"},
+			AllowedHeaders:   allowedHeaders,
+			ExposedHeaders:   []string{"Content-Length", "Link", APIVersionHeader, httputil.RequestIDHeader, httputil.TotalCountHeader},
 			AllowCredentials: true,
 			MaxAge:           300, // 5 minutes
 		}))
 	}
 
+	// Error bodies as application/problem+json for clients whose Accept prefers it
+	r.Use(httputil.NegotiateErrors)
+
+	// Plain HTTP from the TLS-terminating proxy; runs before RealIP rewrites the peer address
+	if cfg.Server.ForceHTTPS && !cfg.Server.IsDevelopment() {
+		r.Use(ForceHTTPS(cfg.Server.TrustedProxies, cfg.Server.CanonicalHost))
+	}
+
+	// Bound X-Forwarded-For before RealIP and client IP extraction parse it
+	r.Use(LimitForwardedFor(cfg.Server.MaxForwardedFor, logger))
+
+	// HSTS is never sent in dev, where it would pin localhost to HTTPS
+	securityHeaders := SecurityHeadersConfig{
+		ContentSecurityPolicy: cfg.Server.ContentSecurityPolicy,
+		PermissionsPolicy:     cfg.Server.PermissionsPolicy,
+		TrustedProxies:        cfg.Server.TrustedProxies,
+	}
+	if !cfg.Server.IsDevelopment() {
+		securityHeaders.HSTSMaxAge = cfg.Server.HSTSMaxAge
+	}
+
 	// Global middleware
-	r.Use(SecurityHeaders)               // Security headers on all responses
-	r.Use(middleware.Recoverer)          // Recover from panics
-	r.Use(middleware.RequestID)          // Add request ID
-	r.Use(middleware.RealIP)             // Set RemoteAddr to real IP
-	r.Use(logging.RequestLogger(logger)) // Structured logging with request context
-	r.Use(middleware.Compress(5))        // Compress responses
+	r.Use(SecurityHeaders(securityHeaders))        // Security headers on all responses
+	r.Use(MaxBodySize(cfg.Server.MaxBodyBytes))    // Cap request body size
+	r.Use(middleware.Recoverer)                    // Recover from panics
+	r.Use(RequestID)                               // Add request ID, echoed in X-Request-ID
+	r.Use(RealIP(cfg.Server.TrustedProxies))       // Set RemoteAddr to the client IP a trusted proxy reports
+	r.Use(reqmeta.Middleware(auth.GetClientIP))    // Request ID, client IP and start time
+	r.Use(logging.RequestLogger(logger))           // Structured logging with request context
+	r.Use(Compress(5, cfg.Server.CompressMinSize)) // Compress responses of at least COMPRESS_MIN_SIZE bytes
+
+	// Shed load past MAX_CONCURRENT_REQUESTS, leaving health checks unaffected
+	r.Use(MaxConcurrentRequests(cfg.Server.MaxConcurrentRequests, "/health", cfg.Server.APIPrefix+"/health"))
+
+	// Maintenance mode (MAINTENANCE_MODE) keeps health checks, metrics and
+	// admin routes reachable so it can be switched off again
+	r.Use(maintenance.Middleware("/health", cfg.Server.APIPrefix+"/health", "/metrics", "/admin", cfg.Server.APIPrefix+"/admin"))
+
+	// Report the running build on every response (API_VERSION_HEADER)
+	if cfg.Server.APIVersionHeader {
+		r.Use(APIVersion(buildinfo.Version()))
+	}
+
+	// Redacted request/response body logging; a no-op unless enabled in dev
+	r.Use(logging.BodyLogger(cfg.Server.IsDevelopment(), cfg.Server.DebugLogBodies))
 
-	// Public routes
-	r.Get("/health", handleHealth)
+	// Public routes; health is also served under the prefix, which is the
+	// Swagger base path
+	health := handleHealth(authStore)
+	r.Get("/health", health)
+	if cfg.Server.APIPrefix != "" {
+		r.Get(cfg.Server.APIPrefix+"/health", health)
+	}
 
 	// Swagger UI - only in development
 	// Production builds will not have this route at all
 	if cfg.Server.IsDevelopment() {
 		log.Println("Swagger UI enabled at /swagger/*")
+		docs.SwaggerInfo.BasePath = cmp.Or(cfg.Server.APIPrefix, "/")
 		r.Get("/swagger/*", httpSwagger.WrapHandler)
 	} else {
 		log.Println("Swagger UI disabled (production mode)")
 	}
 
-	// Auth routes (public)
-	r.Route("/auth", func(r chi.Router) {
-		r.Post("/register", authHandler.Register)
-		r.Post("/login", authHandler.Login)
-		r.Post("/refresh", authHandler.Refresh)
-		r.Post("/logout", authHandler.Logout)
-		r.Get("/verify-email", authHandler.VerifyEmail)
-		r.Post("/forgot-password", authHandler.ForgotPassword)
-		r.Post("/reset-password", authHandler.ResetPassword)
-		r.Post("/resend-verification", authHandler.ResendVerificationEmail)
-	})
+	// API routes, under API_PREFIX (e.g. /v1)
+	mountAPI(r, cfg.Server.APIPrefix, cfg.Server.ServeUnversionedRoutes, func(r chi.Router) {
+		// Auth routes (public). Each group can be left unmounted with its
+		// ENDPOINT_*_ENABLED flag, so unused endpoints 404.
+		endpoints := cfg.Endpoints
+
+		// Public reference data for clients
+		r.Get("/meta/error-codes", handleErrorCodes)
+
+		r.Route("/auth", func(r chi.Router) {
+			// Users are looked up within the request's tenant (TENANT_MODE)
+			r.Use(authMiddleware.RequireTenant)
+
+			// Side-effecting endpoints honour the Idempotency-Key header so client retries are safe
+			if endpoints.Register {
+				r.With(idempotencyStore.Middleware).Post("/register", registrationGate(cfg.Auth.RegistrationEnabled, authHandler.Register))
+			}
+			if endpoints.Login {
+				r.Post("/login", authHandler.Login)
+			}
+			if endpoints.Refresh {
+				r.Post("/refresh", authHandler.Refresh)
+			}
+			if endpoints.Logout {
+				r.Post("/logout", authHandler.Logout)
+			}
+			if endpoints.EmailVerification {
+				r.Post("/verify-email", authHandler.VerifyEmailFromBody)
+				r.Get("/verify-email", authHandler.VerifyEmail) // Legacy; puts the token in URLs
+				r.Post("/resend-verification", authHandler.ResendVerificationEmail)
+			}
+			if endpoints.PasswordReset {
+				r.With(idempotencyStore.Middleware).Post("/forgot-password", authHandler.ForgotPassword)
+				r.Post("/reset-password", authHandler.ResetPassword)
+			}
+
+			// Passwordless login (opt-in via MAGIC_LINK_ENABLED)
+			if cfg.Auth.MagicLinkEnabled {
+				r.Post("/magic-link", authHandler.RequestMagicLink)
+				r.Get("/magic-link/verify", authHandler.VerifyMagicLink)
+			}
+
+			// Passkeys (opt-in via WEBAUTHN_ENABLED)
+			if cfg.WebAuthn.Enabled {
+				r.Post("/webauthn/login/begin", passkeyHandler.BeginLogin)
+				r.Post("/webauthn/login/finish", passkeyHandler.FinishLogin)
+				r.Group(func(r chi.Router) {
+					// Adding a login credential is sensitive, so it needs a recent login
+					r.Use(authMiddleware.RequireAuth)
+					r.Use(authMiddleware.RequireFreshAuth(cfg.Auth.FreshAuthMaxAge))
+					r.Post("/webauthn/register/begin", passkeyHandler.BeginRegistration)
+					r.Post("/webauthn/register/finish", passkeyHandler.FinishRegistration)
+				})
+			}
+		})
+
+		// Protected routes (require authentication)
+		r.Group(func(r chi.Router) {
+			r.Use(authMiddleware.RequireTenant)
+			r.Use(authMiddleware.RequireAuth)
+			if endpoints.Logout {
+				r.Post("/auth/logout-all", authHandler.LogoutAll)
+			}
+			if endpoints.Sessions {
+				r.Get("/auth/sessions", authHandler.ListSessions)
+				r.Delete("/auth/sessions/{id}", authHandler.RevokeSession)
+			}
+		})
+
+		// Admin routes (require a verified email listed in ADMIN_EMAILS)
+		r.Route("/admin", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequireTenant)
+				r.Use(authMiddleware.RequireAuth)
+				r.Use(authMiddleware.RequireAdmin(cfg.Auth.AdminEmails))
+				r.Post("/invites", authHandler.CreateInvite)
+				r.Get("/invites", authHandler.ListInvites)
+				r.Delete("/invites/{id}", authHandler.RevokeInvite)
+			})
 
-	// Protected routes (require authentication)
-	r.Group(func(r chi.Router) {
-		r.Use(authMiddleware.RequireAuth)
+			// Maintenance mode is process-wide, so it is left out of the
+			// tenant scope: RequireAuth then rejects tenant tokens and only
+			// admins outside any tenant get through. With tenancy on, use
+			// MAINTENANCE_MODE and SIGHUP instead.
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequireAuth)
+				r.Use(authMiddleware.RequireAdmin(cfg.Auth.AdminEmails))
+				r.Get("/maintenance", maintenance.handleGetMaintenance)
+				r.Put("/maintenance", maintenance.handleSetMaintenance)
+			})
+		})
 	})
 
 	return r
 }
 
-// handleHealth is a simple health check endpoint
+// mountAPI registers the API routes under prefix. With unversioned set they
+// are also served at the root, so clients can move to the prefix gradually.
+// A later version gets its own call, e.g. mountAPI(r, "/v2", false, v2Routes).
+func mountAPI(r chi.Router, prefix string, unversioned bool, routes func(r chi.Router)) {
+	if prefix == "" {
+		r.Group(routes)
+		return
+	}
+	r.Route(prefix, routes)
+	if unversioned {
+		r.Group(routes)
+	}
+}
+
+// HealthResponse reports that the API is up and, when the refresh token
+// store has a circuit breaker, its state
+type HealthResponse struct {
+	// "api is running", or "degraded" while the refresh token store's breaker
+	// isn't closed
+	Status string `json:"status" example:"api is running"`
+	// RefreshTokenStore is the breaker's state: closed, open or half_open
+	RefreshTokenStore auth.BreakerState `json:"refresh_token_store,omitempty" example:"closed"`
+	// RefreshTokenFallback is true when refresh tokens go to the SQL fallback
+	// while the breaker is open
+	RefreshTokenFallback bool `json:"refresh_token_fallback,omitempty"`
+}
+
+// handleHealth returns the health check endpoint. It answers 200 even when
+// degraded: restarting the API wouldn't bring Redis back, so liveness probes
+// shouldn't fail, but the state is there for dashboards and alerts.
 // @Summary      Health check
-// @Description  Check if the API is running
+// @Description  Check if the API is running and, when the refresh token store has a circuit breaker, whether it is open
 // @Tags         health
 // @Produce      json
-// @Success      200 {object} map[string]string
+// @Success      200 {object} HealthResponse
 // @Router       /health [get]
-func handleHealth(w http.ResponseWriter, r *http.Request) {
-	httputil.RespondJSON(w, map[string]string{"status": "api is running"}, http.StatusOK)
+func handleHealth(authStore *auth.BreakerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := HealthResponse{Status: "api is running"}
+		if authStore != nil {
+			resp.RefreshTokenStore = authStore.State()
+			resp.RefreshTokenFallback = authStore.HasFallback()
+			if resp.RefreshTokenStore != auth.BreakerClosed {
+				resp.Status = "degraded"
+			}
+		}
+		httputil.RespondJSON(w, resp, http.StatusOK)
+	}
 }
 
+// handleErrorCodes lists every error code the API can send
+// @Summary      List error codes
+// @Description  Every error.code value with its default message and the HTTP status it is usually sent with, for generating client error handling
+// @Tags         meta
+// @Produce      json
+// @Success      200 {array} httputil.ErrorCodeInfo
+// @Router       /meta/error-codes [get]
+func handleErrorCodes(w http.ResponseWriter, r *http.Request) {
+	httputil.RespondJSON(w, httputil.ErrorCodes(), http.StatusOK)
+}
+
+// handleNotFound answers requests for routes that don't exist, including
+// endpoints switched off with ENDPOINT_*_ENABLED
+func handleNotFound(w http.ResponseWriter, r *http.Request) {
+	httputil.RespondError(w, httputil.CodeNotFound, http.StatusNotFound)
+}
+
+// handleMethodNotAllowed answers requests whose path exists but not for their
+// method. Chi only fills in the Allow header for its own handler, so the
+// methods the path does accept are looked up again here.
+func handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.Routes != nil {
+		// Routes is the root router even inside a subrouter, so match the full path
+		var allowed []string
+		for _, method := range routeMethods {
+			if rctx.Routes.Match(chi.NewRouteContext(), method, r.URL.Path) {
+				allowed = append(allowed, method)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+	}
+	httputil.RespondError(w, httputil.CodeMethodNotAllowed, http.StatusMethodNotAllowed)
+}
+
+// routeMethods are the methods checked when building an Allow header
+var routeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// registrationGate serves register while public sign-up is open (REGISTRATION_ENABLED)
+// and rejects every request with 403 once it is closed
+func registrationGate(enabled bool, register http.HandlerFunc) http.HandlerFunc {
+	if enabled {
+		return register
+	}
+	return handleRegistrationClosed
+}
+
+func handleRegistrationClosed(w http.ResponseWriter, r *http.Request) {
+	httputil.RespondError(w, httputil.CodeRegistrationClosed, http.StatusForbidden)
+}
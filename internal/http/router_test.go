@@ -0,0 +1,56 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+)
+
+// downStore is a refresh token store that can't be reached
+type downStore struct{ auth.RefreshTokenRepository }
+
+func (downStore) StoreRefreshToken(context.Context, uuid.UUID, string, time.Time, auth.SessionMeta) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthReportsBreakerState(t *testing.T) {
+	logger := logging.NewLogger("json", slog.LevelError+1, logging.RedactOptions{})
+	open := auth.NewBreakerRepository(downStore{}, auth.NewMemoryRepository(), logger, 1, time.Minute)
+	_ = open.StoreRefreshToken(context.Background(), uuid.New(), "t", time.Now().Add(time.Hour), auth.SessionMeta{})
+
+	tests := []struct {
+		name  string
+		store *auth.BreakerRepository
+		want  HealthResponse
+	}{
+		{"no breaker", nil, HealthResponse{Status: "api is running"}},
+		{"closed", auth.NewBreakerRepository(auth.NewMemoryRepository(), nil, logger, 1, time.Minute), HealthResponse{Status: "api is running", RefreshTokenStore: auth.BreakerClosed}},
+		{"open", open, HealthResponse{Status: "degraded", RefreshTokenStore: auth.BreakerOpen, RefreshTokenFallback: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handleHealth(tt.store)(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("status = %d, want 200 even when degraded", w.Code)
+			}
+			var got HealthResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("health = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,44 +1,216 @@
 package httputil
 
-// Error codes for machine-readable API error responses.
+import (
+	"net/http"
+	"sort"
+)
+
+// ErrorCode is a machine-readable API error code.
 // Frontend uses these for i18n mapping; the "error" field remains for developer debugging.
+type ErrorCode string
+
+// All error codes returned by the API. Add new codes here together with an
+// entry in errorCodes so RespondError can be used.
 const (
 	// Common
-	CodeUnauthorized       = "UNAUTHORIZED"
-	CodeInvalidRequestBody = "INVALID_REQUEST_BODY"
-	CodeTooManyRequests    = "TOO_MANY_REQUESTS"
-	CodeInternalError      = "INTERNAL_ERROR"
+	CodeUnauthorized       ErrorCode = "UNAUTHORIZED"
+	CodeInvalidRequestBody ErrorCode = "INVALID_REQUEST_BODY"
+	CodeValidationFailed   ErrorCode = "VALIDATION_FAILED"
+	CodeTooManyRequests    ErrorCode = "TOO_MANY_REQUESTS"
+	CodeInternalError      ErrorCode = "INTERNAL_ERROR"
+	CodeHTTPSRequired      ErrorCode = "HTTPS_REQUIRED"
+	CodeRequestCanceled    ErrorCode = "REQUEST_CANCELED"
+	CodeRequestTimeout     ErrorCode = "REQUEST_TIMEOUT"
+	CodeServerBusy         ErrorCode = "SERVER_BUSY"
+	CodeMaintenance        ErrorCode = "MAINTENANCE"
+	CodeNotFound           ErrorCode = "NOT_FOUND"
+	CodeMethodNotAllowed   ErrorCode = "METHOD_NOT_ALLOWED"
+
+	// Idempotency
+	CodeIdempotencyKeyReused  ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyInProgress ErrorCode = "IDEMPOTENCY_IN_PROGRESS"
 
 	// Auth - registration
-	CodeEmailAlreadyExists = "EMAIL_ALREADY_EXISTS"
-	CodeEmailRequired      = "EMAIL_REQUIRED"
-	CodePasswordRequired   = "PASSWORD_REQUIRED"
-	CodePasswordTooShort   = "PASSWORD_TOO_SHORT"
-	CodeInvalidEmailFormat = "INVALID_EMAIL_FORMAT"
+	CodeEmailAlreadyExists ErrorCode = "EMAIL_ALREADY_EXISTS"
+	CodeEmailRequired      ErrorCode = "EMAIL_REQUIRED"
+	CodePasswordRequired   ErrorCode = "PASSWORD_REQUIRED"
+	CodePasswordTooShort   ErrorCode = "PASSWORD_TOO_SHORT"
+	CodePasswordTooWeak    ErrorCode = "PASSWORD_TOO_WEAK"
+	CodeInvalidEmailFormat ErrorCode = "INVALID_EMAIL_FORMAT"
+	CodeRegistrationClosed ErrorCode = "REGISTRATION_CLOSED"
+	CodeInviteRequired     ErrorCode = "INVITE_REQUIRED"
+	CodeInvalidInvite      ErrorCode = "INVALID_INVITE"
+	CodeUsernameRequired   ErrorCode = "USERNAME_REQUIRED"
+	CodeInvalidUsername    ErrorCode = "INVALID_USERNAME"
+	CodeUsernameTaken      ErrorCode = "USERNAME_TAKEN"
 
 	// Auth - login
-	CodeInvalidCredentials = "INVALID_CREDENTIALS"
-	CodeEmailNotVerified   = "EMAIL_NOT_VERIFIED"
+	CodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS"
+	CodeEmailNotVerified   ErrorCode = "EMAIL_NOT_VERIFIED"
 
 	// Auth - refresh
-	CodeRefreshTokenRequired = "REFRESH_TOKEN_REQUIRED"
-	CodeInvalidRefreshToken  = "INVALID_REFRESH_TOKEN"
+	CodeRefreshTokenRequired   ErrorCode = "REFRESH_TOKEN_REQUIRED"
+	CodeInvalidRefreshToken    ErrorCode = "INVALID_REFRESH_TOKEN"
+	CodeSessionInactive        ErrorCode = "SESSION_INACTIVE"
+	CodeRefreshTokenIPMismatch ErrorCode = "REFRESH_TOKEN_IP_MISMATCH"
+	CodeAuthStoreUnavailable   ErrorCode = "AUTH_STORE_UNAVAILABLE"
+
+	// Auth - sessions
+	CodeInvalidSessionID ErrorCode = "INVALID_SESSION_ID"
+	CodeSessionNotFound  ErrorCode = "SESSION_NOT_FOUND"
+
+	// Admin - invites
+	CodeInvalidInviteID ErrorCode = "INVALID_INVITE_ID"
+	CodeInviteNotFound  ErrorCode = "INVITE_NOT_FOUND"
 
 	// Auth - email verification
-	CodeVerificationTokenRequired = "VERIFICATION_TOKEN_REQUIRED"
-	CodeVerificationFailed        = "VERIFICATION_FAILED"
-	CodeTokenExpired              = "TOKEN_EXPIRED"
-	CodeAlreadyVerified           = "ALREADY_VERIFIED"
+	CodeVerificationTokenRequired ErrorCode = "VERIFICATION_TOKEN_REQUIRED"
+	CodeVerificationFailed        ErrorCode = "VERIFICATION_FAILED"
+	CodeTokenExpired              ErrorCode = "TOKEN_EXPIRED"
+	CodeAlreadyVerified           ErrorCode = "ALREADY_VERIFIED"
+	CodeVerificationSuperseded    ErrorCode = "VERIFICATION_SUPERSEDED"
 
 	// Auth - password reset
-	CodeInvalidResetToken = "INVALID_RESET_TOKEN"
+	CodeInvalidResetToken ErrorCode = "INVALID_RESET_TOKEN"
+
+	// Auth - magic link
+	CodeMagicLinkTokenRequired ErrorCode = "MAGIC_LINK_TOKEN_REQUIRED"
+	CodeInvalidMagicLinkToken  ErrorCode = "INVALID_MAGIC_LINK_TOKEN"
+
+	// Auth - passkeys (WebAuthn)
+	CodeWebAuthnSessionExpired     ErrorCode = "WEBAUTHN_SESSION_EXPIRED"
+	CodeWebAuthnVerificationFailed ErrorCode = "WEBAUTHN_VERIFICATION_FAILED"
 
 	// Auth - middleware
-	CodeInvalidAuthHeader = "INVALID_AUTH_HEADER"
-	CodeMissingAuth       = "MISSING_AUTH"
-	CodeInvalidToken      = "INVALID_TOKEN"
-	CodeInvalidTokenUserID = "INVALID_TOKEN_USER_ID"
+	CodeInvalidAuthHeader  ErrorCode = "INVALID_AUTH_HEADER"
+	CodeMissingAuth        ErrorCode = "MISSING_AUTH"
+	CodeInvalidToken       ErrorCode = "INVALID_TOKEN"
+	CodeInvalidTokenUserID ErrorCode = "INVALID_TOKEN_USER_ID"
+	CodeReauthRequired     ErrorCode = "REAUTH_REQUIRED"
+	CodeAdminRequired      ErrorCode = "ADMIN_REQUIRED"
+
+	// Auth - tenants
+	CodeTenantRequired ErrorCode = "TENANT_REQUIRED"
+	CodeInvalidTenant  ErrorCode = "INVALID_TENANT"
+	CodeTenantMismatch ErrorCode = "TENANT_MISMATCH"
 
 	// Auth - rate limiting
-	CodeCooldownActive = "COOLDOWN_ACTIVE"
+	CodeCooldownActive ErrorCode = "COOLDOWN_ACTIVE"
 )
+
+// errorCodeInfo is what the registry knows about a code
+type errorCodeInfo struct {
+	message string // Default developer-facing message
+	status  int    // HTTP status the code is usually sent with
+}
+
+// errorCodes is the registry of every code's default message and typical
+// status. GET /meta/error-codes lists it, so keep it complete.
+var errorCodes = map[ErrorCode]errorCodeInfo{
+	CodeUnauthorized:       {"unauthorized", http.StatusUnauthorized},
+	CodeInvalidRequestBody: {"invalid request body", http.StatusBadRequest},
+	CodeValidationFailed:   {"request validation failed", http.StatusBadRequest},
+	CodeTooManyRequests:    {"too many requests, please try again later", http.StatusTooManyRequests},
+	CodeInternalError:      {"internal server error", http.StatusInternalServerError},
+	CodeHTTPSRequired:      {"HTTPS is required", http.StatusBadRequest},
+	CodeRequestCanceled:    {"request canceled by the client", StatusClientClosedRequest},
+	CodeRequestTimeout:     {"request timed out, please try again", http.StatusServiceUnavailable},
+	CodeServerBusy:         {"server is busy, please try again shortly", http.StatusServiceUnavailable},
+	CodeMaintenance:        {"the API is down for maintenance, please try again later", http.StatusServiceUnavailable},
+	CodeNotFound:           {"no such endpoint", http.StatusNotFound},
+	CodeMethodNotAllowed:   {"method not allowed for this endpoint", http.StatusMethodNotAllowed},
+
+	CodeIdempotencyKeyReused:  {"idempotency key was already used with a different request", http.StatusConflict},
+	CodeIdempotencyInProgress: {"a request with this idempotency key is still being processed", http.StatusConflict},
+
+	CodeEmailAlreadyExists: {"email already exists", http.StatusConflict},
+	CodeEmailRequired:      {"email is required", http.StatusBadRequest},
+	CodePasswordRequired:   {"password is required", http.StatusBadRequest},
+	CodePasswordTooShort:   {"password must be at least 8 characters", http.StatusBadRequest},
+	CodePasswordTooWeak:    {"password is too weak", http.StatusBadRequest},
+	CodeInvalidEmailFormat: {"invalid email format", http.StatusBadRequest},
+	CodeRegistrationClosed: {"registration is closed", http.StatusForbidden},
+	CodeInviteRequired:     {"an invite is required to register", http.StatusForbidden},
+	CodeInvalidInvite:      {"invite is invalid, expired or already used", http.StatusForbidden},
+	CodeUsernameRequired:   {"username is required", http.StatusBadRequest},
+	CodeInvalidUsername:    {"username must be 3-32 letters, digits, dots, underscores or hyphens, starting with a letter or digit", http.StatusBadRequest},
+	CodeUsernameTaken:      {"username is already taken", http.StatusConflict},
+
+	CodeInvalidCredentials: {"invalid email or password", http.StatusUnauthorized},
+	CodeEmailNotVerified:   {"email not verified, please check your inbox", http.StatusForbidden},
+
+	CodeRefreshTokenRequired:   {"refresh token required", http.StatusBadRequest},
+	CodeInvalidRefreshToken:    {"invalid or expired refresh token", http.StatusUnauthorized},
+	CodeSessionInactive:        {"session expired due to inactivity, please login again", http.StatusUnauthorized},
+	CodeRefreshTokenIPMismatch: {"session was used from a different network, please login again", http.StatusUnauthorized},
+	CodeAuthStoreUnavailable:   {"sessions are temporarily unavailable, please try again shortly", http.StatusServiceUnavailable},
+
+	CodeInvalidSessionID: {"invalid session ID", http.StatusBadRequest},
+	CodeSessionNotFound:  {"session not found", http.StatusNotFound},
+
+	CodeInvalidInviteID: {"invalid invite ID", http.StatusBadRequest},
+	CodeInviteNotFound:  {"invite not found", http.StatusNotFound},
+
+	CodeVerificationTokenRequired: {"verification token required", http.StatusBadRequest},
+	CodeVerificationFailed:        {"invalid verification token", http.StatusBadRequest},
+	CodeTokenExpired:              {"token has expired", http.StatusUnauthorized},
+	CodeAlreadyVerified:           {"email already verified", http.StatusBadRequest},
+	CodeVerificationSuperseded:    {"verification link was replaced by a newer one, please request a new link", http.StatusBadRequest},
+
+	CodeInvalidResetToken: {"invalid or expired reset token", http.StatusBadRequest},
+
+	CodeMagicLinkTokenRequired: {"magic link token required", http.StatusBadRequest},
+	CodeInvalidMagicLinkToken:  {"invalid or expired login link", http.StatusUnauthorized},
+
+	CodeWebAuthnSessionExpired:     {"passkey request expired, please try again", http.StatusBadRequest},
+	CodeWebAuthnVerificationFailed: {"passkey verification failed", http.StatusBadRequest},
+
+	CodeInvalidAuthHeader:  {"invalid authorization header format", http.StatusUnauthorized},
+	CodeMissingAuth:        {"missing authentication", http.StatusUnauthorized},
+	CodeInvalidToken:       {"invalid token", http.StatusUnauthorized},
+	CodeInvalidTokenUserID: {"invalid user ID in token", http.StatusUnauthorized},
+	CodeReauthRequired:     {"please login again to continue", http.StatusForbidden},
+	CodeAdminRequired:      {"admin access required", http.StatusForbidden},
+
+	CodeTenantRequired: {"tenant is required", http.StatusBadRequest},
+	CodeInvalidTenant:  {"tenant must be 1-63 lowercase letters, digits or hyphens, not starting or ending with a hyphen", http.StatusBadRequest},
+	CodeTenantMismatch: {"token was issued for a different tenant", http.StatusForbidden},
+
+	CodeCooldownActive: {"please wait before trying again", http.StatusTooManyRequests},
+}
+
+// Message returns the default message for the code.
+func (c ErrorCode) Message() string {
+	if info, ok := errorCodes[c]; ok {
+		return info.message
+	}
+	return string(c)
+}
+
+// Status returns the HTTP status the code is usually sent with, or 500 for
+// codes missing from the registry. Handlers still pass their status
+// explicitly; a few codes, like TOKEN_EXPIRED, are sent with more than one.
+func (c ErrorCode) Status() int {
+	if info, ok := errorCodes[c]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorCodeInfo describes one error code for clients generating their error
+// handling
+type ErrorCodeInfo struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Status  int       `json:"status"`
+}
+
+// ErrorCodes lists every registered code, sorted by code
+func ErrorCodes() []ErrorCodeInfo {
+	codes := make([]ErrorCodeInfo, 0, len(errorCodes))
+	for code, info := range errorCodes {
+		codes = append(codes, ErrorCodeInfo{Code: code, Message: info.message, Status: info.status})
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].Code < codes[j].Code })
+	return codes
+}
@@ -0,0 +1,199 @@
+package httputil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Page sizes used by ParsePagination
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// TotalCountHeader carries the total number of items in a paged list
+const TotalCountHeader = "X-Total-Count"
+
+// ParsePagination reads the optional page and per_page query parameters.
+// page defaults to 1 and per_page to DefaultPerPage, and per_page is capped at
+// MaxPerPage. Values that aren't positive integers return a *ValidationError,
+// so RespondBindError can answer them. Pass the result to PageOffset for a
+// database.ListOptions.
+func ParsePagination(r *http.Request) (page, perPage int, err error) {
+	var fields []FieldError
+	page, fields = parsePageParam(r, "page", 1, fields)
+	perPage, fields = parsePageParam(r, "per_page", DefaultPerPage, fields)
+	if len(fields) > 0 {
+		return 0, 0, &ValidationError{Fields: fields}
+	}
+	return page, min(perPage, MaxPerPage), nil
+}
+
+// parsePageParam returns the query parameter name as a positive integer, or
+// def if it is absent, adding a FieldError to fields if it is invalid
+func parsePageParam(r *http.Request, name string, def int, fields []FieldError) (int, []FieldError) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, fields
+	}
+	// 32 bits keeps PageOffset from overflowing
+	n, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil || n < 1 {
+		rule := "min"
+		if err != nil {
+			rule = "number"
+		}
+		return 0, append(fields, FieldError{
+			Field:   name,
+			Rule:    rule,
+			Message: name + " must be a positive integer",
+		})
+	}
+	return int(n), fields
+}
+
+// PageOffset returns how many items come before page
+func PageOffset(page, perPage int) int {
+	return (page - 1) * perPage
+}
+
+// WritePaginationHeaders sets X-Total-Count and an RFC 8288 Link header with
+// first, prev, next and last links, omitting those that don't apply. Links
+// are relative to r and keep its other query parameters, such as filters.
+// Call it before writing the status.
+func WritePaginationHeaders(w http.ResponseWriter, r *http.Request, page, perPage, total int) {
+	w.Header().Set(TotalCountHeader, strconv.Itoa(total))
+
+	last := totalPages(perPage, total)
+	var links []string
+	addLink := func(p int, rel string) {
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", pageURL(r, p, perPage), rel))
+	}
+	addLink(1, "first")
+	if page > 1 {
+		addLink(min(page-1, last), "prev")
+	}
+	if page < last {
+		addLink(page+1, "next")
+	}
+	addLink(last, "last")
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// pageURL returns r's path and query with page and per_page replaced
+func pageURL(r *http.Request, page, perPage int) string {
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(perPage))
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// totalPages returns the number of pages, counting an empty list as one page
+func totalPages(perPage, total int) int {
+	if total <= 0 || perPage <= 0 {
+		return 1
+	}
+	return (total + perPage - 1) / perPage
+}
+
+// PagedResponse is the envelope for a page of a list
+type PagedResponse[T any] struct {
+	Data       []T `json:"data"`
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// NewPagedResponse wraps one page of items, sending an empty page as [] rather
+// than null
+func NewPagedResponse[T any](data []T, page, perPage, total int) PagedResponse[T] {
+	if data == nil {
+		data = []T{}
+	}
+	return PagedResponse[T]{
+		Data:       data,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages(perPage, total),
+	}
+}
+
+// ParseCursor reads the optional cursor and limit query parameters of a list
+// paged by key rather than offset, which stays stable while rows are added.
+// The cursor, made by EncodeCursor, is decoded into after, which is left as
+// it is if there's none; a list starts from the beginning then. limit
+// defaults to DefaultPerPage and is capped at MaxPerPage. Invalid values
+// return a *ValidationError.
+func ParseCursor(r *http.Request, after any) (limit int, err error) {
+	var fields []FieldError
+	limit, fields = parsePageParam(r, "limit", DefaultPerPage, fields)
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if err := DecodeCursor(cursor, after); err != nil {
+			fields = append(fields, FieldError{
+				Field:   "cursor",
+				Rule:    "cursor",
+				Message: "cursor is invalid",
+			})
+		}
+	}
+	if len(fields) > 0 {
+		return 0, &ValidationError{Fields: fields}
+	}
+	return min(limit, MaxPerPage), nil
+}
+
+// EncodeCursor returns an opaque cursor holding position, typically the sort
+// key and ID of the last item on a page
+func EncodeCursor(position any) (string, error) {
+	data, err := json.Marshal(position)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a cursor made by EncodeCursor into position
+func DecodeCursor(cursor string, position any) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("failed to decode cursor: %w", err)
+	}
+	if err := json.Unmarshal(data, position); err != nil {
+		return fmt.Errorf("failed to decode cursor: %w", err)
+	}
+	return nil
+}
+
+// WriteCursorHeaders sets an RFC 8288 Link header to the page that starts at
+// cursor next, keeping r's other query parameters. An empty next, on the last
+// page, sets none. Call it before writing the status.
+func WriteCursorHeaders(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	query := r.URL.Query()
+	query.Set("cursor", next)
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=%q", r.URL.Path+"?"+query.Encode(), "next"))
+}
+
+// CursorResponse is the envelope for a page of a list paged by cursor
+type CursorResponse[T any] struct {
+	Data []T `json:"data"`
+	// NextCursor fetches the following page; it is empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewCursorResponse wraps one page of items, sending an empty page as []
+// rather than null
+func NewCursorResponse[T any](data []T, next string) CursorResponse[T] {
+	if data == nil {
+		data = []T{}
+	}
+	return CursorResponse[T]{Data: data, NextCursor: next}
+}
@@ -0,0 +1,78 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testPosition struct {
+	CreatedAt string `json:"created_at"`
+	ID        string `json:"id"`
+}
+
+func TestParseCursor(t *testing.T) {
+	cursor, err := EncodeCursor(testPosition{CreatedAt: "2026-01-02T03:04:05Z", ID: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantAfter testPosition
+		wantErr   bool
+	}{
+		{name: "defaults", query: "", wantLimit: DefaultPerPage},
+		{name: "cursor and limit", query: "cursor=" + cursor + "&limit=5", wantLimit: 5, wantAfter: testPosition{CreatedAt: "2026-01-02T03:04:05Z", ID: "42"}},
+		{name: "limit capped", query: "limit=1000", wantLimit: MaxPerPage},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "garbled cursor", query: "cursor=***", wantErr: true},
+		{name: "cursor that isn't JSON", query: "cursor=bm90IGpzb24", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var after testPosition
+			limit, err := ParseCursor(httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil), &after)
+			if tt.wantErr {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) {
+					t.Errorf("ParseCursor() error = %v, want *ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCursor() error = %v", err)
+			}
+			if limit != tt.wantLimit || after != tt.wantAfter {
+				t.Errorf("ParseCursor() = %d, %+v, want %d, %+v", limit, after, tt.wantLimit, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestWriteCursorHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/users?cursor=old&limit=5&role=admin", nil)
+
+	w := httptest.NewRecorder()
+	WriteCursorHeaders(w, r, "new")
+	want := `</users?cursor=new&limit=5&role=admin>; rel="next"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	WriteCursorHeaders(w, r, "")
+	if got := w.Header().Get("Link"); got != "" {
+		t.Errorf("Link on the last page = %q, want none", got)
+	}
+}
+
+func TestNewCursorResponse(t *testing.T) {
+	resp := NewCursorResponse[int](nil, "")
+	if resp.Data == nil || len(resp.Data) != 0 {
+		t.Errorf("Data = %#v, want an empty slice", resp.Data)
+	}
+}
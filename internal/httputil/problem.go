@@ -0,0 +1,120 @@
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemContentType is the RFC 7807 media type for error bodies
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails is the RFC 7807 form of ErrorResponse, sent to clients that
+// ask for application/problem+json. Code, Fields and RequestID carry the same
+// values as in ErrorResponse, as extension members.
+type ProblemDetails struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      ErrorCode    `json:"code,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// problemWriter marks a response whose errors should be sent as
+// application/problem+json
+type problemWriter struct {
+	http.ResponseWriter
+}
+
+func (w *problemWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// NegotiateErrors lets error responses honor the request's Accept header.
+// Requests that prefer application/problem+json get ProblemDetails bodies;
+// everything else, including a missing Accept or */*, keeps ErrorResponse.
+// Writers wrapping w further down must implement Unwrap for the choice to be
+// seen.
+func NegotiateErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if prefersProblemJSON(r.Header.Values("Accept")) {
+			w = &problemWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// prefersProblemJSON reports whether Accept lists application/problem+json
+// with a quality at least as high as application/json's. Wildcards don't
+// count, so they fall back to plain JSON.
+func prefersProblemJSON(accept []string) bool {
+	problemQ, jsonQ := 0.0, 0.0
+	for _, header := range accept {
+		for _, part := range strings.Split(header, ",") {
+			mediaType, params, _ := strings.Cut(part, ";")
+			switch strings.ToLower(strings.TrimSpace(mediaType)) {
+			case ProblemContentType:
+				problemQ = Quality(params)
+			case "application/json":
+				jsonQ = Quality(params)
+			}
+		}
+	}
+	return problemQ > 0 && problemQ >= jsonQ
+}
+
+// Quality returns the q parameter of a media range or content coding, 1 if
+// it has none, and 0 if it is malformed
+func Quality(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if !strings.EqualFold(name, "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// wantsProblem reports whether NegotiateErrors marked w, looking through any
+// writers that wrap it
+func wantsProblem(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *problemWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// respondErrorBody sends resp as ErrorResponse JSON, or as ProblemDetails if
+// the client asked for it
+func respondErrorBody(w http.ResponseWriter, resp ErrorResponse, statusCode int) {
+	w.Header().Add("Vary", "Accept")
+	if !wantsProblem(w) {
+		RespondJSON(w, resp, statusCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(statusCode)
+	writeJSON(w, ProblemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    resp.Error,
+		Code:      resp.Code,
+		Fields:    resp.Fields,
+		RequestID: resp.RequestID,
+	})
+}
@@ -0,0 +1,58 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// unwrapper stands in for middleware that wraps the response writer
+type unwrapper struct{ http.ResponseWriter }
+
+func (u unwrapper) Unwrap() http.ResponseWriter { return u.ResponseWriter }
+
+func TestNegotiateErrors(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/json", "application/json"},
+		{"application/problem+json", ProblemContentType},
+		{"application/json;q=0.5, application/problem+json", ProblemContentType},
+		{"application/problem+json;q=0.5, application/json", "application/json"},
+		{"application/problem+json;q=0", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			h := NegotiateErrors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				RespondError(unwrapper{w}, CodeNotFound, http.StatusNotFound)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.contentType)
+			}
+			if vary := w.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("Vary = %q, want Accept", vary)
+			}
+			var body map[string]any
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("body isn't JSON: %v", err)
+			}
+			if body["code"] != string(CodeNotFound) {
+				t.Errorf("code = %v, want %s", body["code"], CodeNotFound)
+			}
+			if tt.contentType == ProblemContentType && body["status"] != float64(http.StatusNotFound) {
+				t.Errorf("problem status = %v, want 404", body["status"])
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package httputil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DecodeJSON decodes a single JSON object from the request body into dst.
+// It rejects unknown fields and trailing data. Body size is capped by the
+// router's MaxBodySize middleware (MAX_REQUEST_BODY_BYTES), and a body over
+// that limit returns an error wrapping *http.MaxBytesError. An empty body
+// returns an error wrapping io.EOF so optional bodies can be detected.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return fmt.Errorf("request body must not exceed %d bytes: %w", maxBytesErr.Limit, err)
+		}
+		return fmt.Errorf("decode request body: %w", err)
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errors.New("request body must contain a single JSON object")
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package httputil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type body struct {
+		Email string `json:"email"`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		limit   int64 // MaxBodySize limit, 0 for none
+		wantErr func(error) bool
+	}{
+		{name: "valid", body: `{"email":"a@example.com"}`},
+		{name: "empty", body: "", wantErr: func(err error) bool { return errors.Is(err, io.EOF) }},
+		{name: "unknown field", body: `{"name":"a"}`, wantErr: isErr},
+		{name: "trailing data", body: `{"email":"a"}{}`, wantErr: isErr},
+		{name: "within the configured limit", body: `{"email":"a@example.com"}`, limit: 64},
+		{
+			name:  "over the configured limit",
+			body:  `{"email":"` + strings.Repeat("a", 100) + `"}`,
+			limit: 64,
+			wantErr: func(err error) bool {
+				var maxBytesErr *http.MaxBytesError
+				return errors.As(err, &maxBytesErr)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.limit > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, tt.limit)
+			}
+
+			var dst body
+			err := DecodeJSON(w, r, &dst)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("DecodeJSON() error = %v", err)
+				}
+				return
+			}
+			if !tt.wantErr(err) {
+				t.Errorf("DecodeJSON() error = %v", err)
+			}
+		})
+	}
+}
+
+func isErr(err error) bool { return err != nil }
@@ -1,15 +1,29 @@
 package httputil
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+
+	"github.com/redmonkez12/go-api-template/internal/logging"
 )
 
+// StatusClientClosedRequest is the non-standard status (from nginx) for a
+// request the client abandoned before the response was ready
+const StatusClientClosedRequest = 499
+
+// RequestIDHeader carries the request ID in responses, and optionally in
+// requests from a gateway that assigned it
+const RequestIDHeader = "X-Request-ID"
+
 // ErrorResponse represents a standard error response
 type ErrorResponse struct {
-	Error string `json:"error"`
-	Code  string `json:"code,omitempty"`
+	Error     string       `json:"error"`
+	Code      ErrorCode    `json:"code,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"` // Set for VALIDATION_FAILED
+	RequestID string       `json:"request_id,omitempty"`
 }
 
 // RespondJSON sends a JSON response with the given status code.
@@ -17,17 +31,48 @@ type ErrorResponse struct {
 func RespondJSON(w http.ResponseWriter, data any, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
+	writeJSON(w, data)
+}
+
+// writeJSON encodes data to w, logging encoding errors
+func writeJSON(w http.ResponseWriter, data any) {
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		log.Printf("ERROR: failed to encode JSON response: %v", err)
 	}
 }
 
-// RespondError sends a JSON error response with the given message and status code.
-func RespondError(w http.ResponseWriter, message string, statusCode int) {
-	RespondJSON(w, ErrorResponse{Error: message}, statusCode)
+// RespondError sends a JSON error response with the code and its default message.
+func RespondError(w http.ResponseWriter, code ErrorCode, statusCode int) {
+	RespondErrorWithCode(w, code.Message(), code, statusCode)
+}
+
+// RespondErrorWithCode sends a JSON error response with a custom message and a machine-readable error code.
+// The request ID is taken from the X-Request-ID header the RequestID
+// middleware already set on w. Clients that prefer application/problem+json,
+// as recorded by NegotiateErrors, get the same error as ProblemDetails.
+func RespondErrorWithCode(w http.ResponseWriter, message string, code ErrorCode, statusCode int) {
+	respondErrorBody(w, ErrorResponse{
+		Error:     message,
+		Code:      code,
+		RequestID: w.Header().Get(RequestIDHeader),
+	}, statusCode)
 }
 
-// RespondErrorWithCode sends a JSON error response with a machine-readable error code.
-func RespondErrorWithCode(w http.ResponseWriter, message string, code string, statusCode int) {
-	RespondJSON(w, ErrorResponse{Error: message, Code: code}, statusCode)
+// RespondInternalError logs err under msg and responds to an unexpected
+// failure. Errors caused by the request's context ending aren't server bugs,
+// so they get their own status and a lower log level: a client disconnect is
+// answered with 499 and logged at info, a deadline with 503 and logged as a
+// warning. Everything else is a 500 logged as an error.
+func RespondInternalError(w http.ResponseWriter, logger *logging.Logger, msg string, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info(msg, "error", err.Error())
+		RespondError(w, CodeRequestCanceled, StatusClientClosedRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		logger.Warn(msg, "error", err.Error())
+		RespondError(w, CodeRequestTimeout, http.StatusServiceUnavailable)
+	default:
+		logger.Error(msg, "error", err.Error())
+		RespondError(w, CodeInternalError, http.StatusInternalServerError)
+	}
 }
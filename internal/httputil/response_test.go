@@ -0,0 +1,56 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/redmonkez12/go-api-template/internal/logging"
+)
+
+func TestRespondInternalError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   ErrorCode
+		level  string
+	}{
+		{"canceled", fmt.Errorf("failed to get user by email: %w", context.Canceled), StatusClientClosedRequest, CodeRequestCanceled, "INFO"},
+		{"deadline", fmt.Errorf("failed to get user by email: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, CodeRequestTimeout, "WARN"},
+		{"other", errors.New("connection refused"), http.StatusInternalServerError, CodeInternalError, "ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := &logging.Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+			w := httptest.NewRecorder()
+
+			RespondInternalError(w, logger, "login failed", tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			var body ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.code {
+				t.Errorf("code = %s, want %s", body.Code, tt.code)
+			}
+			var line map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			if line["level"] != tt.level {
+				t.Errorf("logged at %v, want %s", line["level"], tt.level)
+			}
+		})
+	}
+}
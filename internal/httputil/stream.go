@@ -0,0 +1,54 @@
+package httputil
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// JSONArrayWriter streams a JSON array one element at a time, so a large list
+// is never held in memory whole. Start one with StreamJSONArray, Write each
+// element, then Close.
+type JSONArrayWriter struct {
+	w     http.ResponseWriter
+	enc   *json.Encoder
+	count int
+}
+
+// StreamJSONArray sends statusCode and opens a JSON array. The status can't
+// change once it is called, so check for errors that should change it first;
+// a failure part way through can only cut the response short.
+func StreamJSONArray(w http.ResponseWriter, statusCode int) *JSONArrayWriter {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	a := &JSONArrayWriter{w: w, enc: json.NewEncoder(w)}
+	a.writeString("[")
+	return a
+}
+
+// Write appends v to the array
+func (a *JSONArrayWriter) Write(v any) error {
+	if a.count > 0 {
+		if err := a.writeString(","); err != nil {
+			return err
+		}
+	}
+	a.count++
+	return a.enc.Encode(v)
+}
+
+// Flush sends the elements written so far, for clients that process the
+// array as it arrives
+func (a *JSONArrayWriter) Flush() error {
+	return http.NewResponseController(a.w).Flush()
+}
+
+// Close ends the array
+func (a *JSONArrayWriter) Close() error {
+	return a.writeString("]\n")
+}
+
+func (a *JSONArrayWriter) writeString(s string) error {
+	_, err := io.WriteString(a.w, s)
+	return err
+}
@@ -0,0 +1,120 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate checks `validate` struct tags. It caches struct metadata and is
+// safe for concurrent use, so one instance serves every request.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by their JSON names, which is what clients sent
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// FieldError describes one request field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field of a request that failed validation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// BindAndValidate decodes the JSON body into dst with DecodeJSON and then
+// checks dst's `validate` tags. A body that fails the tags returns a
+// *ValidationError; anything else is a decode error. RespondBindError turns
+// either into the matching response.
+func BindAndValidate(w http.ResponseWriter, r *http.Request, dst any) error {
+	if err := DecodeJSON(w, r, dst); err != nil {
+		return err
+	}
+	return Validate(dst)
+}
+
+// Validate checks v's `validate` tags and returns a *ValidationError listing
+// every failing field
+func Validate(v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return fmt.Errorf("validate request: %w", err)
+	}
+
+	fields := make([]FieldError, len(verrs))
+	for i, fe := range verrs {
+		fields[i] = FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldMessage(fe),
+		}
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// fieldMessage describes a failed rule in words for the rules request
+// structs use; other rules fall back to naming the rule
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fe.Field() + " is required"
+	case "email":
+		return fe.Field() + " must be a valid email address"
+	case "min":
+		return fmt.Sprintf("%s must be at least %s characters", fe.Field(), fe.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters", fe.Field(), fe.Param())
+	default:
+		return fmt.Sprintf("%s failed the %s rule", fe.Field(), fe.Tag())
+	}
+}
+
+// RespondBindError responds to an error from BindAndValidate: 400
+// VALIDATION_FAILED with the failing fields for a *ValidationError, and 400
+// INVALID_REQUEST_BODY for a body that couldn't be decoded
+func RespondBindError(w http.ResponseWriter, err error) {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		respondErrorBody(w, ErrorResponse{
+			Error:     CodeValidationFailed.Message(),
+			Code:      CodeValidationFailed,
+			Fields:    verr.Fields,
+			RequestID: w.Header().Get(RequestIDHeader),
+		}, http.StatusBadRequest)
+		return
+	}
+	RespondError(w, CodeInvalidRequestBody, http.StatusBadRequest)
+}
@@ -0,0 +1,242 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
+)
+
+const (
+	// HeaderName is the request header clients set to make a request idempotent
+	HeaderName = "Idempotency-Key"
+
+	keyTTL      = 24 * time.Hour
+	maxKeyLen   = 255
+	redisPrefix = "idempotency:"
+)
+
+// entry is the stored state of a request seen under an idempotency key.
+// An entry without a status is still being processed.
+type entry struct {
+	BodyHash string      `json:"body_hash"`
+	Status   int         `json:"status,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+}
+
+// Store records responses to idempotent requests in Redis.
+type Store struct {
+	client *redis.Client
+}
+
+// NewStore creates a new Redis-backed idempotency store.
+func NewStore(client *redis.Client) *Store {
+	return &Store{client: client}
+}
+
+// Middleware replays the first response for a repeated Idempotency-Key.
+// Reusing a key with a different body, or while the first request is still
+// running, returns 409. Requests without the header pass through unchanged.
+// Apply it per route with chi's With.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderName)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger := logging.GetLoggerFromContext(r.Context())
+
+		if len(key) > maxKeyLen {
+			httputil.RespondErrorWithCode(w, "idempotency key too long", httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		bodyHash := hashBytes(body)
+		redisKey := storeKey(r, key)
+
+		acquired, existing, err := s.acquire(r.Context(), redisKey, bodyHash)
+		if err != nil {
+			// Fail open: a Redis outage shouldn't block the endpoint
+			logger.Error("idempotency check failed", "error", err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !acquired {
+			switch {
+			case existing.BodyHash != bodyHash:
+				httputil.RespondError(w, httputil.CodeIdempotencyKeyReused, http.StatusConflict)
+			case existing.Status == 0:
+				httputil.RespondError(w, httputil.CodeIdempotencyInProgress, http.StatusConflict)
+			default:
+				logger.Info("replaying idempotent response")
+				replay(w, existing)
+			}
+			return
+		}
+
+		// Headers set by outer middleware (request ID, CORS) belong to each
+		// request, so only the ones the handler adds are stored
+		outer := w.Header().Clone()
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// Only final outcomes are cached. Rate limits, server errors and
+		// abandoned requests release the key so the client can retry with it,
+		// even if the request's context has already ended.
+		if !cacheable(rec.status) {
+			if err := s.client.Del(context.WithoutCancel(r.Context()), redisKey).Err(); err != nil {
+				logger.Error("failed to release idempotency key", "error", err.Error())
+			}
+			return
+		}
+
+		if err := s.save(r.Context(), redisKey, &entry{
+			BodyHash: bodyHash,
+			Status:   rec.status,
+			Header:   addedHeaders(outer, rec.Header()),
+			Body:     rec.body.Bytes(),
+		}); err != nil {
+			logger.Error("failed to store idempotent response", "error", err.Error())
+		}
+	})
+}
+
+// acquire claims the key for this request. If it is already taken, the
+// existing entry is returned instead.
+func (s *Store) acquire(ctx context.Context, key, bodyHash string) (bool, *entry, error) {
+	data, err := json.Marshal(&entry{BodyHash: bodyHash})
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to marshal idempotency entry: %w", err)
+	}
+
+	ok, err := s.client.SetNX(ctx, key, data, keyTTL).Result()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if ok {
+		return true, nil, nil
+	}
+
+	raw, err := s.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to get idempotency entry: %w", err)
+	}
+
+	var existing entry
+	if err := json.Unmarshal(raw, &existing); err != nil {
+		return false, nil, fmt.Errorf("failed to unmarshal idempotency entry: %w", err)
+	}
+
+	return false, &existing, nil
+}
+
+// save stores the completed response, keeping the key's remaining TTL
+func (s *Store) save(ctx context.Context, key string, e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency entry: %w", err)
+	}
+
+	if err := s.client.SetArgs(ctx, key, data, redis.SetArgs{KeepTTL: true}).Err(); err != nil {
+		return fmt.Errorf("failed to store idempotency entry: %w", err)
+	}
+
+	return nil
+}
+
+// cacheable reports whether a response is final for its key: successes,
+// and conflicts or validation failures that a retry would hit again
+func cacheable(status int) bool {
+	switch {
+	case status >= 200 && status < 300:
+		return true
+	case status == http.StatusConflict, status == http.StatusUnprocessableEntity:
+		return true
+	default:
+		return false
+	}
+}
+
+// addedHeaders returns the headers in after that differ from before
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			added[name] = slices.Clone(values)
+		}
+	}
+	return added
+}
+
+// replay writes a stored response back to the client
+func replay(w http.ResponseWriter, e *entry) {
+	maps.Copy(w.Header(), e.Header)
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(e.Status)
+	w.Write(e.Body)
+}
+
+// storeKey scopes the client's key to the route and tenant so the same key
+// can't replay a response from a different endpoint or another tenant
+func storeKey(r *http.Request, key string) string {
+	scope := r.Method + " " + r.URL.Path + " " + key
+	if meta, ok := reqmeta.FromContext(r.Context()); ok && meta.TenantID != "" {
+		scope = meta.TenantID + " " + scope
+	}
+	return redisPrefix + hashBytes([]byte(scope))
+}
+
+func hashBytes(b []byte) string {
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}
+
+// responseRecorder captures the status and body while passing them through
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
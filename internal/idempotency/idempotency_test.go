@@ -0,0 +1,149 @@
+package idempotency
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewStore(client)
+}
+
+// countingHandler responds with status and a Set-Cookie header, counting calls
+func countingHandler(calls *int, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		io.ReadAll(r.Body)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ok":true}`))
+	})
+}
+
+func send(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(HeaderName, key)
+	}
+	w := httptest.NewRecorder()
+	// Set by outer middleware, so it must not be replayed
+	w.Header().Set("X-Request-ID", "req-"+body)
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddlewareReplaysResponse(t *testing.T) {
+	var calls int
+	h := newTestStore(t).Middleware(countingHandler(&calls, http.StatusCreated))
+
+	first := send(h, "key-1", "a")
+	second := send(h, "key-1", "a")
+
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay missing Idempotent-Replayed header")
+	}
+	if got := second.Header().Get("Set-Cookie"); got != first.Header().Get("Set-Cookie") {
+		t.Errorf("replayed Set-Cookie = %q, want %q", got, first.Header().Get("Set-Cookie"))
+	}
+	if got := second.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("replayed Content-Type = %q", got)
+	}
+	if got := second.Header().Get("X-Request-ID"); got != "req-a" {
+		t.Errorf("replayed X-Request-ID = %q, want this request's own ID", got)
+	}
+}
+
+func TestMiddlewareDoesNotCacheRetryableStatuses(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusBadRequest} {
+		var calls int
+		h := newTestStore(t).Middleware(countingHandler(&calls, status))
+
+		send(h, "key-1", "a")
+		if w := send(h, "key-1", "a"); w.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("status %d was replayed", status)
+		}
+		if calls != 2 {
+			t.Errorf("status %d: handler called %d times, want 2", status, calls)
+		}
+	}
+}
+
+func TestMiddlewareCachesFinalStatuses(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusConflict, http.StatusUnprocessableEntity} {
+		var calls int
+		h := newTestStore(t).Middleware(countingHandler(&calls, status))
+
+		send(h, "key-1", "a")
+		if w := send(h, "key-1", "a"); w.Code != status {
+			t.Errorf("replay status = %d, want %d", w.Code, status)
+		}
+		if calls != 1 {
+			t.Errorf("status %d: handler called %d times, want 1", status, calls)
+		}
+	}
+}
+
+func TestMiddlewareRejectsReusedKeyWithDifferentBody(t *testing.T) {
+	var calls int
+	h := newTestStore(t).Middleware(countingHandler(&calls, http.StatusCreated))
+
+	send(h, "key-1", "a")
+	if w := send(h, "key-1", "b"); w.Code != http.StatusConflict {
+		t.Errorf("reused key status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestMiddlewareWithoutKey(t *testing.T) {
+	var calls int
+	h := newTestStore(t).Middleware(countingHandler(&calls, http.StatusCreated))
+
+	send(h, "", "a")
+	send(h, "", "a")
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestMiddlewareScopesKeysToTenant(t *testing.T) {
+	var calls int
+	h := newTestStore(t).Middleware(countingHandler(&calls, http.StatusCreated))
+	inTenant := func(tenantID string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// As tenant resolution would
+			h.ServeHTTP(w, r.WithContext(reqmeta.NewContext(r.Context(), &reqmeta.Meta{TenantID: tenantID})))
+		})
+	}
+
+	send(inTenant("acme"), "key-1", "a")
+	if w := send(inTenant("globex"), "key-1", "a"); w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("globex got acme's response replayed for the same key")
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want once per tenant", calls)
+	}
+	if w := send(inTenant("acme"), "key-1", "a"); w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("acme's retry was not replayed")
+	}
+}
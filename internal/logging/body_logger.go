@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxLoggedBodyBytes caps how much of each body is buffered for logging
+const maxLoggedBodyBytes = 64 << 10
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeyParts marks JSON fields whose values are never logged. A field
+// is redacted when its lowercased name contains any of these.
+var sensitiveKeyParts = []string{"password", "token", "secret", "credential"}
+
+// sensitiveHeaders are never logged
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// BodyLogger logs request and response headers and JSON bodies with sensitive
+// fields redacted, for debugging integrations locally. It only activates when
+// explicitly enabled in development; in any other environment it is a no-op,
+// even with enabled set. Register it after RequestLogger.
+func BodyLogger(isDevelopment, enabled bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !isDevelopment || !enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := GetLoggerFromContext(r.Context())
+
+			reqBody, err := io.ReadAll(io.LimitReader(r.Body, maxLoggedBodyBytes))
+			if err != nil {
+				logger.Warn("debug body logging: failed to read request body", "error", err.Error())
+			}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+
+			logger.Debug("request body",
+				"headers", redactHeaders(r.Header),
+				"body", redactBody(reqBody),
+			)
+
+			rec := &bodyRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			logger.Debug("response body",
+				"headers", redactHeaders(w.Header()),
+				"body", redactBody(rec.body.Bytes()),
+			)
+		})
+	}
+}
+
+// bodyRecorder copies up to maxLoggedBodyBytes of the response while passing it through
+type bodyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	if remaining := maxLoggedBodyBytes - r.body.Len(); remaining > 0 {
+		r.body.Write(b[:min(len(b), remaining)])
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// redactHeaders flattens headers for logging, hiding credentials
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = redacted
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// redactBody returns a JSON body with sensitive fields replaced. Bodies that
+// aren't JSON are omitted entirely, since they can't be redacted reliably.
+func redactBody(body []byte) any {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "[non-JSON body omitted]"
+	}
+	return redactValue(v)
+}
+
+func redactValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			if isSensitiveKey(k) {
+				val[k] = redacted
+				continue
+			}
+			val[k] = redactValue(child)
+		}
+		return val
+	case []any:
+		for i, child := range val {
+			val[i] = redactValue(child)
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveBodyLogged sends body through BodyLogger to a handler that echoes it
+// back, returning the debug log output and the body the handler saw
+func serveBodyLogged(t *testing.T, isDevelopment, enabled bool, body string) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := &Logger{Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+
+	var seen string
+	h := RequestLogger(logger)(BodyLogger(isDevelopment, enabled)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		seen = string(b)
+		w.Header().Set("Set-Cookie", "refresh_token=secret-cookie")
+		w.Write([]byte(`{"access_token":"issued-token","user":{"email":"a@example.com"}}`))
+	})))
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/auth/login", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer header-token")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	return &buf, seen
+}
+
+func TestBodyLoggerRedactsSensitiveFields(t *testing.T) {
+	body := `{"email":"a@example.com","password":"hunter2","new_password":"hunter3","nested":[{"token":"t0ken"}]}`
+	buf, seen := serveBodyLogged(t, true, true, body)
+
+	if seen != body {
+		t.Errorf("handler read %q, want the original body %q", seen, body)
+	}
+
+	logged := buf.String()
+	for _, secret := range []string{"hunter2", "hunter3", "t0ken", "header-token", "issued-token", "secret-cookie"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("log output contains %q:\n%s", secret, logged)
+		}
+	}
+
+	var requestBody map[string]any
+	for _, line := range logLines(t, buf) {
+		if line["msg"] == "request body" {
+			requestBody, _ = line["body"].(map[string]any)
+		}
+	}
+	if requestBody == nil {
+		t.Fatal("no request body was logged")
+	}
+	if requestBody["email"] != "a@example.com" || requestBody["password"] != redacted {
+		b, _ := json.Marshal(requestBody)
+		t.Errorf("logged request body = %s, want email kept and password redacted", b)
+	}
+}
+
+func TestBodyLoggerIsInertOutsideDevelopment(t *testing.T) {
+	tests := map[string]struct{ isDevelopment, enabled bool }{
+		"production with the flag set": {false, true},
+		"development without the flag": {true, false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			buf, seen := serveBodyLogged(t, tt.isDevelopment, tt.enabled, `{"password":"hunter2"}`)
+			if seen != `{"password":"hunter2"}` {
+				t.Errorf("handler read %q", seen)
+			}
+			for _, line := range logLines(t, buf) {
+				if line["msg"] == "request body" || line["msg"] == "response body" {
+					t.Errorf("logged %q", line["msg"])
+				}
+			}
+		})
+	}
+}
@@ -10,25 +10,27 @@ type Logger struct {
 	*slog.Logger
 }
 
-// NewLogger creates a new structured logger
-// In production, this could be configured to use different outputs/formats
-func NewLogger(isDevelopment bool) *Logger {
-	var handler slog.Handler
+// Output formats accepted by NewLogger
+const (
+	FormatJSON = "json" // One JSON object per line, for log collectors such as Loki
+	FormatText = "text" // key=value lines, easier to read in a terminal
+)
 
-	if isDevelopment {
-		// JSON format for easy parsing by Loki
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		})
+// NewLogger creates a structured logger writing to stdout in the given format
+// (FormatJSON or FormatText), dropping records below level and hiding
+// attributes as redact specifies
+func NewLogger(format string, level slog.Level, redact RedactOptions) *Logger {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if format == FormatText {
+		handler = slog.NewTextHandler(os.Stdout, opts)
 	} else {
-		// Production: still JSON but maybe different log level
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		})
+		handler = slog.NewJSONHandler(os.Stdout, opts)
 	}
 
 	return &Logger{
-		Logger: slog.New(handler),
+		Logger: slog.New(newRedactHandler(handler, redact)),
 	}
 }
 
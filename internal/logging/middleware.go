@@ -3,10 +3,14 @@ package logging
 import (
 	"context"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
+
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 )
 
 // ContextKey is a type for context keys
@@ -47,21 +51,30 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // RequestLogger is a middleware that logs HTTP requests
 func RequestLogger(logger *Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-
-			// Get or generate request ID (chi middleware should have already set this)
-			requestID := middleware.GetReqID(r.Context())
+			// Request metadata from reqmeta.Middleware, or what chi provides without it
+			meta, ok := reqmeta.FromContext(r.Context())
+			if !ok {
+				meta = &reqmeta.Meta{
+					RequestID: middleware.GetReqID(r.Context()),
+					ClientIP:  remoteIP(r),
+					StartTime: time.Now(),
+				}
+			}
 
 			// Create a logger with request context
 			reqLogger := logger.WithFields(map[string]any{
-				"request_id": requestID,
+				"request_id": meta.RequestID,
 				"method":     r.Method,
 				"path":       r.URL.Path,
-				"remote_ip":  r.RemoteAddr,
+				"remote_ip":  meta.ClientIP,
 			})
 
 			// Log request start
@@ -77,7 +90,7 @@ func RequestLogger(logger *Logger) func(next http.Handler) http.Handler {
 			next.ServeHTTP(wrapped, r.WithContext(ctx))
 
 			// Calculate duration
-			duration := time.Since(start)
+			duration := time.Since(meta.StartTime)
 
 			// Log request completion with appropriate level
 			logLevel := slog.LevelInfo
@@ -87,19 +100,39 @@ func RequestLogger(logger *Logger) func(next http.Handler) http.Handler {
 				logLevel = slog.LevelWarn
 			}
 
-			reqLogger.Log(r.Context(), logLevel, "request completed",
+			attrs := []any{
 				"status", wrapped.statusCode,
 				"duration_ms", duration.Milliseconds(),
-			)
+			}
+			// Set by authentication while the request was handled
+			if meta.UserID != uuid.Nil {
+				attrs = append(attrs, "user_id", meta.UserID.String())
+			}
+			if meta.TenantID != "" {
+				attrs = append(attrs, "tenant_id", meta.TenantID)
+			}
+
+			reqLogger.Log(r.Context(), logLevel, "request completed", attrs...)
 		})
 	}
 }
 
+// remoteIP returns the direct peer's address without its port. Forwarded
+// headers are never read here: without reqmeta.Middleware nothing has checked
+// that they came from a trusted proxy.
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
 // GetLoggerFromContext retrieves the logger from the request context
 func GetLoggerFromContext(ctx context.Context) *Logger {
 	if logger, ok := ctx.Value(LoggerContextKey).(*Logger); ok {
 		return logger
 	}
 	// Fallback to a default logger if not found
-	return NewLogger(true)
+	return NewLogger(FormatJSON, slog.LevelDebug, RedactOptions{Keys: DefaultRedactKeys})
 }
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/redmonkez12/go-api-template/internal/reqmeta"
+)
+
+// logLines decodes the JSON log lines written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRequestLoggerWithoutReqmeta(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.7:5555"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	RequestLogger(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), r)
+
+	for _, line := range logLines(t, &buf) {
+		if line["remote_ip"] != "203.0.113.7" {
+			t.Errorf("%s: remote_ip = %v, want the peer address without its port", line["msg"], line["remote_ip"])
+		}
+	}
+}
+
+func TestRequestLoggerUsesReqmeta(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+	userID := uuid.New()
+
+	h := reqmeta.Middleware(func(*http.Request) string { return "192.0.2.1" })(
+		RequestLogger(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// As authentication and tenant resolution would
+			meta, _ := reqmeta.FromContext(r.Context())
+			meta.UserID = userID
+			meta.TenantID = "acme"
+			w.WriteHeader(http.StatusNoContent)
+		})),
+	)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	lines := logLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2", len(lines))
+	}
+	completed := lines[1]
+	if completed["remote_ip"] != "192.0.2.1" {
+		t.Errorf("remote_ip = %v, want the reqmeta client IP", completed["remote_ip"])
+	}
+	if completed["user_id"] != userID.String() || completed["tenant_id"] != "acme" {
+		t.Errorf("request completed = %v, want user_id and tenant_id from reqmeta", completed)
+	}
+	if completed["status"] != float64(http.StatusNoContent) {
+		t.Errorf("status = %v, want %d", completed["status"], http.StatusNoContent)
+	}
+}
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/mail"
+	"strings"
+)
+
+// RedactedValue replaces the values of redacted attributes
+const RedactedValue = "***"
+
+// DefaultRedactKeys are the attribute keys redacted unless LOG_REDACT_KEYS
+// says otherwise
+var DefaultRedactKeys = []string{"password", "token", "refresh_token", "authorization"}
+
+// RedactOptions controls what the logger hides before a record is written
+type RedactOptions struct {
+	// Keys whose values are replaced with RedactedValue, matched ignoring
+	// case at any group depth
+	Keys []string
+	// Replace email addresses with a short hash, so log lines about the same
+	// user can still be correlated without storing the address
+	HashEmails bool
+	// Secret the email hashes are keyed with, so they can't be reversed by
+	// hashing a list of known addresses
+	EmailHashKey []byte
+}
+
+// redactHandler wraps another handler and rewrites attributes on every
+// record, including those added through With and WithFields
+type redactHandler struct {
+	next       slog.Handler
+	keys       map[string]bool
+	hashEmails bool
+	emailKey   []byte
+}
+
+func newRedactHandler(next slog.Handler, opts RedactOptions) slog.Handler {
+	if len(opts.Keys) == 0 && !opts.HashEmails {
+		return next
+	}
+
+	keys := make(map[string]bool, len(opts.Keys))
+	for _, key := range opts.Keys {
+		keys[strings.ToLower(key)] = true
+	}
+	return &redactHandler{next: next, keys: keys, hashEmails: opts.HashEmails, emailKey: opts.EmailHashKey}
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted), keys: h.keys, hashEmails: h.hashEmails, emailKey: h.emailKey}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), keys: h.keys, hashEmails: h.hashEmails, emailKey: h.emailKey}
+}
+
+// redact returns a with its value hidden or hashed where required
+func (h *redactHandler) redact(a slog.Attr) slog.Attr {
+	if h.keys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, RedactedValue)
+	}
+
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, child := range group {
+			redacted[i] = h.redact(child)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindString:
+		if h.hashEmails && isEmailAddress(a.Value.String()) {
+			return slog.String(a.Key, hashEmail(h.emailKey, a.Value.String()))
+		}
+	}
+	return a
+}
+
+// isEmailAddress reports whether s is a bare email address, as opposed to a
+// message that happens to contain one
+func isEmailAddress(s string) bool {
+	if !strings.Contains(s, "@") || strings.ContainsAny(s, " <>") {
+		return false
+	}
+	_, err := mail.ParseAddress(s)
+	return err == nil
+}
+
+// hashEmail returns a stable pseudonym for an address, an HMAC-SHA256 under
+// key. Case is ignored, since the same user may type it differently.
+func hashEmail(key []byte, email string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToLower(email)))
+	return "email:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
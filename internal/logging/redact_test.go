@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+var testEmailKey = []byte(strings.Repeat("k", 32))
+
+// logAttrs logs one record with attrs through a redacting JSON logger and
+// returns it decoded
+func logAttrs(t *testing.T, opts RedactOptions, attrs ...any) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(newRedactHandler(slog.NewJSONHandler(&buf, nil), opts))
+	logger.Info("test", attrs...)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	return record
+}
+
+func TestHashEmailIsKeyed(t *testing.T) {
+	email := "user@example.com"
+	hashed := hashEmail(testEmailKey, email)
+
+	if hashed == hashEmail([]byte(strings.Repeat("x", 32)), email) {
+		t.Error("hashes under different keys match")
+	}
+	if hashed != hashEmail(testEmailKey, "User@Example.com") {
+		t.Error("hash depends on case")
+	}
+	if !strings.HasPrefix(hashed, "email:") || strings.Contains(hashed, email) {
+		t.Errorf("hashEmail() = %q", hashed)
+	}
+}
+
+func TestRedactHashesEmails(t *testing.T) {
+	record := logAttrs(t, RedactOptions{HashEmails: true, EmailHashKey: testEmailKey},
+		"email", "user@example.com",
+		slog.Group("user", "email", "user@example.com"),
+		"message", "sent to user@example.com",
+	)
+
+	want := hashEmail(testEmailKey, "user@example.com")
+	if record["email"] != want {
+		t.Errorf("email = %v, want %s", record["email"], want)
+	}
+	if group, _ := record["user"].(map[string]any); group["email"] != want {
+		t.Errorf("user.email = %v, want %s", record["user"], want)
+	}
+	if record["message"] != "sent to user@example.com" {
+		t.Errorf("message = %v, want it left alone", record["message"])
+	}
+}
+
+func TestRedactKeys(t *testing.T) {
+	record := logAttrs(t, RedactOptions{Keys: []string{"password"}},
+		"Password", "hunter2",
+		"email", "user@example.com",
+	)
+
+	if record["Password"] != RedactedValue {
+		t.Errorf("Password = %v, want %s", record["Password"], RedactedValue)
+	}
+	if record["email"] != "user@example.com" {
+		t.Errorf("email = %v, want it unhashed", record["email"])
+	}
+}
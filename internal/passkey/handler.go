@@ -0,0 +1,209 @@
+package passkey
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+
+	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/httputil"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+)
+
+// Handler handles passkey HTTP requests.
+type Handler struct {
+	service             *Service
+	logger              *logging.Logger
+	cookies             auth.CookieOptions
+	accessCookieMaxAge  time.Duration
+	refreshCookieMaxAge time.Duration
+}
+
+// NewHandler creates a new passkey handler.
+func NewHandler(
+	service *Service,
+	logger *logging.Logger,
+	cookies auth.CookieOptions,
+	accessCookieMaxAge time.Duration,
+	refreshCookieMaxAge time.Duration,
+) *Handler {
+	return &Handler{
+		service:             service,
+		logger:              logger,
+		cookies:             cookies,
+		accessCookieMaxAge:  accessCookieMaxAge,
+		refreshCookieMaxAge: refreshCookieMaxAge,
+	}
+}
+
+// LoginBeginResponse carries the assertion options and the ceremony ID
+// that must be passed to the finish endpoint.
+type LoginBeginResponse struct {
+	SessionID string                        `json:"session_id"`
+	Options   *protocol.CredentialAssertion `json:"options"`
+}
+
+// BeginRegistration handles passkey registration start
+// @Summary      Begin passkey registration
+// @Description  Returns credential creation options for registering a passkey to the current user
+// @Tags         webauthn
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]any
+// @Failure      401 {object} auth.ErrorResponse "Unauthorized"
+// @Failure      500 {object} auth.ErrorResponse "Internal server error"
+// @Router       /auth/webauthn/register/begin [post]
+func (h *Handler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	creation, err := h.service.BeginRegistration(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+			return
+		}
+		httputil.RespondInternalError(w, logger, "passkey registration begin failed", err)
+		return
+	}
+
+	httputil.RespondJSON(w, creation, http.StatusOK)
+}
+
+// FinishRegistration handles passkey registration completion
+// @Summary      Finish passkey registration
+// @Description  Verifies the authenticator attestation and stores the new passkey
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      201 {object} map[string]string
+// @Failure      400 {object} auth.ErrorResponse "Invalid response, expired or failed ceremony"
+// @Failure      401 {object} auth.ErrorResponse "Unauthorized"
+// @Failure      500 {object} auth.ErrorResponse "Internal server error"
+// @Router       /auth/webauthn/register/finish [post]
+func (h *Handler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.RespondError(w, httputil.CodeUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	response, err := protocol.ParseCredentialCreationResponseBody(r.Body)
+	if err != nil {
+		logger.Warn("invalid passkey registration response", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.FinishRegistration(r.Context(), userID, response); err != nil {
+		h.respondCeremonyError(w, logger, err)
+		return
+	}
+
+	logger.Info("passkey registered")
+
+	httputil.RespondJSON(w, map[string]string{
+		"message": "Passkey registered successfully.",
+	}, http.StatusCreated)
+}
+
+// BeginLogin handles passkey login start
+// @Summary      Begin passkey login
+// @Description  Returns assertion options for a discoverable passkey login
+// @Tags         webauthn
+// @Produce      json
+// @Success      200 {object} LoginBeginResponse
+// @Failure      500 {object} auth.ErrorResponse "Internal server error"
+// @Router       /auth/webauthn/login/begin [post]
+func (h *Handler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	assertion, sessionID, err := h.service.BeginLogin(r.Context())
+	if err != nil {
+		httputil.RespondInternalError(w, logger, "passkey login begin failed", err)
+		return
+	}
+
+	httputil.RespondJSON(w, LoginBeginResponse{
+		SessionID: sessionID,
+		Options:   assertion,
+	}, http.StatusOK)
+}
+
+// FinishLogin handles passkey login completion
+// @Summary      Finish passkey login
+// @Description  Verifies the authenticator assertion and returns access and refresh tokens
+// @Tags         webauthn
+// @Accept       json
+// @Produce      json
+// @Param        session_id query string true "Session ID from the begin step"
+// @Success      200 {object} auth.AuthTokens
+// @Failure      400 {object} auth.ErrorResponse "Invalid response, expired or failed ceremony"
+// @Failure      500 {object} auth.ErrorResponse "Internal server error"
+// @Failure      503 {object} auth.ErrorResponse "Session store unavailable"
+// @Router       /auth/webauthn/login/finish [post]
+func (h *Handler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLoggerFromContext(r.Context())
+
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		httputil.RespondErrorWithCode(w, "session_id is required", httputil.CodeWebAuthnSessionExpired, http.StatusBadRequest)
+		return
+	}
+
+	response, err := protocol.ParseCredentialRequestResponseBody(r.Body)
+	if err != nil {
+		logger.Warn("invalid passkey login response", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeInvalidRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.service.FinishLogin(r.Context(), sessionID, response, auth.SessionMetaFromRequest(r))
+	if err != nil {
+		h.respondCeremonyError(w, logger, err)
+		return
+	}
+
+	logger.Info("user logged in with passkey")
+
+	// Set cookies if request is from browser
+	if auth.ShouldUseCookies(r) {
+		auth.SetAuthCookies(w, tokens.AccessToken, tokens.RefreshToken, h.cookies, h.accessCookieMaxAge, h.refreshCookieMaxAge)
+	}
+
+	if auth.ShouldReturnTokensInBody(r) {
+		httputil.RespondJSON(w, tokens, http.StatusOK)
+	} else {
+		httputil.RespondJSON(w, map[string]string{
+			"message": "logged in successfully",
+		}, http.StatusOK)
+	}
+}
+
+// respondCeremonyError maps finish-step errors to HTTP responses
+func (h *Handler) respondCeremonyError(w http.ResponseWriter, logger *logging.Logger, err error) {
+	if errors.Is(err, ErrSessionNotFound) {
+		httputil.RespondError(w, httputil.CodeWebAuthnSessionExpired, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrVerificationFailed) || errors.Is(err, ErrUserNotFound) {
+		httputil.RespondError(w, httputil.CodeWebAuthnVerificationFailed, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, auth.ErrAuthStoreUnavailable) {
+		logger.Error("passkey ceremony failed", "error", err.Error())
+		httputil.RespondError(w, httputil.CodeAuthStoreUnavailable, http.StatusServiceUnavailable)
+		return
+	}
+	httputil.RespondInternalError(w, logger, "passkey ceremony failed", err)
+}
@@ -0,0 +1,96 @@
+package passkey
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/redmonkez12/go-api-template/internal/database"
+)
+
+// CredentialRepository handles passkey credential persistence
+type CredentialRepository struct {
+	db bun.IDB
+}
+
+func NewCredentialRepository(db *bun.DB) *CredentialRepository {
+	return &CredentialRepository{db: db}
+}
+
+// WithDB returns a copy of the repository that runs its queries on db,
+// usually a transaction from database.UnitOfWork
+func (r *CredentialRepository) WithDB(db bun.IDB) *CredentialRepository {
+	return &CredentialRepository{db: db}
+}
+
+// Create stores a newly registered credential for a user
+func (r *CredentialRepository) Create(ctx context.Context, userID uuid.UUID, credential *webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential: %w", err)
+	}
+
+	dbCredential := &database.WebAuthnCredential{
+		ID:         credential.ID,
+		UserID:     userID,
+		Credential: data,
+	}
+
+	_, err = r.db.NewInsert().
+		Model(dbCredential).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to store credential: %w", err)
+	}
+
+	return nil
+}
+
+// ListByUserID returns all credentials registered to a user
+func (r *CredentialRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]webauthn.Credential, error) {
+	var dbCredentials []database.WebAuthnCredential
+	err := r.db.NewSelect().
+		Model(&dbCredentials).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(dbCredentials))
+	for _, c := range dbCredentials {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(c.Credential, &credential); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal credential: %w", err)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, nil
+}
+
+// UpdateAfterLogin persists the updated sign count and flags after a successful login
+func (r *CredentialRepository) UpdateAfterLogin(ctx context.Context, credential *webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential: %w", err)
+	}
+
+	_, err = r.db.NewUpdate().
+		Model((*database.WebAuthnCredential)(nil)).
+		Set("credential = ?", string(data)).
+		Set("last_used_at = ?", time.Now()).
+		Where("id = ?", credential.ID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update credential: %w", err)
+	}
+
+	return nil
+}
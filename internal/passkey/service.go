@@ -0,0 +1,203 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/user"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("passkey ceremony not found or expired")
+	ErrVerificationFailed = errors.New("passkey verification failed")
+	ErrUserNotFound       = errors.New("user not found")
+)
+
+// CredentialStore persists users' registered passkeys
+type CredentialStore interface {
+	Create(ctx context.Context, userID uuid.UUID, credential *webauthn.Credential) error
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]webauthn.Credential, error)
+	UpdateAfterLogin(ctx context.Context, credential *webauthn.Credential) error
+}
+
+// TokenIssuer starts a session for a user who logged in with a passkey.
+// auth.Service implements it.
+type TokenIssuer interface {
+	IssueTokens(ctx context.Context, u *user.User, meta auth.SessionMeta) (*auth.AuthTokens, error)
+}
+
+// Service handles passkey registration and login ceremonies.
+type Service struct {
+	webAuthn    *webauthn.WebAuthn
+	credentials CredentialStore
+	sessions    *SessionStore
+	userRepo    user.RepositoryInterface
+	tokens      TokenIssuer
+	logger      *logging.Logger
+}
+
+// NewService creates a new passkey service.
+func NewService(
+	webAuthn *webauthn.WebAuthn,
+	credentials CredentialStore,
+	sessions *SessionStore,
+	userRepo user.RepositoryInterface,
+	tokens TokenIssuer,
+	logger *logging.Logger,
+) *Service {
+	return &Service{
+		webAuthn:    webAuthn,
+		credentials: credentials,
+		sessions:    sessions,
+		userRepo:    userRepo,
+		tokens:      tokens,
+		logger:      logger,
+	}
+}
+
+// BeginRegistration starts registering a new passkey for an authenticated user.
+func (s *Service) BeginRegistration(ctx context.Context, userID uuid.UUID) (*protocol.CredentialCreation, error) {
+	u, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exclude already registered authenticators and require a discoverable
+	// credential so the passkey can later be used without typing an email
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(u,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin registration: %w", err)
+	}
+
+	if err := s.sessions.Save(ctx, registrationKey(userID), session); err != nil {
+		return nil, err
+	}
+
+	return creation, nil
+}
+
+// FinishRegistration verifies the authenticator's response and stores the new credential.
+func (s *Service) FinishRegistration(ctx context.Context, userID uuid.UUID, response *protocol.ParsedCredentialCreationData) error {
+	session, err := s.sessions.Take(ctx, registrationKey(userID))
+	if err != nil {
+		return err
+	}
+
+	u, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(u, *session, response)
+	if err != nil {
+		s.logger.Warn("passkey registration verification failed", "user_id", userID, "error", err)
+		return ErrVerificationFailed
+	}
+
+	if err := s.credentials.Create(ctx, userID, credential); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// BeginLogin starts a discoverable login and returns the options along with
+// the ID the client must send back to finish the ceremony.
+func (s *Service) BeginLogin(ctx context.Context) (*protocol.CredentialAssertion, string, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to begin login: %w", err)
+	}
+
+	sessionID, err := auth.GenerateRandomToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	if err := s.sessions.Save(ctx, loginKey(sessionID), session); err != nil {
+		return nil, "", err
+	}
+
+	return assertion, sessionID, nil
+}
+
+// FinishLogin verifies the assertion and returns auth tokens for the credential's owner.
+func (s *Service) FinishLogin(ctx context.Context, sessionID string, response *protocol.ParsedCredentialAssertionData, meta auth.SessionMeta) (*auth.AuthTokens, error) {
+	session, err := s.sessions.Take(ctx, loginKey(sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	// The user handle returned by the authenticator is the user ID set at registration
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, ErrUserNotFound
+		}
+		return s.loadUser(ctx, userID)
+	}
+
+	found, credential, err := s.webAuthn.ValidatePasskeyLogin(findUser, *session, response)
+	if err != nil {
+		s.logger.Warn("passkey login verification failed", "error", err)
+		return nil, ErrVerificationFailed
+	}
+
+	// A sign count that went backwards suggests a cloned authenticator
+	if credential.Authenticator.CloneWarning {
+		s.logger.Warn("passkey clone warning, rejecting login", "credential_id", credential.ID)
+		return nil, ErrVerificationFailed
+	}
+
+	if err := s.credentials.UpdateAfterLogin(ctx, credential); err != nil {
+		s.logger.Warn("failed to update passkey after login", "error", err)
+	}
+
+	u := found.(*webAuthnUser)
+	if !auth.TenantMatches(ctx, u.user) {
+		s.logger.Warn("passkey belongs to another tenant, rejecting login", "credential_id", credential.ID)
+		return nil, ErrVerificationFailed
+	}
+	return s.tokens.IssueTokens(ctx, u.user, meta)
+}
+
+// loadUser fetches a user and their credentials as a webauthn.User
+func (s *Service) loadUser(ctx context.Context, userID uuid.UUID) (*webAuthnUser, error) {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	credentials, err := s.credentials.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnUser{user: u, credentials: credentials}, nil
+}
+
+func registrationKey(userID uuid.UUID) string {
+	return "register:" + userID.String()
+}
+
+func loginKey(sessionID string) string {
+	return "login:" + sessionID
+}
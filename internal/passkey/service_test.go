@@ -0,0 +1,428 @@
+package passkey
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/uptrace/bun"
+
+	"github.com/redmonkez12/go-api-template/internal/auth"
+	"github.com/redmonkez12/go-api-template/internal/logging"
+	"github.com/redmonkez12/go-api-template/internal/user"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	svc, u, issuer, creds := newTestService(t)
+	a := newTestAuthenticator(t, testOrigin)
+
+	register(t, svc, u.ID, a)
+	if got := len(creds.byUser[u.ID]); got != 1 {
+		t.Fatalf("stored %d credentials, want 1", got)
+	}
+
+	tokens, err := login(t, svc, a)
+	if err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+	if tokens.AccessToken != "access-"+u.ID.String() {
+		t.Errorf("FinishLogin() access token = %q, want the issuer's token for the user", tokens.AccessToken)
+	}
+	if issuer.issued != 1 {
+		t.Errorf("issued %d sessions, want 1", issuer.issued)
+	}
+
+	// The sign count the authenticator reported is kept for clone detection
+	stored, err := creds.ListByUserID(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored[0].Authenticator.SignCount != a.signCount {
+		t.Errorf("stored sign count = %d, want %d", stored[0].Authenticator.SignCount, a.signCount)
+	}
+}
+
+func TestRegisterExcludesExistingPasskeys(t *testing.T) {
+	svc, u, _, _ := newTestService(t)
+	a := newTestAuthenticator(t, testOrigin)
+	register(t, svc, u.ID, a)
+
+	creation, err := svc.BeginRegistration(context.Background(), u.ID)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	excluded := creation.Response.CredentialExcludeList
+	if len(excluded) != 1 || string(excluded[0].CredentialID) != string(a.id) {
+		t.Errorf("exclude list = %v, want the registered passkey", excluded)
+	}
+}
+
+func TestFinishLoginIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	svc, u, _, _ := newTestService(t)
+	a := newTestAuthenticator(t, testOrigin)
+	register(t, svc, u.ID, a)
+
+	assertion, sessionID, err := svc.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	if _, err := svc.FinishLogin(ctx, sessionID, a.assert(t, assertion), auth.SessionMeta{}); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+
+	_, err = svc.FinishLogin(ctx, sessionID, a.assert(t, assertion), auth.SessionMeta{})
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("replayed FinishLogin() error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestFinishLoginRejectsWrongOrigin(t *testing.T) {
+	svc, u, issuer, _ := newTestService(t)
+	a := newTestAuthenticator(t, testOrigin)
+	register(t, svc, u.ID, a)
+
+	a.origin = "https://phishing.example"
+	if _, err := login(t, svc, a); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("FinishLogin() error = %v, want ErrVerificationFailed", err)
+	}
+	if issuer.issued != 0 {
+		t.Errorf("issued %d sessions, want none", issuer.issued)
+	}
+}
+
+func TestFinishLoginRejectsUnknownPasskey(t *testing.T) {
+	svc, u, _, _ := newTestService(t)
+	register(t, svc, u.ID, newTestAuthenticator(t, testOrigin))
+
+	// Same user handle, but a key the server never saw
+	stranger := newTestAuthenticator(t, testOrigin)
+	stranger.userHandle = u.ID[:]
+	if _, err := login(t, svc, stranger); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("FinishLogin() error = %v, want ErrVerificationFailed", err)
+	}
+}
+
+func TestFinishLoginRejectsClonedAuthenticator(t *testing.T) {
+	svc, u, issuer, _ := newTestService(t)
+	a := newTestAuthenticator(t, testOrigin)
+	register(t, svc, u.ID, a)
+
+	a.signCount = 10
+	if _, err := login(t, svc, a); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+
+	// A copy of the key still at an older sign count
+	a.signCount = 5
+	if _, err := login(t, svc, a); !errors.Is(err, ErrVerificationFailed) {
+		t.Errorf("FinishLogin() error = %v, want ErrVerificationFailed", err)
+	}
+	if issuer.issued != 1 {
+		t.Errorf("issued %d sessions, want 1", issuer.issued)
+	}
+}
+
+func TestFinishRegistrationWithoutBegin(t *testing.T) {
+	svc, u, _, _ := newTestService(t)
+
+	err := svc.FinishRegistration(context.Background(), u.ID, &protocol.ParsedCredentialCreationData{})
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("FinishRegistration() error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+// newTestService returns a Service for one existing user, backed by an
+// in-memory Redis and credential store
+func newTestService(t *testing.T) (*Service, *user.User, *fakeIssuer, *fakeCredentials) {
+	t.Helper()
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "Test",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	u := &user.User{ID: uuid.New(), Email: "alice@example.com", EmailVerified: true}
+	issuer := &fakeIssuer{}
+	creds := &fakeCredentials{byUser: map[uuid.UUID][]webauthn.Credential{}}
+	logger := logging.NewLogger("json", slog.LevelError+1, logging.RedactOptions{})
+
+	svc := NewService(webAuthn, creds, NewSessionStore(client), fakeUsers{u.ID: u}, issuer, logger)
+	return svc, u, issuer, creds
+}
+
+// register runs a registration ceremony for userID with a
+func register(t *testing.T, svc *Service, userID uuid.UUID, a *testAuthenticator) {
+	t.Helper()
+	ctx := context.Background()
+
+	creation, err := svc.BeginRegistration(ctx, userID)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	if err := svc.FinishRegistration(ctx, userID, a.create(t, creation)); err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+}
+
+// login runs a discoverable login ceremony with a
+func login(t *testing.T, svc *Service, a *testAuthenticator) (*auth.AuthTokens, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	assertion, sessionID, err := svc.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	return svc.FinishLogin(ctx, sessionID, a.assert(t, assertion), auth.SessionMeta{})
+}
+
+// testAuthenticator simulates a platform authenticator holding one
+// discoverable P-256 credential, answering ceremonies the way a browser
+// would pass them on: "none" attestation and ES256 assertions.
+type testAuthenticator struct {
+	key        *ecdsa.PrivateKey
+	id         []byte
+	userHandle []byte
+	origin     string
+	signCount  uint32
+}
+
+func newTestAuthenticator(t *testing.T, origin string) *testAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthenticator{key: key, id: id, origin: origin}
+}
+
+// create answers a registration ceremony, remembering the user handle
+func (a *testAuthenticator) create(t *testing.T, creation *protocol.CredentialCreation) *protocol.ParsedCredentialCreationData {
+	t.Helper()
+
+	userHandle, ok := creation.Response.User.ID.(protocol.URLEncodedBase64)
+	if !ok {
+		t.Fatalf("user ID has type %T", creation.Response.User.ID)
+	}
+	a.userHandle = userHandle
+
+	ecdh, err := a.key.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	point := ecdh.Bytes() // 0x04 || x || y
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: point[1:33],
+		YCoord: point[33:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(protocol.FlagAttestedCredentialData)
+	authData = append(authData, make([]byte, 16)...) // Zero AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.id)))
+	authData = append(authData, a.id...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := a.credential(map[string]any{
+		"clientDataJSON":    a.clientData(t, protocol.CreateCeremony, creation.Response.Challenge),
+		"attestationObject": protocol.URLEncodedBase64(attestation),
+	})
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(mustJSON(t, body))
+	if err != nil {
+		t.Fatalf("ParseCredentialCreationResponseBytes() error = %v", err)
+	}
+	return parsed
+}
+
+// assert answers a login ceremony, signing with the credential's key
+func (a *testAuthenticator) assert(t *testing.T, assertion *protocol.CredentialAssertion) *protocol.ParsedCredentialAssertionData {
+	t.Helper()
+
+	clientData := a.clientData(t, protocol.AssertCeremony, assertion.Response.Challenge)
+	authData := a.authData(0)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := a.credential(map[string]any{
+		"clientDataJSON":    clientData,
+		"authenticatorData": protocol.URLEncodedBase64(authData),
+		"signature":         protocol.URLEncodedBase64(signature),
+		"userHandle":        protocol.URLEncodedBase64(a.userHandle),
+	})
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(mustJSON(t, body))
+	if err != nil {
+		t.Fatalf("ParseCredentialRequestResponseBytes() error = %v", err)
+	}
+	return parsed
+}
+
+// authData returns authenticator data for the relying party with the user
+// present and verified, plus flags
+func (a *testAuthenticator) authData(flags protocol.AuthenticatorFlags) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], byte(protocol.FlagUserPresent|protocol.FlagUserVerified|flags))
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+// clientData returns the client data JSON a browser at a.origin would send
+func (a *testAuthenticator) clientData(t *testing.T, ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) protocol.URLEncodedBase64 {
+	return mustJSON(t, map[string]string{
+		"type":      string(ceremony),
+		"challenge": challenge.String(),
+		"origin":    a.origin,
+	})
+}
+
+// credential wraps an authenticator response in a PublicKeyCredential
+func (a *testAuthenticator) credential(response map[string]any) map[string]any {
+	return map[string]any{
+		"id":       protocol.URLEncodedBase64(a.id).String(),
+		"rawId":    protocol.URLEncodedBase64(a.id),
+		"type":     "public-key",
+		"response": response,
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// fakeUsers serves users by ID; the passkey service needs nothing else
+type fakeUsers map[uuid.UUID]*user.User
+
+func (f fakeUsers) GetByID(ctx context.Context, id uuid.UUID) (*user.User, error) {
+	u, ok := f[id]
+	if !ok {
+		return nil, user.ErrNotFound
+	}
+	return u, nil
+}
+
+func (fakeUsers) Create(ctx context.Context, tenantID, email, username, passwordHash, verificationToken string) (*user.User, error) {
+	panic("not used")
+}
+
+func (fakeUsers) GetByEmail(ctx context.Context, tenantID, email string) (*user.User, error) {
+	panic("not used")
+}
+
+func (fakeUsers) GetByUsername(ctx context.Context, tenantID, username string) (*user.User, error) {
+	panic("not used")
+}
+
+func (fakeUsers) GetByVerificationToken(ctx context.Context, token string) (*user.User, error) {
+	panic("not used")
+}
+
+func (fakeUsers) CheckIfTokenAlreadyUsed(ctx context.Context, token string) (bool, error) {
+	panic("not used")
+}
+
+func (fakeUsers) MarkEmailAsVerified(ctx context.Context, userID uuid.UUID) error {
+	panic("not used")
+}
+
+func (fakeUsers) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	panic("not used")
+}
+
+func (fakeUsers) UpdateVerificationToken(ctx context.Context, userID uuid.UUID, token string) error {
+	panic("not used")
+}
+
+func (fakeUsers) WithDB(db bun.IDB) user.RepositoryInterface {
+	panic("not used")
+}
+
+// fakeCredentials is an in-memory CredentialStore
+type fakeCredentials struct {
+	byUser map[uuid.UUID][]webauthn.Credential
+}
+
+func (f *fakeCredentials) Create(ctx context.Context, userID uuid.UUID, credential *webauthn.Credential) error {
+	f.byUser[userID] = append(f.byUser[userID], *credential)
+	return nil
+}
+
+func (f *fakeCredentials) ListByUserID(ctx context.Context, userID uuid.UUID) ([]webauthn.Credential, error) {
+	return append([]webauthn.Credential(nil), f.byUser[userID]...), nil
+}
+
+func (f *fakeCredentials) UpdateAfterLogin(ctx context.Context, credential *webauthn.Credential) error {
+	for _, credentials := range f.byUser {
+		for i := range credentials {
+			if string(credentials[i].ID) == string(credential.ID) {
+				credentials[i] = *credential
+			}
+		}
+	}
+	return nil
+}
+
+// fakeIssuer hands out placeholder tokens and counts sessions
+type fakeIssuer struct {
+	issued int
+}
+
+func (f *fakeIssuer) IssueTokens(ctx context.Context, u *user.User, meta auth.SessionMeta) (*auth.AuthTokens, error) {
+	f.issued++
+	return &auth.AuthTokens{AccessToken: "access-" + u.ID.String(), RefreshToken: "refresh", TokenType: "Bearer"}, nil
+}
@@ -0,0 +1,58 @@
+package passkey
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sessionTTL    = 5 * time.Minute
+	sessionPrefix = "webauthn_session:"
+)
+
+// SessionStore keeps in-flight ceremony challenge state in Redis.
+type SessionStore struct {
+	client *redis.Client
+}
+
+// NewSessionStore creates a new Redis-backed session store.
+func NewSessionStore(client *redis.Client) *SessionStore {
+	return &SessionStore{client: client}
+}
+
+// Save stores the challenge state for a ceremony under the given key.
+func (s *SessionStore) Save(ctx context.Context, key string, session *webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	if err := s.client.Set(ctx, sessionPrefix+key, data, sessionTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+
+	return nil
+}
+
+// Take returns the challenge state for a key and deletes it (single-use).
+func (s *SessionStore) Take(ctx context.Context, key string) (*webauthn.SessionData, error) {
+	data, err := s.client.GetDel(ctx, sessionPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+
+	return &session, nil
+}
@@ -0,0 +1,33 @@
+package passkey
+
+import (
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"github.com/redmonkez12/go-api-template/internal/user"
+)
+
+// webAuthnUser adapts a user and their stored credentials to webauthn.User.
+type webAuthnUser struct {
+	user        *user.User
+	credentials []webauthn.Credential
+}
+
+var _ webauthn.User = (*webAuthnUser)(nil)
+
+// WebAuthnID returns the raw UUID bytes, which the authenticator hands back
+// as the user handle during discoverable login
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoginBackoffDelay(t *testing.T) {
+	b := LoginBackoff{Enabled: true, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	want := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for failures, d := range want {
+		if got := b.Delay(int64(failures)); got != d {
+			t.Errorf("Delay(%d) = %s, want %s", failures, got, d)
+		}
+	}
+	if got := b.Delay(1000); got != time.Second {
+		t.Errorf("Delay(1000) = %s, want the cap", got)
+	}
+
+	b.Enabled = false
+	if got := b.Delay(5); got != 0 {
+		t.Errorf("disabled Delay(5) = %s, want 0", got)
+	}
+}
+
+func TestWaitStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := Wait(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait() took %s after cancel", elapsed)
+	}
+}
+
+func TestRecordLoginFailureNormalizesEmail(t *testing.T) {
+	l := NewMemoryLimiter(LimiterConfig{})
+	defer l.Close()
+	ctx := context.Background()
+
+	for i, email := range []string{"user@example.com", "User@Example.com", " USER@example.com "} {
+		// A different IP each time, so only the account count grows
+		count, err := l.RecordLoginFailure(ctx, "", email, "192.0.2."+string(rune('1'+i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != int64(i+1) {
+			t.Errorf("RecordLoginFailure(%q) = %d, want %d", email, count, i+1)
+		}
+	}
+
+	if err := l.ResetLoginFailures(ctx, "", "USER@EXAMPLE.COM"); err != nil {
+		t.Fatal(err)
+	}
+	count, err := l.RecordLoginFailure(ctx, "", "user@example.com", "192.0.2.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("count after reset = %d, want 1", count)
+	}
+}
+
+func TestLoginFailuresAreCountedPerTenant(t *testing.T) {
+	l := NewMemoryLimiter(LimiterConfig{})
+	defer l.Close()
+	ctx := context.Background()
+
+	for i := range 3 {
+		if _, err := l.RecordLoginFailure(ctx, "acme", "bob@example.com", "192.0.2."+string(rune('1'+i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// bob in another tenant is a different account; each try is from a fresh IP
+	for i, tenantID := range []string{"globex", ""} {
+		count, err := l.RecordLoginFailure(ctx, tenantID, "bob@example.com", "198.51.100."+string(rune('1'+i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("RecordLoginFailure() in tenant %q = %d, want 1 unaffected by acme", tenantID, count)
+		}
+	}
+}
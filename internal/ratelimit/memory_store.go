@@ -0,0 +1,237 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// defaultSweepInterval is how often expired entries are evicted when
+// LimiterConfig.SweepInterval is unset
+const defaultSweepInterval = time.Minute
+
+// memoryShardCount splits keys across independently locked maps, so requests
+// for different IPs rarely wait on each other
+const memoryShardCount = 32
+
+// memoryStore keeps counters in process memory for tests and single-instance
+// deployments. A background sweeper evicts expired entries, so keys that are
+// never touched again don't accumulate; call close to stop it.
+type memoryStore struct {
+	seed   maphash.Seed
+	shards [memoryShardCount]memoryShard
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// memoryShard holds the entries for the keys that hash to it
+type memoryShard struct {
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+	windows  map[string]memoryWindow
+}
+
+type memoryCounter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+type memoryWindow struct {
+	times  []time.Time // Allowed request times, oldest first
+	length time.Duration
+}
+
+func newMemoryStore(sweepInterval time.Duration) *memoryStore {
+	s := &memoryStore{
+		seed: maphash.MakeSeed(),
+		stop: make(chan struct{}),
+	}
+	for i := range s.shards {
+		s.shards[i].counters = make(map[string]memoryCounter)
+		s.shards[i].windows = make(map[string]memoryWindow)
+	}
+
+	go s.sweepEvery(sweepInterval)
+	return s
+}
+
+// shard returns the shard that holds key
+func (s *memoryStore) shard(key string) *memoryShard {
+	return &s.shards[maphash.String(s.seed, key)%memoryShardCount]
+}
+
+func (s *memoryStore) allow(_ context.Context, key string, now time.Time, window time.Duration, max int) (Result, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	times := pruneWindow(sh.windows[key].times, now.Add(-window))
+
+	allowed := len(times) < max
+	if allowed {
+		times = append(times, now)
+	}
+	if len(times) > 0 {
+		sh.windows[key] = memoryWindow{times: times, length: window}
+	} else {
+		delete(sh.windows, key)
+	}
+
+	resetAt := now.Add(window)
+	if len(times) > 0 {
+		resetAt = times[0].Add(window)
+	}
+
+	return Result{
+		Allowed:   allowed,
+		Remaining: max - len(times),
+		ResetAt:   resetAt,
+	}, nil
+}
+
+func (s *memoryStore) peek(_ context.Context, key string, now time.Time, window time.Duration, max int) (Result, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	times := pruneWindow(sh.windows[key].times, now.Add(-window))
+
+	resetAt := now.Add(window)
+	if len(times) > 0 {
+		resetAt = times[0].Add(window)
+	}
+
+	return Result{
+		Allowed:   len(times) < max,
+		Remaining: max - len(times),
+		ResetAt:   resetAt,
+	}, nil
+}
+
+func (s *memoryStore) exists(_ context.Context, key string) (bool, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	_, ok := sh.counter(key, time.Now())
+	return ok, nil
+}
+
+func (s *memoryStore) ttl(_ context.Context, key string) (time.Duration, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	now := time.Now()
+	c, ok := sh.counter(key, now)
+	if !ok {
+		return 0, nil
+	}
+	return c.expiresAt.Sub(now), nil
+}
+
+func (s *memoryStore) set(_ context.Context, key string, ttl time.Duration) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	sh.counters[key] = memoryCounter{value: 1, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// incr locks one shard at a time, so the counters are each updated atomically
+// but not as a group; callers only compare the resulting counts
+func (s *memoryStore) incr(_ context.Context, ttl time.Duration, keys ...string) ([]int64, error) {
+	now := time.Now()
+	counts := make([]int64, len(keys))
+	for i, key := range keys {
+		sh := s.shard(key)
+		sh.mu.Lock()
+		c, _ := sh.counter(key, now)
+		c.value++
+		c.expiresAt = now.Add(ttl)
+		sh.counters[key] = c
+		sh.mu.Unlock()
+		counts[i] = c.value
+	}
+	return counts, nil
+}
+
+func (s *memoryStore) del(_ context.Context, key string) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	delete(sh.counters, key)
+	delete(sh.windows, key)
+	return nil
+}
+
+// close stops the background sweeper. It is safe to call more than once.
+func (s *memoryStore) close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// sweepEvery evicts expired entries every interval until close is called
+func (s *memoryStore) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.sweep(now)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sweep evicts expired counters and windows from every shard, holding one
+// shard's lock at a time
+func (s *memoryStore) sweep(now time.Time) {
+	for i := range s.shards {
+		s.shards[i].sweep(now)
+	}
+}
+
+func (sh *memoryShard) sweep(now time.Time) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	for key, c := range sh.counters {
+		if !now.Before(c.expiresAt) {
+			delete(sh.counters, key)
+		}
+	}
+	for key, w := range sh.windows {
+		if len(pruneWindow(w.times, now.Add(-w.length))) == 0 {
+			delete(sh.windows, key)
+		}
+	}
+}
+
+// counter returns key's counter if it hasn't expired, evicting it if it has.
+// The caller must hold sh.mu.
+func (sh *memoryShard) counter(key string, now time.Time) (memoryCounter, bool) {
+	c, ok := sh.counters[key]
+	if !ok {
+		return memoryCounter{}, false
+	}
+	if !now.Before(c.expiresAt) {
+		delete(sh.counters, key)
+		return memoryCounter{}, false
+	}
+	return c, true
+}
+
+// pruneWindow drops request times at or before cutoff, matching the Redis
+// script's inclusive ZREMRANGEBYSCORE
+func pruneWindow(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// entries counts the counters and windows held across all shards
+func (s *memoryStore) entries() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.counters) + len(sh.windows)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// Run with -race: every method is hit from many goroutines at once
+func TestMemoryStoreConcurrentKeys(t *testing.T) {
+	s := newMemoryStore(time.Millisecond)
+	t.Cleanup(s.close)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for g := range 16 {
+		wg.Go(func() {
+			for i := range 200 {
+				key := fmt.Sprintf("ip:%d:%d", g, i%50)
+				now := time.Now()
+				if _, err := s.allow(ctx, key, now, time.Minute, 100); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.peek(ctx, key, now, time.Minute, 100); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.incr(ctx, time.Minute, key+":fail", "shared:fail"); err != nil {
+					t.Error(err)
+				}
+				if err := s.set(ctx, key+":cooldown", time.Minute); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.ttl(ctx, key+":cooldown"); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	counts, err := s.incr(ctx, time.Minute, "shared:fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(16*200 + 1); counts[0] != want {
+		t.Errorf("shared counter = %d, want %d", counts[0], want)
+	}
+}
+
+func TestMemoryStoreSweepsExpiredEntries(t *testing.T) {
+	s := newMemoryStore(5 * time.Millisecond)
+	t.Cleanup(s.close)
+	ctx := context.Background()
+
+	for i := range 100 {
+		key := fmt.Sprintf("ip:%d", i)
+		if _, err := s.allow(ctx, key, time.Now(), 10*time.Millisecond, 10); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.set(ctx, key+":cooldown", 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.entries(); n != 200 {
+		t.Fatalf("entries = %d, want 200", n)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.entries() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("entries = %d after 2s, want the sweeper to evict them all", s.entries())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -9,106 +9,210 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// Defaults for LimiterConfig fields left unset
 const (
-	emailCooldownDuration = 2 * time.Minute
-	ipRateLimitWindow     = 15 * time.Minute
-	ipRateLimitMax        = 10
+	defaultEmailCooldown = 2 * time.Minute
+	defaultIPWindow      = 15 * time.Minute
+	defaultIPMax         = 10
+	defaultRefreshWindow = 15 * time.Minute
+	defaultRefreshMax    = 30
 )
 
+// Email cooldown purposes. Each is tracked separately, so requesting one kind
+// of email doesn't block the others.
+const (
+	EmailPurposeVerification  = "verification"
+	EmailPurposePasswordReset = "password_reset"
+	EmailPurposeMagicLink     = "magic_link"
+)
+
+// LimiterConfig sets the limiter's thresholds. Zero values use the defaults:
+// 10 requests per IP per 15 minutes, 30 token refreshes per user per 15
+// minutes, and a 2-minute email cooldown.
+type LimiterConfig struct {
+	IPMax    int           // Requests allowed per IP and purpose within IPWindow
+	IPWindow time.Duration // Sliding window for IP rate limits
+	// RefreshMax token refreshes are allowed per user within RefreshWindow
+	RefreshMax    int
+	RefreshWindow time.Duration
+	// EmailCooldown is the minimum time between emails to one address
+	EmailCooldown time.Duration
+	// EmailCooldowns overrides EmailCooldown per purpose
+	EmailCooldowns map[string]time.Duration
+	// SweepInterval is how often the in-memory store evicts expired entries
+	// (default 1 minute). Unused with Redis, which expires keys itself.
+	SweepInterval time.Duration
+}
+
 // Limiter handles rate limiting for authentication endpoints
 type Limiter struct {
-	client *redis.Client
+	store  store
+	config LimiterConfig
+}
+
+// NewLimiter creates a new rate limiter backed by Redis, shared by all instances
+func NewLimiter(client *redis.Client, config LimiterConfig) *Limiter {
+	return newLimiter(&redisStore{client: client}, config)
+}
+
+// NewMemoryLimiter creates a rate limiter that keeps its state in process
+// memory. Limits are per instance and reset on restart, so it only suits tests
+// and single-instance deployments. Call Close to stop its background sweeper.
+func NewMemoryLimiter(config LimiterConfig) *Limiter {
+	if config.SweepInterval <= 0 {
+		config.SweepInterval = defaultSweepInterval
+	}
+	return newLimiter(newMemoryStore(config.SweepInterval), config)
 }
 
-// NewLimiter creates a new rate limiter instance
-func NewLimiter(client *redis.Client) *Limiter {
+func newLimiter(store store, config LimiterConfig) *Limiter {
+	if config.IPMax <= 0 {
+		config.IPMax = defaultIPMax
+	}
+	if config.IPWindow <= 0 {
+		config.IPWindow = defaultIPWindow
+	}
+	if config.RefreshMax <= 0 {
+		config.RefreshMax = defaultRefreshMax
+	}
+	if config.RefreshWindow <= 0 {
+		config.RefreshWindow = defaultRefreshWindow
+	}
+	if config.EmailCooldown <= 0 {
+		config.EmailCooldown = defaultEmailCooldown
+	}
+
 	return &Limiter{
-		client: client,
+		store:  store,
+		config: config,
+	}
+}
+
+// Close stops the in-memory store's background sweeper. It does nothing for
+// the Redis store, whose client is closed by its owner.
+func (l *Limiter) Close() {
+	if s, ok := l.store.(*memoryStore); ok {
+		s.close()
 	}
 }
 
 // CheckEmailCooldown returns true if the email is on cooldown (should reject request)
 func (l *Limiter) CheckEmailCooldown(ctx context.Context, email string) (bool, error) {
-	key := emailCooldownKey(email)
-	exists, err := l.client.Exists(ctx, key).Result()
+	return l.CheckEmailCooldownWithPurpose(ctx, "", email, "auth")
+}
+
+// CheckEmailCooldownWithPurpose returns true if the email is on cooldown for
+// a specific purpose. Cooldowns are kept per tenant, since the same address
+// can belong to a different account in each ("" = no tenant).
+func (l *Limiter) CheckEmailCooldownWithPurpose(ctx context.Context, tenantID, email string, purpose string) (bool, error) {
+	key := emailCooldownKeyWithPurpose(tenantID, email, purpose)
+	exists, err := l.store.exists(ctx, key)
 	if err != nil {
 		return false, fmt.Errorf("failed to check email cooldown: %w", err)
 	}
-	return exists > 0, nil
+	return exists, nil
+}
+
+// EmailCooldownRemaining returns how long the email's cooldown for purpose
+// has left, or 0 if it isn't on cooldown
+func (l *Limiter) EmailCooldownRemaining(ctx context.Context, tenantID, email string, purpose string) (time.Duration, error) {
+	remaining, err := l.store.ttl(ctx, emailCooldownKeyWithPurpose(tenantID, email, purpose))
+	if err != nil {
+		return 0, fmt.Errorf("failed to check email cooldown: %w", err)
+	}
+	return remaining, nil
 }
 
-// SetEmailCooldown sets a 2-minute cooldown for the given email
+// SetEmailCooldown sets a cooldown for the given email
 func (l *Limiter) SetEmailCooldown(ctx context.Context, email string) error {
-	key := emailCooldownKey(email)
-	err := l.client.Set(ctx, key, "1", emailCooldownDuration).Err()
+	return l.SetEmailCooldownWithPurpose(ctx, "", email, "auth")
+}
+
+// SetEmailCooldownWithPurpose sets the purpose's configured cooldown for the
+// given email in tenantID
+func (l *Limiter) SetEmailCooldownWithPurpose(ctx context.Context, tenantID, email string, purpose string) error {
+	key := emailCooldownKeyWithPurpose(tenantID, email, purpose)
+	err := l.store.set(ctx, key, l.emailCooldown(purpose))
 	if err != nil {
 		return fmt.Errorf("failed to set email cooldown: %w", err)
 	}
 	return nil
 }
 
-// CheckIPRateLimit returns true if the IP has exceeded rate limit (10 req/15 min)
-func (l *Limiter) CheckIPRateLimit(ctx context.Context, ip string) (bool, error) {
-	return l.CheckIPRateLimitWithPurpose(ctx, ip, "auth")
+// Result describes a rate limit decision
+type Result struct {
+	Allowed   bool      // Whether the request may proceed
+	Remaining int       // Requests left in the current window
+	ResetAt   time.Time // When the oldest counted request leaves the window
 }
 
-// CheckIPRateLimitWithPurpose returns true if the IP has exceeded rate limit for a specific purpose
-func (l *Limiter) CheckIPRateLimitWithPurpose(ctx context.Context, ip string, purpose string) (bool, error) {
-	key := ipRateLimitKeyWithPurpose(ip, purpose)
-	now := time.Now().Unix()
-	windowStart := now - int64(ipRateLimitWindow.Seconds())
-
-	// Remove expired entries
-	err := l.client.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%d", windowStart)).Err()
+// Allow checks whether key (usually the client IP) may make another request
+// for purpose and, if so, counts it. Each purpose has its own limit.
+func (l *Limiter) Allow(ctx context.Context, key string, purpose string) (Result, error) {
+	result, err := l.store.allow(ctx, ipRateLimitKeyWithPurpose(key, purpose), time.Now(), l.config.IPWindow, l.config.IPMax)
 	if err != nil {
-		return false, fmt.Errorf("failed to clean up expired entries: %w", err)
+		return Result{}, fmt.Errorf("failed to apply rate limit: %w", err)
 	}
+	return result, nil
+}
 
-	// Count requests in current window
-	count, err := l.client.ZCard(ctx, key).Result()
+// Check reports whether key may make another request for purpose without
+// counting it. Pair it with Record to count only some outcomes, such as failed
+// logins; concurrent requests can all pass Check before any is recorded, so the
+// limit may be overshot by the number of requests in flight.
+func (l *Limiter) Check(ctx context.Context, key string, purpose string) (Result, error) {
+	result, err := l.store.peek(ctx, ipRateLimitKeyWithPurpose(key, purpose), time.Now(), l.config.IPWindow, l.config.IPMax)
 	if err != nil {
-		return false, fmt.Errorf("failed to count requests: %w", err)
+		return Result{}, fmt.Errorf("failed to check rate limit: %w", err)
 	}
-
-	return count >= ipRateLimitMax, nil
+	return result, nil
 }
 
-// RecordIPRequest records a request for the given IP address
-func (l *Limiter) RecordIPRequest(ctx context.Context, ip string) error {
-	return l.RecordIPRequestWithPurpose(ctx, ip, "auth")
+// Record counts a request by key for purpose after the fact. A request made
+// while the limit is already reached isn't counted, as with Allow.
+func (l *Limiter) Record(ctx context.Context, key string, purpose string) error {
+	if _, err := l.store.allow(ctx, ipRateLimitKeyWithPurpose(key, purpose), time.Now(), l.config.IPWindow, l.config.IPMax); err != nil {
+		return fmt.Errorf("failed to record rate limited request: %w", err)
+	}
+	return nil
 }
 
-// RecordIPRequestWithPurpose records a request for the given IP address with a specific purpose
-func (l *Limiter) RecordIPRequestWithPurpose(ctx context.Context, ip string, purpose string) error {
-	key := ipRateLimitKeyWithPurpose(ip, purpose)
-	now := time.Now().Unix()
-
-	// Add current request with timestamp as score and value
-	member := fmt.Sprintf("%d", now)
-	err := l.client.ZAdd(ctx, key, redis.Z{
-		Score:  float64(now),
-		Member: member,
-	}).Err()
+// AllowRefresh checks whether userID may refresh its tokens again and, if so,
+// counts the refresh. It is keyed on the user rather than the IP, so a client
+// stuck in a refresh loop is throttled wherever it connects from, without
+// affecting other users behind the same IP.
+func (l *Limiter) AllowRefresh(ctx context.Context, userID string) (Result, error) {
+	result, err := l.store.allow(ctx, refreshRateLimitKey(userID), time.Now(), l.config.RefreshWindow, l.config.RefreshMax)
 	if err != nil {
-		return fmt.Errorf("failed to record IP request: %w", err)
+		return Result{}, fmt.Errorf("failed to apply refresh rate limit: %w", err)
 	}
+	return result, nil
+}
 
-	// Set expiry on the key to clean up old data
-	err = l.client.Expire(ctx, key, ipRateLimitWindow).Err()
-	if err != nil {
-		return fmt.Errorf("failed to set expiry on rate limit key: %w", err)
+// emailCooldown returns the configured cooldown for a purpose
+func (l *Limiter) emailCooldown(purpose string) time.Duration {
+	if d, ok := l.config.EmailCooldowns[purpose]; ok && d > 0 {
+		return d
 	}
-
-	return nil
+	return l.config.EmailCooldown
 }
 
-// emailCooldownKey generates a Redis key for email cooldown
-func emailCooldownKey(email string) string {
+// emailCooldownKeyWithPurpose generates a Redis key for email cooldown with a
+// specific purpose. Keys without a tenant keep their old shape.
+func emailCooldownKeyWithPurpose(tenantID, email string, purpose string) string {
 	hash := sha256.Sum256([]byte(email))
-	return fmt.Sprintf("ratelimit:email:%x", hash)
+	if tenantID == "" {
+		return fmt.Sprintf("ratelimit:email:%s:%x", purpose, hash)
+	}
+	return fmt.Sprintf("ratelimit:email:%s:%s:%x", purpose, tenantID, hash)
 }
 
 // ipRateLimitKeyWithPurpose generates a Redis key for IP rate limiting with a specific purpose
 func ipRateLimitKeyWithPurpose(ip string, purpose string) string {
 	return fmt.Sprintf("ratelimit:ip:%s:%s", ip, purpose)
 }
+
+// refreshRateLimitKey generates a Redis key for a user's token refreshes
+func refreshRateLimitKey(userID string) string {
+	return fmt.Sprintf("ratelimit:user:%s:refresh", userID)
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEmailCooldownKeyKeepsShapeWithoutTenant(t *testing.T) {
+	const want = "ratelimit:email:auth:"
+	if got := emailCooldownKeyWithPurpose("", "a@example.com", "auth"); got[:len(want)] != want || len(got) != len(want)+64 {
+		t.Errorf("key = %q, want %s<sha256>", got, want)
+	}
+}
+
+func TestEmailCooldownIsPerTenant(t *testing.T) {
+	ctx := context.Background()
+	l := NewMemoryLimiter(LimiterConfig{EmailCooldown: time.Minute})
+
+	if err := l.SetEmailCooldownWithPurpose(ctx, "acme", "a@example.com", EmailPurposeVerification); err != nil {
+		t.Fatalf("SetEmailCooldownWithPurpose() error = %v", err)
+	}
+	for tenantID, want := range map[string]bool{"acme": true, "other": false, "": false} {
+		onCooldown, err := l.CheckEmailCooldownWithPurpose(ctx, tenantID, "a@example.com", EmailPurposeVerification)
+		if err != nil {
+			t.Fatalf("CheckEmailCooldownWithPurpose(%q) error = %v", tenantID, err)
+		}
+		if onCooldown != want {
+			t.Errorf("CheckEmailCooldownWithPurpose(%q) = %v, want %v", tenantID, onCooldown, want)
+		}
+	}
+}
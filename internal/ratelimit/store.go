@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// store holds the limiter's counters. Every operation must be atomic, since
+// limits are only meaningful if concurrent requests can't race past them.
+type store interface {
+	// allow records a request under key if fewer than max fall within window
+	allow(ctx context.Context, key string, now time.Time, window time.Duration, max int) (Result, error)
+	// peek reports what allow would decide without recording anything
+	peek(ctx context.Context, key string, now time.Time, window time.Duration, max int) (Result, error)
+	// exists reports whether key is set and unexpired
+	exists(ctx context.Context, key string) (bool, error)
+	// ttl returns how long key has left, or 0 if it isn't set
+	ttl(ctx context.Context, key string) (time.Duration, error)
+	// set marks key as present for ttl
+	set(ctx context.Context, key string, ttl time.Duration) error
+	// incr increments each counter, restarts its ttl and returns the new values
+	incr(ctx context.Context, ttl time.Duration, keys ...string) ([]int64, error)
+	// del removes key
+	del(ctx context.Context, key string) error
+}
+
+// redisStore keeps counters in Redis so limits hold across instances
+type redisStore struct {
+	client *redis.Client
+}
+
+// allowScript checks and records a request in one step, so concurrent requests
+// can't all pass the check before any of them is counted. The sorted set holds
+// one member per allowed request, scored by its time in milliseconds.
+//
+// KEYS[1] = rate limit key
+// ARGV[1] = now (ms), ARGV[2] = window (ms), ARGV[3] = max, ARGV[4] = member
+// Returns {allowed (0/1), remaining, reset at (ms)}
+var allowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local max = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+if count < max then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local resetAt = now + window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	resetAt = tonumber(oldest[2]) + window
+end
+
+return {allowed, max - count, resetAt}
+`)
+
+func (s *redisStore) allow(ctx context.Context, key string, now time.Time, window time.Duration, max int) (Result, error) {
+	// Unique per request, so requests in the same millisecond are all counted
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return Result{}, fmt.Errorf("failed to generate rate limit member: %w", err)
+	}
+	member := fmt.Sprintf("%d-%x", now.UnixMilli(), nonce)
+
+	values, err := allowScript.Run(ctx, s.client, []string{key},
+		now.UnixMilli(), window.Milliseconds(), max, member,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 3 {
+		return Result{}, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	return Result{
+		Allowed:   values[0] == 1,
+		Remaining: int(values[1]),
+		ResetAt:   time.UnixMilli(values[2]),
+	}, nil
+}
+
+// peekScript is allowScript without the ZADD, so a request can be checked now
+// and only counted once its outcome is known
+//
+// KEYS[1] = rate limit key
+// ARGV[1] = now (ms), ARGV[2] = window (ms), ARGV[3] = max
+// Returns {allowed (0/1), remaining, reset at (ms)}
+var peekScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local max = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+if count < max then
+	allowed = 1
+end
+
+local resetAt = now + window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	resetAt = tonumber(oldest[2]) + window
+end
+
+return {allowed, max - count, resetAt}
+`)
+
+func (s *redisStore) peek(ctx context.Context, key string, now time.Time, window time.Duration, max int) (Result, error) {
+	values, err := peekScript.Run(ctx, s.client, []string{key},
+		now.UnixMilli(), window.Milliseconds(), max,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(values) != 3 {
+		return Result{}, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	return Result{
+		Allowed:   values[0] == 1,
+		Remaining: int(values[1]),
+		ResetAt:   time.UnixMilli(values[2]),
+	}, nil
+}
+
+func (s *redisStore) exists(ctx context.Context, key string) (bool, error) {
+	n, err := s.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *redisStore) ttl(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Negative values mean the key is missing or never expires; cooldowns
+	// always expire, so treat both as unset
+	return max(ttl, 0), nil
+}
+
+func (s *redisStore) set(ctx context.Context, key string, ttl time.Duration) error {
+	return s.client.Set(ctx, key, "1", ttl).Err()
+}
+
+func (s *redisStore) incr(ctx context.Context, ttl time.Duration, keys ...string) ([]int64, error) {
+	pipe := s.client.TxPipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, len(cmds))
+	for i, cmd := range cmds {
+		counts[i] = cmd.Val()
+	}
+	return counts, nil
+}
+
+func (s *redisStore) del(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
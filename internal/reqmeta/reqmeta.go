@@ -0,0 +1,56 @@
+// Package reqmeta carries per-request metadata (request ID, client IP, user,
+// tenant, start time) in one context value, so middleware, handlers and the
+// logger share it without each package defining its own context keys.
+package reqmeta
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+// Meta describes the request being served. Middleware fills in what it knows
+// as the request passes through: Middleware sets the request ID, client IP and
+// start time; tenant resolution sets TenantID and authentication sets UserID.
+// Fields are only written from the request's own goroutine, before the
+// handler runs.
+type Meta struct {
+	RequestID string
+	ClientIP  string
+	UserID    uuid.UUID // uuid.Nil until authenticated
+	TenantID  string    // Empty unless tenant-resolving middleware sets it
+	StartTime time.Time
+}
+
+// Middleware attaches a Meta to each request. Register it after chi's
+// RequestID middleware and before anything that reads the metadata.
+// clientIP resolves the caller's address so every consumer agrees on it.
+func Middleware(clientIP func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			meta := &Meta{
+				RequestID: middleware.GetReqID(r.Context()),
+				ClientIP:  clientIP(r),
+				StartTime: time.Now(),
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), meta)))
+		})
+	}
+}
+
+// NewContext returns a copy of ctx carrying meta
+func NewContext(ctx context.Context, meta *Meta) context.Context {
+	return context.WithValue(ctx, contextKey{}, meta)
+}
+
+// FromContext returns the request's metadata. ok is false outside requests
+// served through Middleware.
+func FromContext(ctx context.Context) (meta *Meta, ok bool) {
+	meta, ok = ctx.Value(contextKey{}).(*Meta)
+	return meta, ok
+}
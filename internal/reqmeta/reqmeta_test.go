@@ -0,0 +1,43 @@
+package reqmeta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestMiddleware(t *testing.T) {
+	var got *Meta
+	h := middleware.RequestID(Middleware(func(*http.Request) string { return "192.0.2.1" })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			meta, ok := FromContext(r.Context())
+			if !ok {
+				t.Fatal("FromContext() ok = false inside Middleware")
+			}
+			got = meta
+		}),
+	))
+
+	before := time.Now()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got.RequestID == "" {
+		t.Error("RequestID not set")
+	}
+	if got.ClientIP != "192.0.2.1" {
+		t.Errorf("ClientIP = %q, want %q", got.ClientIP, "192.0.2.1")
+	}
+	if got.StartTime.Before(before) {
+		t.Errorf("StartTime = %v, want after %v", got.StartTime, before)
+	}
+}
+
+func TestFromContextOutsideRequests(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("FromContext(background) ok = true")
+	}
+}
@@ -4,17 +4,23 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/uptrace/bun"
 )
 
 // RepositoryInterface defines the interface for user data persistence.
 // Implementations exist for each supported database/ORM combination.
+// Emails and usernames are unique per tenant, so methods taking them also
+// take a tenant ID; an empty one means users without a tenant.
 type RepositoryInterface interface {
-	Create(ctx context.Context, email, passwordHash, verificationToken string) (*User, error)
-	GetByEmail(ctx context.Context, email string) (*User, error)
+	Create(ctx context.Context, tenantID, email, username, passwordHash, verificationToken string) (*User, error)
+	GetByEmail(ctx context.Context, tenantID, email string) (*User, error)
+	GetByUsername(ctx context.Context, tenantID, username string) (*User, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*User, error)
 	GetByVerificationToken(ctx context.Context, token string) (*User, error)
 	CheckIfTokenAlreadyUsed(ctx context.Context, token string) (bool, error)
 	MarkEmailAsVerified(ctx context.Context, userID uuid.UUID) error
 	UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
 	UpdateVerificationToken(ctx context.Context, userID uuid.UUID, token string) error
+	// WithDB returns the repository bound to db, usually a transaction
+	WithDB(db bun.IDB) RepositoryInterface
 }
@@ -8,7 +8,9 @@ import (
 
 type User struct {
 	ID                      uuid.UUID  `json:"id"`
+	TenantID                *string    `json:"tenant_id,omitempty"`
 	Email                   string     `json:"email"`
+	Username                *string    `json:"username,omitempty"`
 	PasswordHash            string     `json:"-"` // Never expose password hash in JSON
 	EmailVerified           bool       `json:"email_verified"`
 	EmailVerificationToken  *string    `json:"-"`
@@ -16,3 +18,11 @@ type User struct {
 	CreatedAt               time.Time  `json:"created_at"`
 	UpdatedAt               time.Time  `json:"updated_at"`
 }
+
+// Tenant returns the ID of the tenant the user belongs to, or "" if none
+func (u *User) Tenant() string {
+	if u.TenantID == nil {
+		return ""
+	}
+	return *u.TenantID
+}
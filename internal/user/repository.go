@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,37 +14,47 @@ import (
 )
 
 var (
-	ErrNotFound       = errors.New("user not found")
-	ErrDuplicateEmail = errors.New("email already exists")
+	ErrNotFound          = errors.New("user not found")
+	ErrDuplicateEmail    = errors.New("email already exists")
+	ErrDuplicateUsername = errors.New("username already taken")
 )
 
 // Repository handles user data persistence
 type Repository struct {
-	db *bun.DB
+	db    bun.IDB
+	users *database.Repo[database.User]
 }
 
 func NewRepository(db *bun.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: db, users: database.NewRepo[database.User](db)}
 }
 
-// Create inserts a new user into the database
-func (r *Repository) Create(ctx context.Context, email, passwordHash, verificationToken string) (*User, error) {
+// WithDB returns a copy of the repository that runs its queries on db,
+// usually a transaction from database.UnitOfWork
+func (r *Repository) WithDB(db bun.IDB) RepositoryInterface {
+	return &Repository{db: db, users: r.users.WithDB(db)}
+}
+
+// Create inserts a new user into the database. An empty tenant ID or username
+// is stored as NULL.
+func (r *Repository) Create(ctx context.Context, tenantID, email, username, passwordHash, verificationToken string) (*User, error) {
 	now := time.Now()
 	dbUser := &database.User{
+		TenantID:                  nullIfEmpty(tenantID),
 		Email:                     email,
+		Username:                  nullIfEmpty(username),
 		PasswordHash:              passwordHash,
 		EmailVerificationToken:    &verificationToken,
 		EmailVerificationSentAt:   &now,
 		EmailVerified:             false,
 	}
 
-	_, err := r.db.NewInsert().
-		Model(dbUser).
-		Returning("*").
-		Exec(ctx)
-
-	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+	if err := r.users.Create(ctx, dbUser); err != nil {
+		var unique *database.UniqueViolationError
+		if errors.As(err, &unique) {
+			if unique.Constraint == "idx_users_tenant_username" {
+				return nil, ErrDuplicateUsername
+			}
 			return nil, ErrDuplicateEmail
 		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
@@ -54,13 +63,10 @@ func (r *Repository) Create(ctx context.Context, email, passwordHash, verificati
 	return mapDBUserToModel(dbUser), nil
 }
 
-// GetByEmail retrieves a user by email
-func (r *Repository) GetByEmail(ctx context.Context, email string) (*User, error) {
+// GetByEmail retrieves a tenant's user by email
+func (r *Repository) GetByEmail(ctx context.Context, tenantID, email string) (*User, error) {
 	dbUser := new(database.User)
-	err := r.db.NewSelect().
-		Model(dbUser).
-		Where("email = ?", email).
-		Scan(ctx)
+	err := r.byEmail(dbUser, tenantID, email).Scan(ctx)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -72,18 +78,46 @@ func (r *Repository) GetByEmail(ctx context.Context, email string) (*User, error
 	return mapDBUserToModel(dbUser), nil
 }
 
-// GetByID retrieves a user by ID
-func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
+// GetByUsername retrieves a tenant's user by username, ignoring case
+func (r *Repository) GetByUsername(ctx context.Context, tenantID, username string) (*User, error) {
 	dbUser := new(database.User)
-	err := r.db.NewSelect().
-		Model(dbUser).
-		Where("id = ?", id).
-		Scan(ctx)
+	err := r.byUsername(dbUser, tenantID, username).Scan(ctx)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
+	}
+
+	return mapDBUserToModel(dbUser), nil
+}
+
+// byEmail selects a tenant's user by email. The conditions repeat the
+// expressions of idx_users_tenant_email so Postgres can use the index.
+func (r *Repository) byEmail(dbUser *database.User, tenantID, email string) *bun.SelectQuery {
+	return r.db.NewSelect().
+		Model(dbUser).
+		Where("COALESCE(tenant_id, '') = ?", tenantID).
+		Where("email = ?", email)
+}
+
+// byUsername selects a tenant's user by username, ignoring case, matching
+// idx_users_tenant_username the same way
+func (r *Repository) byUsername(dbUser *database.User, tenantID, username string) *bun.SelectQuery {
+	return r.db.NewSelect().
+		Model(dbUser).
+		Where("COALESCE(tenant_id, '') = ?", tenantID).
+		Where("LOWER(username) = LOWER(?)", username)
+}
+
+// GetByID retrieves a user by ID
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	dbUser, err := r.users.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrNotFound
+		}
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 
@@ -208,7 +242,9 @@ func (r *Repository) UpdateVerificationToken(ctx context.Context, userID uuid.UU
 func mapDBUserToModel(dbu *database.User) *User {
 	return &User{
 		ID:                      dbu.ID,
+		TenantID:                dbu.TenantID,
 		Email:                   dbu.Email,
+		Username:                dbu.Username,
 		PasswordHash:            dbu.PasswordHash,
 		EmailVerified:           dbu.EmailVerified,
 		EmailVerificationToken:  dbu.EmailVerificationToken,
@@ -217,3 +253,11 @@ func mapDBUserToModel(dbu *database.User) *User {
 		UpdatedAt:               dbu.UpdatedAt,
 	}
 }
+
+// nullIfEmpty maps an empty string to NULL
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,40 @@
+package user
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+
+	"github.com/redmonkez12/go-api-template/internal/database"
+)
+
+// The unique indexes coalesce a missing tenant to an empty string, so lookups
+// must filter on the same expression for Postgres to use them
+func TestLookupsMatchTenantIndexes(t *testing.T) {
+	sqldb, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { sqldb.Close() })
+	r := &Repository{db: bun.NewDB(sqldb, pgdialect.New())}
+
+	tests := []struct {
+		name  string
+		query *bun.SelectQuery
+		want  string
+	}{
+		{"by email", r.byEmail(&database.User{}, "acme", "a@example.com"), `COALESCE(tenant_id, '') = 'acme') AND (email = 'a@example.com')`},
+		{"by username", r.byUsername(&database.User{}, "", "Alice"), `COALESCE(tenant_id, '') = '') AND (LOWER(username) = LOWER('Alice'))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("query = %s, want it to contain %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_verification_sent_at;
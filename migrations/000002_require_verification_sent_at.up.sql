@@ -0,0 +1,9 @@
+-- Backfill tokens that were stored without a sent-at (e.g. imported users)
+UPDATE users
+SET email_verification_sent_at = created_at
+WHERE email_verification_token IS NOT NULL
+  AND email_verification_sent_at IS NULL;
+
+ALTER TABLE users
+    ADD CONSTRAINT chk_users_verification_sent_at
+    CHECK (email_verification_token IS NULL OR email_verification_sent_at IS NOT NULL);
@@ -0,0 +1 @@
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_only BOOLEAN NOT NULL DEFAULT FALSE;
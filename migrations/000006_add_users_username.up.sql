@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username VARCHAR(32);
+
+-- Usernames are optional and unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(LOWER(username))
+    WHERE username IS NOT NULL;
@@ -0,0 +1,9 @@
+-- Fails if an email or username is now used in more than one tenant
+DROP INDEX IF EXISTS idx_users_tenant_username;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(LOWER(username))
+    WHERE username IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_tenant_email;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63);
+
+-- Emails and usernames are unique within a tenant rather than globally.
+-- Users without a tenant share one scope, which COALESCE keeps unique too.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_email ON users(COALESCE(tenant_id, ''), email);
+
+DROP INDEX IF EXISTS idx_users_username;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_username ON users(COALESCE(tenant_id, ''), LOWER(username))
+    WHERE username IS NOT NULL;
@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL migrations, so the API binary can apply
+// them itself with AUTO_MIGRATE. The migrate CLI reads the same files from
+// this directory.
+package migrations
+
+import "embed"
+
+// FS holds every migration file, named as the migrate CLI expects
+//
+//go:embed *.sql
+var FS embed.FS
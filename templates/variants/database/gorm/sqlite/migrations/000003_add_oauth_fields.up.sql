@@ -0,0 +1,38 @@
+-- SQLite cannot drop NOT NULL from an existing column, so the users table
+-- is rebuilt with a nullable password_hash and the OAuth columns. Foreign
+-- keys must be off so dropping the old table leaves refresh_tokens alone, and
+-- SQLite ignores that pragma inside a transaction, so migrate runs this file
+-- unwrapped (x-no-tx-wrap) and it opens its own.
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE users_new (
+    id TEXT PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    password_hash TEXT,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    email_verification_token TEXT,
+    email_verification_sent_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    auth_provider TEXT NOT NULL DEFAULT 'local',
+    provider_user_id TEXT
+);
+
+INSERT INTO users_new (id, email, password_hash, email_verified, email_verification_token,
+    email_verification_sent_at, created_at, updated_at)
+SELECT id, email, password_hash, email_verified, email_verification_token,
+    email_verification_sent_at, created_at, updated_at
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+CREATE INDEX IF NOT EXISTS idx_users_verification_token ON users(email_verification_token);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oauth_provider ON users(auth_provider, provider_user_id);
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,33 @@
+-- Foreign keys are turned off outside the transaction, see the up migration
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE users_old (
+    id TEXT PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    email_verification_token TEXT,
+    email_verification_sent_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- OAuth-only users have no password and cannot be kept
+INSERT INTO users_old (id, email, password_hash, email_verified, email_verification_token,
+    email_verification_sent_at, created_at, updated_at)
+SELECT id, email, password_hash, email_verified, email_verification_token,
+    email_verification_sent_at, created_at, updated_at
+FROM users
+WHERE password_hash IS NOT NULL;
+
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+CREATE INDEX IF NOT EXISTS idx_users_verification_token ON users(email_verification_token);
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;